@@ -0,0 +1,45 @@
+package main
+
+import (
+	"fmt"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Inspect the configuration",
+	}
+	cmd.AddCommand(
+		&cobra.Command{
+			Use:   "validate",
+			Short: "Load and validate the configuration without starting anything",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				// loading already validated every section and exited on the first error
+				_, _ = fmt.Fprintf(cmd.OutOrStdout(), "configuration is valid (database driver %s)\n", a.cfg.DB.Driver)
+
+				return nil
+			},
+		},
+		&cobra.Command{
+			Use:   "print",
+			Short: "Print the effective configuration and where each value comes from, secrets redacted",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				w := tabwriter.NewWriter(cmd.OutOrStdout(), 0, 0, 2, ' ', 0)
+				_, _ = fmt.Fprintln(w, "KEY\tVALUE\tSOURCE")
+				for _, s := range a.settings {
+					s = s.Redacted()
+					_, _ = fmt.Fprintf(w, "%s\t%s\t%s\n", s.Key, s.Value, s.Source)
+				}
+
+				return w.Flush()
+			},
+		},
+	)
+
+	return cmd
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"task-manager/internal/models"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newExportCmd(a *app) *cobra.Command {
+	var output string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Write all accounts and their tasks as JSON, including password hashes",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			e, err := s.Ad.Export(cmd.Context())
+			if err != nil {
+				return err
+			}
+
+			var w io.Writer = cmd.OutOrStdout()
+			if output != "-" {
+				// the export holds password hashes, keep it private to the operator
+				f, err := os.OpenFile(output, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+				if err != nil {
+					return fmt.Errorf("failed to create %s: %v", output, err)
+				}
+				defer f.Close()
+				w = f
+			}
+
+			enc := json.NewEncoder(w)
+			enc.SetIndent("", "  ")
+			if err := enc.Encode(e); err != nil {
+				return fmt.Errorf("failed to write the export: %v", err)
+			}
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "-", "file to write, - for stdout")
+
+	return cmd
+}
+
+func newImportCmd(a *app) *cobra.Command {
+	var input string
+	var skipExisting bool
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Recreate accounts and tasks from an export",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var r io.Reader = cmd.InOrStdin()
+			if input != "-" {
+				f, err := os.Open(input)
+				if err != nil {
+					return fmt.Errorf("failed to open %s: %v", input, err)
+				}
+				defer f.Close()
+				r = f
+			}
+
+			var e models.DataExport
+			if err := json.NewDecoder(r).Decode(&e); err != nil {
+				return fmt.Errorf("failed to read the export: %v", err)
+			}
+
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			res, err := s.Ad.Import(cmd.Context(), e, skipExisting)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "imported %d users and %d tasks, skipped %d existing users\n", res.Users, res.Tasks, res.Skipped)
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVarP(&input, "input", "i", "-", "file to read, - for stdin")
+	cmd.Flags().BoolVar(&skipExisting, "skip-existing", false, "skip accounts whose e-mail is already registered instead of failing")
+
+	return cmd
+}
+
+func newPurgeTrashCmd(a *app) *cobra.Command {
+	var olderThan time.Duration
+
+	cmd := &cobra.Command{
+		Use:   "purge-trash",
+		Short: "Delete the tasks in the trash for good",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if olderThan < 0 {
+				return fmt.Errorf("--older-than must not be negative")
+			}
+
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			n, err := s.Ad.PurgeTrash(cmd.Context(), time.Now().Add(-olderThan))
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "purged %d tasks\n", n)
+
+			return nil
+		},
+	}
+	cmd.Flags().DurationVar(&olderThan, "older-than", 0, "only purge tasks deleted at least this long ago, e.g. 720h")
+
+	return cmd
+}
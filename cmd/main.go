@@ -1,17 +1,14 @@
 package main
 
 import (
-	"fmt"
-	"net/http"
-	"task-manager/internal/config"
-	"task-manager/internal/server"
+	"log/slog"
+	"os"
 )
 
 func main() {
-	cfg := config.Load()
-
-	s := server.New(cfg)
-
-	fmt.Println("Launching the server")
-	_ = http.ListenAndServe(":8000", s.H)
+	// errors are logged rather than printed by cobra so they follow LOG_FORMAT
+	if err := newRootCmd().Execute(); err != nil {
+		slog.Error("command failed", "err", err)
+		os.Exit(1)
+	}
 }
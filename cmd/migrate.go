@@ -0,0 +1,133 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"task-manager/internal/config"
+	"task-manager/internal/db"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+func newMigrateCmd(a *app) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "migrate",
+		Short: "Apply, revert or inspect database migrations",
+	}
+
+	// -n limits how many migrations are applied or reverted: up applies all pending ones by
+	// default, down and redo the last one
+	step := func(name, short string, defaultSteps int) *cobra.Command {
+		var steps int
+		var dryRun bool
+		c := &cobra.Command{
+			Use:   name,
+			Short: short,
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				o := db.MigrateOptions{DryRun: dryRun, Out: cmd.OutOrStdout()}
+				return runMigrate(cmd.Context(), a.cfg.DB, name, steps, o)
+			},
+		}
+		c.Flags().IntVarP(&steps, "steps", "n", defaultSteps, "number of migrations, 0 for all")
+		c.Flags().BoolVar(&dryRun, "dry-run", false, "print the statements instead of executing them")
+
+		return c
+	}
+
+	cmd.AddCommand(
+		step("up", "Apply pending migrations", 0),
+		step("down", "Revert applied migrations", 1),
+		step("redo", "Revert and reapply the last migrations", 1),
+		&cobra.Command{
+			Use:   "status",
+			Short: "List migrations and whether they are applied",
+			Args:  cobra.NoArgs,
+			RunE: func(cmd *cobra.Command, args []string) error {
+				d, err := openMigrationDB(a.cfg.DB)
+				if err != nil {
+					return err
+				}
+				defer d.Close()
+
+				return printMigrationStatus(cmd.Context(), *d, cmd.OutOrStdout())
+			},
+		},
+	)
+
+	return cmd
+}
+
+func openMigrationDB(c config.DBConfig) (*db.DB, error) {
+	if c.Driver == config.DBDriverMemory {
+		return nil, fmt.Errorf("the %s driver has no schema to migrate", config.DBDriverMemory)
+	}
+	d, err := db.Open(c)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize database: %v", err)
+	}
+
+	return d, nil
+}
+
+// runMigrate implements the up, down and redo subcommands.
+func runMigrate(ctx context.Context, c config.DBConfig, cmd string, steps int, o db.MigrateOptions) error {
+	d, err := openMigrationDB(c)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	var done []db.Migration
+	switch cmd {
+	case "up":
+		done, err = d.MigrateUp(ctx, steps, o)
+	case "down":
+		done, err = d.MigrateDown(ctx, steps, o)
+	case "redo":
+		done, err = d.MigrateRedo(ctx, steps, o)
+	default:
+		return fmt.Errorf("unknown migrate command %q", cmd)
+	}
+
+	verb := map[string]string{"up": "applied", "down": "reverted", "redo": "redone"}[cmd]
+	if o.DryRun {
+		verb = "would be " + verb
+	}
+	for _, m := range done {
+		_, _ = fmt.Fprintf(o.Out, "%s %s\n", verb, m)
+	}
+	if len(done) == 0 && err == nil {
+		_, _ = fmt.Fprintln(o.Out, "nothing to do")
+	}
+
+	return err
+}
+
+func printMigrationStatus(ctx context.Context, d db.DB, out io.Writer) error {
+	st, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return err
+	}
+
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	_, _ = fmt.Fprintln(w, "VERSION\tNAME\tSTATUS\tAPPLIED AT")
+	for _, s := range st {
+		status, appliedAt := "pending", ""
+		if s.AppliedAt != nil {
+			status, appliedAt = "applied", s.AppliedAt.Format(time.RFC3339)
+		}
+		if s.Modified {
+			status = "modified"
+		}
+		if s.Missing {
+			status = "missing"
+		}
+		_, _ = fmt.Fprintf(w, "%04d\t%s\t%s\t%s\n", s.Version, s.Name, status, appliedAt)
+	}
+
+	return w.Flush()
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/logging"
+
+	"github.com/spf13/cobra"
+)
+
+// app carries what every command needs once the configuration is loaded.
+type app struct {
+	cfg      config.Config
+	settings []config.Setting
+	logger   *slog.Logger
+}
+
+// newRootCmd builds the task-manager command tree. Running it without a subcommand starts the
+// server, as the binary did before it grew the operations commands.
+func newRootCmd() *cobra.Command {
+	a := &app{}
+	var file string
+	var overrides []string
+
+	root := &cobra.Command{
+		Use:           "task-manager",
+		Short:         "Task manager API server and operations tool",
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			o := config.Options{File: file, Overrides: map[string]string{}}
+			for _, kv := range overrides {
+				k, v, ok := strings.Cut(kv, "=")
+				if !ok || k == "" {
+					return fmt.Errorf("--set expects KEY=value, got %q", kv)
+				}
+				o.Overrides[k] = v
+			}
+			a.cfg, a.settings = config.LoadWith(o)
+
+			logger, err := logging.New(a.cfg.Log, os.Stdout)
+			if err != nil {
+				return fmt.Errorf("unable to set up logging: %v", err)
+			}
+			slog.SetDefault(logger)
+			a.logger = logger
+
+			return nil
+		},
+	}
+
+	root.PersistentFlags().StringVarP(&file, "config", "c", "", "YAML or TOML config file, defaults to $CONFIG_FILE")
+	root.PersistentFlags().StringArrayVar(&overrides, "set", nil, "override a setting, e.g. --set LOG_LEVEL=debug; repeatable")
+
+	serve := newServeCmd(a)
+	root.RunE = serve.RunE
+	root.AddCommand(
+		serve,
+		newMigrateCmd(a),
+		newCreateUserCmd(a),
+		newResetPasswordCmd(a),
+		newPromoteAdminCmd(a),
+		newExportCmd(a),
+		newImportCmd(a),
+		newPurgeTrashCmd(a),
+		newConfigCmd(a),
+	)
+
+	return root
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"task-manager/internal/server"
+	"task-manager/internal/tracing"
+
+	"github.com/spf13/cobra"
+)
+
+func newServeCmd(a *app) *cobra.Command {
+	return &cobra.Command{
+		Use:   "serve",
+		Short: "Run the HTTP and gRPC servers, applying pending migrations first",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			shutdown, err := tracing.Setup(context.Background(), a.cfg.Tracing)
+			if err != nil {
+				return fmt.Errorf("unable to set up tracing: %v", err)
+			}
+			defer func() {
+				_ = shutdown(context.Background())
+			}()
+
+			s, err := server.New(cmd.Context(), a.cfg, a.logger)
+			if err != nil {
+				return fmt.Errorf("unable to start the server: %v", err)
+			}
+
+			if a.cfg.GRPC.Addr != "" {
+				lis, err := net.Listen("tcp", a.cfg.GRPC.Addr)
+				if err != nil {
+					return fmt.Errorf("unable to listen for gRPC: %v", err)
+				}
+				a.logger.Info("launching the gRPC server", "addr", a.cfg.GRPC.Addr)
+				go func() {
+					if err := s.RPC.Serve(lis); err != nil {
+						a.logger.Error("gRPC server stopped", "err", err)
+					}
+				}()
+			}
+
+			a.logger.Info("launching the server", "addr", ":8000")
+			if err := http.ListenAndServe(":8000", s.H); err != nil {
+				return fmt.Errorf("server stopped: %v", err)
+			}
+
+			return nil
+		},
+	}
+}
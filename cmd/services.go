@@ -0,0 +1,51 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/db"
+	"task-manager/internal/events"
+	"task-manager/internal/mail"
+	"task-manager/internal/repository"
+	"task-manager/internal/services"
+)
+
+// openServices builds the services the way the server does, against a schema that is already
+// up to date. Unlike serve it does not migrate, so an operator command never changes the schema
+// behind the back of running servers.
+func openServices(ctx context.Context, cfg config.Config) (services.Services, func(), error) {
+	if cfg.DB.Driver == config.DBDriverMemory {
+		return services.Services{}, nil, fmt.Errorf("the %s driver keeps no data between runs", config.DBDriverMemory)
+	}
+
+	d, err := db.Open(cfg.DB)
+	if err != nil {
+		return services.Services{}, nil, fmt.Errorf("unable to initialize database: %v", err)
+	}
+	closeDB := func() { _ = d.Close() }
+
+	if err := d.PingContext(ctx); err != nil {
+		closeDB()
+		return services.Services{}, nil, fmt.Errorf("error while connecting to db: %v", err)
+	}
+	pending, err := d.PendingMigrations(ctx)
+	if err != nil {
+		closeDB()
+		return services.Services{}, nil, err
+	}
+	if len(pending) > 0 {
+		closeDB()
+		return services.Services{}, nil, fmt.Errorf("pending migrations %s, run migrate up first", strings.Join(pending, ", "))
+	}
+
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		closeDB()
+		return services.Services{}, nil, fmt.Errorf("unable to set up the mailer: %v", err)
+	}
+
+	// no streams are served here; with postgres the database notifies running servers
+	return services.New(repository.New(*d), cfg, mailer, events.NewBroker()), closeDB, nil
+}
@@ -0,0 +1,141 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"task-manager/internal/models"
+	"task-manager/internal/requests"
+
+	"github.com/spf13/cobra"
+)
+
+func newCreateUserCmd(a *app) *cobra.Command {
+	var req requests.CreateUserRequest
+	var passwordStdin, verified bool
+
+	cmd := &cobra.Command{
+		Use:   "create-user",
+		Short: "Create an account",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if passwordStdin {
+				p, err := readPassword(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				req.Password = p
+			}
+			if v := req.Validate(); !v.Validated {
+				return fmt.Errorf("create user validation failed: %s", v.Message)
+			}
+
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			p := models.CreateUserPayload{Name: req.Name, Email: req.Email, Password: req.Password}
+			u, err := s.Ad.CreateUser(cmd.Context(), p, verified)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "created user %d <%s>\n", u.ID, u.Email)
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&req.Name, "name", "", "display name")
+	cmd.Flags().StringVar(&req.Email, "email", "", "e-mail address used to log in")
+	cmd.Flags().StringVar(&req.Password, "password", "", "password, visible in the process list; prefer --password-stdin")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the password from the first line of stdin")
+	cmd.Flags().BoolVar(&verified, "verified", false, "mark the e-mail address as verified")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-stdin")
+
+	return cmd
+}
+
+func newResetPasswordCmd(a *app) *cobra.Command {
+	var req requests.SetPasswordRequest
+	var passwordStdin bool
+
+	cmd := &cobra.Command{
+		Use:   "reset-password",
+		Short: "Set the password of an account and revoke its pending reset links",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if passwordStdin {
+				p, err := readPassword(cmd.InOrStdin())
+				if err != nil {
+					return err
+				}
+				req.Password = p
+			}
+			if v := req.Validate(); !v.Validated {
+				return fmt.Errorf("reset password validation failed: %s", v.Message)
+			}
+
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			if err := s.Ad.SetPassword(cmd.Context(), req.Email, req.Password); err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "password of <%s> updated\n", req.Email)
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&req.Email, "email", "", "e-mail address of the account")
+	cmd.Flags().StringVar(&req.Password, "password", "", "new password, visible in the process list; prefer --password-stdin")
+	cmd.Flags().BoolVar(&passwordStdin, "password-stdin", false, "read the new password from the first line of stdin")
+	cmd.MarkFlagsMutuallyExclusive("password", "password-stdin")
+
+	return cmd
+}
+
+func newPromoteAdminCmd(a *app) *cobra.Command {
+	var email string
+
+	cmd := &cobra.Command{
+		Use:   "promote-admin",
+		Short: "Grant an account the admin role",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if email == "" {
+				return fmt.Errorf("promote admin validation failed: email is required")
+			}
+
+			s, closeDB, err := openServices(cmd.Context(), a.cfg)
+			if err != nil {
+				return err
+			}
+			defer closeDB()
+
+			u, err := s.Ad.PromoteAdmin(cmd.Context(), email)
+			if err != nil {
+				return err
+			}
+			_, _ = fmt.Fprintf(cmd.OutOrStdout(), "user %d <%s> is now an admin\n", u.ID, u.Email)
+
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&email, "email", "", "e-mail address of the account")
+
+	return cmd
+}
+
+func readPassword(r io.Reader) (string, error) {
+	line, err := bufio.NewReader(r).ReadString('\n')
+	if err != nil && err != io.EOF {
+		return "", fmt.Errorf("failed to read the password from stdin: %v", err)
+	}
+
+	return strings.TrimRight(line, "\r\n"), nil
+}
@@ -0,0 +1,129 @@
+package collab
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"sync"
+	"task-manager/internal/metrics"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// maxMessageSize bounds the messages clients send, which are all small.
+	maxMessageSize = 4096
+	writeWait      = 10 * time.Second
+)
+
+// Client is a connection to the hub. Only its write pump writes to conn, as the connection
+// allows a single writer.
+type Client struct {
+	id   string
+	uID  int64
+	hub  *Hub
+	conn *websocket.Conn
+
+	send chan []byte
+	// done is closed to disconnect the client with closeCode and closeReason.
+	done        chan struct{}
+	stopOnce    sync.Once
+	closeCode   int
+	closeReason string
+
+	// topics maps the subscribed topics to the presence state of the client; guarded by hub.mu.
+	topics map[string]string
+}
+
+func newClient(h *Hub, conn *websocket.Conn, uID int64) (*Client, error) {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+
+	return &Client{
+		id:     hex.EncodeToString(b),
+		uID:    uID,
+		hub:    h,
+		conn:   conn,
+		send:   make(chan []byte, h.cfg.SendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]string),
+	}, nil
+}
+
+// enqueue queues msg without blocking. A client that fell SendBuffer messages behind would
+// miss updates if messages were dropped, so it is disconnected instead and catches up when it
+// reconnects.
+func (c *Client) enqueue(msg []byte) {
+	select {
+	case c.send <- msg:
+	default:
+		metrics.CollabSlowClients.Inc()
+		c.stop(websocket.CloseTryAgainLater, "client too slow")
+	}
+}
+
+// stop disconnects the client. Only the first call decides the close code.
+func (c *Client) stop(code int, reason string) {
+	c.stopOnce.Do(func() {
+		c.closeCode = code
+		c.closeReason = reason
+		close(c.done)
+	})
+}
+
+func (c *Client) readPump(ctx context.Context) {
+	pongWait := 2 * c.hub.cfg.PingInterval
+	c.conn.SetReadLimit(maxMessageSize)
+	_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	c.conn.SetPongHandler(func(string) error {
+		return c.conn.SetReadDeadline(time.Now().Add(pongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = c.conn.SetReadDeadline(time.Now().Add(pongWait))
+
+		var req Request
+		if err := json.Unmarshal(data, &req); err != nil {
+			c.enqueue(encode(errorMessage{Type: TypeError, Message: "messages have to be JSON objects"}))
+			continue
+		}
+		c.hub.handle(ctx, c, req)
+	}
+}
+
+func (c *Client) writePump() {
+	ping := time.NewTicker(c.hub.cfg.PingInterval)
+	defer func() {
+		ping.Stop()
+		_ = c.conn.Close()
+	}()
+
+	for {
+		select {
+		case msg := <-c.send:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				c.stop(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-ping.C:
+			_ = c.conn.SetWriteDeadline(time.Now().Add(writeWait))
+			if err := c.conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				c.stop(websocket.CloseAbnormalClosure, "")
+				return
+			}
+		case <-c.done:
+			msg := websocket.FormatCloseMessage(c.closeCode, c.closeReason)
+			_ = c.conn.WriteControl(websocket.CloseMessage, msg, time.Now().Add(writeWait))
+			return
+		}
+	}
+}
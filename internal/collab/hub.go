@@ -0,0 +1,391 @@
+// Package collab runs the WebSocket channel shared task boards are built on. Clients subscribe
+// to the tasks of their user or to single tasks, receive their changes as they happen, see which
+// of the user's sessions have a task open and take advisory edit locks that expire unless
+// renewed.
+//
+// Tasks are only ever accessible to their owner, so presence and locks are shared between the
+// tabs and devices the owner is signed in on, never between different users.
+//
+// Changes reach every server through the event stream of the database, while presence and
+// locks are kept by the server a client is connected to.
+package collab
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"task-manager/internal/config"
+	"task-manager/internal/health"
+	"task-manager/internal/services"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+const (
+	// TopicTasks delivers the changes to every task of the user.
+	TopicTasks = "tasks"
+	// taskTopicPrefix followed by a task id delivers the changes to that task, together with
+	// its presence and lock.
+	taskTopicPrefix = "task:"
+
+	StateViewing = "viewing"
+	StateEditing = "editing"
+)
+
+var errNotSubscribed = errors.New("subscribe to the task first")
+
+// TaskTopic returns the topic of the task with the given id.
+func TaskTopic(id int) string {
+	return taskTopicPrefix + strconv.Itoa(id)
+}
+
+// Hub tracks the connected clients with their subscriptions, presence and locks. Messages are
+// queued per client without blocking, so a slow client never holds up the others.
+type Hub struct {
+	cfg config.CollabConfig
+	ts  services.TaskService
+	es  services.EventService
+
+	mu      sync.RWMutex
+	clients map[*Client]struct{}
+	topics  map[string]map[*Client]struct{}
+	locks   map[int]*lock
+}
+
+type lock struct {
+	holder    *Client
+	expiresAt time.Time
+}
+
+func NewHub(cfg config.CollabConfig, ts services.TaskService, es services.EventService) *Hub {
+	return &Hub{
+		cfg:     cfg,
+		ts:      ts,
+		es:      es,
+		clients: make(map[*Client]struct{}),
+		topics:  make(map[string]map[*Client]struct{}),
+		locks:   make(map[int]*lock),
+	}
+}
+
+// RunInterval is how often Run releases expired locks, and so the longest it goes without
+// beating its heartbeat.
+func RunInterval(cfg config.CollabConfig) time.Duration {
+	return min(time.Second, cfg.LockTTL/2)
+}
+
+// Run releases expired locks until ctx is done, beating hb on every round.
+func (h *Hub) Run(ctx context.Context, hb *health.Heartbeat) {
+	t := time.NewTicker(RunInterval(h.cfg))
+	defer t.Stop()
+	for {
+		hb.Beat()
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-t.C:
+			h.expireLocks(now)
+		}
+	}
+}
+
+// Clients counts the connected clients.
+func (h *Hub) Clients() int {
+	h.mu.RLock()
+	defer h.mu.RUnlock()
+	return len(h.clients)
+}
+
+// Serve runs the connection of a client of the user until it is closed. ctx is used for the
+// lookups made on behalf of the client.
+func (h *Hub) Serve(ctx context.Context, conn *websocket.Conn, uID int64) error {
+	c, err := newClient(h, conn, uID)
+	if err != nil {
+		_ = conn.Close()
+		return err
+	}
+
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+	defer h.unregister(c)
+
+	go c.writePump()
+	go h.forwardEvents(ctx, c)
+
+	c.enqueue(encode(welcomeMessage{Type: TypeWelcome, ClientID: c.id}))
+	c.readPump(ctx)
+	c.stop(websocket.CloseNormalClosure, "")
+
+	return nil
+}
+
+func (h *Hub) unregister(c *Client) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	delete(h.clients, c)
+	for topic := range c.topics {
+		h.leaveLocked(c, topic)
+	}
+}
+
+// handle answers a request of c.
+func (h *Hub) handle(ctx context.Context, c *Client, req Request) {
+	var err error
+	switch req.Type {
+	case TypeSubscribe:
+		err = h.subscribe(ctx, c, req.Topic)
+	case TypeUnsubscribe:
+		err = h.unsubscribe(c, req.Topic)
+	case TypePresence:
+		err = h.setPresence(c, req.TaskID, req.State)
+	case TypeLock:
+		err = h.lock(c, req.TaskID)
+	case TypeUnlock:
+		err = h.unlock(c, req.TaskID)
+	default:
+		err = fmt.Errorf("unknown message type %q", req.Type)
+	}
+
+	if err != nil {
+		c.enqueue(encode(errorMessage{Type: TypeError, Request: req.Type, Message: err.Error()}))
+	}
+}
+
+// subscribe lets a client follow a single task only when its user owns the task, which keeps
+// presence and locks among the sessions of that user.
+func (h *Hub) subscribe(ctx context.Context, c *Client, topic string) error {
+	taskID, err := parseTopic(topic)
+	if err != nil {
+		return err
+	}
+	if taskID != 0 {
+		ok, err := h.ts.IsTaskOwner(ctx, c.uID, taskID)
+		if err != nil {
+			return fmt.Errorf("failed to check access to task %d", taskID)
+		}
+		if !ok {
+			return fmt.Errorf("task %d does not exist or is not yours", taskID)
+		}
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := c.topics[topic]; !ok {
+		c.topics[topic] = StateViewing
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*Client]struct{})
+		}
+		h.topics[topic][c] = struct{}{}
+	}
+	c.enqueue(encode(topicMessage{Type: TypeSubscribed, Topic: topic}))
+	if taskID != 0 {
+		h.broadcastPresenceLocked(taskID)
+		c.enqueue(encode(h.lockMessageLocked(taskID)))
+	}
+
+	return nil
+}
+
+func (h *Hub) unsubscribe(c *Client, topic string) error {
+	if _, err := parseTopic(topic); err != nil {
+		return err
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.leaveLocked(c, topic)
+	c.enqueue(encode(topicMessage{Type: TypeUnsubscribed, Topic: topic}))
+	return nil
+}
+
+// leaveLocked removes c from topic, releasing its lock on the task of the topic.
+func (h *Hub) leaveLocked(c *Client, topic string) {
+	if _, ok := c.topics[topic]; !ok {
+		return
+	}
+	delete(c.topics, topic)
+	delete(h.topics[topic], c)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+	}
+
+	taskID, _ := parseTopic(topic)
+	if taskID == 0 {
+		return
+	}
+	if l, ok := h.locks[taskID]; ok && l.holder == c {
+		delete(h.locks, taskID)
+		h.broadcastLocked(TaskTopic(taskID), encode(h.lockMessageLocked(taskID)))
+	}
+	h.broadcastPresenceLocked(taskID)
+}
+
+func (h *Hub) setPresence(c *Client, taskID int, state string) error {
+	if state != StateViewing && state != StateEditing {
+		return fmt.Errorf("state must be %s or %s", StateViewing, StateEditing)
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic := TaskTopic(taskID)
+	if _, ok := c.topics[topic]; !ok {
+		return errNotSubscribed
+	}
+	if c.topics[topic] != state {
+		c.topics[topic] = state
+		h.broadcastPresenceLocked(taskID)
+	}
+	return nil
+}
+
+// lock takes or renews the edit lock of the task for LockTTL.
+func (h *Hub) lock(c *Client, taskID int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if _, ok := c.topics[TaskTopic(taskID)]; !ok {
+		return errNotSubscribed
+	}
+	now := time.Now()
+	if l, ok := h.locks[taskID]; ok && l.holder != c && l.expiresAt.After(now) {
+		c.enqueue(encode(h.lockMessageLocked(taskID)))
+		return fmt.Errorf("task %d is locked by client %s", taskID, l.holder.id)
+	}
+
+	h.locks[taskID] = &lock{holder: c, expiresAt: now.Add(h.cfg.LockTTL)}
+	h.broadcastLocked(TaskTopic(taskID), encode(h.lockMessageLocked(taskID)))
+	return nil
+}
+
+func (h *Hub) unlock(c *Client, taskID int) error {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	l, ok := h.locks[taskID]
+	if !ok || l.holder != c {
+		return fmt.Errorf("task %d is not locked by this client", taskID)
+	}
+	delete(h.locks, taskID)
+	h.broadcastLocked(TaskTopic(taskID), encode(h.lockMessageLocked(taskID)))
+	return nil
+}
+
+func (h *Hub) expireLocks(now time.Time) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for taskID, l := range h.locks {
+		if l.expiresAt.After(now) {
+			continue
+		}
+		delete(h.locks, taskID)
+		h.broadcastLocked(TaskTopic(taskID), encode(h.lockMessageLocked(taskID)))
+	}
+}
+
+func (h *Hub) lockMessageLocked(taskID int) lockMessage {
+	m := lockMessage{Type: TypeLock, TaskID: taskID}
+	if l, ok := h.locks[taskID]; ok {
+		m.Holder = &Holder{ClientID: l.holder.id, UserID: l.holder.uID, ExpiresAt: l.expiresAt}
+	}
+	return m
+}
+
+func (h *Hub) broadcastPresenceLocked(taskID int) {
+	topic := TaskTopic(taskID)
+	members := make([]Member, 0, len(h.topics[topic]))
+	for c := range h.topics[topic] {
+		members = append(members, Member{ClientID: c.id, UserID: c.uID, State: c.topics[topic]})
+	}
+	slices.SortFunc(members, func(a, b Member) int {
+		return strings.Compare(a.ClientID, b.ClientID)
+	})
+
+	h.broadcastLocked(topic, encode(presenceMessage{Type: TypePresence, TaskID: taskID, Members: members}))
+}
+
+// broadcastLocked queues msg for every client subscribed to topic; h.mu has to be held.
+func (h *Hub) broadcastLocked(topic string, msg []byte) {
+	for c := range h.topics[topic] {
+		c.enqueue(msg)
+	}
+}
+
+// forwardEvents sends the changes to the subscribed tasks of c until c disconnects. Like the
+// event stream it reads the events following the last one it saw whenever it is woken.
+func (h *Hub) forwardEvents(ctx context.Context, c *Client) {
+	sub := h.es.Subscribe(c.uID)
+	defer sub.Close()
+
+	lastID, err := h.es.LastID(ctx)
+	if err != nil {
+		c.stop(websocket.CloseInternalServerErr, "failed to read events")
+		return
+	}
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case _, ok := <-sub.C:
+			if !ok {
+				c.stop(websocket.CloseGoingAway, "server is shutting down")
+				return
+			}
+			if lastID, err = h.deliver(ctx, c, lastID); err != nil {
+				c.stop(websocket.CloseInternalServerErr, "failed to read events")
+				return
+			}
+		}
+	}
+}
+
+func (h *Hub) deliver(ctx context.Context, c *Client, lastID int64) (int64, error) {
+	for {
+		events, err := h.es.After(ctx, c.uID, lastID)
+		if err != nil {
+			return lastID, err
+		}
+
+		h.mu.RLock()
+		for _, e := range events {
+			lastID = e.ID
+			topic := TaskTopic(e.TaskID)
+			if _, ok := c.topics[topic]; !ok {
+				topic = TopicTasks
+				if _, ok := c.topics[topic]; !ok {
+					continue
+				}
+			}
+			c.enqueue(encode(eventMessage{Type: TypeEvent, Topic: topic, Event: e}))
+		}
+		h.mu.RUnlock()
+
+		if len(events) < services.EventBatchSize {
+			return lastID, nil
+		}
+	}
+}
+
+// parseTopic validates topic, returning the id of the task for task topics and 0 otherwise.
+func parseTopic(topic string) (int, error) {
+	if topic == TopicTasks {
+		return 0, nil
+	}
+	if s, ok := strings.CutPrefix(topic, taskTopicPrefix); ok {
+		if id, err := strconv.Atoi(s); err == nil && id > 0 {
+			return id, nil
+		}
+	}
+	return 0, fmt.Errorf("unknown topic %q, expected %s or %s<id>", topic, TopicTasks, taskTopicPrefix)
+}
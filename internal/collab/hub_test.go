@@ -0,0 +1,262 @@
+package collab
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/events"
+	"task-manager/internal/health"
+	"task-manager/internal/models"
+	"task-manager/internal/repository/memory"
+	"task-manager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+type testEnv struct {
+	ts  services.TaskService
+	url string
+}
+
+func newTestEnv(t *testing.T, cfg config.CollabConfig) testEnv {
+	t.Helper()
+	r := memory.New()
+	b := events.NewBroker()
+	ts := services.NewTaskService(r.Tr, b)
+	es := services.NewEventService(r.Ev, b, config.EventsConfig{Retention: time.Hour})
+	h := NewHub(cfg, ts, es)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	t.Cleanup(cancel)
+	go h.Run(ctx, health.NewHeartbeat(time.Minute))
+
+	for _, email := range []string{"lorem@example.com", "ipsum@example.com"} {
+		if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: email, Password: "hash"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := ts.StoreTask(context.WithValue(ctx, contextkeys.UserID, int64(1)), models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+		t.Fatal(err)
+	}
+
+	upgrader := websocket.Upgrader{}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		uID := int64(1)
+		if r.URL.Query().Get("user") == "2" {
+			uID = 2
+		}
+		_ = h.Serve(r.Context(), conn, uID)
+	}))
+	t.Cleanup(srv.Close)
+
+	return testEnv{ts: ts, url: "ws" + strings.TrimPrefix(srv.URL, "http")}
+}
+
+type testClient struct {
+	t    *testing.T
+	conn *websocket.Conn
+	id   string
+}
+
+func (e testEnv) dial(t *testing.T, query string) *testClient {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(e.url+query, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	c := &testClient{t: t, conn: conn}
+	c.id = c.expect(TypeWelcome)["client_id"].(string)
+	return c
+}
+
+func (c *testClient) send(req Request) {
+	c.t.Helper()
+	if err := c.conn.WriteJSON(req); err != nil {
+		c.t.Fatal(err)
+	}
+}
+
+// expect reads messages until one of the given type arrives.
+func (c *testClient) expect(typ string) map[string]any {
+	c.t.Helper()
+	_ = c.conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	for {
+		var m map[string]any
+		if err := c.conn.ReadJSON(&m); err != nil {
+			c.t.Fatalf("waiting for %s: %v", typ, err)
+		}
+		if m["type"] == typ {
+			return m
+		}
+	}
+}
+
+var testCollabConfig = config.CollabConfig{LockTTL: time.Minute, PingInterval: time.Minute, SendBuffer: 64}
+
+func TestHub_subscriptions(t *testing.T) {
+	env := newTestEnv(t, testCollabConfig)
+	c := env.dial(t, "")
+
+	c.send(Request{Type: TypeSubscribe, Topic: "projects"})
+	if m := c.expect(TypeError); !strings.Contains(m["message"].(string), "unknown topic") {
+		t.Errorf("unexpected error %v", m)
+	}
+
+	other := env.dial(t, "?user=2")
+	other.send(Request{Type: TypeSubscribe, Topic: TaskTopic(1)})
+	if m := other.expect(TypeError); !strings.Contains(m["message"].(string), "not yours") {
+		t.Errorf("subscribing to the task of another user should fail, got %v", m)
+	}
+
+	c.send(Request{Type: TypeSubscribe, Topic: TopicTasks})
+	c.expect(TypeSubscribed)
+	ctx := context.WithValue(context.Background(), contextkeys.UserID, int64(1))
+	if _, err := env.ts.UpdateTask(ctx, models.UpdateTask{ID: 1, Name: "Renamed", Priority: models.PriorityLow}); err != nil {
+		t.Fatal(err)
+	}
+	m := c.expect(TypeEvent)
+	event := m["event"].(map[string]any)
+	if m["topic"] != TopicTasks || event["type"] != models.TaskEventUpdated || event["task_id"] != float64(1) {
+		t.Errorf("unexpected event %v", m)
+	}
+}
+
+func TestHub_presenceAndLocks(t *testing.T) {
+	env := newTestEnv(t, config.CollabConfig{LockTTL: 300 * time.Millisecond, PingInterval: time.Minute, SendBuffer: 64})
+	a, b := env.dial(t, ""), env.dial(t, "")
+	topic := TaskTopic(1)
+
+	a.send(Request{Type: TypeLock, TaskID: 1})
+	if m := a.expect(TypeError); m["message"] != errNotSubscribed.Error() {
+		t.Errorf("locking without subscribing should fail, got %v", m)
+	}
+
+	a.send(Request{Type: TypeSubscribe, Topic: topic})
+	a.expect(TypeSubscribed)
+	b.send(Request{Type: TypeSubscribe, Topic: topic})
+	b.expect(TypeSubscribed)
+	members := a.expect(TypePresence)["members"].([]any)
+	for len(members) != 2 {
+		members = a.expect(TypePresence)["members"].([]any)
+	}
+
+	b.send(Request{Type: TypePresence, TaskID: 1, State: StateEditing})
+	for {
+		m := a.expect(TypePresence)
+		found := false
+		for _, mem := range m["members"].([]any) {
+			mem := mem.(map[string]any)
+			found = found || (mem["client_id"] == b.id && mem["state"] == StateEditing)
+		}
+		if found {
+			break
+		}
+	}
+
+	a.send(Request{Type: TypeLock, TaskID: 1})
+	for {
+		m := b.expect(TypeLock)
+		if h, ok := m["holder"].(map[string]any); ok && h["client_id"] == a.id {
+			break
+		}
+	}
+	b.send(Request{Type: TypeLock, TaskID: 1})
+	if m := b.expect(TypeError); !strings.Contains(m["message"].(string), "is locked by client "+a.id) {
+		t.Errorf("locking a locked task should fail, got %v", m)
+	}
+
+	// the lock expires unless it is renewed
+	start := time.Now()
+	for {
+		if m := b.expect(TypeLock); m["holder"] == nil {
+			break
+		}
+	}
+	if time.Since(start) < 200*time.Millisecond {
+		t.Errorf("the lock was released before it expired")
+	}
+
+	b.send(Request{Type: TypeLock, TaskID: 1})
+	for {
+		if h, ok := a.expect(TypeLock)["holder"].(map[string]any); ok && h["client_id"] == b.id {
+			break
+		}
+	}
+
+	// disconnecting releases the lock and leaves the presence
+	_ = b.conn.Close()
+	for {
+		if m := a.expect(TypeLock); m["holder"] == nil {
+			break
+		}
+	}
+	for {
+		if m := a.expect(TypePresence); len(m["members"].([]any)) == 1 {
+			break
+		}
+	}
+}
+
+func TestClient_enqueue(t *testing.T) {
+	h := NewHub(config.CollabConfig{SendBuffer: 1}, nil, nil)
+	c, err := newClient(h, nil, 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	c.enqueue([]byte("first"))
+	select {
+	case <-c.done:
+		t.Fatalf("a client with room in its buffer should not be stopped")
+	default:
+	}
+
+	c.enqueue([]byte("second"))
+	select {
+	case <-c.done:
+	default:
+		t.Fatalf("a client with a full buffer should be stopped")
+	}
+	if c.closeCode != websocket.CloseTryAgainLater {
+		t.Errorf("expected close code %d but got %d", websocket.CloseTryAgainLater, c.closeCode)
+	}
+	if m := <-c.send; string(m) != "first" {
+		t.Errorf("queued messages should be kept, got %s", m)
+	}
+}
+
+func TestParseTopic(t *testing.T) {
+	var tests = []struct {
+		topic    string
+		expected int
+		fails    bool
+	}{
+		{TopicTasks, 0, false},
+		{"task:12", 12, false},
+		{"task:0", 0, true},
+		{"task:abc", 0, true},
+		{"project:1", 0, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.topic, func(t *testing.T) {
+			id, err := parseTopic(tc.topic)
+			if (err != nil) != tc.fails || id != tc.expected {
+				t.Errorf("expected %d (fails %t) but got %d, %v", tc.expected, tc.fails, id, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,89 @@
+package collab
+
+import (
+	"encoding/json"
+	"task-manager/internal/models"
+	"time"
+)
+
+// Types of the messages clients send.
+const (
+	TypeSubscribe   = "subscribe"
+	TypeUnsubscribe = "unsubscribe"
+	TypePresence    = "presence"
+	TypeLock        = "lock"
+	TypeUnlock      = "unlock"
+)
+
+// Types of the messages only the server sends; presence and lock updates reuse the types above.
+const (
+	TypeWelcome      = "welcome"
+	TypeSubscribed   = "subscribed"
+	TypeUnsubscribed = "unsubscribed"
+	TypeEvent        = "event"
+	TypeError        = "error"
+)
+
+// Request is a message sent by a client. Presence, lock and unlock refer to a task the client
+// subscribed to.
+type Request struct {
+	Type   string `json:"type"`
+	Topic  string `json:"topic,omitempty"`
+	TaskID int    `json:"task_id,omitempty"`
+	State  string `json:"state,omitempty"`
+}
+
+type welcomeMessage struct {
+	Type     string `json:"type"`
+	ClientID string `json:"client_id"`
+}
+
+type topicMessage struct {
+	Type  string `json:"type"`
+	Topic string `json:"topic"`
+}
+
+type eventMessage struct {
+	Type  string           `json:"type"`
+	Topic string           `json:"topic"`
+	Event models.TaskEvent `json:"event"`
+}
+
+// Member is a client connected to a task topic.
+type Member struct {
+	ClientID string `json:"client_id"`
+	UserID   int64  `json:"user_id"`
+	State    string `json:"state"`
+}
+
+type presenceMessage struct {
+	Type    string   `json:"type"`
+	TaskID  int      `json:"task_id"`
+	Members []Member `json:"members"`
+}
+
+// Holder is the client holding an edit lock.
+type Holder struct {
+	ClientID  string    `json:"client_id"`
+	UserID    int64     `json:"user_id"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// lockMessage announces the lock of a task; Holder is nil when the task is not locked.
+type lockMessage struct {
+	Type   string  `json:"type"`
+	TaskID int     `json:"task_id"`
+	Holder *Holder `json:"holder"`
+}
+
+type errorMessage struct {
+	Type    string `json:"type"`
+	Request string `json:"request,omitempty"`
+	Message string `json:"message"`
+}
+
+// encode marshals a message. They are plain structs, which always encode.
+func encode(m any) []byte {
+	b, _ := json.Marshal(m)
+	return b
+}
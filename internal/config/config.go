@@ -2,8 +2,46 @@ package config
 
 import (
 	"fmt"
+	"net"
 	"reflect"
+	"slices"
+	"strconv"
 	"strings"
+	"time"
+)
+
+const (
+	DBDriverPostgres = "postgres"
+	DBDriverSQLite   = "sqlite"
+	DBDriverMemory   = "memory"
+
+	SSLModeDisable    = "disable"
+	SSLModeRequire    = "require"
+	SSLModeVerifyCA   = "verify-ca"
+	SSLModeVerifyFull = "verify-full"
+
+	TracingExporterNone   = "none"
+	TracingExporterStdout = "stdout"
+	TracingExporterOTLP   = "otlp"
+
+	LogFormatJSON = "json"
+	LogFormatText = "text"
+
+	RateLimitStoreMemory   = "memory"
+	RateLimitStorePostgres = "postgres"
+
+	SameSiteLax    = "lax"
+	SameSiteStrict = "strict"
+	SameSiteNone   = "none"
+
+	MailDriverSMTP = "smtp"
+	MailDriverFile = "file"
+
+	DeletedUserTasksDelete    = "delete"
+	DeletedUserTasksAnonymize = "anonymize"
+
+	PasswordAlgorithmArgon2id = "argon2id"
+	PasswordAlgorithmBcrypt   = "bcrypt"
 )
 
 type Validator interface {
@@ -11,10 +49,52 @@ type Validator interface {
 }
 
 type Config struct {
-	DB  DBConfig
-	JWT JWTConfig
+	DB        DBConfig
+	JWT       JWTConfig
+	Tracing   TracingConfig
+	Log       LogConfig
+	RateLimit RateLimitConfig
+	CORS      CORSConfig
+	Session   SessionConfig
+	Mail      MailConfig
+	Auth      AuthConfig
+	OIDC      OIDCConfig
+	Password  PasswordConfig
+	HTTP      HTTPConfig
+	Events    EventsConfig
+	Collab    CollabConfig
+	GRPC      GRPCConfig
+	GraphQL   GraphQLConfig
 }
+
+// DBConfig selects the storage backend: "postgres" connects with Name, User, Password, Host and
+// Port, "sqlite" keeps everything in the embedded database file at Path and "memory" holds the
+// data in process, which is lost on restart and only meant for development and tests.
+//
+// The pool settings apply to every connection pool; zero keeps the database/sql default.
+// StatementTimeout makes postgres cancel statements running longer. ReplicaHosts lists
+// "host:port" read replicas, sharing the primary's credentials, that serve listings.
 type DBConfig struct {
+	Driver   string
+	Name     string
+	User     string
+	Password string
+	Host     string
+	Port     string
+	Path     string
+
+	SSLMode          string
+	SSLRootCert      string
+	ConnectTimeout   time.Duration
+	StatementTimeout time.Duration
+	MaxOpenConns     int
+	MaxIdleConns     int
+	ConnMaxLifetime  time.Duration
+	ConnMaxIdleTime  time.Duration
+	ReplicaHosts     []string
+}
+
+type postgresConfig struct {
 	Name     string
 	User     string
 	Password string
@@ -26,26 +106,414 @@ type JWTConfig struct {
 	Secret string
 }
 
+// TracingConfig selects where spans are exported: "none", "stdout" or "otlp". Endpoint is the
+// OTLP/HTTP collector URL, e.g. http://localhost:4318/v1/traces; when empty the standard
+// OTEL_EXPORTER_OTLP_* variables apply.
+type TracingConfig struct {
+	Exporter    string
+	Endpoint    string
+	ServiceName string
+}
+
+// LogConfig sets the minimum level (debug, info, warn, error) and output format (json, text).
+type LogConfig struct {
+	Level  string
+	Format string
+}
+
+// RateLimitConfig holds the token bucket policies, written as "<limit>/<window>" (e.g. "10/1m"),
+// for each anonymous auth route and for authenticated API calls, and the failed login lockout:
+// after LockoutThreshold consecutive failures an account is locked for LockoutBase, doubling
+// with every further failure up to LockoutMax. Every auth route has a bucket of its own, so
+// traffic on one of them cannot use up the others.
+type RateLimitConfig struct {
+	Store                string
+	RegisterPolicy       string
+	LoginPolicy          string
+	LoginMFAPolicy       string
+	VerifyEmailPolicy    string
+	ForgotPasswordPolicy string
+	ResetPasswordPolicy  string
+	OIDCPolicy           string
+	APIPolicy            string
+	LockoutThreshold     int
+	LockoutBase          time.Duration
+	LockoutMax           time.Duration
+}
+
+// CORSConfig lists the origins allowed to call the API from a browser; "*" allows any origin
+// but cannot be combined with AllowCredentials.
+type CORSConfig struct {
+	AllowedOrigins   []string
+	AllowCredentials bool
+	MaxAge           time.Duration
+}
+
+// SessionConfig enables cookie based sessions for browser clients next to Bearer tokens.
+// HSTSMaxAge is sent as Strict-Transport-Security when non-zero.
+type SessionConfig struct {
+	CookieEnabled bool
+	CookieDomain  string
+	CookieSecure  bool
+	SameSite      string
+	HSTSMaxAge    time.Duration
+}
+
+// MailConfig selects how outgoing mail is delivered: "smtp" sends through SMTPHost, "file"
+// drops every message as an .eml file into Dir, which is handy for local testing.
+type MailConfig struct {
+	Driver       string
+	From         string
+	SMTPHost     string
+	SMTPPort     int
+	SMTPUser     string
+	SMTPPassword string
+	Dir          string
+}
+
+// AuthConfig controls the account flows. AppURL is the frontend base URL used to build the
+// links sent by email; when RequireVerifiedEmail is set users cannot log in before verifying.
+// DeletedUserTasks decides whether the tasks of a deleted account are deleted with it or kept
+// without an owner ("anonymize"). MFAIssuer is the name authenticator apps show next to codes.
+type AuthConfig struct {
+	RequireVerifiedEmail bool
+	VerificationTTL      time.Duration
+	ResetTTL             time.Duration
+	AppURL               string
+	DeletedUserTasks     string
+	MFAIssuer            string
+}
+
+// OIDCConfig enables login through an OpenID Connect provider. Users are matched by the
+// provider's subject, then linked to an existing account by verified e-mail; unknown users
+// get an account created on first login when AutoProvision is set.
+type OIDCConfig struct {
+	Enabled       bool
+	Issuer        string
+	ClientID      string
+	ClientSecret  string
+	RedirectURL   string
+	Scopes        []string
+	AutoProvision bool
+}
+
+// PasswordConfig selects the algorithm for new password hashes; hashes made with other
+// settings are still accepted and replaced on the next login. Argon2Memory is in KiB.
+type PasswordConfig struct {
+	Algorithm         string
+	BcryptCost        int
+	Argon2Memory      int
+	Argon2Iterations  int
+	Argon2Parallelism int
+}
+
+// HTTPConfig bounds how long a request may take; the deadline is passed down to every database
+// call, and requests running past it are answered with 503. ValidateResponses checks responses
+// against the OpenAPI document as well as requests; it is meant for tests and development, as
+// it buffers every response.
+type HTTPConfig struct {
+	RequestTimeout    time.Duration
+	ValidateResponses bool
+}
+
+// EventsConfig tunes the stream of task changes: idle streams get a comment every Heartbeat so
+// proxies keep them open, and clients can resume from events up to Retention old.
+type EventsConfig struct {
+	Heartbeat time.Duration
+	Retention time.Duration
+}
+
+// CollabConfig tunes the WebSocket collaboration channel: edit locks expire LockTTL after they
+// were last renewed, connections are pinged every PingInterval and a client falling more than
+// SendBuffer messages behind is disconnected.
+type CollabConfig struct {
+	LockTTL      time.Duration
+	PingInterval time.Duration
+	SendBuffer   int
+}
+
+// minLockTTL keeps LockTTL/2, the interval expired locks are released at, above zero.
+const minLockTTL = 2 * time.Millisecond
+
+// GRPCConfig enables the gRPC API on Addr, a "host:port" listened on next to the HTTP server.
+// It is disabled when Addr is empty.
+type GRPCConfig struct {
+	Addr string
+}
+
+// GraphQLConfig bounds the queries the GraphQL endpoint runs: fields may nest MaxDepth levels
+// deep, and a query may cost MaxComplexity, where every field costs 1 and the fields below a
+// list count once per expected item.
+type GraphQLConfig struct {
+	MaxDepth      int
+	MaxComplexity int
+}
+
 func (db DBConfig) Validate() error {
-	return validateStruct(db)
+	switch db.Driver {
+	case DBDriverPostgres:
+		err := validateStruct(postgresConfig{
+			Name:     db.Name,
+			User:     db.User,
+			Password: db.Password,
+			Host:     db.Host,
+			Port:     db.Port,
+		})
+		if err != nil {
+			return err
+		}
+		if p, err := strconv.Atoi(db.Port); err != nil || p < 1 || p > 65535 {
+			return fmt.Errorf("Port must be a number between 1 and 65535")
+		}
+		switch db.SSLMode {
+		case "", SSLModeDisable, SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull:
+		default:
+			return fmt.Errorf("SSLMode must be one of %s, %s, %s, %s", SSLModeDisable, SSLModeRequire, SSLModeVerifyCA, SSLModeVerifyFull)
+		}
+		for _, h := range db.ReplicaHosts {
+			if _, _, err := net.SplitHostPort(h); err != nil {
+				return fmt.Errorf("ReplicaHosts must be host:port pairs: %v", err)
+			}
+		}
+	case DBDriverSQLite:
+		if strings.TrimSpace(db.Path) == "" {
+			return fmt.Errorf("Path is required for the sqlite driver")
+		}
+		if len(db.ReplicaHosts) > 0 {
+			return fmt.Errorf("ReplicaHosts require the %s driver", DBDriverPostgres)
+		}
+	case DBDriverMemory:
+		return nil
+	default:
+		return fmt.Errorf("Driver must be one of %s, %s, %s", DBDriverPostgres, DBDriverSQLite, DBDriverMemory)
+	}
+
+	if db.MaxOpenConns < 0 || db.MaxIdleConns < 0 {
+		return fmt.Errorf("MaxOpenConns and MaxIdleConns must not be negative")
+	}
+	if db.MaxOpenConns > 0 && db.MaxIdleConns > db.MaxOpenConns {
+		return fmt.Errorf("MaxIdleConns must not be greater than MaxOpenConns")
+	}
+	if db.ConnectTimeout < 0 || db.StatementTimeout < 0 || db.ConnMaxLifetime < 0 || db.ConnMaxIdleTime < 0 {
+		return fmt.Errorf("timeouts and connection lifetimes must not be negative")
+	}
+	return nil
 }
 
 func (jwt JWTConfig) Validate() error {
 	return validateStruct(jwt)
 }
 
+func (t TracingConfig) Validate() error {
+	switch t.Exporter {
+	case TracingExporterNone, TracingExporterStdout, TracingExporterOTLP:
+	default:
+		return fmt.Errorf("Exporter must be one of %s, %s, %s", TracingExporterNone, TracingExporterStdout, TracingExporterOTLP)
+	}
+	if strings.TrimSpace(t.ServiceName) == "" {
+		return fmt.Errorf("ServiceName is required")
+	}
+	return nil
+}
+
+func (l LogConfig) Validate() error {
+	switch strings.ToLower(l.Level) {
+	case "debug", "info", "warn", "error":
+	default:
+		return fmt.Errorf("Level must be one of debug, info, warn, error")
+	}
+	switch l.Format {
+	case LogFormatJSON, LogFormatText:
+	default:
+		return fmt.Errorf("Format must be one of %s, %s", LogFormatJSON, LogFormatText)
+	}
+	return nil
+}
+
+func (r RateLimitConfig) Validate() error {
+	switch r.Store {
+	case RateLimitStoreMemory, RateLimitStorePostgres:
+	default:
+		return fmt.Errorf("Store must be one of %s, %s", RateLimitStoreMemory, RateLimitStorePostgres)
+	}
+	if err := validateStruct(r); err != nil {
+		return err
+	}
+	if r.LockoutMax < r.LockoutBase {
+		return fmt.Errorf("LockoutMax must not be lower than LockoutBase")
+	}
+	return nil
+}
+
+func (c CORSConfig) Validate() error {
+	for _, o := range c.AllowedOrigins {
+		if o == "*" && c.AllowCredentials {
+			return fmt.Errorf("AllowedOrigins cannot contain * when AllowCredentials is set")
+		}
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("MaxAge must not be negative")
+	}
+	return nil
+}
+
+func (s SessionConfig) Validate() error {
+	switch s.SameSite {
+	case SameSiteLax, SameSiteStrict:
+	case SameSiteNone:
+		if !s.CookieSecure {
+			return fmt.Errorf("SameSite none requires CookieSecure")
+		}
+	default:
+		return fmt.Errorf("SameSite must be one of %s, %s, %s", SameSiteLax, SameSiteStrict, SameSiteNone)
+	}
+	return nil
+}
+
+func (m MailConfig) Validate() error {
+	if strings.TrimSpace(m.From) == "" {
+		return fmt.Errorf("From is required")
+	}
+	switch m.Driver {
+	case MailDriverSMTP:
+		if strings.TrimSpace(m.SMTPHost) == "" {
+			return fmt.Errorf("SMTPHost is required for the smtp driver")
+		}
+		if m.SMTPPort <= 0 {
+			return fmt.Errorf("SMTPPort must be positive")
+		}
+	case MailDriverFile:
+		if strings.TrimSpace(m.Dir) == "" {
+			return fmt.Errorf("Dir is required for the file driver")
+		}
+	default:
+		return fmt.Errorf("Driver must be one of %s, %s", MailDriverSMTP, MailDriverFile)
+	}
+	return nil
+}
+
+func (a AuthConfig) Validate() error {
+	if a.VerificationTTL <= 0 {
+		return fmt.Errorf("VerificationTTL must be positive")
+	}
+	if a.ResetTTL <= 0 {
+		return fmt.Errorf("ResetTTL must be positive")
+	}
+	if strings.TrimSpace(a.AppURL) == "" {
+		return fmt.Errorf("AppURL is required")
+	}
+	switch a.DeletedUserTasks {
+	case DeletedUserTasksDelete, DeletedUserTasksAnonymize:
+	default:
+		return fmt.Errorf("DeletedUserTasks must be one of %s, %s", DeletedUserTasksDelete, DeletedUserTasksAnonymize)
+	}
+	if strings.TrimSpace(a.MFAIssuer) == "" {
+		return fmt.Errorf("MFAIssuer is required")
+	}
+	return nil
+}
+
+func (o OIDCConfig) Validate() error {
+	if !o.Enabled {
+		return nil
+	}
+	if strings.TrimSpace(o.Issuer) == "" {
+		return fmt.Errorf("Issuer is required")
+	}
+	if strings.TrimSpace(o.ClientID) == "" {
+		return fmt.Errorf("ClientID is required")
+	}
+	if strings.TrimSpace(o.RedirectURL) == "" {
+		return fmt.Errorf("RedirectURL is required")
+	}
+	if !slices.Contains(o.Scopes, "openid") {
+		return fmt.Errorf("Scopes must contain openid")
+	}
+	return nil
+}
+
+func (p PasswordConfig) Validate() error {
+	switch p.Algorithm {
+	case PasswordAlgorithmArgon2id, PasswordAlgorithmBcrypt:
+	default:
+		return fmt.Errorf("Algorithm must be one of %s, %s", PasswordAlgorithmArgon2id, PasswordAlgorithmBcrypt)
+	}
+	if p.BcryptCost < 10 || p.BcryptCost > 31 {
+		return fmt.Errorf("BcryptCost must be between 10 and 31")
+	}
+	if p.Argon2Parallelism < 1 || p.Argon2Parallelism > 255 {
+		return fmt.Errorf("Argon2Parallelism must be between 1 and 255")
+	}
+	if p.Argon2Memory < 8*p.Argon2Parallelism {
+		return fmt.Errorf("Argon2Memory must be at least 8 KiB per thread")
+	}
+	if p.Argon2Iterations < 1 {
+		return fmt.Errorf("Argon2Iterations must be positive")
+	}
+	return nil
+}
+
+func (h HTTPConfig) Validate() error {
+	if h.RequestTimeout == 0 {
+		return fmt.Errorf("RequestTimeout is required")
+	}
+	if h.RequestTimeout < 0 {
+		return fmt.Errorf("RequestTimeout must be positive")
+	}
+	return nil
+}
+
+func (e EventsConfig) Validate() error {
+	return validateStruct(e)
+}
+
+func (c CollabConfig) Validate() error {
+	if err := validateStruct(c); err != nil {
+		return err
+	}
+	if c.LockTTL < minLockTTL {
+		return fmt.Errorf("LockTTL must be at least %v", minLockTTL)
+	}
+	return nil
+}
+
+func (c GRPCConfig) Validate() error {
+	if c.Addr == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(c.Addr); err != nil {
+		return fmt.Errorf("Addr must be host:port: %v", err)
+	}
+	return nil
+}
+
+func (c GraphQLConfig) Validate() error {
+	return validateStruct(c)
+}
+
 func validateStruct(s any) error {
 	v := reflect.ValueOf(s)
 	t := v.Type()
 
 	for i := 0; i < v.NumField(); i++ {
 		f := v.Field(i)
-		if f.Kind() == reflect.String {
+		switch f.Kind() {
+		case reflect.String:
 			if strings.TrimSpace(f.String()) == "" {
 				return fmt.Errorf("%s is required", t.Field(i).Name)
 			}
-		} else if f.IsZero() {
-			return fmt.Errorf("%s is required", t.Field(i).Name)
+		case reflect.Int, reflect.Int64:
+			// covers time.Duration, for which a negative value is as meaningless as zero
+			if f.Int() == 0 {
+				return fmt.Errorf("%s is required", t.Field(i).Name)
+			}
+			if f.Int() < 0 {
+				return fmt.Errorf("%s must be positive", t.Field(i).Name)
+			}
+		default:
+			if f.IsZero() {
+				return fmt.Errorf("%s is required", t.Field(i).Name)
+			}
 		}
 	}
 
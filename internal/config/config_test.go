@@ -1,6 +1,9 @@
 package config
 
-import "testing"
+import (
+	"testing"
+	"time"
+)
 
 func testValidateStruct[Struct testStruct](t *testing.T, s Struct, expectsError bool, errorWanted string) {
 	t.Helper()
@@ -23,6 +26,20 @@ func testValidateStruct[Struct testStruct](t *testing.T, s Struct, expectsError
 type testStruct interface {
 	DBConfig |
 		JWTConfig |
+		TracingConfig |
+		LogConfig |
+		RateLimitConfig |
+		CORSConfig |
+		SessionConfig |
+		MailConfig |
+		AuthConfig |
+		OIDCConfig |
+		PasswordConfig |
+		HTTPConfig |
+		EventsConfig |
+		CollabConfig |
+		GRPCConfig |
+		GraphQLConfig |
 		structWithInt
 	Validate() error
 }
@@ -47,11 +64,12 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"valid struct, no errors",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				Name:     "Lorem",
 				User:     "Ipsum",
 				Password: "Dolor",
 				Host:     "Et",
-				Port:     "Amet",
+				Port:     "5432",
 			},
 			false,
 			"",
@@ -59,10 +77,11 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"name missing",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				User:     "Ipsum",
 				Password: "Dolor",
 				Host:     "Et",
-				Port:     "Amet",
+				Port:     "5432",
 			},
 			true,
 			"Name is required",
@@ -70,10 +89,11 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"user missing",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				Name:     "Lorem",
 				Password: "Dolor",
 				Host:     "Et",
-				Port:     "Amet",
+				Port:     "5432",
 			},
 			true,
 			"User is required",
@@ -81,10 +101,11 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"password missing",
 			DBConfig{
-				Name: "Lorem",
-				User: "Ipsum",
-				Host: "Et",
-				Port: "Amet",
+				Driver: DBDriverPostgres,
+				Name:   "Lorem",
+				User:   "Ipsum",
+				Host:   "Et",
+				Port:   "Amet",
 			},
 			true,
 			"Password is required",
@@ -92,10 +113,11 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"host missing",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				Name:     "Lorem",
 				User:     "Ipsum",
 				Password: "Dolor",
-				Port:     "Amet",
+				Port:     "5432",
 			},
 			true,
 			"Host is required",
@@ -103,6 +125,7 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"port missing",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				Name:     "Lorem",
 				User:     "Ipsum",
 				Password: "Dolor",
@@ -114,15 +137,139 @@ func TestDBConfig_Validate(t *testing.T) {
 		{
 			"name is a whitespace",
 			DBConfig{
+				Driver:   DBDriverPostgres,
 				Name:     "   ",
 				User:     "Ipsum",
 				Password: "Dolor",
 				Host:     "Et",
-				Port:     "Amet",
+				Port:     "5432",
 			},
 			true,
 			"Name is required",
 		},
+		{
+			"sqlite only needs a path",
+			DBConfig{
+				Driver: DBDriverSQLite,
+				Path:   "task-manager.db",
+			},
+			false,
+			"",
+		},
+		{
+			"sqlite path missing",
+			DBConfig{
+				Driver: DBDriverSQLite,
+			},
+			true,
+			"Path is required for the sqlite driver",
+		},
+		{
+			"memory needs no connection settings",
+			DBConfig{
+				Driver: DBDriverMemory,
+			},
+			false,
+			"",
+		},
+		{
+			"port not a number",
+			DBConfig{
+				Driver:   DBDriverPostgres,
+				Name:     "Lorem",
+				User:     "Ipsum",
+				Password: "Dolor",
+				Host:     "Et",
+				Port:     "Amet",
+			},
+			true,
+			"Port must be a number between 1 and 65535",
+		},
+		{
+			"port out of range",
+			DBConfig{
+				Driver:   DBDriverPostgres,
+				Name:     "Lorem",
+				User:     "Ipsum",
+				Password: "Dolor",
+				Host:     "Et",
+				Port:     "70000",
+			},
+			true,
+			"Port must be a number between 1 and 65535",
+		},
+		{
+			"unknown ssl mode",
+			DBConfig{
+				Driver:   DBDriverPostgres,
+				Name:     "Lorem",
+				User:     "Ipsum",
+				Password: "Dolor",
+				Host:     "Et",
+				Port:     "5432",
+				SSLMode:  "prefer",
+			},
+			true,
+			"SSLMode must be one of disable, require, verify-ca, verify-full",
+		},
+		{
+			"replica without a port",
+			DBConfig{
+				Driver:       DBDriverPostgres,
+				Name:         "Lorem",
+				User:         "Ipsum",
+				Password:     "Dolor",
+				Host:         "Et",
+				Port:         "5432",
+				ReplicaHosts: []string{"replica"},
+			},
+			true,
+			"ReplicaHosts must be host:port pairs: address replica: missing port in address",
+		},
+		{
+			"replicas with sqlite",
+			DBConfig{
+				Driver:       DBDriverSQLite,
+				Path:         "tasks.db",
+				ReplicaHosts: []string{"replica:5432"},
+			},
+			true,
+			"ReplicaHosts require the postgres driver",
+		},
+		{
+			"more idle than open connections",
+			DBConfig{
+				Driver:       DBDriverSQLite,
+				Path:         "tasks.db",
+				MaxOpenConns: 5,
+				MaxIdleConns: 10,
+			},
+			true,
+			"MaxIdleConns must not be greater than MaxOpenConns",
+		},
+		{
+			"negative statement timeout",
+			DBConfig{
+				Driver:           DBDriverSQLite,
+				Path:             "tasks.db",
+				StatementTimeout: -time.Second,
+			},
+			true,
+			"timeouts and connection lifetimes must not be negative",
+		},
+		{
+			"unknown driver",
+			DBConfig{
+				Driver:   "mysql",
+				Name:     "Lorem",
+				User:     "Ipsum",
+				Password: "Dolor",
+				Host:     "Et",
+				Port:     "5432",
+			},
+			true,
+			"Driver must be one of postgres, sqlite, memory",
+		},
 	}
 
 	for _, tc := range tests {
@@ -168,6 +315,398 @@ func TestJWTConfig_Validate(t *testing.T) {
 	}
 }
 
+func TestTracingConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name          string
+		tracingStruct TracingConfig
+		expectsError  bool
+		errorWanted   string
+	}{
+		{
+			"exporter disabled",
+			TracingConfig{Exporter: TracingExporterNone, ServiceName: "task-manager"},
+			false,
+			"",
+		},
+		{
+			"otlp exporter with endpoint",
+			TracingConfig{Exporter: TracingExporterOTLP, Endpoint: "http://localhost:4318/v1/traces", ServiceName: "task-manager"},
+			false,
+			"",
+		},
+		{
+			"unknown exporter",
+			TracingConfig{Exporter: "jaeger", ServiceName: "task-manager"},
+			true,
+			"Exporter must be one of none, stdout, otlp",
+		},
+		{
+			"service name missing",
+			TracingConfig{Exporter: TracingExporterStdout},
+			true,
+			"ServiceName is required",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.tracingStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestLogConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		logStruct    LogConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{
+			"valid json config",
+			LogConfig{Level: "debug", Format: LogFormatJSON},
+			false,
+			"",
+		},
+		{
+			"level is case insensitive",
+			LogConfig{Level: "WARN", Format: LogFormatText},
+			false,
+			"",
+		},
+		{
+			"unknown level",
+			LogConfig{Level: "verbose", Format: LogFormatText},
+			true,
+			"Level must be one of debug, info, warn, error",
+		},
+		{
+			"unknown format",
+			LogConfig{Level: "info", Format: "xml"},
+			true,
+			"Format must be one of json, text",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.logStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestRateLimitConfig_Validate(t *testing.T) {
+	t.Parallel()
+	valid := RateLimitConfig{
+		Store:                RateLimitStoreMemory,
+		RegisterPolicy:       "10/1m",
+		LoginPolicy:          "10/1m",
+		LoginMFAPolicy:       "10/1m",
+		VerifyEmailPolicy:    "10/1m",
+		ForgotPasswordPolicy: "10/1m",
+		ResetPasswordPolicy:  "10/1m",
+		OIDCPolicy:           "10/1m",
+		APIPolicy:            "300/1m",
+		LockoutThreshold:     5,
+		LockoutBase:          time.Minute,
+		LockoutMax:           time.Hour,
+	}
+	withStore := valid
+	withStore.Store = "redis"
+	withoutThreshold := valid
+	withoutThreshold.LockoutThreshold = 0
+	withShortMax := valid
+	withShortMax.LockoutMax = time.Second
+	withNegativeBase := valid
+	withNegativeBase.LockoutBase = -time.Minute
+
+	var tests = []struct {
+		name         string
+		rlStruct     RateLimitConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"valid struct, no errors", valid, false, ""},
+		{"unknown store", withStore, true, "Store must be one of memory, postgres"},
+		{"lockout threshold missing", withoutThreshold, true, "LockoutThreshold is required"},
+		{"max lockout shorter than base", withShortMax, true, "LockoutMax must not be lower than LockoutBase"},
+		{"negative lockout base", withNegativeBase, true, "LockoutBase must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.rlStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestCORSConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		corsStruct   CORSConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"no origins", CORSConfig{}, false, ""},
+		{"origins with credentials", CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true}, false, ""},
+		{"wildcard without credentials", CORSConfig{AllowedOrigins: []string{"*"}}, false, ""},
+		{"wildcard with credentials", CORSConfig{AllowedOrigins: []string{"*"}, AllowCredentials: true}, true, "AllowedOrigins cannot contain * when AllowCredentials is set"},
+		{"negative max age", CORSConfig{MaxAge: -time.Second}, true, "MaxAge must not be negative"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.corsStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestSessionConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name          string
+		sessionStruct SessionConfig
+		expectsError  bool
+		errorWanted   string
+	}{
+		{"lax", SessionConfig{SameSite: SameSiteLax}, false, ""},
+		{"none with secure cookie", SessionConfig{SameSite: SameSiteNone, CookieSecure: true}, false, ""},
+		{"none without secure cookie", SessionConfig{SameSite: SameSiteNone}, true, "SameSite none requires CookieSecure"},
+		{"unknown same site", SessionConfig{SameSite: "loose"}, true, "SameSite must be one of lax, strict, none"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.sessionStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestMailConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		mailStruct   MailConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"file driver", MailConfig{Driver: MailDriverFile, From: "no-reply@localhost", Dir: "mail"}, false, ""},
+		{"smtp driver", MailConfig{Driver: MailDriverSMTP, From: "no-reply@localhost", SMTPHost: "smtp.local", SMTPPort: 25}, false, ""},
+		{"missing from", MailConfig{Driver: MailDriverFile, Dir: "mail"}, true, "From is required"},
+		{"smtp without host", MailConfig{Driver: MailDriverSMTP, From: "no-reply@localhost", SMTPPort: 25}, true, "SMTPHost is required for the smtp driver"},
+		{"file without dir", MailConfig{Driver: MailDriverFile, From: "no-reply@localhost"}, true, "Dir is required for the file driver"},
+		{"unknown driver", MailConfig{Driver: "pigeon", From: "no-reply@localhost"}, true, "Driver must be one of smtp, file"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.mailStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestAuthConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		authStruct   AuthConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"valid", AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost", DeletedUserTasks: DeletedUserTasksDelete, MFAIssuer: "task-manager"}, false, ""},
+		{"anonymize tasks", AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost", DeletedUserTasks: DeletedUserTasksAnonymize, MFAIssuer: "task-manager"}, false, ""},
+		{"missing verification ttl", AuthConfig{ResetTTL: time.Hour, AppURL: "http://localhost"}, true, "VerificationTTL must be positive"},
+		{"negative reset ttl", AuthConfig{VerificationTTL: time.Hour, ResetTTL: -time.Hour, AppURL: "http://localhost"}, true, "ResetTTL must be positive"},
+		{"missing app url", AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour}, true, "AppURL is required"},
+		{"missing mfa issuer", AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost", DeletedUserTasks: DeletedUserTasksDelete}, true, "MFAIssuer is required"},
+		{"unknown task policy", AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost", DeletedUserTasks: "keep"}, true, "DeletedUserTasks must be one of delete, anonymize"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.authStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestOIDCConfig_Validate(t *testing.T) {
+	t.Parallel()
+	valid := OIDCConfig{
+		Enabled:     true,
+		Issuer:      "https://idp.example.com",
+		ClientID:    "task-manager",
+		RedirectURL: "http://localhost:8000/auth/oidc/callback",
+		Scopes:      []string{"openid", "email"},
+	}
+	withoutIssuer := valid
+	withoutIssuer.Issuer = ""
+	withoutOpenID := valid
+	withoutOpenID.Scopes = []string{"email"}
+
+	var tests = []struct {
+		name         string
+		oidcStruct   OIDCConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"disabled", OIDCConfig{}, false, ""},
+		{"valid", valid, false, ""},
+		{"missing issuer", withoutIssuer, true, "Issuer is required"},
+		{"missing openid scope", withoutOpenID, true, "Scopes must contain openid"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.oidcStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestPasswordConfig_Validate(t *testing.T) {
+	t.Parallel()
+	valid := PasswordConfig{
+		Algorithm:         PasswordAlgorithmArgon2id,
+		BcryptCost:        12,
+		Argon2Memory:      19 * 1024,
+		Argon2Iterations:  2,
+		Argon2Parallelism: 1,
+	}
+	unknownAlgorithm := valid
+	unknownAlgorithm.Algorithm = "md5"
+	lowCost := valid
+	lowCost.BcryptCost = 6
+	lowMemory := valid
+	lowMemory.Argon2Parallelism = 4
+	lowMemory.Argon2Memory = 16
+	noIterations := valid
+	noIterations.Argon2Iterations = 0
+
+	var tests = []struct {
+		name           string
+		passwordStruct PasswordConfig
+		expectsError   bool
+		errorWanted    string
+	}{
+		{"valid", valid, false, ""},
+		{"unknown algorithm", unknownAlgorithm, true, "Algorithm must be one of argon2id, bcrypt"},
+		{"bcrypt cost too low", lowCost, true, "BcryptCost must be between 10 and 31"},
+		{"argon2 memory too low", lowMemory, true, "Argon2Memory must be at least 8 KiB per thread"},
+		{"argon2 without iterations", noIterations, true, "Argon2Iterations must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.passwordStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestHTTPConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		httpStruct   HTTPConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"valid struct, no errors", HTTPConfig{RequestTimeout: 30 * time.Second}, false, ""},
+		{"validating responses", HTTPConfig{RequestTimeout: 30 * time.Second, ValidateResponses: true}, false, ""},
+		{"request timeout missing", HTTPConfig{}, true, "RequestTimeout is required"},
+		{"negative request timeout", HTTPConfig{RequestTimeout: -time.Second}, true, "RequestTimeout must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.httpStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestEventsConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		eventsStruct EventsConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"valid struct, no errors", EventsConfig{Heartbeat: 15 * time.Second, Retention: 24 * time.Hour}, false, ""},
+		{"heartbeat missing", EventsConfig{Retention: 24 * time.Hour}, true, "Heartbeat is required"},
+		{"negative retention", EventsConfig{Heartbeat: 15 * time.Second, Retention: -time.Hour}, true, "Retention must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.eventsStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestCollabConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		collabStruct CollabConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"valid struct, no errors", CollabConfig{LockTTL: 30 * time.Second, PingInterval: 30 * time.Second, SendBuffer: 64}, false, ""},
+		{"send buffer missing", CollabConfig{LockTTL: 30 * time.Second, PingInterval: 30 * time.Second}, true, "SendBuffer is required"},
+		{"negative lock ttl", CollabConfig{LockTTL: -time.Second, PingInterval: 30 * time.Second, SendBuffer: 64}, true, "LockTTL must be positive"},
+		{"lock ttl too short", CollabConfig{LockTTL: time.Nanosecond, PingInterval: 30 * time.Second, SendBuffer: 64}, true, "LockTTL must be at least 2ms"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.collabStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestGRPCConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name         string
+		grpcStruct   GRPCConfig
+		expectsError bool
+		errorWanted  string
+	}{
+		{"disabled, no errors", GRPCConfig{}, false, ""},
+		{"port only, no errors", GRPCConfig{Addr: ":9000"}, false, ""},
+		{"host and port, no errors", GRPCConfig{Addr: "127.0.0.1:9000"}, false, ""},
+		{"missing port", GRPCConfig{Addr: "localhost"}, true, "Addr must be host:port: address localhost: missing port in address"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.grpcStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
+func TestGraphQLConfig_Validate(t *testing.T) {
+	t.Parallel()
+	var tests = []struct {
+		name          string
+		graphqlStruct GraphQLConfig
+		expectsError  bool
+		errorWanted   string
+	}{
+		{"valid struct, no errors", GraphQLConfig{MaxDepth: 8, MaxComplexity: 1000}, false, ""},
+		{"max depth missing", GraphQLConfig{MaxComplexity: 1000}, true, "MaxDepth is required"},
+		{"negative max complexity", GraphQLConfig{MaxDepth: 8, MaxComplexity: -1}, true, "MaxComplexity must be positive"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			t.Parallel()
+			testValidateStruct(t, tc.graphqlStruct, tc.expectsError, tc.errorWanted)
+		})
+	}
+}
+
 func TestValidateZeroValue(t *testing.T) {
 	t.Parallel()
 	var tests = []struct {
@@ -202,6 +741,15 @@ func TestValidateZeroValue(t *testing.T) {
 			true,
 			"count is required",
 		},
+		{
+			"negative value",
+			structWithInt{
+				name:  "Lorem",
+				count: -1,
+			},
+			true,
+			"count must be positive",
+		},
 	}
 
 	for _, tc := range tests {
@@ -1,36 +1,211 @@
 package config
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/BurntSushi/toml"
 	"github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
-var fatalf = log.Fatalf
+var fatalf = func(format string, args ...any) {
+	slog.Error(fmt.Sprintf(format, args...))
+	os.Exit(1)
+}
+
+// Sources of a Setting, from the lowest to the highest priority.
+const (
+	SourceDefault = "default"
+	SourceFile    = "file"
+	SourceEnv     = "env"
+	SourceFlag    = "flag"
+)
 
+// secretKeys are redacted when the effective configuration is printed.
+var secretKeys = []string{"DB_PASSWORD", "JWT_SECRET", "SMTP_PASSWORD", "OIDC_CLIENT_SECRET"}
+
+// Options adds the layers that do not come from the environment. File is a YAML or TOML file,
+// told apart by extension, and defaults to the CONFIG_FILE variable. Overrides are KEY=value
+// pairs from the command line and take precedence over everything else.
+type Options struct {
+	File      string
+	Overrides map[string]string
+}
+
+// Setting is one value of the effective configuration and the layer it was taken from.
+type Setting struct {
+	Key    string
+	Value  string
+	Source string
+}
+
+// Redacted hides the value of secrets, keeping whether one is set visible.
+func (s Setting) Redacted() Setting {
+	if s.Value != "" && slices.Contains(secretKeys, s.Key) {
+		s.Value = "[redacted]"
+	}
+	return s
+}
+
+// Load reads the configuration from the environment, and the file named by CONFIG_FILE if any.
+// A .env file in the working directory is loaded into the environment when it exists.
 func Load() Config {
-	err := godotenv.Load()
-	if err != nil {
+	cfg, _ := LoadWith(Options{})
+	return cfg
+}
+
+// LoadWith resolves every setting from, in increasing priority, its default, the config file,
+// the environment and o.Overrides. Every key can also be given as KEY_FILE naming a file that
+// holds the value, which is how mounted secrets are read. Like Load it exits on invalid values.
+func LoadWith(o Options) (Config, []Setting) {
+	if err := godotenv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
 		fatalf("error while loading env file: %s", err)
 	}
 
+	l := &loader{}
+	l.layers = append(l.layers, layer{source: SourceFlag, values: normalizeKeys(o.Overrides)})
+	l.layers = append(l.layers, layer{source: SourceEnv, lookup: os.LookupEnv})
+
+	if o.File == "" {
+		o.File = os.Getenv("CONFIG_FILE")
+	}
+	if o.File != "" {
+		values, err := readFile(o.File)
+		if err != nil {
+			fatalf("error while loading config file: %s", err)
+		}
+		l.layers = append(l.layers, layer{source: SourceFile, values: values})
+	}
+
+	// the auth routes default to one shared policy, each of them can override it
+	authPolicy := l.str("RATE_LIMIT_AUTH", "10/1m")
+
 	cfg := Config{
 		DB: DBConfig{
-			Name:     os.Getenv("DB_NAME"),
-			User:     os.Getenv("DB_USERNAME"),
-			Password: os.Getenv("DB_PASSWORD"),
-			Host:     os.Getenv("DB_HOST"),
-			Port:     os.Getenv("DB_PORT"),
+			Driver:   l.str("DB_DRIVER", DBDriverPostgres),
+			Name:     l.str("DB_NAME", ""),
+			User:     l.str("DB_USERNAME", ""),
+			Password: l.str("DB_PASSWORD", ""),
+			Host:     l.str("DB_HOST", ""),
+			Port:     l.str("DB_PORT", ""),
+			Path:     l.str("DB_SQLITE_PATH", "task-manager.db"),
+
+			SSLMode:          l.str("DB_SSL_MODE", SSLModeDisable),
+			SSLRootCert:      l.str("DB_SSL_ROOT_CERT", ""),
+			ConnectTimeout:   l.duration("DB_CONNECT_TIMEOUT", 5*time.Second),
+			StatementTimeout: l.duration("DB_STATEMENT_TIMEOUT", 0),
+			MaxOpenConns:     l.int("DB_MAX_OPEN_CONNS", 25),
+			MaxIdleConns:     l.int("DB_MAX_IDLE_CONNS", 10),
+			ConnMaxLifetime:  l.duration("DB_CONN_MAX_LIFETIME", 30*time.Minute),
+			ConnMaxIdleTime:  l.duration("DB_CONN_MAX_IDLE_TIME", 5*time.Minute),
+			ReplicaHosts:     l.list("DB_REPLICA_HOSTS"),
 		},
 		JWT: JWTConfig{
-			Secret: os.Getenv("JWT_SECRET"),
+			Secret: l.str("JWT_SECRET", ""),
+		},
+		Tracing: TracingConfig{
+			Exporter:    l.str("TRACING_EXPORTER", TracingExporterNone),
+			Endpoint:    l.str("TRACING_OTLP_ENDPOINT", ""),
+			ServiceName: l.str("TRACING_SERVICE_NAME", "task-manager"),
+		},
+		Log: LogConfig{
+			Level:  l.str("LOG_LEVEL", "info"),
+			Format: l.str("LOG_FORMAT", LogFormatText),
+		},
+		RateLimit: RateLimitConfig{
+			Store:                l.str("RATE_LIMIT_STORE", RateLimitStoreMemory),
+			RegisterPolicy:       l.str("RATE_LIMIT_REGISTER", authPolicy),
+			LoginPolicy:          l.str("RATE_LIMIT_LOGIN", authPolicy),
+			LoginMFAPolicy:       l.str("RATE_LIMIT_LOGIN_MFA", authPolicy),
+			VerifyEmailPolicy:    l.str("RATE_LIMIT_VERIFY_EMAIL", authPolicy),
+			ForgotPasswordPolicy: l.str("RATE_LIMIT_PASSWORD_FORGOT", authPolicy),
+			ResetPasswordPolicy:  l.str("RATE_LIMIT_PASSWORD_RESET", authPolicy),
+			OIDCPolicy:           l.str("RATE_LIMIT_OIDC", authPolicy),
+			APIPolicy:            l.str("RATE_LIMIT_API", "300/1m"),
+			LockoutThreshold:     l.int("LOGIN_LOCKOUT_THRESHOLD", 5),
+			LockoutBase:          l.duration("LOGIN_LOCKOUT_BASE", time.Minute),
+			LockoutMax:           l.duration("LOGIN_LOCKOUT_MAX", time.Hour),
+		},
+		CORS: CORSConfig{
+			AllowedOrigins:   l.list("CORS_ALLOWED_ORIGINS"),
+			AllowCredentials: l.bool("CORS_ALLOW_CREDENTIALS", false),
+			MaxAge:           l.duration("CORS_MAX_AGE", 10*time.Minute),
+		},
+		Session: SessionConfig{
+			CookieEnabled: l.bool("SESSION_COOKIE_ENABLED", false),
+			CookieDomain:  l.str("SESSION_COOKIE_DOMAIN", ""),
+			CookieSecure:  l.bool("SESSION_COOKIE_SECURE", true),
+			SameSite:      l.str("SESSION_COOKIE_SAMESITE", SameSiteLax),
+			HSTSMaxAge:    l.duration("HSTS_MAX_AGE", 0),
+		},
+		Mail: MailConfig{
+			Driver:       l.str("MAIL_DRIVER", MailDriverFile),
+			From:         l.str("MAIL_FROM", "no-reply@localhost"),
+			SMTPHost:     l.str("SMTP_HOST", ""),
+			SMTPPort:     l.int("SMTP_PORT", 587),
+			SMTPUser:     l.str("SMTP_USERNAME", ""),
+			SMTPPassword: l.str("SMTP_PASSWORD", ""),
+			Dir:          l.str("MAIL_FILE_DIR", "mail"),
+		},
+		Auth: AuthConfig{
+			RequireVerifiedEmail: l.bool("REQUIRE_VERIFIED_EMAIL", false),
+			VerificationTTL:      l.duration("EMAIL_VERIFICATION_TTL", 24*time.Hour),
+			ResetTTL:             l.duration("PASSWORD_RESET_TTL", time.Hour),
+			AppURL:               l.str("APP_URL", "http://localhost:8000"),
+			DeletedUserTasks:     l.str("ACCOUNT_DELETE_TASKS", DeletedUserTasksDelete),
+			MFAIssuer:            l.str("MFA_ISSUER", "task-manager"),
+		},
+		OIDC: OIDCConfig{
+			Enabled:       l.bool("OIDC_ENABLED", false),
+			Issuer:        l.str("OIDC_ISSUER", ""),
+			ClientID:      l.str("OIDC_CLIENT_ID", ""),
+			ClientSecret:  l.str("OIDC_CLIENT_SECRET", ""),
+			RedirectURL:   l.str("OIDC_REDIRECT_URL", ""),
+			Scopes:        l.list("OIDC_SCOPES", "openid", "email", "profile"),
+			AutoProvision: l.bool("OIDC_AUTO_PROVISION", true),
+		},
+		Password: PasswordConfig{
+			Algorithm:         l.str("PASSWORD_HASH_ALGORITHM", PasswordAlgorithmArgon2id),
+			BcryptCost:        l.int("BCRYPT_COST", 12),
+			Argon2Memory:      l.int("ARGON2_MEMORY_KIB", 19*1024),
+			Argon2Iterations:  l.int("ARGON2_ITERATIONS", 2),
+			Argon2Parallelism: l.int("ARGON2_PARALLELISM", 1),
+		},
+		HTTP: HTTPConfig{
+			RequestTimeout:    l.duration("HTTP_REQUEST_TIMEOUT", 30*time.Second),
+			ValidateResponses: l.bool("HTTP_VALIDATE_RESPONSES", false),
+		},
+		Events: EventsConfig{
+			Heartbeat: l.duration("EVENTS_HEARTBEAT", 15*time.Second),
+			Retention: l.duration("EVENTS_RETENTION", 24*time.Hour),
+		},
+		Collab: CollabConfig{
+			LockTTL:      l.duration("COLLAB_LOCK_TTL", 30*time.Second),
+			PingInterval: l.duration("COLLAB_PING_INTERVAL", 30*time.Second),
+			SendBuffer:   l.int("COLLAB_SEND_BUFFER", 64),
+		},
+		GRPC: GRPCConfig{
+			Addr: l.str("GRPC_ADDR", ""),
+		},
+		GraphQL: GraphQLConfig{
+			MaxDepth:      l.int("GRAPHQL_MAX_DEPTH", 8),
+			MaxComplexity: l.int("GRAPHQL_MAX_COMPLEXITY", 1000),
 		},
 	}
 
 	validate(cfg)
+	l.checkUnknown()
 
-	return cfg
+	return cfg, l.settings
 }
 
 func validate(c Config) {
@@ -43,4 +218,271 @@ func validate(c Config) {
 	if err != nil {
 		fatalf("JWTConfig validation error: %s", err)
 	}
+
+	err = c.Tracing.Validate()
+	if err != nil {
+		fatalf("TracingConfig validation error: %s", err)
+	}
+
+	err = c.Log.Validate()
+	if err != nil {
+		fatalf("LogConfig validation error: %s", err)
+	}
+
+	err = c.RateLimit.Validate()
+	if err != nil {
+		fatalf("RateLimitConfig validation error: %s", err)
+	}
+
+	err = c.CORS.Validate()
+	if err != nil {
+		fatalf("CORSConfig validation error: %s", err)
+	}
+
+	err = c.Session.Validate()
+	if err != nil {
+		fatalf("SessionConfig validation error: %s", err)
+	}
+
+	err = c.Mail.Validate()
+	if err != nil {
+		fatalf("MailConfig validation error: %s", err)
+	}
+
+	err = c.Auth.Validate()
+	if err != nil {
+		fatalf("AuthConfig validation error: %s", err)
+	}
+
+	err = c.OIDC.Validate()
+	if err != nil {
+		fatalf("OIDCConfig validation error: %s", err)
+	}
+
+	err = c.Password.Validate()
+	if err != nil {
+		fatalf("PasswordConfig validation error: %s", err)
+	}
+
+	err = c.HTTP.Validate()
+	if err != nil {
+		fatalf("HTTPConfig validation error: %s", err)
+	}
+
+	err = c.Events.Validate()
+	if err != nil {
+		fatalf("EventsConfig validation error: %s", err)
+	}
+
+	err = c.Collab.Validate()
+	if err != nil {
+		fatalf("CollabConfig validation error: %s", err)
+	}
+
+	err = c.GRPC.Validate()
+	if err != nil {
+		fatalf("GRPCConfig validation error: %s", err)
+	}
+
+	err = c.GraphQL.Validate()
+	if err != nil {
+		fatalf("GraphQLConfig validation error: %s", err)
+	}
+}
+
+type layer struct {
+	source string
+	values map[string]string
+	lookup func(key string) (string, bool)
+}
+
+func (y layer) get(key string) (string, bool) {
+	if y.lookup != nil {
+		return y.lookup(key)
+	}
+	v, ok := y.values[key]
+	return v, ok
+}
+
+type loader struct {
+	layers   []layer
+	settings []Setting
+}
+
+// value returns the first non-empty value of key, or of key_FILE read from disk, walking the
+// layers from the highest priority down, and records where it came from.
+func (l *loader) value(key, fallback string) (string, string) {
+	for _, y := range l.layers {
+		v, _ := y.get(key)
+		path, _ := y.get(key + "_FILE")
+		if v != "" && path != "" {
+			fatalf("only one of %s and %s_FILE can be set", key, key)
+		}
+		source := y.source
+		if path != "" {
+			b, err := os.ReadFile(path)
+			if err != nil {
+				fatalf("%s_FILE: %s", key, err)
+			}
+			v = strings.TrimRight(string(b), "\r\n")
+			source = fmt.Sprintf("%s (%s_FILE)", y.source, key)
+		}
+		if v != "" {
+			l.settings = append(l.settings, Setting{Key: key, Value: v, Source: source})
+			return v, source
+		}
+	}
+
+	l.settings = append(l.settings, Setting{Key: key, Value: fallback, Source: SourceDefault})
+	return "", SourceDefault
+}
+
+func (l *loader) str(key, fallback string) string {
+	if v, _ := l.value(key, fallback); v != "" {
+		return v
+	}
+	return fallback
+}
+
+func (l *loader) int(key string, fallback int) int {
+	v, source := l.value(key, strconv.Itoa(fallback))
+	if v == "" {
+		return fallback
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		fatalf("%s from %s must be an integer: %s", key, source, err)
+	}
+	return i
+}
+
+func (l *loader) bool(key string, fallback bool) bool {
+	v, source := l.value(key, strconv.FormatBool(fallback))
+	if v == "" {
+		return fallback
+	}
+	b, err := strconv.ParseBool(v)
+	if err != nil {
+		fatalf("%s from %s must be a boolean: %s", key, source, err)
+	}
+	return b
+}
+
+// list reads a comma separated list, skipping empty entries; fallback is returned when the list
+// is empty.
+func (l *loader) list(key string, fallback ...string) []string {
+	v, _ := l.value(key, strings.Join(fallback, ","))
+	var list []string
+	for _, e := range strings.Split(v, ",") {
+		if e = strings.TrimSpace(e); e != "" {
+			list = append(list, e)
+		}
+	}
+	if len(list) == 0 {
+		return fallback
+	}
+	return list
+}
+
+func (l *loader) duration(key string, fallback time.Duration) time.Duration {
+	v, source := l.value(key, fallback.String())
+	if v == "" {
+		return fallback
+	}
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fatalf("%s from %s must be a duration: %s", key, source, err)
+	}
+	return d
+}
+
+// checkUnknown rejects keys given in the config file or as overrides that no setting reads,
+// which are most likely typos.
+func (l *loader) checkUnknown() {
+	known := make(map[string]bool, 2*len(l.settings))
+	for _, s := range l.settings {
+		known[s.Key] = true
+		known[s.Key+"_FILE"] = true
+	}
+	for _, y := range l.layers {
+		var unknown []string
+		for k := range y.values {
+			if !known[k] {
+				unknown = append(unknown, k)
+			}
+		}
+		if len(unknown) > 0 {
+			slices.Sort(unknown)
+			fatalf("unknown settings from %s: %s", y.source, strings.Join(unknown, ", "))
+		}
+	}
+}
+
+// readFile flattens a YAML or TOML document into the keys used by the environment: nested
+// tables are joined with underscores, so db: {name: x} sets DB_NAME, and lists are joined with
+// commas.
+func readFile(path string) (map[string]string, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	doc := map[string]any{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		err = yaml.Unmarshal(b, &doc)
+	case ".toml":
+		err = toml.Unmarshal(b, &doc)
+	default:
+		return nil, fmt.Errorf("%s: unsupported format, use .yaml, .yml or .toml", path)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+
+	values := map[string]string{}
+	if err := flatten(values, "", doc); err != nil {
+		return nil, fmt.Errorf("%s: %v", path, err)
+	}
+	return values, nil
+}
+
+func flatten(dst map[string]string, prefix string, v any) error {
+	switch v := v.(type) {
+	case map[string]any:
+		for k, e := range v {
+			if err := flatten(dst, joinKey(prefix, k), e); err != nil {
+				return err
+			}
+		}
+	case []any:
+		list := make([]string, 0, len(v))
+		for _, e := range v {
+			list = append(list, fmt.Sprint(e))
+		}
+		dst[prefix] = strings.Join(list, ",")
+	case nil:
+	default:
+		if prefix == "" {
+			return fmt.Errorf("expected a table of settings")
+		}
+		dst[prefix] = fmt.Sprint(v)
+	}
+	return nil
+}
+
+func joinKey(prefix, k string) string {
+	k = strings.ToUpper(strings.NewReplacer("-", "_", ".", "_").Replace(k))
+	if prefix == "" {
+		return k
+	}
+	return prefix + "_" + k
+}
+
+func normalizeKeys(m map[string]string) map[string]string {
+	out := make(map[string]string, len(m))
+	for k, v := range m {
+		out[joinKey("", k)] = v
+	}
+	return out
 }
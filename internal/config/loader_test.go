@@ -6,18 +6,88 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
 
 func setupTests(t *testing.T) {
 	t.Helper()
+	_ = os.Unsetenv("CONFIG_FILE")
+	_ = os.Unsetenv("DB_PASSWORD_FILE")
+	_ = os.Unsetenv("JWT_SECRET_FILE")
+	_ = os.Unsetenv("DB_DRIVER")
+	_ = os.Unsetenv("DB_SQLITE_PATH")
 	_ = os.Unsetenv("DB_NAME")
 	_ = os.Unsetenv("DB_USERNAME")
 	_ = os.Unsetenv("DB_PASSWORD")
 	_ = os.Unsetenv("DB_HOST")
 	_ = os.Unsetenv("DB_PORT")
+	_ = os.Unsetenv("DB_SSL_MODE")
+	_ = os.Unsetenv("DB_SSL_ROOT_CERT")
+	_ = os.Unsetenv("DB_CONNECT_TIMEOUT")
+	_ = os.Unsetenv("DB_STATEMENT_TIMEOUT")
+	_ = os.Unsetenv("DB_MAX_OPEN_CONNS")
+	_ = os.Unsetenv("DB_MAX_IDLE_CONNS")
+	_ = os.Unsetenv("DB_CONN_MAX_LIFETIME")
+	_ = os.Unsetenv("DB_CONN_MAX_IDLE_TIME")
+	_ = os.Unsetenv("DB_REPLICA_HOSTS")
 	_ = os.Unsetenv("JWT_SECRET")
+	_ = os.Unsetenv("TRACING_EXPORTER")
+	_ = os.Unsetenv("TRACING_OTLP_ENDPOINT")
+	_ = os.Unsetenv("TRACING_SERVICE_NAME")
+	_ = os.Unsetenv("LOG_LEVEL")
+	_ = os.Unsetenv("LOG_FORMAT")
+	_ = os.Unsetenv("RATE_LIMIT_STORE")
+	_ = os.Unsetenv("RATE_LIMIT_AUTH")
+	_ = os.Unsetenv("RATE_LIMIT_LOGIN")
+	_ = os.Unsetenv("RATE_LIMIT_API")
+	_ = os.Unsetenv("LOGIN_LOCKOUT_THRESHOLD")
+	_ = os.Unsetenv("LOGIN_LOCKOUT_BASE")
+	_ = os.Unsetenv("LOGIN_LOCKOUT_MAX")
+	_ = os.Unsetenv("CORS_ALLOWED_ORIGINS")
+	_ = os.Unsetenv("CORS_ALLOW_CREDENTIALS")
+	_ = os.Unsetenv("CORS_MAX_AGE")
+	_ = os.Unsetenv("SESSION_COOKIE_ENABLED")
+	_ = os.Unsetenv("SESSION_COOKIE_DOMAIN")
+	_ = os.Unsetenv("SESSION_COOKIE_SECURE")
+	_ = os.Unsetenv("SESSION_COOKIE_SAMESITE")
+	_ = os.Unsetenv("HSTS_MAX_AGE")
+	_ = os.Unsetenv("MAIL_DRIVER")
+	_ = os.Unsetenv("MAIL_FROM")
+	_ = os.Unsetenv("SMTP_HOST")
+	_ = os.Unsetenv("SMTP_PORT")
+	_ = os.Unsetenv("SMTP_USERNAME")
+	_ = os.Unsetenv("SMTP_PASSWORD")
+	_ = os.Unsetenv("MAIL_FILE_DIR")
+	_ = os.Unsetenv("REQUIRE_VERIFIED_EMAIL")
+	_ = os.Unsetenv("EMAIL_VERIFICATION_TTL")
+	_ = os.Unsetenv("PASSWORD_RESET_TTL")
+	_ = os.Unsetenv("APP_URL")
+	_ = os.Unsetenv("ACCOUNT_DELETE_TASKS")
+	_ = os.Unsetenv("MFA_ISSUER")
+	_ = os.Unsetenv("OIDC_ENABLED")
+	_ = os.Unsetenv("OIDC_ISSUER")
+	_ = os.Unsetenv("OIDC_CLIENT_ID")
+	_ = os.Unsetenv("OIDC_CLIENT_SECRET")
+	_ = os.Unsetenv("OIDC_REDIRECT_URL")
+	_ = os.Unsetenv("OIDC_SCOPES")
+	_ = os.Unsetenv("OIDC_AUTO_PROVISION")
+	_ = os.Unsetenv("PASSWORD_HASH_ALGORITHM")
+	_ = os.Unsetenv("BCRYPT_COST")
+	_ = os.Unsetenv("ARGON2_MEMORY_KIB")
+	_ = os.Unsetenv("ARGON2_ITERATIONS")
+	_ = os.Unsetenv("ARGON2_PARALLELISM")
+	_ = os.Unsetenv("HTTP_REQUEST_TIMEOUT")
+	_ = os.Unsetenv("HTTP_VALIDATE_RESPONSES")
+	_ = os.Unsetenv("EVENTS_HEARTBEAT")
+	_ = os.Unsetenv("EVENTS_RETENTION")
+	_ = os.Unsetenv("COLLAB_LOCK_TTL")
+	_ = os.Unsetenv("COLLAB_PING_INTERVAL")
+	_ = os.Unsetenv("COLLAB_SEND_BUFFER")
+	_ = os.Unsetenv("GRPC_ADDR")
+	_ = os.Unsetenv("GRAPHQL_MAX_DEPTH")
+	_ = os.Unsetenv("GRAPHQL_MAX_COMPLEXITY")
 }
 
 type mockSetup struct {
@@ -62,15 +132,93 @@ func TestLoad(t *testing.T) {
 			}},
 			Config{
 				DB: DBConfig{
+					Driver:   DBDriverPostgres,
 					Name:     "test",
 					User:     "testingUser",
 					Password: "secretPassword",
 					Host:     "localhost",
 					Port:     "5432",
+					Path:     "task-manager.db",
+
+					SSLMode:         SSLModeDisable,
+					ConnectTimeout:  5 * time.Second,
+					MaxOpenConns:    25,
+					MaxIdleConns:    10,
+					ConnMaxLifetime: 30 * time.Minute,
+					ConnMaxIdleTime: 5 * time.Minute,
 				},
 				JWT: JWTConfig{
 					Secret: "secret-key-for-testing",
 				},
+				Tracing: TracingConfig{
+					Exporter:    TracingExporterNone,
+					ServiceName: "task-manager",
+				},
+				Log: LogConfig{
+					Level:  "info",
+					Format: LogFormatText,
+				},
+				RateLimit: RateLimitConfig{
+					Store:                RateLimitStoreMemory,
+					RegisterPolicy:       "10/1m",
+					LoginPolicy:          "10/1m",
+					LoginMFAPolicy:       "10/1m",
+					VerifyEmailPolicy:    "10/1m",
+					ForgotPasswordPolicy: "10/1m",
+					ResetPasswordPolicy:  "10/1m",
+					OIDCPolicy:           "10/1m",
+					APIPolicy:            "300/1m",
+					LockoutThreshold:     5,
+					LockoutBase:          time.Minute,
+					LockoutMax:           time.Hour,
+				},
+				CORS: CORSConfig{
+					MaxAge: 10 * time.Minute,
+				},
+				Session: SessionConfig{
+					CookieSecure: true,
+					SameSite:     SameSiteLax,
+				},
+				Mail: MailConfig{
+					Driver:   MailDriverFile,
+					From:     "no-reply@localhost",
+					SMTPPort: 587,
+					Dir:      "mail",
+				},
+				Auth: AuthConfig{
+					VerificationTTL:  24 * time.Hour,
+					ResetTTL:         time.Hour,
+					AppURL:           "http://localhost:8000",
+					DeletedUserTasks: DeletedUserTasksDelete,
+					MFAIssuer:        "task-manager",
+				},
+				OIDC: OIDCConfig{
+					Scopes:        []string{"openid", "email", "profile"},
+					AutoProvision: true,
+				},
+				Password: PasswordConfig{
+					Algorithm:         PasswordAlgorithmArgon2id,
+					BcryptCost:        12,
+					Argon2Memory:      19 * 1024,
+					Argon2Iterations:  2,
+					Argon2Parallelism: 1,
+				},
+				HTTP: HTTPConfig{
+					RequestTimeout: 30 * time.Second,
+				},
+				Events: EventsConfig{
+					Heartbeat: 15 * time.Second,
+					Retention: 24 * time.Hour,
+				},
+				Collab: CollabConfig{
+					LockTTL:      30 * time.Second,
+					PingInterval: 30 * time.Second,
+					SendBuffer:   64,
+				},
+				GraphQL: GraphQLConfig{
+					MaxDepth:      8,
+					MaxComplexity: 1000,
+				},
 			},
 			false,
 		},
@@ -99,15 +247,93 @@ func TestLoad(t *testing.T) {
 			}},
 			Config{
 				DB: DBConfig{
+					Driver:   DBDriverPostgres,
 					Name:     "test",
 					User:     "testingUser",
 					Password: "secretPassword",
 					Host:     "localhost",
 					Port:     "5432",
+					Path:     "task-manager.db",
+
+					SSLMode:         SSLModeDisable,
+					ConnectTimeout:  5 * time.Second,
+					MaxOpenConns:    25,
+					MaxIdleConns:    10,
+					ConnMaxLifetime: 30 * time.Minute,
+					ConnMaxIdleTime: 5 * time.Minute,
 				},
 				JWT: JWTConfig{
 					Secret: "secret-key-for-testing",
 				},
+				Tracing: TracingConfig{
+					Exporter:    TracingExporterNone,
+					ServiceName: "task-manager",
+				},
+				Log: LogConfig{
+					Level:  "info",
+					Format: LogFormatText,
+				},
+				RateLimit: RateLimitConfig{
+					Store:                RateLimitStoreMemory,
+					RegisterPolicy:       "10/1m",
+					LoginPolicy:          "10/1m",
+					LoginMFAPolicy:       "10/1m",
+					VerifyEmailPolicy:    "10/1m",
+					ForgotPasswordPolicy: "10/1m",
+					ResetPasswordPolicy:  "10/1m",
+					OIDCPolicy:           "10/1m",
+					APIPolicy:            "300/1m",
+					LockoutThreshold:     5,
+					LockoutBase:          time.Minute,
+					LockoutMax:           time.Hour,
+				},
+				CORS: CORSConfig{
+					MaxAge: 10 * time.Minute,
+				},
+				Session: SessionConfig{
+					CookieSecure: true,
+					SameSite:     SameSiteLax,
+				},
+				Mail: MailConfig{
+					Driver:   MailDriverFile,
+					From:     "no-reply@localhost",
+					SMTPPort: 587,
+					Dir:      "mail",
+				},
+				Auth: AuthConfig{
+					VerificationTTL:  24 * time.Hour,
+					ResetTTL:         time.Hour,
+					AppURL:           "http://localhost:8000",
+					DeletedUserTasks: DeletedUserTasksDelete,
+					MFAIssuer:        "task-manager",
+				},
+				OIDC: OIDCConfig{
+					Scopes:        []string{"openid", "email", "profile"},
+					AutoProvision: true,
+				},
+				Password: PasswordConfig{
+					Algorithm:         PasswordAlgorithmArgon2id,
+					BcryptCost:        12,
+					Argon2Memory:      19 * 1024,
+					Argon2Iterations:  2,
+					Argon2Parallelism: 1,
+				},
+				HTTP: HTTPConfig{
+					RequestTimeout: 30 * time.Second,
+				},
+				Events: EventsConfig{
+					Heartbeat: 15 * time.Second,
+					Retention: 24 * time.Hour,
+				},
+				Collab: CollabConfig{
+					LockTTL:      30 * time.Second,
+					PingInterval: 30 * time.Second,
+					SendBuffer:   64,
+				},
+				GraphQL: GraphQLConfig{
+					MaxDepth:      8,
+					MaxComplexity: 1000,
+				},
 			},
 			false,
 		},
@@ -241,3 +467,192 @@ func TestLoad(t *testing.T) {
 		})
 	}
 }
+
+func TestLoadWith(t *testing.T) {
+	var tests = []struct {
+		name       string
+		env        map[string]string
+		files      map[string]string
+		opts       func(dir string) Options
+		check      func(t *testing.T, cfg Config, settings []Setting)
+		shouldFail bool
+	}{
+		{
+			name: "environment only, without an env file",
+			env:  map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET": "secret-key-for-testing"},
+			check: func(t *testing.T, cfg Config, _ []Setting) {
+				if cfg.DB.Driver != DBDriverMemory || cfg.JWT.Secret != "secret-key-for-testing" {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name: "auth routes default to the shared policy",
+			env:  map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET": "secret", "RATE_LIMIT_AUTH": "20/1m", "RATE_LIMIT_LOGIN": "5/1m"},
+			check: func(t *testing.T, cfg Config, _ []Setting) {
+				if cfg.RateLimit.LoginPolicy != "5/1m" || cfg.RateLimit.VerifyEmailPolicy != "20/1m" {
+					t.Errorf("unexpected auth policies %+v", cfg.RateLimit)
+				}
+			},
+		},
+		{
+			name: "nested yaml file",
+			files: map[string]string{"config.yaml": `
+db:
+  driver: sqlite
+  sqlite_path: /data/tasks.db
+jwt:
+  secret: secret-key-for-testing
+cors:
+  allowed_origins: [https://a.example.com, https://b.example.com]
+  max_age: 1h
+login_lockout:
+  threshold: 3
+`},
+			opts: func(dir string) Options { return Options{File: filepath.Join(dir, "config.yaml")} },
+			check: func(t *testing.T, cfg Config, _ []Setting) {
+				if cfg.DB.Driver != DBDriverSQLite || cfg.DB.Path != "/data/tasks.db" {
+					t.Errorf("unexpected db config %+v", cfg.DB)
+				}
+				if diff := cmp.Diff([]string{"https://a.example.com", "https://b.example.com"}, cfg.CORS.AllowedOrigins); diff != "" {
+					t.Errorf("unexpected origins <-want,+got>\n%s", diff)
+				}
+				if cfg.CORS.MaxAge != time.Hour || cfg.RateLimit.LockoutThreshold != 3 {
+					t.Errorf("typed values not parsed: %+v %+v", cfg.CORS, cfg.RateLimit)
+				}
+			},
+		},
+		{
+			name: "toml file named by CONFIG_FILE",
+			files: map[string]string{"config.toml": `
+[db]
+driver = "memory"
+
+[jwt]
+secret = "secret-key-for-testing"
+
+[smtp]
+port = 2525
+`},
+			env: map[string]string{"CONFIG_FILE": "config.toml"},
+			check: func(t *testing.T, cfg Config, _ []Setting) {
+				if cfg.DB.Driver != DBDriverMemory || cfg.Mail.SMTPPort != 2525 {
+					t.Errorf("unexpected config %+v", cfg)
+				}
+			},
+		},
+		{
+			name:  "environment overrides the file and flags override both",
+			files: map[string]string{"config.yaml": "db:\n  driver: memory\njwt:\n  secret: from-the-file\nlog:\n  level: debug\n"},
+			env:   map[string]string{"JWT_SECRET": "from-the-environment", "LOG_LEVEL": "warn"},
+			opts: func(dir string) Options {
+				return Options{File: filepath.Join(dir, "config.yaml"), Overrides: map[string]string{"log.level": "error"}}
+			},
+			check: func(t *testing.T, cfg Config, settings []Setting) {
+				if cfg.JWT.Secret != "from-the-environment" || cfg.Log.Level != "error" {
+					t.Errorf("unexpected precedence: %+v %+v", cfg.JWT, cfg.Log)
+				}
+				sources := map[string]string{}
+				for _, s := range settings {
+					sources[s.Key] = s.Source
+				}
+				want := map[string]string{"DB_DRIVER": SourceFile, "JWT_SECRET": SourceEnv, "LOG_LEVEL": SourceFlag, "LOG_FORMAT": SourceDefault}
+				for k, v := range want {
+					if sources[k] != v {
+						t.Errorf("expected %s from %s but got %s", k, v, sources[k])
+					}
+				}
+			},
+		},
+		{
+			name:  "secrets read from files",
+			files: map[string]string{"jwt_secret": "secret-from-a-file\n"},
+			env:   map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET_FILE": "jwt_secret"},
+			check: func(t *testing.T, cfg Config, settings []Setting) {
+				if cfg.JWT.Secret != "secret-from-a-file" {
+					t.Errorf("expected the secret from the file but got %q", cfg.JWT.Secret)
+				}
+				for _, s := range settings {
+					if s.Key == "JWT_SECRET" && s.Redacted().Value != "[redacted]" {
+						t.Errorf("the secret should be redacted but got %q", s.Redacted().Value)
+					}
+				}
+			},
+		},
+		{
+			name:       "value and secret file both set",
+			files:      map[string]string{"jwt_secret": "secret-from-a-file"},
+			env:        map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET": "secret", "JWT_SECRET_FILE": "jwt_secret"},
+			shouldFail: true,
+		},
+		{
+			name:       "missing secret file",
+			env:        map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET_FILE": "does-not-exist"},
+			shouldFail: true,
+		},
+		{
+			name:       "unknown key in the file",
+			files:      map[string]string{"config.yaml": "db:\n  driver: memory\n  nmae: tasks\njwt:\n  secret: secret\n"},
+			opts:       func(dir string) Options { return Options{File: filepath.Join(dir, "config.yaml")} },
+			shouldFail: true,
+		},
+		{
+			name:       "invalid duration",
+			env:        map[string]string{"DB_DRIVER": DBDriverMemory, "JWT_SECRET": "secret", "CORS_MAX_AGE": "ten minutes"},
+			shouldFail: true,
+		},
+		{
+			name:       "unsupported file format",
+			files:      map[string]string{"config.json": "{}"},
+			opts:       func(dir string) Options { return Options{File: filepath.Join(dir, "config.json")} },
+			shouldFail: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			setupTests(t)
+			dir := t.TempDir()
+			for name, content := range tc.files {
+				if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0600); err != nil {
+					t.Fatal(err)
+				}
+			}
+			orgDir, _ := os.Getwd()
+			_ = os.Chdir(dir)
+			t.Cleanup(func() {
+				_ = os.Chdir(orgDir)
+			})
+			for k, v := range tc.env {
+				t.Setenv(k, v)
+			}
+
+			failed := false
+			fatalf = func(format string, args ...any) {
+				failed = true
+				panic(fmt.Sprintf(format, args...))
+			}
+			t.Cleanup(func() {
+				fatalf = log.Fatalf
+			})
+
+			defer func() {
+				if r := recover(); r != nil && !tc.shouldFail {
+					t.Errorf("LoadWith failed unexpectedly: %v", r)
+				}
+				if tc.shouldFail && !failed {
+					t.Errorf("expected LoadWith to fail but it did not")
+				}
+			}()
+
+			var o Options
+			if tc.opts != nil {
+				o = tc.opts(dir)
+			}
+			cfg, settings := LoadWith(o)
+			if tc.check != nil {
+				tc.check(t, cfg, settings)
+			}
+		})
+	}
+}
@@ -4,4 +4,5 @@ type Key int
 
 const (
 	UserID Key = iota
+	AuthMethod
 )
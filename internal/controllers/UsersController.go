@@ -2,28 +2,59 @@ package controllers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"strings"
+	"task-manager/internal/contextkeys"
 	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"task-manager/internal/metrics"
 	"task-manager/internal/models"
+	"task-manager/internal/ratelimit"
+	"task-manager/internal/repository"
 	"task-manager/internal/requests"
+	"task-manager/internal/security"
 	"task-manager/internal/services"
+	"task-manager/internal/tracing"
+	"time"
 )
 
 type UsersController interface {
 	Store(BodySizeLimit int64) func(http.ResponseWriter, *http.Request)
 	Login() func(w http.ResponseWriter, r *http.Request)
+	LoginMFA() func(w http.ResponseWriter, r *http.Request)
+	Logout() func(w http.ResponseWriter, r *http.Request)
+	VerifyEmail() func(w http.ResponseWriter, r *http.Request)
+	ForgotPassword() func(w http.ResponseWriter, r *http.Request)
+	ResetPassword() func(w http.ResponseWriter, r *http.Request)
+	Me() func(w http.ResponseWriter, r *http.Request)
+	UpdateMe(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request)
+	ChangePassword(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request)
+	DeleteMe() func(w http.ResponseWriter, r *http.Request)
 }
 
 type usersController struct {
 	us services.UserService
+	au services.Authenticator
 	as services.AuthService
+	ac services.AccountService
+	mf services.MFAService
+	lo *ratelimit.Lockout
+	ss *security.Sessions
 }
 
-func NewUsersController(us services.UserService, as services.AuthService) UsersController {
+// NewUsersController creates the controller; lo may be nil to disable the failed login lockout
+// and ss may be nil when cookie sessions are disabled.
+func NewUsersController(us services.UserService, au services.Authenticator, as services.AuthService, ac services.AccountService, mf services.MFAService, lo *ratelimit.Lockout, ss *security.Sessions) UsersController {
 	return &usersController{
 		us: us,
+		au: au,
 		as: as,
+		ac: ac,
+		mf: mf,
+		lo: lo,
+		ss: ss,
 	}
 }
 
@@ -31,13 +62,33 @@ type AuthResponse struct {
 	Token string `json:"token"`
 }
 
+type SessionResponse struct {
+	CSRFToken string `json:"csrf_token"`
+}
+
+// MFAChallengeResponse is returned by Login instead of a token when a second factor is
+// required; MFAToken has to be sent to /login/mfa together with a code.
+type MFAChallengeResponse struct {
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+}
+
+// ProfileResponse carries fresh credentials when the e-mail change invalidated the old ones.
+type ProfileResponse struct {
+	models.Profile
+	Token     string `json:"token,omitempty"`
+	CSRFToken string `json:"csrf_token,omitempty"`
+}
+
 func (uc usersController) Store(BodySizeLimit int64) func(http.ResponseWriter, *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, BodySizeLimit)
 
 		var request requests.CreateUserRequest
 
+		_, span := tracing.Start(r.Context(), "json.decode")
 		err := json.NewDecoder(r.Body).Decode(&request)
+		span.End()
 		if err != nil {
 			helpers.JsonResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("store user: request body too large: %v", err))
 			return
@@ -56,17 +107,26 @@ func (uc usersController) Store(BodySizeLimit int64) func(http.ResponseWriter, *
 		}
 
 		if err := uc.us.RegisterUser(r.Context(), payload); err != nil {
+			logging.FromContext(r.Context()).Error("failed to register user", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("store user: failed to register: %v", err))
 			return
 		}
 
+		// the account exists at this point, a mail failure only means the link has to be resent
+		if err := uc.ac.SendVerification(r.Context(), payload.Email); err != nil {
+			logging.FromContext(r.Context()).Error("failed to send verification e-mail", "err", err)
+		}
+
 		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("successfully created user"))
 	}
 }
 func (uc usersController) Login() func(w http.ResponseWriter, r *http.Request) {
 	return func(w http.ResponseWriter, r *http.Request) {
 		var req requests.Credentials
-		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
 			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("login error, incorrect payload: %v", err))
 			return
 		}
@@ -82,20 +142,423 @@ func (uc usersController) Login() func(w http.ResponseWriter, r *http.Request) {
 			Password: req.Password,
 		}
 
-		u, err := uc.us.LoginUser(p)
+		if uc.locked(r, p.Email) {
+			// the password is checked all the same, a faster answer would give the lock away
+			uc.au.Spend(r.Context(), p)
+			uc.rejectLogin(w, r, "locked", nil)
+			return
+		}
+
+		u, err := uc.au.Authenticate(r.Context(), p)
+		switch {
+		case errors.Is(err, services.ErrInvalidCredentials):
+			// the Authenticator counted the reason already
+			uc.rejectLogin(w, r, "", err)
+			uc.recordFailure(r, p.Email)
+			return
+		case errors.Is(err, services.ErrEmailNotVerified):
+			uc.rejectLogin(w, r, "email_not_verified", err)
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to authenticate", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to authenticate"))
+			return
+		}
+		logging.With(r.Context(), "user_id", u.ID)
+
+		mfaEnabled, err := uc.mf.IsEnabled(r.Context(), int64(u.ID))
 		if err != nil {
-			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("failed to authenticate, incorrect credentials"))
+			logging.FromContext(r.Context()).Error("failed to check two-factor authentication", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to authenticate"))
+			return
+		}
+		if mfaEnabled {
+			// failed logins are only reset once the second factor passed as well, otherwise
+			// knowing the password would allow unlimited guesses of the code
+			challenge, err := uc.as.CreateChallengeToken(r.Context(), u)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("failed to generate mfa challenge", "err", err)
+				helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to authenticate"))
+				return
+			}
+			helpers.JsonResponse(w, http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge})
 			return
 		}
-		fmt.Printf("%v", u)
 
-		token, err := uc.as.CreateToken(u)
+		if uc.lo != nil {
+			if err := uc.lo.Success(r.Context(), p.Email); err != nil {
+				logging.FromContext(r.Context()).Error("failed to reset failed logins", "err", err)
+			}
+		}
+
+		uc.respondWithToken(w, r, u)
+	}
+}
+
+// LoginMFA completes a login started by Login or the OIDC callback with a TOTP or recovery
+// code.
+func (uc usersController) LoginMFA() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.MFALoginRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
 		if err != nil {
-			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to generate JWT token, %v", err))
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("login error, incorrect payload: %v", err))
 			return
 		}
 
-		helpers.JsonResponse(w, http.StatusOK, AuthResponse{Token: token})
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("login: login request is invalid: %s", v.Message))
+			return
+		}
+
+		u, err := uc.as.ParseChallengeToken(r.Context(), req.MFAToken)
+		if err != nil {
+			uc.rejectLogin(w, r, "invalid_challenge", err)
+			return
+		}
+		logging.With(r.Context(), "user_id", u.ID)
+
+		// the challenge proves the password or the identity provider login already, so
+		// answering a locked account right away tells the caller nothing about it they could
+		// not learn from Login
+		if uc.locked(r, u.Email) {
+			uc.rejectLogin(w, r, "locked", nil)
+			return
+		}
+
+		err = uc.mf.Verify(r.Context(), int64(u.ID), req.Code)
+		if errors.Is(err, services.ErrInvalidMFACode) || errors.Is(err, services.ErrMFANotEnrolled) {
+			uc.rejectLogin(w, r, "invalid_mfa_code", err)
+			uc.recordFailure(r, u.Email)
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to verify mfa code", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to authenticate"))
+			return
+		}
+
+		if uc.lo != nil {
+			if err := uc.lo.Success(r.Context(), u.Email); err != nil {
+				logging.FromContext(r.Context()).Error("failed to reset failed logins", "err", err)
+			}
+		}
+
+		uc.respondWithToken(w, r, u)
+	}
+}
+
+// errLoginFailed is the answer to every rejected login, so it tells nothing about the account.
+const errLoginFailed = "failed to authenticate, incorrect credentials"
+
+// rejectLogin answers a rejected login with errLoginFailed, keeping the reason in the logs and,
+// unless it is empty because it was counted already, in the login failure metric.
+func (uc usersController) rejectLogin(w http.ResponseWriter, r *http.Request, reason string, err error) {
+	if reason != "" {
+		metrics.LoginFailures.WithLabelValues(reason).Inc()
+	}
+	logging.FromContext(r.Context()).Info("login rejected", "reason", reason, "err", err)
+	helpers.JsonResponse(w, http.StatusUnauthorized, errLoginFailed)
+}
+
+// locked reports whether logins to the account are locked after too many failures. A failing
+// lockout store doesn't lock anyone out.
+func (uc usersController) locked(r *http.Request, email string) bool {
+	if uc.lo == nil {
+		return false
+	}
+	wait, err := uc.lo.Check(r.Context(), email, time.Now())
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to check login lockout", "err", err)
+	}
+	return wait > 0
+}
+
+// recordFailure counts a failed login towards the lockout of the account.
+func (uc usersController) recordFailure(r *http.Request, email string) {
+	if uc.lo == nil {
 		return
 	}
+	if err := uc.lo.Failure(r.Context(), email, time.Now()); err != nil {
+		logging.FromContext(r.Context()).Error("failed to record failed login", "err", err)
+	}
+}
+
+// respondWithToken finishes a successful login, either with a Bearer token or, when asked
+// for with ?session=cookie and enabled, with session cookies.
+func (uc usersController) respondWithToken(w http.ResponseWriter, r *http.Request, u models.User) {
+	token, err := uc.as.CreateToken(r.Context(), u)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to generate JWT token", "err", err)
+		helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to generate JWT token, %v", err))
+		return
+	}
+
+	if r.URL.Query().Get("session") == security.AuthCookie && uc.ss.Enabled() {
+		csrf, err := uc.ss.Issue(w, token)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to issue session", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to create session"))
+			return
+		}
+		helpers.JsonResponse(w, http.StatusOK, SessionResponse{CSRFToken: csrf})
+		return
+	}
+
+	helpers.JsonResponse(w, http.StatusOK, AuthResponse{Token: token})
+}
+
+// Logout ends a cookie session. Bearer tokens are stateless and stay valid until they expire.
+func (uc usersController) Logout() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if uc.ss.Enabled() {
+			uc.ss.Clear(w)
+		}
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("logged out"))
+	}
+}
+
+func (uc usersController) VerifyEmail() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.VerifyEmailRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("verify email: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("verify email: request is invalid: %s", v.Message))
+			return
+		}
+
+		err = uc.ac.VerifyEmail(r.Context(), req.Token)
+		if errors.Is(err, services.ErrInvalidToken) {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("verify email: %v", services.ErrInvalidToken))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to verify e-mail", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("verify email: failed to verify e-mail"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("e-mail address verified"))
+	}
+}
+
+// ForgotPassword answers 202 whether or not the address is registered, so it cannot be used
+// to enumerate accounts.
+func (uc usersController) ForgotPassword() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.ForgotPasswordRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("forgot password: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("forgot password: request is invalid: %s", v.Message))
+			return
+		}
+
+		if err := uc.ac.ForgotPassword(r.Context(), req.Email); err != nil {
+			logging.FromContext(r.Context()).Error("failed to send password reset e-mail", "err", err)
+		}
+
+		helpers.JsonResponse(w, http.StatusAccepted, fmt.Sprintf("if the address is registered a reset link has been sent"))
+	}
+}
+
+func (uc usersController) ResetPassword() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		var req requests.ResetPasswordRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("reset password: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("reset password: request is invalid: %s", v.Message))
+			return
+		}
+
+		err = uc.ac.ResetPassword(r.Context(), req.Token, req.Password)
+		if errors.Is(err, services.ErrInvalidToken) {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("reset password: %v", services.ErrInvalidToken))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to reset password", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("reset password: failed to reset password"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("password updated"))
+	}
+}
+
+func (uc usersController) Me() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+
+		u, err := uc.us.GetProfile(r.Context(), uID)
+		if errors.Is(err, repository.ErrNotFound) {
+			helpers.JsonResponse(w, http.StatusNotFound, fmt.Sprintf("user not found"))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to get profile", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get profile"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, u.Profile())
+	}
+}
+
+// UpdateMe changes the name and/or e-mail. Tokens carry the e-mail, so a new one is issued
+// (or the session cookie renewed) when it changes, and a verification link is sent.
+func (uc usersController) UpdateMe(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, BodySizeLimit)
+
+		var req requests.UpdateUserRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("update profile: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("update profile validation failed: %s", v.Message))
+			return
+		}
+
+		u, emailChanged, err := uc.us.UpdateProfile(r.Context(), uID, models.UpdateProfilePayload{
+			Name:  strings.TrimSpace(req.Name),
+			Email: req.Email,
+		})
+		if errors.Is(err, services.ErrEmailInUse) {
+			helpers.JsonResponse(w, http.StatusConflict, fmt.Sprintf("update profile: %v", services.ErrEmailInUse))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to update profile", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to update profile"))
+			return
+		}
+
+		res := ProfileResponse{Profile: u.Profile()}
+		if emailChanged {
+			if err := uc.ac.SendVerification(r.Context(), u.Email); err != nil {
+				logging.FromContext(r.Context()).Error("failed to send verification e-mail", "err", err)
+			}
+
+			token, err := uc.as.CreateToken(r.Context(), u)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("failed to generate JWT token", "err", err)
+				helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("profile updated, please log in again"))
+				return
+			}
+			if method, _ := r.Context().Value(contextkeys.AuthMethod).(string); method == security.AuthCookie && uc.ss.Enabled() {
+				res.CSRFToken, err = uc.ss.Issue(w, token)
+				if err != nil {
+					logging.FromContext(r.Context()).Error("failed to issue session", "err", err)
+					helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("profile updated, please log in again"))
+					return
+				}
+			} else {
+				res.Token = token
+			}
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, res)
+	}
+}
+
+func (uc usersController) ChangePassword(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, BodySizeLimit)
+
+		var req requests.ChangePasswordRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("change password: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("change password validation failed: %s", v.Message))
+			return
+		}
+
+		err = uc.us.ChangePassword(r.Context(), uID, req.CurrentPassword, req.Password)
+		if errors.Is(err, services.ErrIncorrectPassword) {
+			helpers.JsonResponse(w, http.StatusForbidden, fmt.Sprintf("current password is incorrect"))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to change password", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to change password"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("password updated"))
+	}
+}
+
+func (uc usersController) DeleteMe() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+
+		if err := uc.us.DeleteAccount(r.Context(), uID); err != nil {
+			logging.FromContext(r.Context()).Error("failed to delete account", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete account"))
+			return
+		}
+		if uc.ss.Enabled() {
+			uc.ss.Clear(w)
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("account deleted successfully"))
+	}
 }
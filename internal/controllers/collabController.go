@@ -0,0 +1,56 @@
+package controllers
+
+import (
+	"fmt"
+	"net/http"
+	"task-manager/internal/collab"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+
+	"github.com/gorilla/websocket"
+)
+
+type CollabController interface {
+	Connect() func(w http.ResponseWriter, r *http.Request)
+}
+
+type collabController struct {
+	hub      *collab.Hub
+	upgrader websocket.Upgrader
+}
+
+// NewCollabController creates the controller upgrading requests to connections of hub.
+// checkOrigin decides which sites may open them.
+func NewCollabController(hub *collab.Hub, checkOrigin func(r *http.Request) bool) CollabController {
+	return &collabController{
+		hub: hub,
+		upgrader: websocket.Upgrader{
+			CheckOrigin: checkOrigin,
+			Error: func(w http.ResponseWriter, r *http.Request, status int, reason error) {
+				helpers.JsonResponse(w, status, fmt.Sprintf("connect: %v", reason))
+			},
+		},
+	}
+}
+
+// Connect upgrades the request to a WebSocket connection of the collaboration channel and
+// serves it until either side closes it.
+func (cc collabController) Connect() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+
+		// the upgrader answers failed handshakes itself
+		conn, err := cc.upgrader.Upgrade(w, r, nil)
+		if err != nil {
+			return
+		}
+		if err := cc.hub.Serve(r.Context(), conn, uID); err != nil {
+			logging.FromContext(r.Context()).Error("failed to serve collaboration client", "err", err)
+		}
+	}
+}
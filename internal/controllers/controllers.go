@@ -1,15 +1,37 @@
 package controllers
 
-import "task-manager/internal/services"
+import (
+	"net/http"
+	"task-manager/internal/collab"
+	"task-manager/internal/graph"
+	"task-manager/internal/ratelimit"
+	"task-manager/internal/security"
+	"task-manager/internal/services"
+)
 
 type Controllers struct {
 	Uc UsersController
 	Tc TasksController
+	Mc MFAController
+	Ec EventsController
+	Cc CollabController
+	Gc GraphQLController
+	// Oc is nil unless OpenID Connect login is enabled.
+	Oc OIDCController
 }
 
-func New(s services.Services) Controllers {
-	return Controllers{
-		Uc: NewUsersController(s.Us, s.As),
+func New(s services.Services, lo *ratelimit.Lockout, sess *security.Sessions, hub *collab.Hub, g *graph.Graph, checkOrigin func(r *http.Request) bool, appURL string) Controllers {
+	c := Controllers{
+		Uc: NewUsersController(s.Us, s.Au, s.As, s.Ac, s.Mf, lo, sess),
 		Tc: NewTasksController(s.Ts),
+		Mc: NewMFAController(s.Us, s.Mf),
+		Ec: NewEventsController(s.Ev),
+		Cc: NewCollabController(hub, checkOrigin),
+		Gc: NewGraphQLController(g),
 	}
+	if s.Oi != nil {
+		c.Oc = NewOIDCController(s.Oi, s.As, s.Mf, sess, appURL)
+	}
+
+	return c
 }
@@ -0,0 +1,145 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"task-manager/internal/models"
+	"task-manager/internal/services"
+	"time"
+)
+
+// reconnectDelay is how long clients wait before reconnecting after the stream ended.
+const reconnectDelay = 3 * time.Second
+
+type EventsController interface {
+	Stream(heartbeat time.Duration) func(w http.ResponseWriter, r *http.Request)
+}
+
+type eventsController struct {
+	es services.EventService
+}
+
+func NewEventsController(es services.EventService) EventsController {
+	return &eventsController{es: es}
+}
+
+// Stream sends the changes to the tasks of the user as server-sent events until the client
+// disconnects. A client reconnecting with the id of the last event it received in the
+// Last-Event-ID header is sent the events it missed; otherwise only new events are sent.
+func (ec eventsController) Stream(heartbeat time.Duration) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		ctx := r.Context()
+		log := logging.FromContext(ctx)
+		uID, ok := ctx.Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+
+		var lastID int64
+		if h := r.Header.Get("Last-Event-ID"); h != "" {
+			id, err := strconv.ParseInt(h, 10, 64)
+			if err != nil || id < 0 {
+				helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("stream: invalid Last-Event-ID %q", h))
+				return
+			}
+			lastID = id
+		} else {
+			id, err := ec.es.LastID(ctx)
+			if err != nil {
+				log.Error("failed to get the last event", "err", err)
+				helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to open the event stream"))
+				return
+			}
+			lastID = id
+		}
+
+		// subscribe before reading, so changes made in between wake the stream
+		sub := ec.es.Subscribe(uID)
+		defer sub.Close()
+
+		rc := http.NewResponseController(w)
+		// the stream outlives any write timeout of the server
+		_ = rc.SetWriteDeadline(time.Time{})
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.Header().Set("X-Accel-Buffering", "no")
+		w.WriteHeader(http.StatusOK)
+		if _, err := fmt.Fprintf(w, "retry: %d\n\n", reconnectDelay.Milliseconds()); err != nil {
+			return
+		}
+
+		send := func() error {
+			for {
+				events, err := ec.es.After(ctx, uID, lastID)
+				if err != nil {
+					return err
+				}
+				for _, e := range events {
+					if err := writeEvent(w, e); err != nil {
+						return err
+					}
+					lastID = e.ID
+				}
+				if len(events) < services.EventBatchSize {
+					return rc.Flush()
+				}
+			}
+		}
+
+		if err := send(); err != nil {
+			ec.failed(r, err)
+			return
+		}
+
+		t := time.NewTicker(heartbeat)
+		defer t.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case _, ok := <-sub.C:
+				if !ok {
+					// the server is shutting down
+					return
+				}
+				if err := send(); err != nil {
+					ec.failed(r, err)
+					return
+				}
+			case <-t.C:
+				// a comment, ignored by clients, keeps idle connections open through proxies
+				if _, err := io.WriteString(w, ": heartbeat\n\n"); err != nil {
+					return
+				}
+				if err := rc.Flush(); err != nil {
+					return
+				}
+			}
+		}
+	}
+}
+
+// failed logs why a stream ended early. Clients going away is expected; they reconnect with
+// the id of the last event they got after anything else.
+func (ec eventsController) failed(r *http.Request, err error) {
+	if r.Context().Err() != nil {
+		return
+	}
+	logging.FromContext(r.Context()).Error("event stream failed", "err", err)
+}
+
+func writeEvent(w io.Writer, e models.TaskEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Type, data)
+	return err
+}
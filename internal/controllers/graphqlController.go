@@ -0,0 +1,41 @@
+package controllers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"task-manager/internal/graph"
+	"task-manager/internal/helpers"
+	"task-manager/internal/tracing"
+)
+
+type GraphQLController interface {
+	Query(bodySizeLimit int64) func(w http.ResponseWriter, r *http.Request)
+}
+
+type graphqlController struct {
+	g *graph.Graph
+}
+
+func NewGraphQLController(g *graph.Graph) GraphQLController {
+	return &graphqlController{g: g}
+}
+
+// Query executes a GraphQL request. Errors of the request are reported in the result with
+// 200, as GraphQL clients expect; only bodies that cannot be decoded are rejected.
+func (gc graphqlController) Query(bodySizeLimit int64) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		r.Body = http.MaxBytesReader(w, r.Body, bodySizeLimit)
+		var req graph.Request
+
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("graphql: invalid request body: %v", err))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, gc.g.Execute(r.Context(), req))
+	}
+}
@@ -0,0 +1,151 @@
+package controllers
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"task-manager/internal/requests"
+	"task-manager/internal/services"
+	"task-manager/internal/tracing"
+)
+
+type MFAController interface {
+	Enrol() func(w http.ResponseWriter, r *http.Request)
+	Confirm(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request)
+	Disable(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request)
+}
+
+type mfaController struct {
+	us services.UserService
+	mf services.MFAService
+}
+
+func NewMFAController(us services.UserService, mf services.MFAService) MFAController {
+	return &mfaController{
+		us: us,
+		mf: mf,
+	}
+}
+
+type RecoveryCodesResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+func (mc mfaController) Enrol() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+
+		u, err := mc.us.GetProfile(r.Context(), uID)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to get profile", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to enrol two-factor authentication"))
+			return
+		}
+
+		e, err := mc.mf.EnrolTOTP(r.Context(), u)
+		if errors.Is(err, services.ErrMFAAlreadyEnabled) {
+			helpers.JsonResponse(w, http.StatusConflict, fmt.Sprintf("enrol: %v", services.ErrMFAAlreadyEnabled))
+			return
+		}
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to enrol totp", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to enrol two-factor authentication"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, e)
+	}
+}
+
+// Confirm activates the enrolled secret and returns the recovery codes, which are shown only once.
+func (mc mfaController) Confirm(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, BodySizeLimit)
+
+		var req requests.MFACodeRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("confirm: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("confirm validation failed: %s", v.Message))
+			return
+		}
+
+		codes, err := mc.mf.ConfirmTOTP(r.Context(), uID, req.Code)
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("confirm: %v", services.ErrInvalidMFACode))
+			return
+		case errors.Is(err, services.ErrMFANotEnrolled), errors.Is(err, services.ErrMFAAlreadyEnabled):
+			helpers.JsonResponse(w, http.StatusConflict, fmt.Sprintf("confirm: %v", errors.Unwrap(err)))
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to confirm totp", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to confirm two-factor authentication"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, RecoveryCodesResponse{RecoveryCodes: codes})
+	}
+}
+
+func (mc mfaController) Disable(BodySizeLimit int64) func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		uID, ok := r.Context().Value(contextkeys.UserID).(int64)
+		if !ok {
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("invalid user data, please relog"))
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, BodySizeLimit)
+
+		var req requests.MFACodeRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
+		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
+		if err != nil {
+			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("disable: incorrect payload: %v", err))
+			return
+		}
+
+		v := req.Validate()
+		if !v.Validated {
+			helpers.JsonResponse(w, http.StatusUnprocessableEntity, fmt.Sprintf("disable validation failed: %s", v.Message))
+			return
+		}
+
+		err = mc.mf.DisableTOTP(r.Context(), uID, req.Code)
+		switch {
+		case errors.Is(err, services.ErrInvalidMFACode):
+			helpers.JsonResponse(w, http.StatusForbidden, fmt.Sprintf("disable: %v", services.ErrInvalidMFACode))
+			return
+		case errors.Is(err, services.ErrMFANotEnrolled):
+			helpers.JsonResponse(w, http.StatusConflict, fmt.Sprintf("disable: %v", services.ErrMFANotEnrolled))
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to disable totp", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to disable two-factor authentication"))
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, fmt.Sprintf("two-factor authentication disabled"))
+	}
+}
@@ -0,0 +1,146 @@
+package controllers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"task-manager/internal/models"
+	"task-manager/internal/oidc"
+	"task-manager/internal/security"
+	"task-manager/internal/services"
+)
+
+const (
+	oidcFlowCookie = "oidc_flow"
+	oidcFlowPath   = "/auth/oidc"
+)
+
+type OIDCController interface {
+	Login() func(w http.ResponseWriter, r *http.Request)
+	Callback() func(w http.ResponseWriter, r *http.Request)
+}
+
+type oidcController struct {
+	oi     services.OIDCService
+	as     services.AuthService
+	mf     services.MFAService
+	ss     *security.Sessions
+	appURL string
+}
+
+func NewOIDCController(oi services.OIDCService, as services.AuthService, mf services.MFAService, ss *security.Sessions, appURL string) OIDCController {
+	return &oidcController{
+		oi:     oi,
+		as:     as,
+		mf:     mf,
+		ss:     ss,
+		appURL: appURL,
+	}
+}
+
+// Login redirects the browser to the identity provider. The state, nonce and PKCE verifier
+// of the attempt travel in a cookie that is only sent back to the callback.
+func (oc oidcController) Login() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		authURL, flow, err := oc.oi.Begin(r.Context())
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to start oidc login", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to start login"))
+			return
+		}
+
+		http.SetCookie(w, oc.ss.FlowCookie(oidcFlowCookie, flow, oidcFlowPath, services.OIDCFlowTTL))
+		http.Redirect(w, r, authURL, http.StatusFound)
+	}
+}
+
+// Callback completes the login. With cookie sessions enabled the browser is sent back to the
+// application, otherwise the token is returned like on /login.
+//
+// The identity provider stands in for the password only: accounts with two-factor
+// authentication enabled get the challenge of /login instead, which the application passes
+// to /login/mfa. Sessions receive it in the fragment of the redirect, which is never sent to
+// a server.
+func (oc oidcController) Callback() func(w http.ResponseWriter, r *http.Request) {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, oc.ss.FlowCookie(oidcFlowCookie, "", oidcFlowPath, -1))
+
+		q := r.URL.Query()
+		if e := q.Get("error"); e != "" {
+			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("login failed: %s", e))
+			return
+		}
+
+		c, err := r.Cookie(oidcFlowCookie)
+		if err != nil || q.Get("state") == "" || q.Get("code") == "" {
+			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("login: %v", services.ErrInvalidOIDCFlow))
+			return
+		}
+
+		u, err := oc.oi.Complete(r.Context(), c.Value, q.Get("state"), q.Get("code"))
+		switch {
+		case errors.Is(err, services.ErrInvalidOIDCFlow), errors.Is(err, oidc.ErrInvalidIDToken), errors.Is(err, oidc.ErrCodeRejected):
+			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("login: %v", services.ErrInvalidOIDCFlow))
+			return
+		case errors.Is(err, services.ErrOIDCEmailNotVerified), errors.Is(err, services.ErrOIDCNoAccount):
+			helpers.JsonResponse(w, http.StatusForbidden, fmt.Sprintf("login: %v", err))
+			return
+		case err != nil:
+			logging.FromContext(r.Context()).Error("failed to complete oidc login", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to complete login"))
+			return
+		}
+		logging.With(r.Context(), "user_id", int64(u.ID))
+
+		mfaEnabled, err := oc.mf.IsEnabled(r.Context(), int64(u.ID))
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to check two-factor authentication", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to complete login"))
+			return
+		}
+		if mfaEnabled {
+			oc.challenge(w, r, u)
+			return
+		}
+
+		token, err := oc.as.CreateToken(r.Context(), u)
+		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to generate JWT token", "err", err)
+			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to generate JWT token, %v", err))
+			return
+		}
+
+		if oc.ss.Enabled() {
+			if _, err := oc.ss.Issue(w, token); err != nil {
+				logging.FromContext(r.Context()).Error("failed to issue session", "err", err)
+				helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to create session"))
+				return
+			}
+			http.Redirect(w, r, oc.appURL, http.StatusFound)
+			return
+		}
+
+		helpers.JsonResponse(w, http.StatusOK, AuthResponse{Token: token})
+	}
+}
+
+// challenge answers a login of an account with two-factor authentication enabled with the
+// challenge to complete on /login/mfa.
+func (oc oidcController) challenge(w http.ResponseWriter, r *http.Request, u models.User) {
+	challenge, err := oc.as.CreateChallengeToken(r.Context(), u)
+	if err != nil {
+		logging.FromContext(r.Context()).Error("failed to generate mfa challenge", "err", err)
+		helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to complete login"))
+		return
+	}
+
+	if oc.ss.Enabled() {
+		http.Redirect(w, r, oc.appURL+"#"+url.Values{"mfa_token": {challenge}}.Encode(), http.StatusFound)
+		return
+	}
+
+	helpers.JsonResponse(w, http.StatusOK, MFAChallengeResponse{MFARequired: true, MFAToken: challenge})
+}
@@ -7,9 +7,11 @@ import (
 	"strconv"
 	"task-manager/internal/contextkeys"
 	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
 	"task-manager/internal/models"
 	"task-manager/internal/requests"
 	"task-manager/internal/services"
+	"task-manager/internal/tracing"
 	"time"
 
 	"github.com/go-chi/chi/v5"
@@ -39,8 +41,9 @@ func (t tasksController) Index() func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		tl, err := t.ts.GetTasksList(uID)
+		tl, err := t.ts.GetTasksList(r.Context(), uID)
 		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to retrieve tasks list", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to retreive tasks list: %v", err))
 			return
 		}
@@ -53,7 +56,9 @@ func (t tasksController) Store(bodySizeLimit int64) func(w http.ResponseWriter,
 		r.Body = http.MaxBytesReader(w, r.Body, bodySizeLimit)
 		var req requests.CreateTasksRequest
 
+		_, span := tracing.Start(r.Context(), "json.decode")
 		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
 		if err != nil {
 			helpers.JsonResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("store task: request body too large: %v", err))
 			return
@@ -72,7 +77,8 @@ func (t tasksController) Store(bodySizeLimit int64) func(w http.ResponseWriter,
 			DueDate:     req.DueDate,
 			CreatedAt:   &now,
 		}
-		if err := t.ts.StoreTask(r.Context(), p); err != nil {
+		if _, err := t.ts.StoreTask(r.Context(), p); err != nil {
+			logging.FromContext(r.Context()).Error("failed to save task", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("store task: failed to save the data: %v", err))
 			return
 		}
@@ -94,8 +100,9 @@ func (t tasksController) Show() func(w http.ResponseWriter, r *http.Request) {
 			helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("invalid task id"))
 		}
 
-		t, err := t.ts.ShowTask(id)
+		t, err := t.ts.ShowTask(r.Context(), id)
 		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to get task data", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to get task data: %v", err))
 			return
 		}
@@ -112,7 +119,9 @@ func (t tasksController) Update(bodySizeLimit int64) func(w http.ResponseWriter,
 	return func(w http.ResponseWriter, r *http.Request) {
 		r.Body = http.MaxBytesReader(w, r.Body, bodySizeLimit)
 		var req requests.UpdateTaskRequest
+		_, span := tracing.Start(r.Context(), "json.decode")
 		err := json.NewDecoder(r.Body).Decode(&req)
+		span.End()
 		if err != nil {
 			helpers.JsonResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("update task: payload invalid: %v", err))
 			return
@@ -138,8 +147,9 @@ func (t tasksController) Update(bodySizeLimit int64) func(w http.ResponseWriter,
 			return
 		}
 
-		isOwner, err := t.ts.IsTaskOwner(uID, int(id))
+		isOwner, err := t.ts.IsTaskOwner(r.Context(), uID, int(id))
 		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to check ownership", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to check ownership: %v", err))
 			return
 		}
@@ -159,6 +169,7 @@ func (t tasksController) Update(bodySizeLimit int64) func(w http.ResponseWriter,
 
 		t, err := t.ts.UpdateTask(r.Context(), p)
 		if err != nil {
+			logging.FromContext(r.Context()).Error("failed to update task", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("update: failed to update task: %v", err))
 			return
 		}
@@ -182,7 +193,8 @@ func (t tasksController) Delete() func(w http.ResponseWriter, r *http.Request) {
 			return
 		}
 
-		if err := t.ts.DeleteTask(id, uID); err != nil {
+		if err := t.ts.DeleteTask(r.Context(), id, uID); err != nil {
+			logging.FromContext(r.Context()).Error("failed to delete the task", "err", err)
 			helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("failed to delete the task: %v", err))
 			return
 		}
@@ -2,178 +2,202 @@ package db
 
 import (
 	"database/sql"
+	"errors"
 	"fmt"
-	"sync"
+	"log/slog"
+	"math"
+	"net"
+	"net/url"
+	"strconv"
+	"sync/atomic"
 	"task-manager/internal/config"
-	"task-manager/internal/helpers"
-	"time"
 
 	_ "github.com/lib/pq"
-)
-
-var (
-	once      sync.Once
-	dbConnect *DB
+	_ "modernc.org/sqlite"
 )
 
 const (
 	dbDir = "./internal/db/"
 	mDir  = dbDir + "migrations/"
+
+	// sqliteDir holds the SQLite variants of embedded files, under the same relative paths.
+	sqliteDir = "sqlite/"
 )
 
 type DB struct {
 	*sql.DB
+	hooks    *hookSet
+	driver   string
+	replicas *replicaSet
 }
 
-type dsnConfig struct {
-	Username string
-	Password string
-	Host     string
-	Port     string
-	Database string
+type replicaSet struct {
+	dbs  []*sql.DB
+	next atomic.Uint64
 }
 
-func createDsn(c config.DBConfig) string {
-	cfg := dsnConfig{
-		Username: c.User,
-		Password: c.Password,
-		Host:     c.Host,
-		Port:     c.Port,
-		Database: c.Name,
+// createDsn builds the connection URL for the server at host ("host:port"). The statement
+// timeout is sent as a run-time parameter, so the server cancels statements running longer.
+func createDsn(c config.DBConfig, host string) string {
+	q := url.Values{}
+	q.Set("sslmode", c.SSLMode)
+	if c.SSLMode == "" {
+		q.Set("sslmode", "disable")
 	}
-
-	return fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", cfg.Username, cfg.Password, cfg.Host, cfg.Port, cfg.Database)
-}
-
-func InitDb(c config.DBConfig) (*DB, error) {
-	fmt.Println("initializing db")
-	var err error
-	dsn := createDsn(c)
-	once.Do(func() {
-		var sqlDB *sql.DB
-		sqlDB, err = sql.Open("postgres", dsn)
-		if err != nil {
-			return
-		}
-		dbConnect = &DB{sqlDB}
-	})
-
-	if err != nil {
-		return nil, err
+	if c.SSLRootCert != "" {
+		q.Set("sslrootcert", c.SSLRootCert)
 	}
-
-	return dbConnect, nil
-}
-
-func (d DB) RunMigrations() error {
-	exists, err := d.tableExists("migrations")
-	if err != nil {
-		return fmt.Errorf("db: RunMigrations: error while checking for migrations table: %v", err)
+	if c.ConnectTimeout > 0 {
+		q.Set("connect_timeout", strconv.Itoa(int(math.Ceil(c.ConnectTimeout.Seconds()))))
 	}
-	if !exists {
-		if err = d.createTable("0001_create_migrations_table.sql"); err != nil {
-			return fmt.Errorf("db: RunMigrations: error while initializing migrations table: %v", err)
-		}
+	if c.StatementTimeout > 0 {
+		q.Set("statement_timeout", strconv.FormatInt(c.StatementTimeout.Milliseconds(), 10))
 	}
 
-	mDone, err := d.getMigrated()
-	if err != nil {
-		return fmt.Errorf("db: RunMigrations: %v", err)
+	u := url.URL{
+		Scheme:   "postgres",
+		User:     url.UserPassword(c.User, c.Password),
+		Host:     host,
+		Path:     "/" + c.Name,
+		RawQuery: q.Encode(),
 	}
+	return u.String()
+}
 
-	m, err := SQLFiles.ReadDir("migrations")
-	if err != nil {
-		return fmt.Errorf("db: RunMigrations: error while reading migrations dir: %s", err)
-	}
+// DSN returns the connection URL of the primary server, for connections opened outside the
+// pool like the listener of notifications.
+func DSN(c config.DBConfig) string {
+	return createDsn(c, net.JoinHostPort(c.Host, c.Port))
+}
+
+func createSQLiteDsn(path string) string {
+	return fmt.Sprintf("file:%s?_pragma=foreign_keys(1)&_pragma=busy_timeout(5000)&_pragma=journal_mode(WAL)&_time_format=sqlite", path)
+}
 
-	for _, item := range m {
-		if !item.IsDir() {
-			if !helpers.SliceContains(mDone, item.Name()) {
-				if err := d.createTable(item.Name()); err != nil {
-					return fmt.Errorf("db: RunMigrations: error while running %s, %v", item.Name(), err)
-				}
+// Open connects to the database selected by c.Driver and, for postgres, to the read replicas
+// in c.ReplicaHosts. Every call returns a new handle which the caller has to Close.
+func Open(c config.DBConfig) (*DB, error) {
+	switch c.Driver {
+	case config.DBDriverSQLite:
+		slog.Debug("initializing db", "driver", c.Driver, "path", c.Path)
+		sqlDB, err := sql.Open("sqlite", createSQLiteDsn(c.Path))
+		if err != nil {
+			return nil, err
+		}
+		configurePool(sqlDB, c)
+		// SQLite allows a single writer at a time; sharing one connection avoids "database is
+		// locked" errors between concurrent transactions.
+		sqlDB.SetMaxOpenConns(1)
+		return &DB{DB: sqlDB, hooks: &hookSet{}, driver: c.Driver, replicas: &replicaSet{}}, nil
+	case config.DBDriverPostgres:
+		slog.Debug("initializing db", "driver", c.Driver, "host", c.Host, "port", c.Port, "database", c.Name, "replicas", len(c.ReplicaHosts))
+		sqlDB, err := sql.Open("postgres", createDsn(c, net.JoinHostPort(c.Host, c.Port)))
+		if err != nil {
+			return nil, err
+		}
+		configurePool(sqlDB, c)
+
+		d := &DB{DB: sqlDB, hooks: &hookSet{}, driver: c.Driver, replicas: &replicaSet{}}
+		for _, h := range c.ReplicaHosts {
+			r, err := sql.Open("postgres", createDsn(c, h))
+			if err != nil {
+				_ = d.Close()
+				return nil, fmt.Errorf("db: replica %s: %v", h, err)
 			}
+			configurePool(r, c)
+			d.replicas.dbs = append(d.replicas.dbs, r)
 		}
+		return d, nil
+	default:
+		return nil, fmt.Errorf("db: unsupported driver %q", c.Driver)
 	}
-
-	return nil
 }
 
-func GetQuery(path string) (string, error) {
-	data, err := SQLFiles.ReadFile(path)
-	if err != nil {
-		return "", fmt.Errorf("GetQuery: unable to read embedded query from path %s: %v", path, err)
+// configurePool applies the pool settings of c; zero values keep the database/sql defaults.
+func configurePool(d *sql.DB, c config.DBConfig) {
+	if c.MaxOpenConns > 0 {
+		d.SetMaxOpenConns(c.MaxOpenConns)
 	}
-
-	return string(data), nil
-}
-
-func (d DB) tableExists(n string) (bool, error) {
-	q, err := GetQuery("queries/utils/tableExists.sql")
-	if err != nil {
-		fmt.Println(q)
-		return false, fmt.Errorf("tableExists: error while reading query: %v", err)
+	if c.MaxIdleConns > 0 {
+		d.SetMaxIdleConns(c.MaxIdleConns)
 	}
-	q = fmt.Sprintf(q, n)
-	var exists bool
-	err = d.QueryRow(q).Scan(&exists)
-	if err != nil {
-		return false, fmt.Errorf("tableExists: error while executing query: %v", err)
+	if c.ConnMaxLifetime > 0 {
+		d.SetConnMaxLifetime(c.ConnMaxLifetime)
+	}
+	if c.ConnMaxIdleTime > 0 {
+		d.SetConnMaxIdleTime(c.ConnMaxIdleTime)
 	}
-	return exists, nil
 }
 
-func (d DB) createTable(n string) error {
-	q, err := GetQuery("migrations/" + n)
-	if err != nil {
-		return fmt.Errorf("createTable: error reading query from %s, %v", "migrations/"+n, err)
+// Reader returns a handle for read-only statements that can tolerate replication lag, like
+// listings and aggregates. Replicas are used in turn; without any the primary is returned.
+// Statements that must see the caller's own writes should keep using d. Inside a unit of work
+// (see Transact) statements run in its transaction on the primary.
+func (d DB) Reader() DB {
+	if d.replicas == nil || len(d.replicas.dbs) == 0 {
+		return d
 	}
 
-	_, err = d.Exec(q)
-	if err != nil {
-		return fmt.Errorf("createTable: error while executing create table query: %v", err)
-	}
+	i := d.replicas.next.Add(1) - 1
+	d.DB = d.replicas.dbs[i%uint64(len(d.replicas.dbs))]
+	return d
+}
 
-	if err = d.registerMigration(n); err != nil {
-		return fmt.Errorf("createTable: %v", err)
+// Replicas returns the connection pools of the read replicas, e.g. for health checks.
+func (d DB) Replicas() []*sql.DB {
+	if d.replicas == nil {
+		return nil
 	}
-
-	return nil
+	return d.replicas.dbs
 }
 
-func (d DB) registerMigration(n string) error {
-	q, err := GetQuery("queries/utils/insertMigration.sql")
-	if err != nil {
-		return fmt.Errorf("registerMigration: error while reading query: %v", err)
-	}
-	q = fmt.Sprintf(q, n, time.Now().Format(time.RFC3339))
-	_, err = d.Exec(q)
-	if err != nil {
-		return fmt.Errorf("registerMigration: error while executing query: %v", err)
+// Close closes the primary and every replica. Handles returned by Reader share the pools, so
+// it must be called once, on the handle returned by Open.
+func (d DB) Close() error {
+	errs := []error{d.DB.Close()}
+	for _, r := range d.Replicas() {
+		errs = append(errs, r.Close())
 	}
-	return nil
+	return errors.Join(errs...)
 }
 
-func (d DB) getMigrated() ([]string, error) {
-	q, err := GetQuery("queries/utils/readMigrationsTable.sql")
-	if err != nil {
-		return nil, fmt.Errorf("db: getMigrated: error while reading migrations table: %v", err)
+// Driver reports the database the handle is connected to, config.DBDriverPostgres or
+// config.DBDriverSQLite.
+func (d DB) Driver() string {
+	if d.driver == "" {
+		return config.DBDriverPostgres
 	}
+	return d.driver
+}
 
-	var n string
-	rows, err := d.Query(q)
+func GetQuery(path string) (string, error) {
+	data, err := SQLFiles.ReadFile(path)
 	if err != nil {
-		return nil, fmt.Errorf("getMigrated: error while executing query: %v", err)
+		return "", fmt.Errorf("GetQuery: unable to read embedded query from path %s: %v", path, err)
 	}
-	defer rows.Close()
+	registerQueryName(path, string(data))
+
+	return string(data), nil
+}
 
-	var list []string
-	for rows.Next() {
-		if err = rows.Scan(&n); err != nil {
-			return nil, fmt.Errorf("getMigrated: error while reading rows: %v", err)
+// GetQuery reads an embedded file like the package level GetQuery, but prefers its variant
+// under sqlite/ when d is a SQLite database. Files without a variant are shared by both.
+func (d DB) GetQuery(path string) (string, error) {
+	if d.Driver() == config.DBDriverSQLite {
+		if data, err := SQLFiles.ReadFile(sqliteDir + path); err == nil {
+			registerQueryName(path, string(data))
+			return string(data), nil
 		}
-		list = append(list, n)
 	}
 
-	return list, nil
+	return GetQuery(path)
+}
+
+// ForUpdate appends a row lock to a select statement. SQLite has no row locks, writers lock
+// the whole database, so the statement is returned unchanged there.
+func (d DB) ForUpdate(q string) string {
+	if d.Driver() == config.DBDriverSQLite {
+		return q
+	}
+	return fmt.Sprintf("%s for update", q)
 }
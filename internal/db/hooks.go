@@ -0,0 +1,230 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"strings"
+	"sync"
+	"task-manager/internal/config"
+	"time"
+)
+
+// QueryHook is called before a statement is executed with the name of the embedded SQL file it came
+// from. The returned context is used for the statement and the returned func is called with its outcome.
+type QueryHook func(ctx context.Context, name string) (context.Context, func(err error))
+
+type hookSet struct {
+	mu    sync.RWMutex
+	hooks []QueryHook
+}
+
+const unknownQuery = "unknown"
+
+var queryNames sync.Map
+
+// AddQueryHook registers h for every statement executed through d and transactions started from it.
+func (d DB) AddQueryHook(h QueryHook) {
+	d.hooks.mu.Lock()
+	defer d.hooks.mu.Unlock()
+	d.hooks.hooks = append(d.hooks.hooks, h)
+}
+
+func (d DB) before(ctx context.Context, query string) (context.Context, func(error)) {
+	if d.hooks == nil {
+		return ctx, func(error) {}
+	}
+
+	d.hooks.mu.RLock()
+	hooks := d.hooks.hooks
+	d.hooks.mu.RUnlock()
+	if len(hooks) == 0 {
+		return ctx, func(error) {}
+	}
+
+	name := QueryName(query)
+	after := make([]func(error), 0, len(hooks))
+	for _, h := range hooks {
+		var done func(error)
+		ctx, done = h(ctx, name)
+		after = append(after, done)
+	}
+
+	return ctx, func(err error) {
+		for i := len(after) - 1; i >= 0; i-- {
+			after[i](err)
+		}
+	}
+}
+
+func registerQueryName(path, query string) {
+	queryNames.Store(query, strings.TrimPrefix(path, "queries/"))
+}
+
+// QueryName resolves the embedded file a statement was read from. Statements built from a file
+// with fmt.Sprintf or with a suffix appended (e.g. "for update") resolve to the same file.
+func QueryName(query string) string {
+	if n, ok := queryNames.Load(query); ok {
+		return n.(string)
+	}
+
+	name := unknownQuery
+	queryNames.Range(func(k, v any) bool {
+		tmpl := k.(string)
+		if i := strings.Index(tmpl, "%"); i >= 0 {
+			tmpl = tmpl[:i]
+		}
+		if tmpl != "" && strings.HasPrefix(query, tmpl) {
+			name = v.(string)
+			return false
+		}
+		return true
+	})
+
+	return name
+}
+
+// bind prepares statement arguments for the driver. SQLite stores times as text, so they are
+// converted to UTC for stored values to compare in chronological order.
+func (d DB) bind(args []any) []any {
+	if d.Driver() != config.DBDriverSQLite {
+		return args
+	}
+
+	out := make([]any, len(args))
+	for i, a := range args {
+		switch v := a.(type) {
+		case time.Time:
+			out[i] = v.UTC()
+		case *time.Time:
+			if v != nil {
+				out[i] = v.UTC()
+			} else {
+				out[i] = nil
+			}
+		default:
+			out[i] = a
+		}
+	}
+
+	return out
+}
+
+func (d DB) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	if u := d.unitFrom(ctx); u != nil {
+		return u.tx.QueryContext(ctx, query, args...)
+	}
+	ctx, done := d.before(ctx, query)
+	rows, err := d.DB.QueryContext(ctx, query, d.bind(args)...)
+	done(err)
+	return rows, err
+}
+
+func (d DB) Query(query string, args ...any) (*sql.Rows, error) {
+	return d.QueryContext(context.Background(), query, args...)
+}
+
+func (d DB) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	if u := d.unitFrom(ctx); u != nil {
+		return u.tx.QueryRowContext(ctx, query, args...)
+	}
+	ctx, done := d.before(ctx, query)
+	row := d.DB.QueryRowContext(ctx, query, d.bind(args)...)
+	done(row.Err())
+	return row
+}
+
+func (d DB) QueryRow(query string, args ...any) *sql.Row {
+	return d.QueryRowContext(context.Background(), query, args...)
+}
+
+func (d DB) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	if u := d.unitFrom(ctx); u != nil {
+		return u.tx.ExecContext(ctx, query, args...)
+	}
+	ctx, done := d.before(ctx, query)
+	res, err := d.DB.ExecContext(ctx, query, d.bind(args)...)
+	done(err)
+	return res, err
+}
+
+func (d DB) Exec(query string, args ...any) (sql.Result, error) {
+	return d.ExecContext(context.Background(), query, args...)
+}
+
+// Tx wraps sql.Tx so statements executed inside a transaction reach the query hooks as well.
+// A Tx started while ctx already carries a unit of work (see Transact) is a savepoint of it.
+type Tx struct {
+	*sql.Tx
+	d         DB
+	unit      *unit
+	savepoint string
+}
+
+func (d DB) BeginTx(ctx context.Context, opts *sql.TxOptions) (*Tx, error) {
+	if u := d.unitFrom(ctx); u != nil {
+		return u.savepoint(ctx)
+	}
+	tx, err := d.DB.BeginTx(ctx, opts)
+	if err != nil {
+		return nil, err
+	}
+	return &Tx{Tx: tx, d: d}, nil
+}
+
+func (d DB) Begin() (*Tx, error) {
+	return d.BeginTx(context.Background(), nil)
+}
+
+// Commit commits the transaction or, for a savepoint, releases it into the enclosing one.
+func (t *Tx) Commit() error {
+	if t.savepoint == "" {
+		return t.Tx.Commit()
+	}
+	_, err := t.ExecContext(context.Background(), "release savepoint "+t.savepoint)
+	return err
+}
+
+// Rollback aborts the transaction or, for a savepoint, undoes what was done since it was taken.
+func (t *Tx) Rollback() error {
+	if t.savepoint == "" {
+		return t.Tx.Rollback()
+	}
+	_, err := t.ExecContext(context.Background(), "rollback to savepoint "+t.savepoint)
+	return err
+}
+
+func (t *Tx) QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error) {
+	ctx, done := t.d.before(ctx, query)
+	rows, err := t.Tx.QueryContext(ctx, query, t.d.bind(args)...)
+	done(err)
+	t.observe(err)
+	return rows, err
+}
+
+func (t *Tx) Query(query string, args ...any) (*sql.Rows, error) {
+	return t.QueryContext(context.Background(), query, args...)
+}
+
+func (t *Tx) QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row {
+	ctx, done := t.d.before(ctx, query)
+	row := t.Tx.QueryRowContext(ctx, query, t.d.bind(args)...)
+	done(row.Err())
+	t.observe(row.Err())
+	return row
+}
+
+func (t *Tx) QueryRow(query string, args ...any) *sql.Row {
+	return t.QueryRowContext(context.Background(), query, args...)
+}
+
+func (t *Tx) ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error) {
+	ctx, done := t.d.before(ctx, query)
+	res, err := t.Tx.ExecContext(ctx, query, t.d.bind(args)...)
+	done(err)
+	t.observe(err)
+	return res, err
+}
+
+func (t *Tx) Exec(query string, args ...any) (sql.Result, error) {
+	return t.ExecContext(context.Background(), query, args...)
+}
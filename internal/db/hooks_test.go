@@ -0,0 +1,36 @@
+package db
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestQueryName(t *testing.T) {
+	getTask, err := GetQuery("queries/task/GetTask.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tableExists, err := GetQuery("queries/utils/tableExists.sql")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		query    string
+		expected string
+	}{
+		{"exact query", getTask, "task/GetTask.sql"},
+		{"query with suffix", fmt.Sprintf("%s for update", getTask), "task/GetTask.sql"},
+		{"formatted query", fmt.Sprintf(tableExists, "migrations"), "utils/tableExists.sql"},
+		{"unknown query", "select 1", unknownQuery},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if n := QueryName(tc.query); n != tc.expected {
+				t.Errorf("expected name %s but got %s", tc.expected, n)
+			}
+		})
+	}
+}
@@ -0,0 +1,522 @@
+package db
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"task-manager/internal/config"
+	"time"
+)
+
+// migrationLockID is the key of the Postgres advisory lock held while migrations run, so
+// instances starting at the same time apply them one after another.
+const migrationLockID int64 = 0x7461736b6d6772
+
+var migrationFile = regexp.MustCompile(`^(\d+)_(\w+)\.(up|down)\.sql$`)
+
+// Migration is a pair of embedded files, <version>_<name>.up.sql and the optional
+// <version>_<name>.down.sql. Checksum is the SHA-256 of the up file.
+type Migration struct {
+	Version  int64
+	Name     string
+	Up       string
+	Down     string
+	Checksum string
+}
+
+func (m Migration) String() string {
+	return fmt.Sprintf("%04d_%s", m.Version, m.Name)
+}
+
+// MigrationStatus is a migration together with its state in the database. Modified is set when
+// the up file changed after it was applied, Missing for applied versions without a file.
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time
+	Modified  bool
+	Missing   bool
+}
+
+// MigrateOptions controls a migration run. With DryRun set the statements are written to Out
+// instead of being executed and nothing is recorded.
+type MigrateOptions struct {
+	DryRun bool
+	Out    io.Writer
+}
+
+type appliedMigration struct {
+	name      string
+	checksum  string
+	appliedAt time.Time
+}
+
+// RunMigrations applies every pending migration.
+func (d DB) RunMigrations(ctx context.Context) error {
+	if _, err := d.MigrateUp(ctx, 0, MigrateOptions{}); err != nil {
+		return fmt.Errorf("db: RunMigrations: %v", err)
+	}
+	return nil
+}
+
+// PendingMigrations lists embedded migrations that were not yet applied.
+func (d DB) PendingMigrations(ctx context.Context) ([]string, error) {
+	st, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("db: PendingMigrations: %v", err)
+	}
+
+	var pending []string
+	for _, s := range st {
+		if s.AppliedAt == nil && !s.Missing {
+			pending = append(pending, s.String())
+		}
+	}
+
+	return pending, nil
+}
+
+// Migrations reads the embedded migrations ordered by version.
+func (d DB) Migrations() ([]Migration, error) {
+	entries, err := SQLFiles.ReadDir("migrations")
+	if err != nil {
+		return nil, fmt.Errorf("migrations: error while reading migrations dir: %v", err)
+	}
+
+	byVersion := make(map[int64]*Migration)
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		parts := migrationFile.FindStringSubmatch(e.Name())
+		if parts == nil {
+			return nil, fmt.Errorf("migrations: invalid file name %s, expected <version>_<name>.up.sql or .down.sql", e.Name())
+		}
+		v, err := strconv.ParseInt(parts[1], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("migrations: invalid version in %s: %v", e.Name(), err)
+		}
+
+		m, ok := byVersion[v]
+		if !ok {
+			m = &Migration{Version: v, Name: parts[2]}
+			byVersion[v] = m
+		} else if m.Name != parts[2] {
+			return nil, fmt.Errorf("migrations: %s and %s share version %d", m, e.Name(), v)
+		}
+
+		q, err := d.GetQuery("migrations/" + e.Name())
+		if err != nil {
+			return nil, fmt.Errorf("migrations: %v", err)
+		}
+		if parts[3] == "up" {
+			m.Up = q
+			m.Checksum = checksum(q)
+		} else {
+			m.Down = q
+		}
+	}
+
+	list := make([]Migration, 0, len(byVersion))
+	for _, m := range byVersion {
+		if m.Up == "" {
+			return nil, fmt.Errorf("migrations: %s has no up file", m)
+		}
+		list = append(list, *m)
+	}
+	slices.SortFunc(list, func(a, b Migration) int {
+		return int(a.Version - b.Version)
+	})
+
+	return list, nil
+}
+
+// MigrationStatus lists embedded and applied migrations ordered by version. It does not
+// change the database.
+func (d DB) MigrationStatus(ctx context.Context) ([]MigrationStatus, error) {
+	list, err := d.Migrations()
+	if err != nil {
+		return nil, err
+	}
+	applied, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	st := make([]MigrationStatus, 0, len(list))
+	for _, m := range list {
+		s := MigrationStatus{Migration: m}
+		if a, ok := applied[m.Version]; ok {
+			s.AppliedAt = &a.appliedAt
+			s.Modified = a.checksum != "" && a.checksum != m.Checksum
+			delete(applied, m.Version)
+		}
+		st = append(st, s)
+	}
+	for v, a := range applied {
+		s := MigrationStatus{
+			Migration: Migration{Version: v, Name: a.name, Checksum: a.checksum},
+			AppliedAt: &a.appliedAt,
+			Missing:   true,
+		}
+		st = append(st, s)
+	}
+	slices.SortFunc(st, func(a, b MigrationStatus) int {
+		return int(a.Version - b.Version)
+	})
+
+	return st, nil
+}
+
+// MigrateUp applies up to steps pending migrations, all of them when steps is 0, and returns
+// the applied ones. It refuses to run when an applied migration was modified or is missing.
+func (d DB) MigrateUp(ctx context.Context, steps int, o MigrateOptions) ([]Migration, error) {
+	unlock, err := d.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	st, err := d.prepareMigrations(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range st {
+		if s.AppliedAt != nil {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if err := d.applyMigration(ctx, s.Migration, true, o); err != nil {
+			return done, err
+		}
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// MigrateDown reverts up to steps applied migrations, newest first, all of them when steps
+// is 0, and returns the reverted ones.
+func (d DB) MigrateDown(ctx context.Context, steps int, o MigrateOptions) ([]Migration, error) {
+	unlock, err := d.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	st, err := d.prepareMigrations(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, s := range slices.Backward(st) {
+		if s.AppliedAt == nil {
+			continue
+		}
+		if steps > 0 && len(done) == steps {
+			break
+		}
+		if s.Down == "" {
+			return done, fmt.Errorf("migrate down: %s has no down file", s.Migration)
+		}
+		if err := d.applyMigration(ctx, s.Migration, false, o); err != nil {
+			return done, err
+		}
+		done = append(done, s.Migration)
+	}
+
+	return done, nil
+}
+
+// MigrateRedo reverts the last steps applied migrations and applies them again, which is
+// handy while writing a migration. It returns the migrations that were redone.
+func (d DB) MigrateRedo(ctx context.Context, steps int, o MigrateOptions) ([]Migration, error) {
+	unlock, err := d.lockMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	st, err := d.prepareMigrations(ctx, o)
+	if err != nil {
+		return nil, err
+	}
+
+	var redo []Migration
+	for _, s := range slices.Backward(st) {
+		if s.AppliedAt == nil {
+			continue
+		}
+		if steps > 0 && len(redo) == steps {
+			break
+		}
+		if s.Down == "" {
+			return nil, fmt.Errorf("migrate redo: %s has no down file", s.Migration)
+		}
+		redo = append(redo, s.Migration)
+	}
+
+	for _, m := range redo {
+		if err := d.applyMigration(ctx, m, false, o); err != nil {
+			return nil, err
+		}
+	}
+	slices.Reverse(redo)
+	for i, m := range redo {
+		if err := d.applyMigration(ctx, m, true, o); err != nil {
+			return redo[:i], err
+		}
+	}
+
+	return redo, nil
+}
+
+// prepareMigrations creates the schema_migrations table, taking over the versions recorded by
+// the previous migrations table, and checks the applied migrations against their files.
+func (d DB) prepareMigrations(ctx context.Context, o MigrateOptions) ([]MigrationStatus, error) {
+	if !o.DryRun {
+		if err := d.createMigrationsTable(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	st, err := d.MigrationStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range st {
+		if s.Modified {
+			return nil, fmt.Errorf("migrations: %s was modified after it was applied", s.Migration)
+		}
+		if s.Missing {
+			return nil, fmt.Errorf("migrations: applied migration %s has no file", s.Migration)
+		}
+	}
+
+	return st, nil
+}
+
+func (d DB) applyMigration(ctx context.Context, m Migration, up bool, o MigrateOptions) error {
+	direction, q := "up", m.Up
+	if !up {
+		direction, q = "down", m.Down
+	}
+
+	if o.DryRun {
+		if o.Out != nil {
+			_, _ = fmt.Fprintf(o.Out, "-- %s %s\n%s;\n\n", direction, m, strings.TrimSpace(q))
+		}
+		return nil
+	}
+
+	rq := "queries/migrate/InsertMigration.sql"
+	args := []any{m.Version, m.Name, m.Checksum, time.Now().UTC()}
+	if !up {
+		rq = "queries/migrate/DeleteMigration.sql"
+		args = []any{m.Version}
+	}
+	record, err := d.GetQuery(rq)
+	if err != nil {
+		return fmt.Errorf("migrate %s %s: %v", direction, m, err)
+	}
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("migrate %s %s: failed to begin tx: %v", direction, m, err)
+	}
+	if _, err := tx.ExecContext(ctx, q); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate %s %s: %v", direction, m, err)
+	}
+	if _, err := tx.ExecContext(ctx, record, args...); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("migrate %s %s: failed to record migration: %v", direction, m, err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("migrate %s %s: failed to commit tx: %v", direction, m, err)
+	}
+
+	return nil
+}
+
+// lockMigrations holds a Postgres advisory lock until the returned func is called. SQLite
+// needs none: each migration runs in a transaction that fails if another process recorded the
+// same version first.
+func (d DB) lockMigrations(ctx context.Context) (func(), error) {
+	if d.Driver() != config.DBDriverPostgres {
+		return func() {}, nil
+	}
+
+	lq, err := d.GetQuery("queries/migrate/AcquireLock.sql")
+	if err != nil {
+		return nil, fmt.Errorf("lockMigrations: %v", err)
+	}
+	uq, err := d.GetQuery("queries/migrate/ReleaseLock.sql")
+	if err != nil {
+		return nil, fmt.Errorf("lockMigrations: %v", err)
+	}
+
+	// advisory locks belong to a session, so lock and unlock have to use the same connection
+	conn, err := d.DB.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("lockMigrations: failed to get a connection: %v", err)
+	}
+	if _, err := conn.ExecContext(ctx, lq, migrationLockID); err != nil {
+		_ = conn.Close()
+		return nil, fmt.Errorf("lockMigrations: failed to acquire lock: %v", err)
+	}
+
+	return func() {
+		_, _ = conn.ExecContext(context.Background(), uq, migrationLockID)
+		_ = conn.Close()
+	}, nil
+}
+
+func (d DB) createMigrationsTable(ctx context.Context) error {
+	exists, err := d.tableExists(ctx, "schema_migrations")
+	if err != nil || exists {
+		return err
+	}
+
+	// read the versions recorded by the previous migrations table before creating the new one
+	legacy, err := d.appliedMigrations(ctx)
+	if err != nil {
+		return err
+	}
+	list, err := d.Migrations()
+	if err != nil {
+		return err
+	}
+	checksums := make(map[int64]string, len(list))
+	for _, m := range list {
+		checksums[m.Version] = m.Checksum
+	}
+
+	cq, err := d.GetQuery("queries/migrate/CreateSchemaMigrations.sql")
+	if err != nil {
+		return fmt.Errorf("createMigrationsTable: %v", err)
+	}
+	iq, err := d.GetQuery("queries/migrate/InsertMigration.sql")
+	if err != nil {
+		return fmt.Errorf("createMigrationsTable: %v", err)
+	}
+
+	tx, err := d.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("createMigrationsTable: failed to begin tx: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, cq); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("createMigrationsTable: failed to create table: %v", err)
+	}
+	for v, a := range legacy {
+		if _, err := tx.ExecContext(ctx, iq, v, a.name, checksums[v], a.appliedAt); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("createMigrationsTable: failed to copy migration %d: %v", v, err)
+		}
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("createMigrationsTable: failed to commit tx: %v", err)
+	}
+
+	return nil
+}
+
+// appliedMigrations reads schema_migrations or, before it exists, the file names recorded in
+// the previous migrations table. Versions read from the latter have no checksum.
+func (d DB) appliedMigrations(ctx context.Context) (map[int64]appliedMigration, error) {
+	applied := make(map[int64]appliedMigration)
+
+	exists, err := d.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		return nil, err
+	}
+	if exists {
+		q, err := d.GetQuery("queries/migrate/GetAppliedMigrations.sql")
+		if err != nil {
+			return nil, fmt.Errorf("appliedMigrations: %v", err)
+		}
+		rows, err := d.QueryContext(ctx, q)
+		if err != nil {
+			return nil, fmt.Errorf("appliedMigrations: failed to execute query: %v", err)
+		}
+		defer rows.Close()
+
+		for rows.Next() {
+			var v int64
+			var a appliedMigration
+			if err := rows.Scan(&v, &a.name, &a.checksum, &a.appliedAt); err != nil {
+				return nil, fmt.Errorf("appliedMigrations: failed to read rows: %v", err)
+			}
+			applied[v] = a
+		}
+		if err := rows.Err(); err != nil {
+			return nil, fmt.Errorf("appliedMigrations: query failed: %v", err)
+		}
+
+		return applied, nil
+	}
+
+	exists, err = d.tableExists(ctx, "migrations")
+	if err != nil || !exists {
+		return applied, err
+	}
+	q, err := d.GetQuery("queries/utils/readMigrationsTable.sql")
+	if err != nil {
+		return nil, fmt.Errorf("appliedMigrations: %v", err)
+	}
+	rows, err := d.QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("appliedMigrations: failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var n string
+		var at sql.NullTime
+		if err := rows.Scan(&n, &at); err != nil {
+			return nil, fmt.Errorf("appliedMigrations: failed to read rows: %v", err)
+		}
+		parts := strings.SplitN(strings.TrimSuffix(n, ".sql"), "_", 2)
+		v, err := strconv.ParseInt(parts[0], 10, 64)
+		if err != nil || len(parts) != 2 {
+			return nil, fmt.Errorf("appliedMigrations: invalid migration name %s", n)
+		}
+		applied[v] = appliedMigration{name: parts[1], appliedAt: at.Time}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("appliedMigrations: query failed: %v", err)
+	}
+
+	return applied, nil
+}
+
+func (d DB) tableExists(ctx context.Context, n string) (bool, error) {
+	q, err := d.GetQuery("queries/utils/tableExists.sql")
+	if err != nil {
+		return false, fmt.Errorf("tableExists: error while reading query: %v", err)
+	}
+	q = fmt.Sprintf(q, n)
+	var exists bool
+	err = d.QueryRowContext(ctx, q).Scan(&exists)
+	if err != nil {
+		return false, fmt.Errorf("tableExists: error while executing query: %v", err)
+	}
+	return exists, nil
+}
+
+func checksum(q string) string {
+	sum := sha256.Sum256([]byte(q))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"bytes"
+	"context"
+	"path/filepath"
+	"strings"
+	"task-manager/internal/config"
+	"testing"
+)
+
+func openSQLite(t *testing.T) *DB {
+	t.Helper()
+	d, err := Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: filepath.Join(t.TempDir(), "test.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = d.Close()
+	})
+	return d
+}
+
+func TestDB_Migrations(t *testing.T) {
+	for _, driver := range []string{config.DBDriverPostgres, config.DBDriverSQLite} {
+		t.Run(driver, func(t *testing.T) {
+			list, err := DB{driver: driver}.Migrations()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, m := range list {
+				if m.Version != int64(i+1) {
+					t.Errorf("expected version %d but got %s", i+1, m)
+				}
+				if m.Up == "" || m.Down == "" || m.Checksum == "" {
+					t.Errorf("%s should have an up and a down file", m)
+				}
+			}
+		})
+	}
+}
+
+func TestDB_MigrateUp(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+	list, err := d.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	done, err := d.MigrateUp(ctx, 2, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Version != 1 || done[1].Version != 2 {
+		t.Errorf("expected the first two migrations to be applied but got %v", done)
+	}
+
+	done, err = d.MigrateUp(ctx, 0, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != len(list)-2 {
+		t.Errorf("expected %d migrations to be applied but got %d", len(list)-2, len(done))
+	}
+
+	done, err = d.MigrateUp(ctx, 0, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 0 {
+		t.Errorf("applied migrations should be skipped but got %v", done)
+	}
+
+	pending, err := d.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("expected no pending migrations but got %v", pending)
+	}
+}
+
+func TestDB_MigrateDown(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+	if err := d.RunMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+	list, err := d.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+	last := list[len(list)-1]
+
+	done, err := d.MigrateDown(ctx, 1, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 1 || done[0].Version != last.Version {
+		t.Errorf("expected %s to be reverted but got %v", last, done)
+	}
+	pending, err := d.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 || pending[0] != last.String() {
+		t.Errorf("expected %s to be pending but got %v", last, pending)
+	}
+
+	// reverting everything has to leave a database every migration can be applied to again
+	if _, err := d.MigrateDown(ctx, 0, MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	exists, err := d.tableExists(ctx, "users")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("users table should be dropped")
+	}
+	if err := d.RunMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDB_MigrateUp_verifiesAppliedMigrations(t *testing.T) {
+	var tests = []struct {
+		name        string
+		statement   string
+		errorWanted string
+	}{
+		{"modified file", "update schema_migrations set checksum = 'edited' where version = 2", "0002_create_users_table was modified after it was applied"},
+		{"missing file", "insert into schema_migrations values (9999, 'removed', 'sum', '2030-01-02 03:04:05')", "applied migration 9999_removed has no file"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			d := openSQLite(t)
+			if err := d.RunMigrations(ctx); err != nil {
+				t.Fatal(err)
+			}
+			if _, err := d.Exec(tc.statement); err != nil {
+				t.Fatal(err)
+			}
+
+			_, err := d.MigrateUp(ctx, 0, MigrateOptions{})
+			if err == nil || !strings.Contains(err.Error(), tc.errorWanted) {
+				t.Errorf("expected error containing <%s> but got <%v>", tc.errorWanted, err)
+			}
+			_, err = d.MigrateDown(ctx, 1, MigrateOptions{})
+			if err == nil || !strings.Contains(err.Error(), tc.errorWanted) {
+				t.Errorf("expected error containing <%s> but got <%v>", tc.errorWanted, err)
+			}
+		})
+	}
+}
+
+func TestDB_MigrateUp_dryRun(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+
+	var out bytes.Buffer
+	done, err := d.MigrateUp(ctx, 0, MigrateOptions{DryRun: true, Out: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) == 0 {
+		t.Fatalf("dry run should report the pending migrations")
+	}
+	if !strings.Contains(out.String(), "-- up 0002_create_users_table\ncreate table if not exists users") {
+		t.Errorf("dry run should print the statements, got %s", out.String())
+	}
+
+	exists, err := d.tableExists(ctx, "schema_migrations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if exists {
+		t.Errorf("dry run should not change the database")
+	}
+}
+
+func TestDB_MigrateUp_legacyTable(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+	list, err := d.Migrations()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the previous engine recorded file names in the migrations table
+	for _, m := range list[:2] {
+		if _, err := d.Exec(m.Up); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, n := range []string{"0001_create_migrations_table.sql", "0002_create_users_table.sql"} {
+		if _, err := d.Exec("insert into migrations(name, created_at) values ($1, '2024-01-02T03:04:05Z')", n); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	done, err := d.MigrateUp(ctx, 0, MigrateOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) == 0 || done[0].Version != 3 {
+		t.Errorf("versions recorded by the migrations table should be skipped, got %v", done)
+	}
+
+	st, err := d.MigrationStatus(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range st {
+		if s.AppliedAt == nil || s.Modified || s.Missing {
+			t.Errorf("unexpected status of %s: %+v", s.Migration, s)
+		}
+	}
+}
+
+func TestDB_MigrateRedo(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+	if err := d.RunMigrations(ctx); err != nil {
+		t.Fatal(err)
+	}
+
+	var out bytes.Buffer
+	done, err := d.MigrateRedo(ctx, 2, MigrateOptions{DryRun: true, Out: &out})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(done) != 2 || done[0].Version >= done[1].Version {
+		t.Errorf("expected the last two migrations in order but got %v", done)
+	}
+	first, second := out.String(), ""
+	if i := strings.Index(first, "-- up"); i >= 0 {
+		first, second = first[:i], first[i:]
+	}
+	if strings.Count(first, "-- down") != 2 || strings.Count(second, "-- up") != 2 {
+		t.Errorf("dry run should print both down files before the up files, got %s", out.String())
+	}
+
+	if _, err := d.MigrateRedo(ctx, 1, MigrateOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := d.PendingMigrations(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 0 {
+		t.Errorf("redo should leave no pending migrations, got %v", pending)
+	}
+}
@@ -0,0 +1 @@
+drop table if exists migrations
\ No newline at end of file
@@ -0,0 +1 @@
+drop table if exists tasks
\ No newline at end of file
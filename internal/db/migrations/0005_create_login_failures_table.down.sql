@@ -0,0 +1 @@
+drop table if exists login_failures
\ No newline at end of file
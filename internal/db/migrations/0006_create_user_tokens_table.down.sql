@@ -0,0 +1,4 @@
+drop table if exists user_tokens;
+
+alter table users
+    drop column if exists email_verified_at
\ No newline at end of file
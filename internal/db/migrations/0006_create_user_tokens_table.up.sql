@@ -0,0 +1,14 @@
+alter table users
+    add column if not exists email_verified_at timestamptz;
+
+create table if not exists user_tokens
+(
+    id         serial primary key,
+    user_id    int          not null,
+    purpose    varchar(32)  not null,
+    token_hash varchar(64)  not null unique,
+    expires_at timestamptz  not null,
+    used_at    timestamptz,
+    created_at timestamptz  not null,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade
+)
\ No newline at end of file
@@ -0,0 +1,20 @@
+create table if not exists user_totp
+(
+    user_id      int primary key,
+    secret       varchar(64) not null,
+    confirmed_at timestamptz,
+    last_step    bigint,
+    created_at   timestamptz not null,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade
+);
+
+create table if not exists recovery_codes
+(
+    id         serial primary key,
+    user_id    int         not null,
+    code_hash  varchar(64) not null,
+    used_at    timestamptz,
+    created_at timestamptz not null,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade,
+    constraint recovery_codes_user_code unique (user_id, code_hash)
+)
\ No newline at end of file
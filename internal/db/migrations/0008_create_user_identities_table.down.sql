@@ -0,0 +1 @@
+drop table if exists user_identities
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table users
+    drop column if exists last_login_at,
+    drop column if exists last_failed_login_at
\ No newline at end of file
@@ -0,0 +1,3 @@
+alter table users
+    add column if not exists last_login_at timestamptz,
+    add column if not exists last_failed_login_at timestamptz
\ No newline at end of file
@@ -0,0 +1,3 @@
+drop trigger if exists record_task_event on tasks;
+drop function if exists record_task_event();
+drop table if exists task_events
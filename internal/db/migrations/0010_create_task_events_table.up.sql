@@ -0,0 +1,49 @@
+create table if not exists task_events
+(
+    id         bigserial primary key,
+    user_id    int         not null,
+    task_id    int         not null,
+    type       varchar(32) not null,
+    created_at timestamptz not null default now(),
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade
+);
+
+create index if not exists task_events_user_id_id on task_events (user_id, id);
+create index if not exists task_events_created_at on task_events (created_at);
+
+-- every change is recorded by the database, whoever makes it, and announced on the
+-- task_events channel with the id of the owner so other servers can wake their streams
+create or replace function record_task_event() returns trigger as
+$$
+declare
+    t    record;
+    kind varchar(32);
+begin
+    if tg_op = 'DELETE' then
+        t := old;
+        kind := 'task.deleted';
+    elsif tg_op = 'UPDATE' then
+        t := new;
+        kind := 'task.updated';
+    else
+        t := new;
+        kind := 'task.created';
+    end if;
+
+    -- tasks kept anonymously after their owner left have no one to notify
+    if t.created_by is null then
+        return null;
+    end if;
+
+    insert into task_events (user_id, task_id, type) values (t.created_by, t.id, kind);
+    perform pg_notify('task_events', t.created_by::text);
+    return null;
+end;
+$$ language plpgsql;
+
+drop trigger if exists record_task_event on tasks;
+create trigger record_task_event
+    after insert or update or delete
+    on tasks
+    for each row
+execute function record_task_event()
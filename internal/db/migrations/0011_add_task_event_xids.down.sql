@@ -0,0 +1,6 @@
+create index if not exists task_events_user_id_id on task_events (user_id, id);
+drop index if exists task_events_xid_id;
+drop index if exists task_events_user_id_xid_id;
+
+alter table task_events
+    drop column if exists xid
@@ -0,0 +1,10 @@
+-- event ids are taken from the sequence when an event is recorded, not when it is committed,
+-- so a stream reading "id > last id" could move past an event committed later with a lower
+-- id. Each event keeps the transaction that recorded it instead: streams read events in
+-- transaction order and only up to the oldest transaction still running.
+alter table task_events
+    add column if not exists xid xid8 not null default pg_current_xact_id();
+
+create index if not exists task_events_user_id_xid_id on task_events (user_id, xid, id);
+create index if not exists task_events_xid_id on task_events (xid, id);
+drop index if exists task_events_user_id_id
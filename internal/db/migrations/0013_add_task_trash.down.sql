@@ -0,0 +1,37 @@
+-- without the column trashed tasks would come back, their owners were told they are deleted
+delete
+from tasks
+where deleted_at is not null;
+
+create or replace function record_task_event() returns trigger as
+$$
+declare
+    t    record;
+    kind varchar(32);
+begin
+    if tg_op = 'DELETE' then
+        t := old;
+        kind := 'task.deleted';
+    elsif tg_op = 'UPDATE' then
+        t := new;
+        kind := 'task.updated';
+    else
+        t := new;
+        kind := 'task.created';
+    end if;
+
+    -- tasks kept anonymously after their owner left have no one to notify
+    if t.created_by is null then
+        return null;
+    end if;
+
+    insert into task_events (user_id, task_id, type) values (t.created_by, t.id, kind);
+    perform pg_notify('task_events', t.created_by::text);
+    return null;
+end;
+$$ language plpgsql;
+
+drop index if exists tasks_deleted_at;
+
+alter table tasks
+    drop column if exists deleted_at
@@ -0,0 +1,45 @@
+-- deleted tasks stay in the trash until an operator purges it
+alter table tasks
+    add column if not exists deleted_at timestamptz;
+
+create index if not exists tasks_deleted_at on tasks (deleted_at) where deleted_at is not null;
+
+-- moving a task to the trash is what deleting it means to its owner. Changes to trashed tasks,
+-- and purging them, announce nothing.
+create or replace function record_task_event() returns trigger as
+$$
+declare
+    t    record;
+    kind varchar(32);
+begin
+    if tg_op = 'DELETE' then
+        if old.deleted_at is not null then
+            return null;
+        end if;
+        t := old;
+        kind := 'task.deleted';
+    elsif tg_op = 'UPDATE' then
+        if old.deleted_at is not null then
+            return null;
+        end if;
+        t := new;
+        if new.deleted_at is not null then
+            kind := 'task.deleted';
+        else
+            kind := 'task.updated';
+        end if;
+    else
+        t := new;
+        kind := 'task.created';
+    end if;
+
+    -- tasks kept anonymously after their owner left have no one to notify
+    if t.created_by is null then
+        return null;
+    end if;
+
+    insert into task_events (user_id, task_id, type) values (t.created_by, t.id, kind);
+    perform pg_notify('task_events', t.created_by::text);
+    return null;
+end;
+$$ language plpgsql
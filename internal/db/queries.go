@@ -2,5 +2,5 @@ package db
 
 import "embed"
 
-//go:embed queries/task/*.sql queries/user/*.sql queries/utils/*.sql migrations/*.sql
+//go:embed queries/task/*.sql queries/user/*.sql queries/utils/*.sql queries/ratelimit/*.sql queries/token/*.sql queries/mfa/*.sql queries/identity/*.sql queries/event/*.sql queries/migrate/*.sql migrations/*.sql sqlite
 var SQLFiles embed.FS
@@ -0,0 +1,3 @@
+delete
+from task_events
+where created_at < $1
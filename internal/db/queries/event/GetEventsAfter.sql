@@ -0,0 +1,20 @@
+-- events are read in the order of the transactions that recorded them, starting after the
+-- given event, or after its id when it was pruned. The last column tells whether every
+-- transaction that could still record an event before this one has ended.
+with after as (select xid
+               from task_events
+               where id = $2)
+select e.id,
+       e.type,
+       e.task_id,
+       e.user_id,
+       e.created_at,
+       e.xid < pg_snapshot_xmin(pg_current_snapshot())
+from task_events e
+where e.user_id = $1
+  and case
+          when exists(select from after) then (e.xid, e.id) > ((select xid from after), $2)
+          else e.id > $2
+    end
+order by e.xid, e.id
+limit $3
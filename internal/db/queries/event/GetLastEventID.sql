@@ -0,0 +1,6 @@
+-- the last event no transaction still running can record an event before
+select coalesce((select id
+                 from task_events
+                 where xid < pg_snapshot_xmin(pg_current_snapshot())
+                 order by xid desc, id desc
+                 limit 1), 0)
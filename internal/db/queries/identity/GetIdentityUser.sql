@@ -0,0 +1,4 @@
+select user_id
+from user_identities
+where issuer = $1
+and subject = $2
\ No newline at end of file
@@ -0,0 +1,2 @@
+insert into user_identities(user_id, issuer, subject, created_at)
+values ($1, $2, $3, $4)
\ No newline at end of file
@@ -0,0 +1,5 @@
+update user_totp
+set confirmed_at = $2,
+    last_step = $3
+where user_id = $1
+and confirmed_at is null
\ No newline at end of file
@@ -0,0 +1,2 @@
+delete from user_totp
+where user_id = $1
\ No newline at end of file
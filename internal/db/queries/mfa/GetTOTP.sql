@@ -0,0 +1,3 @@
+select user_id, secret, confirmed_at, last_step
+from user_totp
+where user_id = $1
\ No newline at end of file
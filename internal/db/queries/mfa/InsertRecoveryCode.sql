@@ -0,0 +1,2 @@
+insert into recovery_codes(user_id, code_hash, created_at)
+values ($1, $2, $3)
\ No newline at end of file
@@ -0,0 +1,5 @@
+update user_totp
+set last_step = $2
+where user_id = $1
+and confirmed_at is not null
+and (last_step is null or last_step < $2)
\ No newline at end of file
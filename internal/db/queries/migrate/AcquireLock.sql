@@ -0,0 +1 @@
+select pg_advisory_lock($1)
\ No newline at end of file
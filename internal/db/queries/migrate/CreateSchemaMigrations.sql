@@ -0,0 +1,7 @@
+create table if not exists schema_migrations
+(
+    version    bigint primary key,
+    name       varchar(255) not null,
+    checksum   varchar(64)  not null,
+    applied_at timestamp    not null
+)
\ No newline at end of file
@@ -0,0 +1,3 @@
+select version, name, checksum, applied_at
+from schema_migrations
+order by version
\ No newline at end of file
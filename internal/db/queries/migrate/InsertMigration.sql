@@ -0,0 +1,2 @@
+insert into schema_migrations(version, name, checksum, applied_at)
+values ($1, $2, $3, $4)
\ No newline at end of file
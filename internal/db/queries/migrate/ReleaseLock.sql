@@ -0,0 +1 @@
+select pg_advisory_unlock($1)
\ No newline at end of file
@@ -0,0 +1,3 @@
+delete
+from rate_limits
+where updated_at < $1
\ No newline at end of file
@@ -0,0 +1,4 @@
+delete
+from login_failures
+where updated_at < $1
+  and (locked_until is null or locked_until < $2)
\ No newline at end of file
@@ -0,0 +1,4 @@
+select tokens, updated_at
+from rate_limits
+where key = $1
+for update
\ No newline at end of file
@@ -0,0 +1,3 @@
+select failures, locked_until
+from login_failures
+where key = $1
\ No newline at end of file
@@ -0,0 +1,3 @@
+insert into rate_limits(key, tokens, updated_at)
+values ($1, $2, $3)
+on conflict (key) do nothing
\ No newline at end of file
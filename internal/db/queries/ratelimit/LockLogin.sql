@@ -0,0 +1,3 @@
+update login_failures
+set locked_until = $2
+where key = $1
\ No newline at end of file
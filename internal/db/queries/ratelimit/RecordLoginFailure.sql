@@ -0,0 +1,6 @@
+insert into login_failures(key, failures, locked_until, updated_at)
+values ($1, 1, null, $2)
+on conflict (key) do update
+set failures = case when login_failures.updated_at < $3 then 1 else login_failures.failures + 1 end,
+    updated_at = $2
+returning failures
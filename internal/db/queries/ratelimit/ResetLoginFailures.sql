@@ -0,0 +1,2 @@
+delete from login_failures
+where key = $1
\ No newline at end of file
@@ -0,0 +1,4 @@
+update rate_limits
+set tokens = $2,
+    updated_at = $3
+where key = $1
\ No newline at end of file
@@ -0,0 +1,3 @@
+update tasks
+set created_by = null
+where created_by = $1
\ No newline at end of file
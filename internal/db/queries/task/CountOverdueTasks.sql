@@ -0,0 +1,4 @@
+select count(*)
+from tasks
+where due_date < $1
+  and deleted_at is null
\ No newline at end of file
@@ -1,2 +1,4 @@
-delete from tasks
-where id=$1
\ No newline at end of file
+update tasks
+set deleted_at = $2
+where id = $1
+  and deleted_at is null
\ No newline at end of file
@@ -0,0 +1,2 @@
+delete from tasks
+where created_by = $1
\ No newline at end of file
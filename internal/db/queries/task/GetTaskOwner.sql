@@ -3,4 +3,5 @@ select exists(
     from tasks
     where created_by = $1
     and id = $2
+    and deleted_at is null
 )
\ No newline at end of file
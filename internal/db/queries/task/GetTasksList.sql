@@ -1,3 +1,4 @@
 select id, name, priority, description, due_date, created_at, created_by
 from tasks
-where created_by=$1
\ No newline at end of file
+where created_by=$1
+  and deleted_at is null
\ No newline at end of file
@@ -1,2 +1,3 @@
 insert into tasks(name, priority, description, due_date, created_at, created_by)
-values ($1,$2,$3,$4,$5,$6)
\ No newline at end of file
+values ($1,$2,$3,$4,$5,$6)
+returning id, name, priority, description, due_date, created_at, created_by
\ No newline at end of file
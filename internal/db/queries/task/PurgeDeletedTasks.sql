@@ -0,0 +1,3 @@
+delete
+from tasks
+where deleted_at < $1
\ No newline at end of file
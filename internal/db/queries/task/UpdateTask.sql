@@ -3,4 +3,5 @@ set name = $1,
     priority = $2,
     description = $3,
     due_date = $4
-where id = $5
\ No newline at end of file
+where id = $5
+  and deleted_at is null
\ No newline at end of file
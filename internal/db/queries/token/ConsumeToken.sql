@@ -0,0 +1,7 @@
+update user_tokens
+set used_at = $3
+where token_hash = $1
+  and purpose = $2
+  and used_at is null
+  and expires_at > $3
+returning user_id
\ No newline at end of file
@@ -0,0 +1,4 @@
+delete from user_tokens
+where user_id = $1
+  and purpose = $2
+  and used_at is null
\ No newline at end of file
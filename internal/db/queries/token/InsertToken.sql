@@ -0,0 +1,2 @@
+insert into user_tokens(user_id, purpose, token_hash, expires_at, created_at)
+values ($1, $2, $3, $4, $5)
\ No newline at end of file
@@ -0,0 +1,4 @@
+select id, name, email, password, created_at, email_verified_at,
+       last_login_at, role
+from users
+where id=$1
\ No newline at end of file
@@ -0,0 +1,4 @@
+insert into users(name, email, password, created_at, email_verified_at)
+values ($1, $2, $3, $4, $5)
+returning id, name, email, password, created_at, email_verified_at,
+          last_login_at
\ No newline at end of file
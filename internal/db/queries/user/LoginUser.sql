@@ -1,3 +0,0 @@
-select id, name, email, password, created_at
-from users
-where email=$1
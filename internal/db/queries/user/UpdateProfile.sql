@@ -0,0 +1,7 @@
+update users
+set name = $2,
+    email = $3,
+    email_verified_at = case when email = $3 then email_verified_at end
+where id = $1
+returning id, name, email, password, created_at, email_verified_at,
+          last_login_at, role
\ No newline at end of file
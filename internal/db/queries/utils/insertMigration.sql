@@ -1,2 +0,0 @@
-insert into migrations(name, created_at)
-values ('%s', '%v')
\ No newline at end of file
@@ -1,2 +1,2 @@
-select name
+select name, created_at
 from migrations;
\ No newline at end of file
@@ -0,0 +1,6 @@
+create table if not exists migrations
+(
+    id         integer primary key autoincrement,
+    name       varchar(255) unique,
+    created_at timestamp
+)
\ No newline at end of file
@@ -0,0 +1,8 @@
+create table if not exists users
+(
+    id         integer primary key autoincrement,
+    name       varchar(255)        not null,
+    email      varchar(255) unique not null,
+    password   varchar(255)        not null,
+    created_at timestamp
+)
\ No newline at end of file
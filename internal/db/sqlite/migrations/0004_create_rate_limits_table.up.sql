@@ -0,0 +1,6 @@
+create table if not exists rate_limits
+(
+    key        varchar(255) primary key,
+    tokens     double precision not null,
+    updated_at timestamp        not null
+)
\ No newline at end of file
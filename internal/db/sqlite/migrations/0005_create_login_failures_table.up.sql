@@ -0,0 +1,7 @@
+create table if not exists login_failures
+(
+    key          varchar(255) primary key,
+    failures     int       not null,
+    locked_until timestamp,
+    updated_at   timestamp not null
+)
\ No newline at end of file
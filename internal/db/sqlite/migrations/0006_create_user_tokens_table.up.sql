@@ -0,0 +1,14 @@
+alter table users
+    add column email_verified_at timestamp;
+
+create table if not exists user_tokens
+(
+    id         integer primary key autoincrement,
+    user_id    int         not null,
+    purpose    varchar(32) not null,
+    token_hash varchar(64) not null unique,
+    expires_at timestamp   not null,
+    used_at    timestamp,
+    created_at timestamp   not null,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade
+)
\ No newline at end of file
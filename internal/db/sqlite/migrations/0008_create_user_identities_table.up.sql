@@ -0,0 +1,10 @@
+create table if not exists user_identities
+(
+    id         integer primary key autoincrement,
+    user_id    int          not null,
+    issuer     varchar(255) not null,
+    subject    varchar(255) not null,
+    created_at timestamp    not null,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade,
+    constraint user_identities_issuer_subject unique (issuer, subject)
+)
\ No newline at end of file
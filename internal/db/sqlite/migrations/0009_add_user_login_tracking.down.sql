@@ -0,0 +1,5 @@
+alter table users
+    drop column last_login_at;
+
+alter table users
+    drop column last_failed_login_at
\ No newline at end of file
@@ -0,0 +1,5 @@
+alter table users
+    add column last_login_at timestamp;
+
+alter table users
+    add column last_failed_login_at timestamp
\ No newline at end of file
@@ -0,0 +1,4 @@
+drop trigger if exists record_task_created;
+drop trigger if exists record_task_updated;
+drop trigger if exists record_task_deleted;
+drop table if exists task_events
@@ -0,0 +1,36 @@
+create table if not exists task_events
+(
+    id         integer primary key autoincrement,
+    user_id    int         not null,
+    task_id    int         not null,
+    type       varchar(32) not null,
+    created_at timestamp   not null default current_timestamp,
+    constraint fk_user_id foreign key (user_id) references users (id) on delete cascade
+);
+
+create index if not exists task_events_user_id_id on task_events (user_id, id);
+create index if not exists task_events_created_at on task_events (created_at);
+
+create trigger if not exists record_task_created
+    after insert
+    on tasks
+    when new.created_by is not null
+begin
+    insert into task_events (user_id, task_id, type) values (new.created_by, new.id, 'task.created');
+end;
+
+create trigger if not exists record_task_updated
+    after update
+    on tasks
+    when new.created_by is not null
+begin
+    insert into task_events (user_id, task_id, type) values (new.created_by, new.id, 'task.updated');
+end;
+
+create trigger if not exists record_task_deleted
+    after delete
+    on tasks
+    when old.created_by is not null
+begin
+    insert into task_events (user_id, task_id, type) values (old.created_by, old.id, 'task.deleted');
+end
@@ -0,0 +1 @@
+select 1
@@ -0,0 +1,2 @@
+-- SQLite runs one write transaction at a time, so events are committed in id order
+select 1
@@ -0,0 +1,2 @@
+alter table users
+    add column role varchar(16) not null default 'user'
\ No newline at end of file
@@ -0,0 +1,28 @@
+delete
+from tasks
+where deleted_at is not null;
+
+drop trigger if exists record_task_trashed;
+drop trigger if exists record_task_updated;
+drop trigger if exists record_task_deleted;
+
+create trigger if not exists record_task_updated
+    after update
+    on tasks
+    when new.created_by is not null
+begin
+    insert into task_events (user_id, task_id, type) values (new.created_by, new.id, 'task.updated');
+end;
+
+create trigger if not exists record_task_deleted
+    after delete
+    on tasks
+    when old.created_by is not null
+begin
+    insert into task_events (user_id, task_id, type) values (old.created_by, old.id, 'task.deleted');
+end;
+
+drop index if exists tasks_deleted_at;
+
+alter table tasks
+    drop column deleted_at
@@ -0,0 +1,31 @@
+alter table tasks
+    add column deleted_at timestamp;
+
+create index if not exists tasks_deleted_at on tasks (deleted_at) where deleted_at is not null;
+
+drop trigger if exists record_task_updated;
+drop trigger if exists record_task_deleted;
+
+create trigger if not exists record_task_updated
+    after update
+    on tasks
+    when new.created_by is not null and old.deleted_at is null and new.deleted_at is null
+begin
+    insert into task_events (user_id, task_id, type) values (new.created_by, new.id, 'task.updated');
+end;
+
+create trigger if not exists record_task_trashed
+    after update
+    on tasks
+    when new.created_by is not null and old.deleted_at is null and new.deleted_at is not null
+begin
+    insert into task_events (user_id, task_id, type) values (new.created_by, new.id, 'task.deleted');
+end;
+
+create trigger if not exists record_task_deleted
+    after delete
+    on tasks
+    when old.created_by is not null and old.deleted_at is null
+begin
+    insert into task_events (user_id, task_id, type) values (old.created_by, old.id, 'task.deleted');
+end
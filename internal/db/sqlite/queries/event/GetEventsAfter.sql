@@ -0,0 +1,6 @@
+select id, type, task_id, user_id, created_at, 1
+from task_events
+where user_id = $1
+  and id > $2
+order by id
+limit $3
@@ -0,0 +1,5 @@
+select id, name, email, password, created_at, email_verified_at,
+       last_login_at, role
+from users
+where id in (select value from json_each($1))
+order by id
\ No newline at end of file
@@ -0,0 +1,6 @@
+select exists(
+    select 1
+    from sqlite_master
+    where type = 'table'
+    and name = '%s'
+)
\ No newline at end of file
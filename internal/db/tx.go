@@ -0,0 +1,124 @@
+package db
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+const (
+	// MaxTxAttempts bounds how often Transact runs a unit of work the database aborted.
+	MaxTxAttempts = 5
+
+	txRetryDelay = 10 * time.Millisecond
+)
+
+// Postgres error codes after which a transaction can succeed when it is run again.
+const (
+	codeSerializationFailure = "40001"
+	codeDeadlockDetected     = "40P01"
+)
+
+type unitKey struct{}
+
+// unit is the transaction shared by every statement executed with the context passed to the
+// func given to Transact.
+type unit struct {
+	tx *Tx
+	// owner is allocated once per Open and shared by every copy of the handle, so statements
+	// of another database never join the unit.
+	owner      *hookSet
+	savepoints int
+	retry      bool
+}
+
+func (d DB) unitFrom(ctx context.Context) *unit {
+	u, _ := ctx.Value(unitKey{}).(*unit)
+	if u == nil || u.owner != d.hooks {
+		return nil
+	}
+	return u
+}
+
+func (u *unit) savepoint(ctx context.Context) (*Tx, error) {
+	u.savepoints++
+	t := &Tx{Tx: u.tx.Tx, d: u.tx.d, unit: u, savepoint: fmt.Sprintf("sp_%d", u.savepoints)}
+	if _, err := t.ExecContext(ctx, "savepoint "+t.savepoint); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// observe remembers when a statement failed in a way that is resolved by running the unit
+// again. Repositories flatten the errors they return, so Transact cannot tell from fn's error.
+func (t *Tx) observe(err error) {
+	if t.unit != nil && retryable(err) {
+		t.unit.retry = true
+	}
+}
+
+func retryable(err error) bool {
+	var pqErr *pq.Error
+	if !errors.As(err, &pqErr) {
+		return false
+	}
+	return pqErr.Code == codeSerializationFailure || pqErr.Code == codeDeadlockDetected
+}
+
+// Transact runs fn in a transaction. Every statement executed through d with the context passed
+// to fn is part of it, including transactions repositories begin themselves, which become
+// savepoints. The transaction is committed when fn returns nil and rolled back otherwise.
+//
+// When Postgres aborts the transaction because of a serialization failure or a deadlock, fn is
+// run again in a new transaction, at most MaxTxAttempts times in total, so fn must not have
+// effects outside the database. Calling Transact inside fn joins the enclosing transaction.
+// Statements of one unit must not be executed concurrently.
+func (d DB) Transact(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) error {
+	if d.unitFrom(ctx) != nil {
+		return fn(ctx)
+	}
+
+	for attempt := 1; ; attempt++ {
+		retry, err := d.transact(ctx, opts, fn)
+		if err == nil || !retry || attempt == MaxTxAttempts {
+			return err
+		}
+
+		delay := txRetryDelay<<(attempt-1) + rand.N(txRetryDelay)
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w (retry canceled: %v)", err, ctx.Err())
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (d DB) transact(ctx context.Context, opts *sql.TxOptions, fn func(ctx context.Context) error) (retry bool, err error) {
+	tx, err := d.BeginTx(ctx, opts)
+	if err != nil {
+		return retryable(err), err
+	}
+	u := &unit{tx: tx, owner: d.hooks}
+	tx.unit = u
+
+	defer func() {
+		if p := recover(); p != nil {
+			_ = tx.Rollback()
+			panic(p)
+		}
+	}()
+
+	if err := fn(context.WithValue(ctx, unitKey{}, u)); err != nil {
+		_ = tx.Rollback()
+		return u.retry, err
+	}
+	if err := tx.Commit(); err != nil {
+		return u.retry || retryable(err), err
+	}
+	return false, nil
+}
@@ -0,0 +1,123 @@
+package db
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/lib/pq"
+)
+
+func TestDB_Transact(t *testing.T) {
+	errFailed := errors.New("failed")
+	deadlock := &pq.Error{Code: codeDeadlockDetected}
+
+	var tests = []struct {
+		name string
+		// fail returns the error of the given attempt and whether the database reported a
+		// conflict before it
+		fail             func(attempt int) (error, bool)
+		expectedErr      error
+		expectedAttempts int
+		expectedRows     int
+	}{
+		{"commit", func(int) (error, bool) { return nil, false }, nil, 1, 1},
+		{"rollback", func(int) (error, bool) { return errFailed, false }, errFailed, 1, 0},
+		{"retry after a conflict", func(attempt int) (error, bool) {
+			if attempt == 1 {
+				return errFailed, true
+			}
+			return nil, false
+		}, nil, 2, 1},
+		{"attempts are bounded", func(int) (error, bool) { return errFailed, true }, errFailed, MaxTxAttempts, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			d := openSQLite(t)
+			if _, err := d.Exec("create table items (id integer primary key)"); err != nil {
+				t.Fatal(err)
+			}
+
+			attempts := 0
+			err := d.Transact(ctx, nil, func(ctx context.Context) error {
+				attempts++
+				if _, err := d.ExecContext(ctx, "insert into items default values"); err != nil {
+					return err
+				}
+				err, conflict := tc.fail(attempts)
+				if conflict {
+					d.unitFrom(ctx).tx.observe(deadlock)
+				}
+				return err
+			})
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error %v but got %v", tc.expectedErr, err)
+			}
+			if attempts != tc.expectedAttempts {
+				t.Errorf("expected %d attempts but got %d", tc.expectedAttempts, attempts)
+			}
+
+			var rows int
+			if err := d.QueryRow("select count(*) from items").Scan(&rows); err != nil {
+				t.Fatal(err)
+			}
+			if rows != tc.expectedRows {
+				t.Errorf("expected %d rows but got %d", tc.expectedRows, rows)
+			}
+		})
+	}
+}
+
+func TestDB_Transact_savepoints(t *testing.T) {
+	ctx := context.Background()
+	d := openSQLite(t)
+	if _, err := d.Exec("create table items (id integer primary key)"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := d.Transact(ctx, nil, func(ctx context.Context) error {
+		for _, commit := range []bool{true, false} {
+			tx, err := d.BeginTx(ctx, nil)
+			if err != nil {
+				return err
+			}
+			if _, err := tx.ExecContext(ctx, "insert into items default values"); err != nil {
+				return err
+			}
+			if commit {
+				err = tx.Commit()
+			} else {
+				err = tx.Rollback()
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rows int
+	if err := d.QueryRow("select count(*) from items").Scan(&rows); err != nil {
+		t.Fatal(err)
+	}
+	if rows != 1 {
+		t.Errorf("only the released savepoint should be committed, got %d rows", rows)
+	}
+
+	// units of one database must not capture statements of another
+	other := openSQLite(t)
+	err = d.Transact(ctx, nil, func(ctx context.Context) error {
+		if other.unitFrom(ctx) != nil {
+			return errors.New("another database joined the unit")
+		}
+		return nil
+	})
+	if err != nil {
+		t.Error(err)
+	}
+}
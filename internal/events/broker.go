@@ -0,0 +1,137 @@
+// Package events wakes the streams of task changes. Changes themselves are read from the
+// database, so a wakeup carries no data and missed or merged wakeups lose nothing.
+package events
+
+import (
+	"sync"
+	"time"
+)
+
+// Broker fans wakeups out to the subscriptions of a user.
+type Broker struct {
+	mu     sync.Mutex
+	subs   map[int64]map[*Subscription]struct{}
+	closed bool
+	// later holds the users with a wakeup scheduled by NotifyLater
+	later map[int64]struct{}
+}
+
+// Subscription receives on C when the user may have new events. Wakeups arriving while one
+// is pending are merged, so a slow reader is never blocked on. C is closed with the broker.
+type Subscription struct {
+	C <-chan struct{}
+
+	c   chan struct{}
+	b   *Broker
+	uID int64
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs:  make(map[int64]map[*Subscription]struct{}),
+		later: make(map[int64]struct{}),
+	}
+}
+
+// Subscribe returns a subscription for the wakeups of the user, which has to be closed when
+// it is no longer read.
+func (b *Broker) Subscribe(uID int64) *Subscription {
+	c := make(chan struct{}, 1)
+	s := &Subscription{C: c, c: c, b: b, uID: uID}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(c)
+		return s
+	}
+	if b.subs[uID] == nil {
+		b.subs[uID] = make(map[*Subscription]struct{})
+	}
+	b.subs[uID][s] = struct{}{}
+
+	return s
+}
+
+func (s *Subscription) Close() {
+	s.b.mu.Lock()
+	defer s.b.mu.Unlock()
+	if _, ok := s.b.subs[s.uID][s]; !ok {
+		return
+	}
+	delete(s.b.subs[s.uID], s)
+	if len(s.b.subs[s.uID]) == 0 {
+		delete(s.b.subs, s.uID)
+	}
+}
+
+// Notify wakes the subscriptions of the user.
+func (b *Broker) Notify(uID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for s := range b.subs[uID] {
+		s.wake()
+	}
+}
+
+// NotifyLater wakes the subscriptions of the user after d. Calls made while a wakeup of the
+// user is scheduled are merged into it.
+func (b *Broker) NotifyLater(uID int64, d time.Duration) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if _, ok := b.later[uID]; ok || b.closed {
+		return
+	}
+	b.later[uID] = struct{}{}
+	time.AfterFunc(d, func() {
+		b.mu.Lock()
+		delete(b.later, uID)
+		b.mu.Unlock()
+		b.Notify(uID)
+	})
+}
+
+// NotifyAll wakes every subscription, e.g. after notifications may have been lost.
+func (b *Broker) NotifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subs {
+		for s := range subs {
+			s.wake()
+		}
+	}
+}
+
+// Subscriptions counts the open subscriptions.
+func (b *Broker) Subscriptions() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	n := 0
+	for _, subs := range b.subs {
+		n += len(subs)
+	}
+	return n
+}
+
+// Close closes the channel of every subscription so their readers stop, e.g. on shutdown.
+func (b *Broker) Close() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for _, subs := range b.subs {
+		for s := range subs {
+			close(s.c)
+		}
+	}
+	b.subs = make(map[int64]map[*Subscription]struct{})
+}
+
+func (s *Subscription) wake() {
+	select {
+	case s.c <- struct{}{}:
+	default:
+	}
+}
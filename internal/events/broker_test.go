@@ -0,0 +1,85 @@
+package events
+
+import (
+	"testing"
+	"time"
+)
+
+func received(s *Subscription) bool {
+	select {
+	case _, ok := <-s.C:
+		return ok
+	default:
+		return false
+	}
+}
+
+func TestBroker(t *testing.T) {
+	b := NewBroker()
+	a1, a2, other := b.Subscribe(1), b.Subscribe(1), b.Subscribe(2)
+
+	b.Notify(1)
+	b.Notify(1)
+	if !received(a1) || !received(a2) {
+		t.Errorf("every subscription of the user should be woken")
+	}
+	if received(a1) {
+		t.Errorf("pending wakeups should be merged")
+	}
+	if received(other) {
+		t.Errorf("subscriptions of other users should not be woken")
+	}
+
+	b.NotifyAll()
+	if !received(a1) || !received(a2) || !received(other) {
+		t.Errorf("every subscription should be woken")
+	}
+
+	a2.Close()
+	a2.Close()
+	b.Notify(1)
+	if received(a2) {
+		t.Errorf("closed subscriptions should not be woken")
+	}
+	if n := b.Subscriptions(); n != 2 {
+		t.Errorf("expected 2 subscriptions but got %d", n)
+	}
+
+	b.Close()
+	if _, ok := <-other.C; ok {
+		t.Errorf("closing the broker should close the subscriptions")
+	}
+	if _, ok := <-b.Subscribe(3).C; ok {
+		t.Errorf("subscribing to a closed broker should return a closed subscription")
+	}
+	other.Close()
+}
+
+func TestBroker_NotifyLater(t *testing.T) {
+	b := NewBroker()
+	s := b.Subscribe(1)
+	defer s.Close()
+
+	b.NotifyLater(1, 10*time.Millisecond)
+	b.NotifyLater(1, 10*time.Millisecond)
+	if received(s) {
+		t.Fatalf("the wakeup should wait")
+	}
+	select {
+	case <-s.C:
+	case <-time.After(time.Second):
+		t.Fatalf("the subscription should be woken")
+	}
+	time.Sleep(50 * time.Millisecond)
+	if received(s) {
+		t.Errorf("calls made while a wakeup is scheduled should be merged into it")
+	}
+
+	// the next call schedules a new wakeup
+	b.NotifyLater(1, time.Millisecond)
+	select {
+	case <-s.C:
+	case <-time.After(time.Second):
+		t.Errorf("the subscription should be woken again")
+	}
+}
@@ -0,0 +1,77 @@
+package events
+
+import (
+	"context"
+	"log/slog"
+	"strconv"
+	"task-manager/internal/health"
+	"time"
+
+	"github.com/lib/pq"
+)
+
+// Channel is the Postgres notification channel the task triggers announce changes on, with
+// the id of the owner as payload.
+const Channel = "task_events"
+
+const (
+	minReconnect = time.Second
+	maxReconnect = time.Minute
+	// PingInterval is how often Listen checks its connection, and so the longest it goes
+	// without beating its heartbeat.
+	PingInterval = 90 * time.Second
+)
+
+// Listen forwards the notifications of other servers sharing the database at dsn to b until
+// ctx is done. Notifications sent while the connection was down are lost, so every
+// subscription is woken after reconnecting to catch up from the database. hb is beaten while
+// the notifications are being forwarded.
+func Listen(ctx context.Context, dsn string, b *Broker, hb *health.Heartbeat, logger *slog.Logger) error {
+	l := pq.NewListener(dsn, minReconnect, maxReconnect, func(ev pq.ListenerEventType, err error) {
+		switch ev {
+		case pq.ListenerEventConnectionAttemptFailed, pq.ListenerEventDisconnected:
+			logger.Warn("event listener disconnected", "err", err)
+		case pq.ListenerEventReconnected:
+			logger.Info("event listener reconnected")
+		}
+	})
+	if err := l.Listen(Channel); err != nil {
+		_ = l.Close()
+		return err
+	}
+
+	go func() {
+		defer func() {
+			_ = l.Close()
+		}()
+
+		ping := time.NewTicker(PingInterval)
+		defer ping.Stop()
+		for {
+			hb.Beat()
+			select {
+			case <-ctx.Done():
+				return
+			case n := <-l.Notify:
+				// nil is sent after the connection was re-established
+				if n == nil {
+					b.NotifyAll()
+					continue
+				}
+				uID, err := strconv.ParseInt(n.Extra, 10, 64)
+				if err != nil {
+					logger.Warn("ignoring malformed event notification", "payload", n.Extra)
+					continue
+				}
+				b.Notify(uID)
+			case <-ping.C:
+				// detects connections that died without being closed
+				go func() {
+					_ = l.Ping()
+				}()
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,130 @@
+package graph
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"task-manager/internal/services"
+	"task-manager/internal/tracing"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/gqlerrors"
+	"github.com/graphql-go/graphql/language/ast"
+	"github.com/graphql-go/graphql/language/parser"
+	"github.com/graphql-go/graphql/language/source"
+)
+
+// Request is the body of a GraphQL request.
+type Request struct {
+	Query         string         `json:"query"`
+	OperationName string         `json:"operationName,omitempty"`
+	Variables     map[string]any `json:"variables,omitempty"`
+}
+
+// Graph executes GraphQL requests against the services.
+type Graph struct {
+	schema graphql.Schema
+	ts     services.TaskService
+	us     services.UserService
+	limits config.GraphQLConfig
+}
+
+// New builds the schema over ts and us. Operations nesting deeper or costing more than
+// allowed by limits are rejected before they are executed.
+func New(ts services.TaskService, us services.UserService, limits config.GraphQLConfig) (*Graph, error) {
+	g := &Graph{ts: ts, us: us, limits: limits}
+	s, err := g.buildSchema()
+	if err != nil {
+		return nil, fmt.Errorf("New: failed to build the schema: %v", err)
+	}
+	g.schema = s
+	return g, nil
+}
+
+// Execute runs the request as the user in ctx. Errors of the request, including exceeded
+// limits, are reported in the result, as GraphQL clients expect.
+func (g *Graph) Execute(ctx context.Context, req Request) *graphql.Result {
+	ctx, span := tracing.Start(ctx, "Graph.Execute")
+	defer span.End()
+
+	doc, err := parser.Parse(parser.ParseParams{
+		Source: source.NewSource(&source.Source{Body: []byte(req.Query), Name: "GraphQL request"}),
+	})
+	if err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+	if v := graphql.ValidateDocument(&g.schema, doc, nil); !v.IsValid {
+		return &graphql.Result{Errors: v.Errors}
+	}
+	if err := g.checkLimits(doc, req.OperationName); err != nil {
+		return &graphql.Result{Errors: gqlerrors.FormatErrors(err)}
+	}
+
+	return graphql.Execute(graphql.ExecuteParams{
+		Schema:        g.schema,
+		AST:           doc,
+		OperationName: req.OperationName,
+		Args:          req.Variables,
+		Context:       withLoaders(ctx, g.newLoaders()),
+	})
+}
+
+// checkLimits measures the operation to be executed. A missing or ambiguous operation is
+// left to the executor to report.
+func (g *Graph) checkLimits(doc *ast.Document, name string) error {
+	var op *ast.OperationDefinition
+	for _, d := range doc.Definitions {
+		o, ok := d.(*ast.OperationDefinition)
+		if !ok {
+			continue
+		}
+		if name == "" || (o.Name != nil && o.Name.Value == name) {
+			op = o
+			break
+		}
+	}
+	if op == nil {
+		return nil
+	}
+
+	depth, complexity := measure(g.schema, doc, op)
+	if depth > g.limits.MaxDepth {
+		return fmt.Errorf("query depth %d exceeds the limit of %d", depth, g.limits.MaxDepth)
+	}
+	if complexity > g.limits.MaxComplexity {
+		return fmt.Errorf("query complexity %d exceeds the limit of %d", complexity, g.limits.MaxComplexity)
+	}
+	return nil
+}
+
+// loaders are the batching loaders of one request.
+type loaders struct {
+	users *loader[int64, models.User]
+}
+
+type loadersKey struct{}
+
+func (g *Graph) newLoaders() *loaders {
+	return &loaders{
+		users: newLoader(func(ctx context.Context, ids []int64) (map[int64]models.User, error) {
+			us, err := g.us.GetUsers(ctx, ids)
+			if err != nil {
+				return nil, err
+			}
+			m := make(map[int64]models.User, len(us))
+			for _, u := range us {
+				m[int64(u.ID)] = u
+			}
+			return m, nil
+		}),
+	}
+}
+
+func withLoaders(ctx context.Context, l *loaders) context.Context {
+	return context.WithValue(ctx, loadersKey{}, l)
+}
+
+func loadersFrom(ctx context.Context) *loaders {
+	return ctx.Value(loadersKey{}).(*loaders)
+}
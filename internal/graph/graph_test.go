@@ -0,0 +1,273 @@
+package graph
+
+import (
+	"context"
+	"encoding/json"
+	"slices"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/events"
+	"task-manager/internal/mail"
+	"task-manager/internal/models"
+	"task-manager/internal/repository/memory"
+	"task-manager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+// countingUsers counts the batch lookups of users.
+type countingUsers struct {
+	services.UserService
+	fetches [][]int64
+}
+
+func (c *countingUsers) GetUsers(ctx context.Context, ids []int64) ([]models.User, error) {
+	c.fetches = append(c.fetches, slices.Sorted(slices.Values(ids)))
+	return c.UserService.GetUsers(ctx, ids)
+}
+
+type testEnv struct {
+	g     *Graph
+	users *countingUsers
+	s     services.Services
+}
+
+func newTestEnv(t *testing.T, limits config.GraphQLConfig) testEnv {
+	t.Helper()
+	m, err := mail.NewFileMailer("no-reply@localhost", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		JWT:      config.JWTConfig{Secret: "secret-for-testing"},
+		Auth:     config.AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost"},
+		Password: config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4},
+		Events:   config.EventsConfig{Heartbeat: time.Minute, Retention: time.Hour},
+	}
+	s := services.New(memory.New(), cfg, m, events.NewBroker())
+	users := &countingUsers{UserService: s.Us}
+
+	g, err := New(s.Ts, users, limits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return testEnv{g: g, users: users, s: s}
+}
+
+// login registers a user and returns a context authenticated as them.
+func (e testEnv) login(t *testing.T, email string) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	if err := e.s.Us.RegisterUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: email, Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := e.s.Au.Authenticate(ctx, models.LoginPayload{Email: email, Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return context.WithValue(ctx, contextkeys.UserID, int64(u.ID))
+}
+
+func (e testEnv) execute(t *testing.T, ctx context.Context, query string, vars map[string]any) *graphql.Result {
+	t.Helper()
+	return e.g.Execute(ctx, Request{Query: query, Variables: vars})
+}
+
+// data decodes the data of a successful result into v.
+func data(t *testing.T, res *graphql.Result, v any) {
+	t.Helper()
+	if res.HasErrors() {
+		t.Fatalf("unexpected errors %v", res.Errors)
+	}
+	b, err := json.Marshal(res.Data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func expectError(t *testing.T, res *graphql.Result, msg string) {
+	t.Helper()
+	for _, e := range res.Errors {
+		if strings.Contains(e.Message, msg) {
+			return
+		}
+	}
+	t.Errorf("expected an error containing %q but got %v", msg, res.Errors)
+}
+
+var defaultLimits = config.GraphQLConfig{MaxDepth: 8, MaxComplexity: 1000}
+
+const createTask = `mutation($input: TaskInput!) { createTask(input: $input) { id name } }`
+
+func TestTasks(t *testing.T) {
+	env := newTestEnv(t, defaultLimits)
+	ctx := env.login(t, "lorem@example.com")
+	other := env.login(t, "ipsum@example.com")
+
+	res := env.execute(t, ctx, createTask, map[string]any{"input": map[string]any{"name": "Task", "priority": "HIGH"}})
+	expectError(t, res, "for priority high due date is required")
+
+	due := time.Now().Add(time.Hour).Truncate(time.Second).UTC()
+	var created []string
+	for _, name := range []string{"First", "Second", "Third"} {
+		res := env.execute(t, ctx, createTask, map[string]any{
+			"input": map[string]any{"name": name, "priority": "HIGH", "dueDate": due.Format(time.RFC3339)},
+		})
+		var task struct {
+			CreateTask struct{ ID, Name string }
+		}
+		data(t, res, &task)
+		// the new task is returned, so clients don't have to look up its id
+		if task.CreateTask.ID == "" || task.CreateTask.Name != name {
+			t.Errorf("unexpected created task %+v", task.CreateTask)
+		}
+		created = append(created, task.CreateTask.ID)
+	}
+
+	var list struct {
+		Tasks []struct {
+			ID       string
+			Name     string
+			Priority string
+			DueDate  time.Time
+			Creator  struct{ Email string }
+		}
+	}
+	data(t, env.execute(t, ctx, `{ tasks { id name priority dueDate creator { email } } }`, nil), &list)
+	if len(list.Tasks) != 3 {
+		t.Fatalf("expected three tasks but got %v", list.Tasks)
+	}
+	for _, task := range list.Tasks {
+		if task.Priority != "HIGH" || !task.DueDate.Equal(due) || task.Creator.Email != "lorem@example.com" {
+			t.Errorf("unexpected task %+v", task)
+		}
+	}
+	// the creators of all tasks are looked up at once
+	if len(env.users.fetches) != 1 {
+		t.Errorf("expected one batch of users but got %v", env.users.fetches)
+	}
+
+	id := list.Tasks[0].ID
+	if id != created[0] {
+		t.Errorf("expected the first task to have the id %s returned on creation but got %s", created[0], id)
+	}
+	var shown struct{ Task *struct{ Name string } }
+	data(t, env.execute(t, other, `query($id: ID!) { task(id: $id) { name } }`, map[string]any{"id": id}), &shown)
+	if shown.Task != nil {
+		t.Errorf("expected the task of another user to be null but got %+v", shown.Task)
+	}
+	res = env.execute(t, other, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]any{"id": id})
+	expectError(t, res, "not found")
+
+	var updated struct {
+		UpdateTask struct{ Name, Priority string }
+	}
+	data(t, env.execute(t, ctx, `mutation($id: ID!, $input: TaskInput!) { updateTask(id: $id, input: $input) { name priority } }`,
+		map[string]any{"id": id, "input": map[string]any{"name": "Renamed"}}), &updated)
+	if updated.UpdateTask.Name != "Renamed" || updated.UpdateTask.Priority != "LOW" {
+		t.Errorf("unexpected task %+v", updated.UpdateTask)
+	}
+
+	data(t, env.execute(t, ctx, `mutation($id: ID!) { deleteTask(id: $id) }`, map[string]any{"id": id}), new(any))
+	data(t, env.execute(t, ctx, `query($id: ID!) { task(id: $id) { name } }`, map[string]any{"id": id}), &shown)
+	if shown.Task != nil {
+		t.Errorf("expected the deleted task to be null but got %+v", shown.Task)
+	}
+}
+
+func TestLimits(t *testing.T) {
+	tests := []struct {
+		name   string
+		limits config.GraphQLConfig
+		query  string
+		err    string
+	}{
+		{
+			name:   "within limits",
+			limits: config.GraphQLConfig{MaxDepth: 3, MaxComplexity: 12},
+			query:  `{ me { tasks { name } } }`,
+		},
+		{
+			name:   "too deep",
+			limits: config.GraphQLConfig{MaxDepth: 4, MaxComplexity: 1000},
+			query:  `{ me { tasks { creator { tasks { name } } } } }`,
+			err:    "query depth 5 exceeds the limit of 4",
+		},
+		{
+			name:   "too deep through fragments",
+			limits: config.GraphQLConfig{MaxDepth: 4, MaxComplexity: 1000},
+			query:  `{ me { ...Tasks } } fragment Tasks on User { tasks { creator { ... on User { tasks { name } } } } }`,
+			err:    "query depth 5 exceeds the limit of 4",
+		},
+		{
+			name:   "too complex",
+			limits: config.GraphQLConfig{MaxDepth: 8, MaxComplexity: 11},
+			query:  `{ me { tasks { name } } }`,
+			err:    "query complexity 12 exceeds the limit of 11",
+		},
+		{
+			name:   "introspection is not counted",
+			limits: config.GraphQLConfig{MaxDepth: 1, MaxComplexity: 1},
+			query:  `{ __schema { types { name fields { name type { name ofType { name } } } } } }`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			env := newTestEnv(t, tt.limits)
+			ctx := env.login(t, "lorem@example.com")
+
+			res := env.execute(t, ctx, tt.query, nil)
+			if tt.err == "" {
+				data(t, res, new(any))
+				return
+			}
+			expectError(t, res, tt.err)
+			if res.Data != nil {
+				t.Errorf("expected the query not to be executed but got %v", res.Data)
+			}
+		})
+	}
+}
+
+func TestLoader(t *testing.T) {
+	var fetches [][]int
+	l := newLoader(func(_ context.Context, keys []int) (map[int]string, error) {
+		fetches = append(fetches, slices.Sorted(slices.Values(keys)))
+		m := make(map[int]string)
+		for _, k := range keys {
+			if k > 0 {
+				m[k] = strings.Repeat("x", k)
+			}
+		}
+		return m, nil
+	})
+
+	ctx := context.Background()
+	thunks := []func() (any, error){l.Load(ctx, 2), l.Load(ctx, 1), l.Load(ctx, 2), l.Load(ctx, -1)}
+	var got []any
+	for _, th := range thunks {
+		v, err := th()
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, v)
+	}
+
+	if want := []any{"xx", "x", "xx", nil}; !slices.Equal(got, want) {
+		t.Errorf("expected %v but got %v", want, got)
+	}
+	// keys loaded before are served from the cache
+	if v, _ := l.Load(ctx, 1)(); v != "x" {
+		t.Errorf("expected the cached value but got %v", v)
+	}
+	if want := [][]int{{-1, 1, 2}}; !slices.EqualFunc(fetches, want, slices.Equal) {
+		t.Errorf("expected the keys to be fetched at once but got %v", fetches)
+	}
+}
@@ -0,0 +1,111 @@
+package graph
+
+import (
+	"strings"
+
+	"github.com/graphql-go/graphql"
+	"github.com/graphql-go/graphql/language/ast"
+)
+
+// listFactor is how many items the cost of the fields below a list is counted for.
+const listFactor = 10
+
+// analysis walks an operation to measure how deep its fields nest and how much it costs.
+// Introspection fields are skipped, as tools send deeply nested introspection queries.
+type analysis struct {
+	schema    graphql.Schema
+	fragments map[string]*ast.FragmentDefinition
+}
+
+// measure returns the depth and complexity of op, which has to be a valid operation of doc.
+func measure(s graphql.Schema, doc *ast.Document, op *ast.OperationDefinition) (depth, complexity int) {
+	a := analysis{schema: s, fragments: make(map[string]*ast.FragmentDefinition)}
+	for _, d := range doc.Definitions {
+		if f, ok := d.(*ast.FragmentDefinition); ok {
+			a.fragments[f.Name.Value] = f
+		}
+	}
+
+	var root *graphql.Object
+	switch op.Operation {
+	case ast.OperationTypeQuery:
+		root = s.QueryType()
+	case ast.OperationTypeMutation:
+		root = s.MutationType()
+	}
+	if root == nil {
+		return 0, 0
+	}
+	return a.selections(op.SelectionSet, root)
+}
+
+func (a analysis) selections(set *ast.SelectionSet, parent graphql.Type) (depth, complexity int) {
+	if set == nil {
+		return 0, 0
+	}
+
+	for _, sel := range set.Selections {
+		var d, c int
+		switch sel := sel.(type) {
+		case *ast.Field:
+			d, c = a.field(sel, parent)
+		case *ast.InlineFragment:
+			d, c = a.selections(sel.SelectionSet, a.condition(sel.TypeCondition, parent))
+		case *ast.FragmentSpread:
+			if f, ok := a.fragments[sel.Name.Value]; ok {
+				d, c = a.selections(f.SelectionSet, a.condition(f.TypeCondition, parent))
+			}
+		}
+		depth = max(depth, d)
+		complexity += c
+	}
+	return depth, complexity
+}
+
+func (a analysis) field(f *ast.Field, parent graphql.Type) (depth, complexity int) {
+	if strings.HasPrefix(f.Name.Value, "__") {
+		return 0, 0
+	}
+
+	obj, ok := parent.(*graphql.Object)
+	if !ok {
+		return 1, 1
+	}
+	def, ok := obj.Fields()[f.Name.Value]
+	if !ok {
+		return 1, 1
+	}
+
+	t, list := unwrap(def.Type)
+	d, c := a.selections(f.SelectionSet, t)
+	if list {
+		c *= listFactor
+	}
+	return d + 1, c + 1
+}
+
+func (a analysis) condition(named *ast.Named, parent graphql.Type) graphql.Type {
+	if named == nil {
+		return parent
+	}
+	if t := a.schema.Type(named.Name.Value); t != nil {
+		return t
+	}
+	return parent
+}
+
+// unwrap strips the non-null and list wrappers of t, reporting whether there was a list.
+func unwrap(t graphql.Type) (graphql.Type, bool) {
+	list := false
+	for {
+		switch w := t.(type) {
+		case *graphql.NonNull:
+			t = w.OfType
+		case *graphql.List:
+			t = w.OfType
+			list = true
+		default:
+			return t, list
+		}
+	}
+}
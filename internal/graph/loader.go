@@ -0,0 +1,71 @@
+package graph
+
+import (
+	"context"
+	"sync"
+)
+
+// loader batches the lookups of one request. Load queues a key and returns a thunk; the first
+// thunk resolved fetches every key queued so far at once. The executor resolves the thunks of
+// a level of the query only after resolving all of its fields, so a field looked up for every
+// item of a list costs a single fetch instead of one per item.
+type loader[K comparable, V any] struct {
+	fetch func(ctx context.Context, keys []K) (map[K]V, error)
+
+	mu      sync.Mutex
+	pending map[K]struct{}
+	done    map[K]loaded[V]
+}
+
+type loaded[V any] struct {
+	value V
+	found bool
+	err   error
+}
+
+func newLoader[K comparable, V any](fetch func(ctx context.Context, keys []K) (map[K]V, error)) *loader[K, V] {
+	return &loader[K, V]{
+		fetch:   fetch,
+		pending: make(map[K]struct{}),
+		done:    make(map[K]loaded[V]),
+	}
+}
+
+// Load returns a thunk resolving to the value of key, or to nil when there is none.
+func (l *loader[K, V]) Load(ctx context.Context, key K) func() (any, error) {
+	l.mu.Lock()
+	if _, ok := l.done[key]; !ok {
+		l.pending[key] = struct{}{}
+	}
+	l.mu.Unlock()
+
+	return func() (any, error) {
+		r := l.get(ctx, key)
+		if r.err != nil || !r.found {
+			return nil, r.err
+		}
+		return r.value, nil
+	}
+}
+
+func (l *loader[K, V]) get(ctx context.Context, key K) loaded[V] {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if r, ok := l.done[key]; ok {
+		return r
+	}
+
+	keys := make([]K, 0, len(l.pending))
+	for k := range l.pending {
+		keys = append(keys, k)
+	}
+	clear(l.pending)
+
+	values, err := l.fetch(ctx, keys)
+	for _, k := range keys {
+		v, found := values[k]
+		l.done[k] = loaded[V]{value: v, found: found, err: err}
+	}
+	return l.done[key]
+}
@@ -0,0 +1,315 @@
+package graph
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/logging"
+	"task-manager/internal/models"
+	"task-manager/internal/requests"
+	"time"
+
+	"github.com/graphql-go/graphql"
+)
+
+var errUnauthenticated = errors.New("invalid user data, please relog")
+
+func (g *Graph) buildSchema() (graphql.Schema, error) {
+	priority := graphql.NewEnum(graphql.EnumConfig{
+		Name: "Priority",
+		Values: graphql.EnumValueConfigMap{
+			"LOW":    &graphql.EnumValueConfig{Value: models.PriorityLow},
+			"MEDIUM": &graphql.EnumValueConfig{Value: models.PriorityMedium},
+			"HIGH":   &graphql.EnumValueConfig{Value: models.PriorityHigh},
+		},
+	})
+
+	user := graphql.NewObject(graphql.ObjectConfig{
+		Name: "User",
+		Fields: graphql.Fields{
+			"id":        &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":      &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"email":     &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"createdAt": &graphql.Field{Type: graphql.DateTime},
+		},
+	})
+
+	task := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Task",
+		Fields: graphql.Fields{
+			"id":          &graphql.Field{Type: graphql.NewNonNull(graphql.ID)},
+			"name":        &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"priority":    &graphql.Field{Type: graphql.NewNonNull(priority)},
+			"description": &graphql.Field{Type: graphql.NewNonNull(graphql.String)},
+			"dueDate":     &graphql.Field{Type: graphql.DateTime},
+			"createdAt":   &graphql.Field{Type: graphql.DateTime},
+			"creator":     &graphql.Field{Type: user, Resolve: g.creator},
+		},
+	})
+
+	// added once both types exist, as they refer to each other
+	user.AddFieldConfig("tasks", &graphql.Field{
+		Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(task))),
+		Resolve: g.userTasks,
+	})
+
+	taskInput := graphql.NewInputObject(graphql.InputObjectConfig{
+		Name: "TaskInput",
+		Fields: graphql.InputObjectConfigFieldMap{
+			"name":        &graphql.InputObjectFieldConfig{Type: graphql.NewNonNull(graphql.String)},
+			"description": &graphql.InputObjectFieldConfig{Type: graphql.String, DefaultValue: ""},
+			"priority":    &graphql.InputObjectFieldConfig{Type: priority, DefaultValue: models.PriorityLow},
+			"dueDate":     &graphql.InputObjectFieldConfig{Type: graphql.DateTime},
+		},
+	})
+	id := graphql.FieldConfigArgument{
+		"id": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.ID)},
+	}
+	input := &graphql.ArgumentConfig{Type: graphql.NewNonNull(taskInput)}
+
+	return graphql.NewSchema(graphql.SchemaConfig{
+		Query: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Query",
+			Fields: graphql.Fields{
+				"me": &graphql.Field{
+					Type:    graphql.NewNonNull(user),
+					Resolve: g.me,
+				},
+				"tasks": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.NewList(graphql.NewNonNull(task))),
+					Resolve: g.tasks,
+				},
+				"task": &graphql.Field{
+					Type:        task,
+					Description: "The task with the given id, or null when the user has no such task.",
+					Args:        id,
+					Resolve:     g.task,
+				},
+			},
+		}),
+		Mutation: graphql.NewObject(graphql.ObjectConfig{
+			Name: "Mutation",
+			Fields: graphql.Fields{
+				"createTask": &graphql.Field{
+					Type:    graphql.NewNonNull(task),
+					Args:    graphql.FieldConfigArgument{"input": input},
+					Resolve: g.createTask,
+				},
+				"updateTask": &graphql.Field{
+					Type:    graphql.NewNonNull(task),
+					Args:    graphql.FieldConfigArgument{"id": id["id"], "input": input},
+					Resolve: g.updateTask,
+				},
+				"deleteTask": &graphql.Field{
+					Type:    graphql.NewNonNull(graphql.Boolean),
+					Args:    id,
+					Resolve: g.deleteTask,
+				},
+			},
+		}),
+	})
+}
+
+func (g *Graph) me(p graphql.ResolveParams) (any, error) {
+	uID, ok := p.Context.Value(contextkeys.UserID).(int64)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	return loadersFrom(p.Context).users.Load(p.Context, uID), nil
+}
+
+func (g *Graph) tasks(p graphql.ResolveParams) (any, error) {
+	uID, ok := p.Context.Value(contextkeys.UserID).(int64)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+
+	tl, err := g.ts.GetTasksList(p.Context, uID)
+	if err != nil {
+		return nil, internalError(p.Context, "failed to retrieve tasks list", err)
+	}
+	return tl.Tasks, nil
+}
+
+func (g *Graph) task(p graphql.ResolveParams) (any, error) {
+	uID, ok := p.Context.Value(contextkeys.UserID).(int64)
+	if !ok {
+		return nil, errUnauthenticated
+	}
+	id, err := taskID(p.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	// the tasks of other users are reported as missing, so their ids are not revealed
+	owner, err := g.ts.IsTaskOwner(p.Context, uID, id)
+	if err != nil {
+		return nil, internalError(p.Context, "failed to check ownership", err)
+	}
+	if !owner {
+		return nil, nil
+	}
+
+	t, err := g.ts.ShowTask(p.Context, id)
+	if err != nil {
+		return nil, internalError(p.Context, "failed to get task data", err)
+	}
+	return t, nil
+}
+
+// creator is loaded in batches, so listing tasks costs one user lookup in total.
+func (g *Graph) creator(p graphql.ResolveParams) (any, error) {
+	t, ok := p.Source.(models.Task)
+	if !ok {
+		return nil, nil
+	}
+	return loadersFrom(p.Context).users.Load(p.Context, t.CreatedBy), nil
+}
+
+// userTasks lists the tasks of the user, which only the user may see.
+func (g *Graph) userTasks(p graphql.ResolveParams) (any, error) {
+	u, ok := p.Source.(models.User)
+	if !ok {
+		return nil, nil
+	}
+	if uID, _ := p.Context.Value(contextkeys.UserID).(int64); uID != int64(u.ID) {
+		return []models.Task{}, nil
+	}
+
+	tl, err := g.ts.GetTasksList(p.Context, int64(u.ID))
+	if err != nil {
+		return nil, internalError(p.Context, "failed to retrieve tasks list", err)
+	}
+	return tl.Tasks, nil
+}
+
+func (g *Graph) createTask(p graphql.ResolveParams) (any, error) {
+	in := p.Args["input"].(map[string]any)
+	req := requests.CreateTasksRequest{
+		Name:        in["name"].(string),
+		Description: stringArg(in["description"]),
+		Priority:    priorityArg(in["priority"]),
+		DueDate:     timeArg(in["dueDate"]),
+	}
+	if v := req.Validate(); !v.Validated {
+		return nil, fmt.Errorf("validation failed: %s", v.Message)
+	}
+
+	now := time.Now()
+	t, err := g.ts.StoreTask(p.Context, models.TaskPayload{
+		Name:        req.Name,
+		Priority:    req.Priority,
+		Description: req.Description,
+		DueDate:     req.DueDate,
+		CreatedAt:   &now,
+	})
+	if err != nil {
+		return nil, internalError(p.Context, "failed to save task", err)
+	}
+	return t, nil
+}
+
+func (g *Graph) updateTask(p graphql.ResolveParams) (any, error) {
+	in := p.Args["input"].(map[string]any)
+	req := requests.UpdateTaskRequest{
+		Name:        in["name"].(string),
+		Description: stringArg(in["description"]),
+		Priority:    priorityArg(in["priority"]),
+		DueDate:     timeArg(in["dueDate"]),
+	}
+	if v := req.Validate(); !v.Validated {
+		return nil, fmt.Errorf("validation failed: %s", v.Message)
+	}
+
+	id, err := g.owned(p)
+	if err != nil {
+		return nil, err
+	}
+
+	t, err := g.ts.UpdateTask(p.Context, models.UpdateTask{
+		ID:          int64(id),
+		Name:        req.Name,
+		Priority:    req.Priority,
+		Description: req.Description,
+		DueDate:     req.DueDate,
+	})
+	if err != nil {
+		return nil, internalError(p.Context, "failed to update task", err)
+	}
+	return t, nil
+}
+
+func (g *Graph) deleteTask(p graphql.ResolveParams) (any, error) {
+	id, err := g.owned(p)
+	if err != nil {
+		return nil, err
+	}
+
+	uID := p.Context.Value(contextkeys.UserID).(int64)
+	if err := g.ts.DeleteTask(p.Context, id, uID); err != nil {
+		return nil, internalError(p.Context, "failed to delete the task", err)
+	}
+	return true, nil
+}
+
+// owned returns the id argument once it is known to be a task of the user. Missing and
+// foreign tasks are reported alike, so the ids of the tasks of other users are not revealed.
+func (g *Graph) owned(p graphql.ResolveParams) (int, error) {
+	uID, ok := p.Context.Value(contextkeys.UserID).(int64)
+	if !ok {
+		return 0, errUnauthenticated
+	}
+	id, err := taskID(p.Args)
+	if err != nil {
+		return 0, err
+	}
+
+	owner, err := g.ts.IsTaskOwner(p.Context, uID, id)
+	if err != nil {
+		return 0, internalError(p.Context, "failed to check ownership", err)
+	}
+	if !owner {
+		return 0, fmt.Errorf("task %d not found", id)
+	}
+	return id, nil
+}
+
+func taskID(args map[string]any) (int, error) {
+	s, _ := args["id"].(string)
+	id, err := strconv.Atoi(s)
+	if err != nil || id < 1 {
+		return 0, fmt.Errorf("invalid task id %q", s)
+	}
+	return id, nil
+}
+
+func stringArg(v any) string {
+	s, _ := v.(string)
+	return s
+}
+
+// priorityArg returns an invalid priority for values that are not one, which validation
+// then rejects.
+func priorityArg(v any) models.Priority {
+	p, ok := v.(models.Priority)
+	if !ok {
+		return -1
+	}
+	return p
+}
+
+func timeArg(v any) *time.Time {
+	t, ok := v.(time.Time)
+	if !ok {
+		return nil
+	}
+	return &t
+}
+
+// internalError logs err and answers with msg only, like the REST API does for server errors.
+func internalError(ctx context.Context, msg string, err error) error {
+	logging.FromContext(ctx).Error(msg, "err", err)
+	return errors.New(msg)
+}
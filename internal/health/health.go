@@ -0,0 +1,150 @@
+package health
+
+import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+	"task-manager/internal/helpers"
+	"time"
+)
+
+type Kind int
+
+const (
+	// Liveness checks answer "is the process able to serve at all".
+	Liveness Kind = iota
+	// Readiness checks answer "should traffic be routed to this instance".
+	Readiness
+)
+
+const (
+	StatusUp   = "up"
+	StatusDown = "down"
+)
+
+// CheckFunc reports a dependency as healthy by returning nil.
+type CheckFunc func(ctx context.Context) error
+
+type check struct {
+	name string
+	kind Kind
+	fn   CheckFunc
+}
+
+type Result struct {
+	Status    string  `json:"status"`
+	LatencyMs float64 `json:"latency_ms"`
+	Error     string  `json:"error,omitempty"`
+}
+
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks"`
+}
+
+// Registry holds the checks registered by the subsystems of the application.
+type Registry struct {
+	mu      sync.RWMutex
+	checks  []check
+	timeout time.Duration
+}
+
+func NewRegistry(timeout time.Duration) *Registry {
+	return &Registry{timeout: timeout}
+}
+
+// Register adds a named check. Registering a name twice replaces the previous check.
+func (r *Registry) Register(name string, kind Kind, fn CheckFunc) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for i, c := range r.checks {
+		if c.name == name {
+			r.checks[i] = check{name: name, kind: kind, fn: fn}
+			return
+		}
+	}
+	r.checks = append(r.checks, check{name: name, kind: kind, fn: fn})
+}
+
+// Run executes every check of the given kind concurrently, each bounded by the registry timeout.
+// Readiness runs include the liveness checks, since a dead process is never ready.
+func (r *Registry) Run(ctx context.Context, kind Kind) Report {
+	r.mu.RLock()
+	var selected []check
+	for _, c := range r.checks {
+		if c.kind <= kind {
+			selected = append(selected, c)
+		}
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(selected, func(i, j int) bool { return selected[i].name < selected[j].name })
+
+	rep := Report{Status: StatusUp, Checks: make(map[string]Result, len(selected))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, c := range selected {
+		wg.Add(1)
+		go func(c check) {
+			defer wg.Done()
+			res := r.runCheck(ctx, c)
+
+			mu.Lock()
+			defer mu.Unlock()
+			rep.Checks[c.name] = res
+			if res.Status != StatusUp {
+				rep.Status = StatusDown
+			}
+		}(c)
+	}
+	wg.Wait()
+
+	return rep
+}
+
+func (r *Registry) runCheck(ctx context.Context, c check) Result {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	start := time.Now()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- c.fn(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-errCh:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	res := Result{
+		Status:    StatusUp,
+		LatencyMs: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	if err != nil {
+		res.Status = StatusDown
+		res.Error = err.Error()
+	}
+
+	return res
+}
+
+// Handler serves the report for the given kind, responding 503 when any check is down.
+func (r *Registry) Handler(kind Kind) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		rep := r.Run(req.Context(), kind)
+		status := http.StatusOK
+		if rep.Status != StatusUp {
+			status = http.StatusServiceUnavailable
+		}
+		w.Header().Set("Cache-Control", "no-store")
+		helpers.JsonResponse(w, status, rep)
+	}
+}
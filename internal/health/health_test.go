@@ -0,0 +1,158 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRegistry_Run(t *testing.T) {
+	var tests = []struct {
+		name           string
+		checks         map[string]CheckFunc
+		expectedStatus string
+		downChecks     []string
+	}{
+		{
+			"no checks registered",
+			map[string]CheckFunc{},
+			StatusUp,
+			nil,
+		},
+		{
+			"all checks passing",
+			map[string]CheckFunc{
+				"database": func(ctx context.Context) error { return nil },
+				"cache":    func(ctx context.Context) error { return nil },
+			},
+			StatusUp,
+			nil,
+		},
+		{
+			"single check failing",
+			map[string]CheckFunc{
+				"database": func(ctx context.Context) error { return fmt.Errorf("connection refused") },
+				"cache":    func(ctx context.Context) error { return nil },
+			},
+			StatusDown,
+			[]string{"database"},
+		},
+		{
+			"check exceeding timeout",
+			map[string]CheckFunc{
+				"slow": func(ctx context.Context) error {
+					time.Sleep(time.Second)
+					return nil
+				},
+			},
+			StatusDown,
+			[]string{"slow"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry(50 * time.Millisecond)
+			for n, fn := range tc.checks {
+				r.Register(n, Readiness, fn)
+			}
+
+			rep := r.Run(context.Background(), Readiness)
+			if rep.Status != tc.expectedStatus {
+				t.Errorf("expected status %s but got %s", tc.expectedStatus, rep.Status)
+			}
+			if len(rep.Checks) != len(tc.checks) {
+				t.Errorf("expected %d results but got %d", len(tc.checks), len(rep.Checks))
+			}
+			for _, n := range tc.downChecks {
+				if rep.Checks[n].Status != StatusDown {
+					t.Errorf("check %s should be down", n)
+				}
+				if rep.Checks[n].Error == "" {
+					t.Errorf("check %s should report an error", n)
+				}
+			}
+		})
+	}
+}
+
+func TestRegistry_Run_kinds(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("process", Liveness, func(ctx context.Context) error { return nil })
+	r.Register("database", Readiness, func(ctx context.Context) error { return fmt.Errorf("down") })
+
+	live := r.Run(context.Background(), Liveness)
+	if live.Status != StatusUp || len(live.Checks) != 1 {
+		t.Errorf("liveness should only run liveness checks, got %+v", live)
+	}
+
+	ready := r.Run(context.Background(), Readiness)
+	if ready.Status != StatusDown || len(ready.Checks) != 2 {
+		t.Errorf("readiness should run every check, got %+v", ready)
+	}
+}
+
+func TestRegistry_Register_replaces(t *testing.T) {
+	r := NewRegistry(time.Second)
+	r.Register("database", Readiness, func(ctx context.Context) error { return fmt.Errorf("down") })
+	r.Register("database", Readiness, func(ctx context.Context) error { return nil })
+
+	rep := r.Run(context.Background(), Readiness)
+	if rep.Status != StatusUp {
+		t.Errorf("re-registered check should replace the previous one")
+	}
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	var tests = []struct {
+		name           string
+		err            error
+		expectedStatus int
+	}{
+		{"healthy", nil, http.StatusOK},
+		{"unhealthy", fmt.Errorf("down"), http.StatusServiceUnavailable},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := NewRegistry(time.Second)
+			r.Register("database", Readiness, func(ctx context.Context) error { return tc.err })
+
+			rec := httptest.NewRecorder()
+			r.Handler(Readiness)(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status code %d but got %d", tc.expectedStatus, rec.Code)
+			}
+
+			var rep Report
+			if err := json.NewDecoder(rec.Body).Decode(&rep); err != nil {
+				t.Fatalf("failed to decode response: %s", err)
+			}
+			if _, ok := rep.Checks["database"]; !ok {
+				t.Errorf("response should contain database check details")
+			}
+		})
+	}
+}
+
+func TestHeartbeat_Check(t *testing.T) {
+	h := NewHeartbeat(50 * time.Millisecond)
+	if err := h.Check(context.Background()); err == nil {
+		t.Errorf("heartbeat without beats should fail")
+	}
+
+	h.Beat()
+	if err := h.Check(context.Background()); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	if err := h.Check(context.Background()); err == nil {
+		t.Errorf("stale heartbeat should fail")
+	}
+}
@@ -0,0 +1,35 @@
+package health
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+)
+
+// Heartbeat lets a background worker prove it is still running. The worker calls Beat on
+// every iteration and registers Check into the readiness registry.
+type Heartbeat struct {
+	last    atomic.Int64
+	maxIdle time.Duration
+}
+
+func NewHeartbeat(maxIdle time.Duration) *Heartbeat {
+	return &Heartbeat{maxIdle: maxIdle}
+}
+
+func (h *Heartbeat) Beat() {
+	h.last.Store(time.Now().UnixNano())
+}
+
+func (h *Heartbeat) Check(_ context.Context) error {
+	last := h.last.Load()
+	if last == 0 {
+		return fmt.Errorf("worker has not started")
+	}
+	idle := time.Since(time.Unix(0, last))
+	if idle > h.maxIdle {
+		return fmt.Errorf("no heartbeat for %s", idle.Truncate(time.Millisecond))
+	}
+	return nil
+}
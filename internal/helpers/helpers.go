@@ -5,8 +5,6 @@ import (
 	"net/http"
 	"net/mail"
 	"regexp"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func SliceContains[T comparable](slice []T, value T) bool {
@@ -18,19 +16,9 @@ func SliceContains[T comparable](slice []T, value T) bool {
 	return false
 }
 
-func HashPassword(p string) (string, error) {
-	bytes, err := bcrypt.GenerateFromPassword([]byte(p), 6)
-	if err != nil {
-		return "", err
-	}
-
-	return string(bytes), nil
-}
-
-func ValidatePassword(password, hash string) bool {
-	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
-	return err == nil
-}
+// StatusClientClosedRequest is the non-standard status, coined by nginx, for requests whose
+// client went away before the response was written.
+const StatusClientClosedRequest = 499
 
 func JsonResponse(w http.ResponseWriter, status int, payload interface{}) {
 	w.Header().Set("Content-Type", "application/json")
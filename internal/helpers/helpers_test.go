@@ -5,8 +5,6 @@ import (
 	"net/http/httptest"
 	"strings"
 	"testing"
-
-	"golang.org/x/crypto/bcrypt"
 )
 
 func testSliceContainsGeneric[T comparable](t *testing.T, tests []struct {
@@ -400,144 +398,6 @@ func TestSliceContainsArrays(t *testing.T) {
 	testSliceContainsGeneric(t, tests)
 }
 
-func TestHashPassword(t *testing.T) {
-	var tests = []struct {
-		name         string
-		password     string
-		expectsError bool
-	}{
-		{
-			"simple password",
-			"loremipsum",
-			false,
-		},
-		{
-			"short password",
-			"a",
-			false,
-		},
-		{
-			"long password",
-			strings.Repeat("a", 73),
-			true,
-		},
-		{
-			"empty string",
-			"",
-			false,
-		},
-	}
-
-	for _, i := range tests {
-		t.Run(i.name, func(t *testing.T) {
-			h, err := HashPassword(i.password)
-			if i.expectsError && err == nil {
-				t.Errorf("expected error while hashing but got nil")
-			}
-
-			if !i.expectsError && err != nil {
-				t.Errorf("unexpected error while hashing password: %s", err)
-			}
-
-			if h == i.password || strings.EqualFold(h, i.password) {
-				t.Errorf("hashed password can't be the same as original value")
-			}
-
-			if !i.expectsError {
-				err = bcrypt.CompareHashAndPassword([]byte(h), []byte(i.password))
-				if err != nil {
-					t.Errorf("unexpected error while comparing values: %s", err)
-				}
-			}
-		})
-	}
-}
-
-func TestValidatePassword(t *testing.T) {
-	validPassword := "loremipsum"
-	validHash, _ := HashPassword(validPassword)
-
-	diffPass := "doloret"
-	diffHash, _ := HashPassword(diffPass)
-
-	emptyPassword := ""
-	emptyHash, _ := HashPassword(emptyPassword)
-
-	nonEmptyHash, _ := HashPassword("nonempty")
-
-	var tests = []struct {
-		name           string
-		password       string
-		hash           string
-		expectedResult bool
-	}{
-		{
-			"valid password and hash",
-			validPassword,
-			validHash,
-			true,
-		},
-		{
-			"wrong password, valid hash",
-			diffPass,
-			validHash,
-			false,
-		},
-		{
-			"correct password, wrong hash",
-			validPassword,
-			diffHash,
-			false,
-		},
-		{
-			"empty password, empty hash",
-			emptyPassword,
-			emptyHash,
-			true,
-		},
-		{
-			"empty password, not empty hash",
-			emptyPassword,
-			nonEmptyHash,
-			false,
-		},
-		{
-			"not empty password, empty hash",
-			validPassword,
-			emptyHash,
-			false,
-		},
-		{
-			"valid password, malformed hash",
-			validPassword,
-			"$2a$06$invalidhashformat",
-			false,
-		},
-		{
-			"valid password, too short hash",
-			validPassword,
-			"$2a$06$short",
-			false,
-		},
-		{
-			"valid password, completely wrong hash",
-			validPassword,
-			"totalywronghash",
-			false,
-		},
-	}
-
-	for _, i := range tests {
-		t.Run(i.name, func(t *testing.T) {
-			r := ValidatePassword(i.password, i.hash)
-
-			if r != i.expectedResult {
-				t.Errorf("expected result %t but got %t", i.expectedResult, r)
-			}
-		})
-	}
-}
-
 func TestJsonResponse(t *testing.T) {
 	var tests = []struct {
 		name           string
@@ -0,0 +1,134 @@
+package logging
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"sync"
+	"task-manager/internal/config"
+	"time"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const redacted = "[REDACTED]"
+
+// sensitiveKeys are attribute keys whose values never reach the log output, whatever the level.
+var sensitiveKeys = []string{"password", "secret", "token", "authorization", "cookie", "hash"}
+
+type ctxKey struct{}
+
+// scope is shared by every handler of a single request, so attributes added deep in the
+// chain (e.g. the user ID set by Authenticate) also end up on the access log line.
+type scope struct {
+	mu     sync.RWMutex
+	logger *slog.Logger
+}
+
+// New builds the application logger according to c, writing to w.
+func New(c config.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(c.Level)); err != nil {
+		return nil, fmt.Errorf("logging: invalid level %q: %v", c.Level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redact}
+
+	switch c.Format {
+	case config.LogFormatJSON:
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	case config.LogFormatText:
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	default:
+		return nil, fmt.Errorf("logging: invalid format %q", c.Format)
+	}
+}
+
+func redact(_ []string, a slog.Attr) slog.Attr {
+	if IsSensitive(a.Key) {
+		return slog.String(a.Key, redacted)
+	}
+	return a
+}
+
+// IsSensitive reports whether values logged under key must be redacted.
+func IsSensitive(key string) bool {
+	k := strings.ToLower(key)
+	for _, s := range sensitiveKeys {
+		if strings.Contains(k, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// FromContext returns the request-scoped logger, or the default logger outside of a request.
+func FromContext(ctx context.Context) *slog.Logger {
+	if s, ok := ctx.Value(ctxKey{}).(*scope); ok {
+		s.mu.RLock()
+		defer s.mu.RUnlock()
+		return s.logger
+	}
+	return slog.Default()
+}
+
+// With adds attributes to the request-scoped logger for the rest of the request.
+func With(ctx context.Context, args ...any) {
+	if s, ok := ctx.Value(ctxKey{}).(*scope); ok {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+		s.logger = s.logger.With(args...)
+	}
+}
+
+// NewContext returns a copy of ctx carrying a request-scoped logger built from l, for requests
+// that do not reach the server through Middleware.
+func NewContext(ctx context.Context, l *slog.Logger) context.Context {
+	return context.WithValue(ctx, ctxKey{}, &scope{logger: l})
+}
+
+// Middleware injects a logger carrying the request and trace IDs into the request context and
+// writes one access log line per request once it is served.
+func Middleware(base *slog.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			l := base
+			if id := chimiddleware.GetReqID(r.Context()); id != "" {
+				l = l.With("request_id", id)
+			}
+			if sc := trace.SpanContextFromContext(r.Context()); sc.HasTraceID() {
+				l = l.With("trace_id", sc.TraceID().String())
+			}
+			s := &scope{logger: l}
+
+			ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+			next.ServeHTTP(ww, r.WithContext(context.WithValue(r.Context(), ctxKey{}, s)))
+
+			status := ww.Status()
+			if status == 0 {
+				status = http.StatusOK
+			}
+			level := slog.LevelInfo
+			if status >= http.StatusInternalServerError {
+				level = slog.LevelError
+			}
+
+			s.mu.RLock()
+			defer s.mu.RUnlock()
+			s.logger.LogAttrs(r.Context(), level, "request served",
+				slog.String("method", r.Method),
+				slog.String("path", r.URL.Path),
+				slog.Int("status", status),
+				slog.Int("bytes", ww.BytesWritten()),
+				slog.Duration("duration", time.Since(start)),
+				slog.String("remote_addr", r.RemoteAddr),
+			)
+		})
+	}
+}
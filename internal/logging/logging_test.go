@@ -0,0 +1,103 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"testing"
+
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+)
+
+func TestNew(t *testing.T) {
+	var tests = []struct {
+		name         string
+		cfg          config.LogConfig
+		expectsError bool
+	}{
+		{"json output", config.LogConfig{Level: "info", Format: config.LogFormatJSON}, false},
+		{"text output", config.LogConfig{Level: "debug", Format: config.LogFormatText}, false},
+		{"invalid level", config.LogConfig{Level: "loud", Format: config.LogFormatText}, true},
+		{"invalid format", config.LogConfig{Level: "info", Format: "xml"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			l, err := New(tc.cfg, &bytes.Buffer{})
+			if tc.expectsError && err == nil {
+				t.Errorf("function should return an error but it did not")
+			}
+			if !tc.expectsError && (err != nil || l == nil) {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestNew_redaction(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(config.LogConfig{Level: "info", Format: config.LogFormatJSON}, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l.Info("login",
+		"password", "secretPassword",
+		"jwt_token", "eyJhbGciOi",
+		"user", models.User{ID: 1, Email: "lorem@ipsum.com", Password: "$2a$06$hash"},
+	)
+
+	out := buf.String()
+	for _, leaked := range []string{"secretPassword", "eyJhbGciOi", "$2a$06$hash"} {
+		if strings.Contains(out, leaked) {
+			t.Errorf("sensitive value %q leaked into log output: %s", leaked, out)
+		}
+	}
+	if !strings.Contains(out, "lorem@ipsum.com") {
+		t.Errorf("non-sensitive fields should be kept: %s", out)
+	}
+}
+
+func TestMiddleware(t *testing.T) {
+	var buf bytes.Buffer
+	base := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	h := chimiddleware.RequestID(Middleware(base)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		With(r.Context(), "user_id", int64(42))
+		FromContext(r.Context()).Info("inside handler")
+		w.WriteHeader(http.StatusCreated)
+	})))
+
+	h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/tasks", nil))
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 log lines but got %d: %s", len(lines), buf.String())
+	}
+
+	var access map[string]any
+	if err := json.Unmarshal([]byte(lines[1]), &access); err != nil {
+		t.Fatal(err)
+	}
+	if access["request_id"] == nil || access["request_id"] == "" {
+		t.Errorf("access log should carry the request id")
+	}
+	if access["user_id"] != float64(42) {
+		t.Errorf("access log should carry the user id added during the request, got %v", access["user_id"])
+	}
+	if access["status"] != float64(http.StatusCreated) {
+		t.Errorf("expected status 201 but got %v", access["status"])
+	}
+}
+
+func TestFromContext_default(t *testing.T) {
+	if FromContext(context.Background()) != slog.Default() {
+		t.Errorf("outside of a request the default logger should be returned")
+	}
+}
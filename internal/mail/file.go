@@ -0,0 +1,48 @@
+package mail
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+type fileMailer struct {
+	from string
+	dir  string
+}
+
+// NewFileMailer writes every message as an .eml file into dir instead of sending it.
+func NewFileMailer(from, dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("mail: failed to create directory %s: %v", dir, err)
+	}
+	return &fileMailer{from: from, dir: dir}, nil
+}
+
+func (f fileMailer) Send(ctx context.Context, m Message) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	msg, err := render(f.from, m, now)
+	if err != nil {
+		return err
+	}
+
+	suffix := make([]byte, 4)
+	if _, err := rand.Read(suffix); err != nil {
+		return fmt.Errorf("mail: failed to generate file name: %v", err)
+	}
+	name := fmt.Sprintf("%s-%s.eml", now.UTC().Format("20060102T150405.000000000"), hex.EncodeToString(suffix))
+
+	if err := os.WriteFile(filepath.Join(f.dir, name), msg, 0o600); err != nil {
+		return fmt.Errorf("mail: failed to write message: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,54 @@
+package mail
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strings"
+	"task-manager/internal/config"
+	"time"
+)
+
+// Message is a plain text email.
+type Message struct {
+	To      string
+	Subject string
+	Body    string
+}
+
+type Mailer interface {
+	Send(ctx context.Context, m Message) error
+}
+
+// New returns the Mailer selected by c.Driver.
+func New(c config.MailConfig) (Mailer, error) {
+	switch c.Driver {
+	case config.MailDriverSMTP:
+		return NewSMTPMailer(c), nil
+	case config.MailDriverFile:
+		return NewFileMailer(c.From, c.Dir)
+	default:
+		return nil, fmt.Errorf("mail: unknown driver %q", c.Driver)
+	}
+}
+
+// render encodes m as an RFC 5322 message.
+func render(from string, m Message, now time.Time) ([]byte, error) {
+	for _, v := range []string{from, m.To, m.Subject} {
+		if strings.ContainsAny(v, "\r\n") {
+			return nil, fmt.Errorf("mail: header values must not contain line breaks")
+		}
+	}
+
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "From: %s\r\n", from)
+	fmt.Fprintf(&b, "To: %s\r\n", m.To)
+	fmt.Fprintf(&b, "Subject: %s\r\n", m.Subject)
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	b.WriteString("Content-Type: text/plain; charset=utf-8\r\n")
+	b.WriteString("\r\n")
+	b.WriteString(strings.ReplaceAll(m.Body, "\n", "\r\n"))
+
+	return b.Bytes(), nil
+}
@@ -0,0 +1,78 @@
+package mail
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"task-manager/internal/config"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	var tests = []struct {
+		name         string
+		cfg          config.MailConfig
+		expectsError bool
+	}{
+		{"file driver", config.MailConfig{Driver: config.MailDriverFile, From: "no-reply@localhost", Dir: t.TempDir()}, false},
+		{"smtp driver", config.MailConfig{Driver: config.MailDriverSMTP, From: "no-reply@localhost", SMTPHost: "localhost", SMTPPort: 25}, false},
+		{"unknown driver", config.MailConfig{Driver: "pigeon"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			m, err := New(tc.cfg)
+			if tc.expectsError && err == nil {
+				t.Errorf("function should return an error but it did not")
+			}
+			if !tc.expectsError && (err != nil || m == nil) {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestFileMailer_Send(t *testing.T) {
+	var tests = []struct {
+		name         string
+		msg          Message
+		expectsError bool
+	}{
+		{"message written", Message{To: "lorem@ipsum.com", Subject: "Hello", Body: "first line\nsecond line"}, false},
+		{"header injection rejected", Message{To: "lorem@ipsum.com\r\nBcc: evil@example.com", Subject: "Hello"}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			dir := t.TempDir()
+			m, err := NewFileMailer("no-reply@localhost", dir)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			err = m.Send(context.Background(), tc.msg)
+			if tc.expectsError {
+				if err == nil {
+					t.Errorf("function should return an error but it did not")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+
+			files, _ := filepath.Glob(filepath.Join(dir, "*.eml"))
+			if len(files) != 1 {
+				t.Fatalf("expected 1 message file but got %d", len(files))
+			}
+			b, _ := os.ReadFile(files[0])
+			out := string(b)
+			for _, want := range []string{"From: no-reply@localhost\r\n", "To: lorem@ipsum.com\r\n", "Subject: Hello\r\n", "first line\r\nsecond line"} {
+				if !strings.Contains(out, want) {
+					t.Errorf("message should contain %q: %s", want, out)
+				}
+			}
+		})
+	}
+}
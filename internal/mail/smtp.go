@@ -0,0 +1,52 @@
+package mail
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"task-manager/internal/config"
+	"time"
+)
+
+type smtpMailer struct {
+	from string
+	addr string
+	auth smtp.Auth
+}
+
+// NewSMTPMailer sends mail through the configured SMTP relay, authenticating with PLAIN auth
+// when a user is set. net/smtp upgrades to STARTTLS whenever the server offers it.
+func NewSMTPMailer(c config.MailConfig) Mailer {
+	m := &smtpMailer{
+		from: c.From,
+		addr: net.JoinHostPort(c.SMTPHost, strconv.Itoa(c.SMTPPort)),
+	}
+	if c.SMTPUser != "" {
+		m.auth = smtp.PlainAuth("", c.SMTPUser, c.SMTPPassword, c.SMTPHost)
+	}
+	return m
+}
+
+func (s smtpMailer) Send(ctx context.Context, m Message) error {
+	msg, err := render(s.from, m, time.Now())
+	if err != nil {
+		return err
+	}
+
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- smtp.SendMail(s.addr, s.auth, s.from, []string{m.To}, msg)
+	}()
+
+	select {
+	case err := <-errCh:
+		if err != nil {
+			return fmt.Errorf("mail: failed to send via smtp: %v", err)
+		}
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
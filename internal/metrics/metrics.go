@@ -0,0 +1,220 @@
+package metrics
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"task-manager/internal/db"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	chimiddleware "github.com/go-chi/chi/v5/middleware"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const (
+	namespace      = "task_manager"
+	unmatchedRoute = "unmatched"
+	scrapeTimeout  = 2 * time.Second
+)
+
+var (
+	httpRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "http_requests_total",
+		Help:      "Number of HTTP requests handled, by route pattern, method and status.",
+	}, []string{"route", "method", "status"})
+
+	httpDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "http_request_duration_seconds",
+		Help:      "HTTP request latency, by route pattern, method and status.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method", "status"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Name:      "db_query_duration_seconds",
+		Help:      "Latency of statements, by embedded SQL file.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"query"})
+
+	queryErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "db_query_errors_total",
+		Help:      "Number of failed statements, by embedded SQL file.",
+	}, []string{"query"})
+
+	TasksCreated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_created_total",
+		Help:      "Number of tasks created.",
+	})
+
+	TasksUpdated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_updated_total",
+		Help:      "Number of task updates.",
+	})
+
+	TasksDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "tasks_deleted_total",
+		Help:      "Number of tasks deleted.",
+	})
+
+	UsersRegistered = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_registered_total",
+		Help:      "Number of registered users.",
+	})
+
+	UsersDeleted = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "users_deleted_total",
+		Help:      "Number of deleted accounts.",
+	})
+
+	LoginFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "login_failures_total",
+		Help:      "Number of rejected logins, by reason.",
+	}, []string{"reason"})
+
+	CollabSlowClients = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "collab_slow_clients_total",
+		Help:      "Number of collaboration clients disconnected for falling behind.",
+	})
+)
+
+// Middleware records the request count and latency labelled by the chi route pattern,
+// so /tasks/1 and /tasks/2 end up in the same /tasks/{task_id} series.
+func Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		ww := chimiddleware.NewWrapResponseWriter(w, r.ProtoMajor)
+
+		next.ServeHTTP(ww, r)
+
+		route := unmatchedRoute
+		if rctx := chi.RouteContext(r.Context()); rctx != nil && rctx.RoutePattern() != "" {
+			route = rctx.RoutePattern()
+		}
+		status := ww.Status()
+		if status == 0 {
+			status = http.StatusOK
+		}
+		labels := prometheus.Labels{"route": route, "method": r.Method, "status": strconv.Itoa(status)}
+
+		httpRequests.With(labels).Inc()
+		httpDuration.With(labels).Observe(time.Since(start).Seconds())
+	})
+}
+
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterDB exposes the database/sql pool statistics of the primary and its replicas and
+// times every statement executed through d.
+func RegisterDB(d db.DB) error {
+	pools := map[string]*sql.DB{namespace: d.DB}
+	for i, r := range d.Replicas() {
+		pools[fmt.Sprintf("%s-replica-%d", namespace, i)] = r
+	}
+	for name, p := range pools {
+		if err := prometheus.Register(collectors.NewDBStatsCollector(p, name)); err != nil {
+			var are prometheus.AlreadyRegisteredError
+			if !errors.As(err, &are) {
+				return err
+			}
+		}
+	}
+
+	d.AddQueryHook(func(ctx context.Context, name string) (context.Context, func(error)) {
+		start := time.Now()
+		return ctx, func(err error) {
+			queryDuration.WithLabelValues(name).Observe(time.Since(start).Seconds())
+			if err != nil {
+				queryErrors.WithLabelValues(name).Inc()
+			}
+		}
+	})
+
+	return nil
+}
+
+// RegisterOverdueTasks exposes the number of overdue tasks, computed on every scrape.
+func RegisterOverdueTasks(count func(ctx context.Context, now time.Time) (int64, error)) error {
+	g := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "tasks_overdue",
+		Help:      "Number of tasks whose due date has passed.",
+	}, func() float64 {
+		ctx, cancel := context.WithTimeout(context.Background(), scrapeTimeout)
+		defer cancel()
+
+		n, err := count(ctx, time.Now())
+		if err != nil {
+			return math.NaN()
+		}
+		return float64(n)
+	})
+
+	if err := prometheus.Register(g); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterEventStreams exposes the number of open event streams.
+func RegisterEventStreams(count func() int) error {
+	g := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "event_streams",
+		Help:      "Number of clients connected to the stream of task events.",
+	}, func() float64 {
+		return float64(count())
+	})
+
+	if err := prometheus.Register(g); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RegisterCollabClients exposes the number of connected collaboration clients.
+func RegisterCollabClients(count func() int) error {
+	g := prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "collab_clients",
+		Help:      "Number of clients connected to the collaboration channel.",
+	}, func() float64 {
+		return float64(count())
+	})
+
+	if err := prometheus.Register(g); err != nil {
+		var are prometheus.AlreadyRegisteredError
+		if !errors.As(err, &are) {
+			return err
+		}
+	}
+
+	return nil
+}
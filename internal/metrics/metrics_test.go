@@ -0,0 +1,85 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestMiddleware(t *testing.T) {
+	r := chi.NewRouter()
+	r.Use(Middleware)
+	r.Get("/tasks/{task_id}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusTeapot)
+	})
+	r.Get("/ok", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ok"))
+	})
+
+	var tests = []struct {
+		name   string
+		path   string
+		route  string
+		status string
+		calls  int
+	}{
+		{"route pattern is used as label", "/tasks/%d", "/tasks/{task_id}", "418", 3},
+		{"implicit status is recorded", "/ok", "/ok", "200", 1},
+		{"unknown route", "/does-not-exist", unmatchedRoute, "404", 2},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			before := testutil.ToFloat64(httpRequests.WithLabelValues(tc.route, http.MethodGet, tc.status))
+			for i := 0; i < tc.calls; i++ {
+				path := tc.path
+				if strings.Contains(path, "%d") {
+					path = fmt.Sprintf(path, i)
+				}
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+			}
+
+			after := testutil.ToFloat64(httpRequests.WithLabelValues(tc.route, http.MethodGet, tc.status))
+			if after-before != float64(tc.calls) {
+				t.Errorf("expected %d requests recorded for %s but got %v", tc.calls, tc.route, after-before)
+			}
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	TasksCreated.Inc()
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("expected status code 200 but got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "task_manager_tasks_created_total") {
+		t.Errorf("exposition should contain business counters")
+	}
+}
+
+func TestRegisterOverdueTasks(t *testing.T) {
+	err := RegisterOverdueTasks(func(ctx context.Context, now time.Time) (int64, error) {
+		return 7, nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	rec := httptest.NewRecorder()
+	Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if !strings.Contains(rec.Body.String(), "task_manager_tasks_overdue 7") {
+		t.Errorf("exposition should contain overdue tasks gauge")
+	}
+}
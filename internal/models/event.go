@@ -0,0 +1,19 @@
+package models
+
+import "time"
+
+const (
+	TaskEventCreated = "task.created"
+	TaskEventUpdated = "task.updated"
+	TaskEventDeleted = "task.deleted"
+)
+
+// TaskEvent records a change to a task of a user. Ids increase with every change, so they
+// double as the position of a client in the stream of changes.
+type TaskEvent struct {
+	ID        int64     `json:"id"`
+	Type      string    `json:"type"`
+	TaskID    int       `json:"task_id"`
+	UserID    int64     `json:"-"`
+	CreatedAt time.Time `json:"created_at"`
+}
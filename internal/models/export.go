@@ -0,0 +1,34 @@
+package models
+
+import "time"
+
+// ExportVersion is bumped whenever the layout of DataExport changes incompatibly.
+const ExportVersion = 1
+
+// DataExport is the document written by the export command and read back by import. Password
+// hashes are included so accounts keep working after a move; tokens, MFA secrets and linked
+// identities are not.
+type DataExport struct {
+	Version    int          `json:"version"`
+	ExportedAt time.Time    `json:"exported_at"`
+	Users      []ExportUser `json:"users"`
+}
+
+type ExportUser struct {
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	Password        string     `json:"password"`
+	CreatedAt       *time.Time `json:"created_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	// Role is left out for RoleUser, so exports made before roles existed read the same.
+	Role  string       `json:"role,omitempty"`
+	Tasks []ExportTask `json:"tasks"`
+}
+
+type ExportTask struct {
+	Name        string     `json:"name"`
+	Priority    Priority   `json:"priority"`
+	Description string     `json:"description,omitempty"`
+	DueDate     *time.Time `json:"due_date"`
+	CreatedAt   *time.Time `json:"created_at"`
+}
@@ -0,0 +1,16 @@
+package models
+
+import "time"
+
+// TOTP is the authenticator app secret of a user. It is only active once ConfirmedAt is set.
+type TOTP struct {
+	UserID      int64
+	Secret      string
+	ConfirmedAt *time.Time
+	LastStep    *int64
+}
+
+type TOTPEnrolment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauth_uri"`
+}
@@ -0,0 +1,17 @@
+package models
+
+import "time"
+
+const (
+	TokenPurposeVerifyEmail   = "verify_email"
+	TokenPurposeResetPassword = "reset_password"
+)
+
+// UserToken is a single-use token sent to a user by e-mail. Only the hash of the token is stored.
+type UserToken struct {
+	UserID    int64
+	Purpose   string
+	Hash      string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -1,13 +1,55 @@
 package models
 
-import "time"
+import (
+	"log/slog"
+	"time"
+)
+
+const (
+	RoleUser  = "user"
+	RoleAdmin = "admin"
+)
 
 type User struct {
-	ID        int        `json:"id" db:"id"`
-	Name      string     `json:"name" db:"name"`
-	Email     string     `json:"email" db:"email"`
-	Password  string     `json:"password" db:"password"`
-	CreatedAt *time.Time `json:"created_at" db:"created_at"`
+	ID              int        `json:"id" db:"id"`
+	Name            string     `json:"name" db:"name"`
+	Email           string     `json:"email" db:"email"`
+	Password        string     `json:"password" db:"password"`
+	CreatedAt       *time.Time `json:"created_at" db:"created_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at" db:"email_verified_at"`
+	LastLoginAt     *time.Time `json:"last_login_at" db:"last_login_at"`
+	// Role is RoleUser unless an operator promoted the account.
+	Role string `json:"role" db:"role"`
+}
+
+// LogValue keeps the password hash out of logs when a User is logged as a whole.
+func (u User) LogValue() slog.Value {
+	return slog.GroupValue(
+		slog.Int("id", u.ID),
+		slog.String("name", u.Name),
+		slog.String("email", u.Email),
+	)
+}
+
+// Profile is the public view of a User returned by the /me endpoints.
+type Profile struct {
+	ID              int        `json:"id"`
+	Name            string     `json:"name"`
+	Email           string     `json:"email"`
+	CreatedAt       *time.Time `json:"created_at"`
+	EmailVerifiedAt *time.Time `json:"email_verified_at"`
+	LastLoginAt     *time.Time `json:"last_login_at"`
+}
+
+func (u User) Profile() Profile {
+	return Profile{
+		ID:              u.ID,
+		Name:            u.Name,
+		Email:           u.Email,
+		CreatedAt:       u.CreatedAt,
+		EmailVerifiedAt: u.EmailVerifiedAt,
+		LastLoginAt:     u.LastLoginAt,
+	}
 }
 
 type UserList struct {
@@ -20,6 +62,12 @@ type CreateUserPayload struct {
 	Password string `json:"password"`
 }
 
+// UpdateProfilePayload holds the profile fields to change; empty fields are left untouched.
+type UpdateProfilePayload struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
 type LoginPayload struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
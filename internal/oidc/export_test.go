@@ -0,0 +1,8 @@
+package oidc
+
+import "time"
+
+// SetNow replaces the clock of the client, so the external tests can expire its caches.
+func (c *Client) SetNow(now func() time.Time) {
+	c.now = now
+}
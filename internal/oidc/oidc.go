@@ -0,0 +1,385 @@
+// Package oidc implements the relying party side of an OpenID Connect authorization code flow
+// with PKCE: provider discovery, the code exchange and ID token validation against the
+// provider's published keys.
+package oidc
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+var (
+	// ErrInvalidIDToken is returned when an ID token fails any of the validation steps.
+	ErrInvalidIDToken = errors.New("oidc: invalid id token")
+	// ErrCodeRejected is returned when the token endpoint refuses the authorization code,
+	// e.g. because it was already used, has expired or the PKCE verifier does not match.
+	ErrCodeRejected = errors.New("oidc: authorization code rejected")
+)
+
+// Discovery holds the fields of the provider metadata the flow relies on.
+type Discovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+// Claims are the ID token claims used to find or create the local user.
+type Claims struct {
+	Issuer        string
+	Subject       string
+	Email         string
+	EmailVerified bool
+	Name          string
+}
+
+const (
+	// discoveryTTL is how long the provider metadata is used before it is fetched again.
+	discoveryTTL = time.Hour
+	// keysMinRefresh is the shortest time between two fetches of the key set, so tokens with
+	// unknown key ids cannot make every login hit the provider.
+	keysMinRefresh = time.Minute
+)
+
+type Config struct {
+	Issuer       string
+	ClientID     string
+	ClientSecret string
+	RedirectURL  string
+	Scopes       []string
+}
+
+// Client talks to a single provider. Discovery and keys are fetched lazily and cached, so the
+// application starts even when the provider is briefly unavailable. The discovery document is
+// fetched again after discoveryTTL and the key set when a token names an unknown key, at most
+// once per keysMinRefresh.
+type Client struct {
+	cfg  Config
+	http *http.Client
+	now  func() time.Time
+
+	mu   sync.Mutex
+	disc *Discovery
+	// discFetched is when disc was fetched, keysFetched when the key set was last requested,
+	// whether or not that succeeded
+	discFetched time.Time
+	keys        map[string]*rsa.PublicKey
+	keysFetched time.Time
+}
+
+func NewClient(cfg Config, hc *http.Client) *Client {
+	if hc == nil {
+		hc = &http.Client{Timeout: 10 * time.Second}
+	}
+	return &Client{cfg: cfg, http: hc, now: time.Now}
+}
+
+// AuthCodeURL returns the provider URL the browser is sent to.
+func (c *Client) AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error) {
+	d, err := c.discovery(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	v := url.Values{}
+	v.Set("response_type", "code")
+	v.Set("client_id", c.cfg.ClientID)
+	v.Set("redirect_uri", c.cfg.RedirectURL)
+	v.Set("scope", strings.Join(c.cfg.Scopes, " "))
+	v.Set("state", state)
+	v.Set("nonce", nonce)
+	v.Set("code_challenge", Challenge(verifier))
+	v.Set("code_challenge_method", "S256")
+
+	sep := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		sep = "&"
+	}
+	return d.AuthorizationEndpoint + sep + v.Encode(), nil
+}
+
+// Exchange trades the authorization code for tokens and returns the validated ID token claims.
+func (c *Client) Exchange(ctx context.Context, code, verifier, nonce string) (Claims, error) {
+	d, err := c.discovery(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", c.cfg.RedirectURL)
+	form.Set("code_verifier", verifier)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: failed to build token request: %v", err)
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(url.QueryEscape(c.cfg.ClientID), url.QueryEscape(c.cfg.ClientSecret))
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return Claims{}, fmt.Errorf("oidc: token request failed: %v", err)
+	}
+	defer res.Body.Close()
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	if err := json.NewDecoder(res.Body).Decode(&body); err != nil {
+		return Claims{}, fmt.Errorf("oidc: failed to decode token response: %v", err)
+	}
+	if res.StatusCode == http.StatusBadRequest && body.Error == "invalid_grant" {
+		return Claims{}, fmt.Errorf("%w: %s", ErrCodeRejected, body.ErrorDescription)
+	}
+	if res.StatusCode != http.StatusOK {
+		return Claims{}, fmt.Errorf("oidc: token endpoint returned %d: %s %s", res.StatusCode, body.Error, body.ErrorDescription)
+	}
+	if body.IDToken == "" {
+		return Claims{}, fmt.Errorf("oidc: token response has no id_token")
+	}
+
+	return c.Verify(ctx, body.IDToken, nonce)
+}
+
+// Verify validates the signature, issuer, audience, expiry and nonce of a raw ID token.
+func (c *Client) Verify(ctx context.Context, raw, nonce string) (Claims, error) {
+	d, err := c.discovery(ctx)
+	if err != nil {
+		return Claims{}, err
+	}
+
+	var claims struct {
+		jwt.RegisteredClaims
+		Nonce         string `json:"nonce"`
+		AuthorizedBy  string `json:"azp"`
+		Email         string `json:"email"`
+		EmailVerified any    `json:"email_verified"`
+		Name          string `json:"name"`
+	}
+	_, err = jwt.ParseWithClaims(raw, &claims, func(t *jwt.Token) (any, error) {
+		kid, _ := t.Header["kid"].(string)
+		return c.key(ctx, kid)
+	},
+		jwt.WithValidMethods([]string{"RS256"}),
+		jwt.WithIssuer(d.Issuer),
+		jwt.WithAudience(c.cfg.ClientID),
+		jwt.WithExpirationRequired(),
+		jwt.WithIssuedAt(),
+		jwt.WithLeeway(time.Minute),
+	)
+	if err != nil {
+		return Claims{}, fmt.Errorf("%w: %v", ErrInvalidIDToken, err)
+	}
+	if claims.Subject == "" {
+		return Claims{}, fmt.Errorf("%w: missing subject", ErrInvalidIDToken)
+	}
+	if claims.Nonce != nonce {
+		return Claims{}, fmt.Errorf("%w: nonce mismatch", ErrInvalidIDToken)
+	}
+	if len(claims.Audience) > 1 && claims.AuthorizedBy != c.cfg.ClientID {
+		return Claims{}, fmt.Errorf("%w: unexpected authorized party", ErrInvalidIDToken)
+	}
+
+	return Claims{
+		Issuer:        claims.Issuer,
+		Subject:       claims.Subject,
+		Email:         claims.Email,
+		EmailVerified: isTrue(claims.EmailVerified),
+		Name:          claims.Name,
+	}, nil
+}
+
+// isTrue accepts email_verified as a boolean or, as some providers send it, a string.
+func isTrue(v any) bool {
+	switch b := v.(type) {
+	case bool:
+		return b
+	case string:
+		return b == "true"
+	}
+	return false
+}
+
+// discovery returns the provider metadata, fetching it again once it is older than
+// discoveryTTL. A document that cannot be refreshed stays in use until the provider is back.
+func (c *Client) discovery(ctx context.Context) (*Discovery, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	now := c.now()
+	if c.disc != nil && now.Sub(c.discFetched) < discoveryTTL {
+		return c.disc, nil
+	}
+
+	d, err := c.fetchDiscovery(ctx)
+	if err != nil {
+		if c.disc != nil {
+			return c.disc, nil
+		}
+		return nil, err
+	}
+
+	// keys published at a new location are fetched on their next use
+	if c.disc != nil && c.disc.JWKSURI != d.JWKSURI {
+		c.keys = nil
+		c.keysFetched = time.Time{}
+	}
+	c.disc = d
+	c.discFetched = now
+	return c.disc, nil
+}
+
+func (c *Client) fetchDiscovery(ctx context.Context) (*Discovery, error) {
+	var d Discovery
+	u := strings.TrimRight(c.cfg.Issuer, "/") + "/.well-known/openid-configuration"
+	if err := c.getJSON(ctx, u, &d); err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %v", err)
+	}
+	if d.Issuer != c.cfg.Issuer {
+		return nil, fmt.Errorf("oidc: discovery issuer %q does not match configured issuer %q", d.Issuer, c.cfg.Issuer)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, fmt.Errorf("oidc: discovery document is missing endpoints")
+	}
+	return &d, nil
+}
+
+// key returns the signing key with the given id, refreshing the key set when it is unknown to
+// pick up provider key rotation. Within keysMinRefresh of the last refresh an unknown key is
+// rejected right away.
+func (c *Client) key(ctx context.Context, kid string) (*rsa.PublicKey, error) {
+	c.mu.Lock()
+	k, ok := c.lookupKey(kid)
+	refresh := !ok && (c.keysFetched.IsZero() || c.now().Sub(c.keysFetched) >= keysMinRefresh)
+	if refresh {
+		c.keysFetched = c.now()
+	}
+	c.mu.Unlock()
+	if ok {
+		return k, nil
+	}
+	if !refresh {
+		return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+	}
+
+	if err := c.refreshKeys(ctx); err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if k, ok := c.lookupKey(kid); ok {
+		return k, nil
+	}
+	return nil, fmt.Errorf("oidc: unknown signing key %q", kid)
+}
+
+// lookupKey finds the key with the given id in the cached set; c.mu has to be held.
+func (c *Client) lookupKey(kid string) (*rsa.PublicKey, bool) {
+	if k, ok := c.keys[kid]; ok {
+		return k, true
+	}
+	// tokens without kid are accepted when the provider publishes a single key
+	if kid == "" && len(c.keys) == 1 {
+		for _, k := range c.keys {
+			return k, true
+		}
+	}
+	return nil, false
+}
+
+func (c *Client) refreshKeys(ctx context.Context) error {
+	d, err := c.discovery(ctx)
+	if err != nil {
+		return err
+	}
+
+	var set struct {
+		Keys []struct {
+			Kty string `json:"kty"`
+			Kid string `json:"kid"`
+			Use string `json:"use"`
+			N   string `json:"n"`
+			E   string `json:"e"`
+		} `json:"keys"`
+	}
+	if err := c.getJSON(ctx, d.JWKSURI, &set); err != nil {
+		return fmt.Errorf("oidc: failed to fetch keys: %v", err)
+	}
+
+	keys := map[string]*rsa.PublicKey{}
+	for _, k := range set.Keys {
+		if k.Kty != "RSA" || (k.Use != "" && k.Use != "sig") {
+			continue
+		}
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			continue
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil || len(e) > 4 {
+			continue
+		}
+		keys[k.Kid] = &rsa.PublicKey{
+			N: new(big.Int).SetBytes(n),
+			E: int(new(big.Int).SetBytes(e).Int64()),
+		}
+	}
+
+	c.mu.Lock()
+	c.keys = keys
+	c.mu.Unlock()
+	return nil
+}
+
+func (c *Client) getJSON(ctx context.Context, u string, v any) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("GET %s returned %d", u, res.StatusCode)
+	}
+	return json.NewDecoder(res.Body).Decode(v)
+}
+
+// NewVerifier returns a random PKCE code verifier; it doubles as a source for state and nonce.
+func NewVerifier() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("oidc: failed to generate random value: %v", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// Challenge derives the S256 PKCE code challenge of verifier.
+func Challenge(verifier string) string {
+	sum := sha256.Sum256([]byte(verifier))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
@@ -0,0 +1,177 @@
+package oidc_test
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/url"
+	"strings"
+	"task-manager/internal/oidc"
+	"task-manager/internal/oidc/oidctest"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func TestClient_Flow(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	c := oidc.NewClient(p.Config("http://localhost/auth/oidc/callback"), nil)
+	ctx := context.Background()
+	id := oidctest.Identity{Subject: "user-1", Email: "lorem@ipsum.com", EmailVerified: true, Name: "Lorem Ipsum"}
+
+	verifier, _ := oidc.NewVerifier()
+	authURL, err := c.AuthCodeURL(ctx, "state-1", "nonce-1", verifier)
+	if err != nil {
+		t.Fatal(err)
+	}
+	u, _ := url.Parse(authURL)
+	if !strings.HasPrefix(authURL, p.URL+"/authorize?") || u.Query().Get("redirect_uri") != "http://localhost/auth/oidc/callback" {
+		t.Errorf("unexpected authorization url %s", authURL)
+	}
+
+	code, state, err := p.Authorize(authURL, id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if state != "state-1" {
+		t.Errorf("state should be passed through, got %s", state)
+	}
+
+	claims, err := c.Exchange(ctx, code, verifier, "nonce-1")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	want := oidc.Claims{Issuer: p.URL, Subject: "user-1", Email: "lorem@ipsum.com", EmailVerified: true, Name: "Lorem Ipsum"}
+	if claims != want {
+		t.Errorf("expected claims %v but got %v", want, claims)
+	}
+
+	if _, err := c.Exchange(ctx, code, verifier, "nonce-1"); err == nil {
+		t.Errorf("a code should only be exchanged once")
+	}
+
+	code, _, _ = p.Authorize(authURL, id)
+	if _, err := c.Exchange(ctx, code, "other-verifier", "nonce-1"); err == nil {
+		t.Errorf("exchange with the wrong PKCE verifier should fail")
+	}
+}
+
+func TestClient_Verify(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	c := oidc.NewClient(p.Config("http://localhost/callback"), nil)
+	other := oidctest.NewProvider(t)
+	id := oidctest.Identity{Subject: "user-1", Email: "lorem@ipsum.com", EmailVerified: true}
+
+	var tests = []struct {
+		name         string
+		token        string
+		expectsError bool
+	}{
+		{"valid token", p.IDToken(id, "nonce", nil), false},
+		{"email_verified as string", p.IDToken(id, "nonce", jwt.MapClaims{"email_verified": "true"}), false},
+		{"wrong nonce", p.IDToken(id, "other", nil), true},
+		{"wrong audience", p.IDToken(id, "nonce", jwt.MapClaims{"aud": "other-client"}), true},
+		{"wrong issuer", p.IDToken(id, "nonce", jwt.MapClaims{"iss": "https://evil.example.com"}), true},
+		{"expired", p.IDToken(id, "nonce", jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()}), true},
+		{"signed by another provider", other.IDToken(id, "nonce", jwt.MapClaims{"iss": p.URL}), true},
+		{"multiple audiences without azp", p.IDToken(id, "nonce", jwt.MapClaims{"aud": []string{oidctest.ClientID, "other"}}), true},
+		{"multiple audiences with azp", p.IDToken(id, "nonce", jwt.MapClaims{"aud": []string{oidctest.ClientID, "other"}, "azp": oidctest.ClientID}), false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := c.Verify(context.Background(), tc.token, "nonce")
+			if tc.expectsError && !errors.Is(err, oidc.ErrInvalidIDToken) {
+				t.Errorf("expected ErrInvalidIDToken but got %v", err)
+			}
+			if !tc.expectsError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		})
+	}
+}
+
+func TestClient_DiscoveryIssuerMismatch(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	cfg := p.Config("http://localhost/callback")
+	cfg.Issuer = p.URL + "/"
+	c := oidc.NewClient(cfg, nil)
+
+	if _, err := c.AuthCodeURL(context.Background(), "s", "n", "v"); err == nil {
+		t.Errorf("issuer mismatch should be rejected")
+	}
+}
+
+func TestClient_KeyRefresh(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	c := oidc.NewClient(p.Config("http://localhost/callback"), nil)
+	now := time.Now()
+	c.SetNow(func() time.Time { return now })
+	ctx := context.Background()
+	id := oidctest.Identity{Subject: "user-1", Email: "lorem@ipsum.com", EmailVerified: true}
+
+	if _, err := c.Verify(ctx, p.IDToken(id, "nonce", nil), "nonce"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if n := p.Requests("/jwks"); n != 1 {
+		t.Fatalf("expected the keys to be fetched once but got %d", n)
+	}
+
+	unknown := p.IDToken(id, "nonce", nil)
+	parts := strings.SplitN(unknown, ".", 2)
+	header, _ := json.Marshal(map[string]string{"alg": "RS256", "typ": "JWT", "kid": "rotated"})
+	unknown = base64.RawURLEncoding.EncodeToString(header) + "." + parts[1]
+
+	for range 3 {
+		if _, err := c.Verify(ctx, unknown, "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+			t.Errorf("expected ErrInvalidIDToken but got %v", err)
+		}
+	}
+	if n := p.Requests("/jwks"); n != 1 {
+		t.Errorf("unknown keys should not refresh the keys again right away, got %d fetches", n)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, err := c.Verify(ctx, unknown, "nonce"); !errors.Is(err, oidc.ErrInvalidIDToken) {
+		t.Errorf("expected ErrInvalidIDToken but got %v", err)
+	}
+	if n := p.Requests("/jwks"); n != 2 {
+		t.Errorf("unknown keys should refresh the keys once the interval passed, got %d fetches", n)
+	}
+}
+
+func TestClient_DiscoveryRefresh(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	c := oidc.NewClient(p.Config("http://localhost/callback"), nil)
+	now := time.Now()
+	c.SetNow(func() time.Time { return now })
+	ctx := context.Background()
+	const path = "/.well-known/openid-configuration"
+
+	for range 2 {
+		if _, err := c.AuthCodeURL(ctx, "s", "n", "v"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if n := p.Requests(path); n != 1 {
+		t.Errorf("expected the discovery document to be cached but it was fetched %d times", n)
+	}
+
+	now = now.Add(2 * time.Hour)
+	if _, err := c.AuthCodeURL(ctx, "s", "n", "v"); err != nil {
+		t.Fatal(err)
+	}
+	if n := p.Requests(path); n != 2 {
+		t.Errorf("expected the discovery document to be fetched again but got %d fetches", n)
+	}
+}
+
+func TestChallenge(t *testing.T) {
+	// RFC 7636 appendix B
+	got := oidc.Challenge("dBjftJeZ4CVP-mB92K27uhbUJU1p1r_wW1gFWFOEjXk")
+	if got != "E9Melhoa2OwvFrEMTJguCHaoeK1t8URWbuGJSstw-cM" {
+		t.Errorf("unexpected challenge %s", got)
+	}
+}
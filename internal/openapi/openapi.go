@@ -0,0 +1,62 @@
+// Package openapi serves the OpenAPI document of the REST API and a page rendering it.
+package openapi
+
+import (
+	"crypto/sha256"
+	_ "embed"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+)
+
+// Spec is the OpenAPI 3.1 document of the routes of the server. The server tests fail when a
+// route is missing from it, so it has to be updated together with the routes.
+//
+//go:embed openapi.json
+var Spec []byte
+
+// swaggerUI is the version of Swagger UI rendering the document; it is loaded from a CDN.
+const swaggerUI = "https://cdn.jsdelivr.net/npm/swagger-ui-dist@5.17.14"
+
+const initScript = `SwaggerUIBundle({url: "/openapi.json", dom_id: "#docs"});`
+
+var docsPage = []byte(fmt.Sprintf(`<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Task Manager API</title>
+<link rel="stylesheet" href="%[1]s/swagger-ui.css">
+</head>
+<body>
+<div id="docs"></div>
+<script src="%[1]s/swagger-ui-bundle.js"></script>
+<script>%[2]s</script>
+</body>
+</html>
+`, swaggerUI, initScript))
+
+// docsPolicy relaxes the API wide Content-Security-Policy just enough for the page: Swagger UI
+// from the CDN, the inline script starting it by its hash, and the document from this origin.
+var docsPolicy = func() string {
+	sum := sha256.Sum256([]byte(initScript))
+	return fmt.Sprintf("default-src 'none'; script-src %[1]s/ 'sha256-%[2]s'; style-src %[1]s/ 'unsafe-inline'; "+
+		"img-src 'self' data:; connect-src 'self'; frame-ancestors 'none'",
+		swaggerUI, base64.StdEncoding.EncodeToString(sum[:]))
+}()
+
+// Handler serves Spec.
+func Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(Spec)
+	}
+}
+
+// Docs serves a page rendering the document served by Handler at /openapi.json.
+func Docs() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Content-Security-Policy", docsPolicy)
+		_, _ = w.Write(docsPage)
+	}
+}
@@ -0,0 +1,131 @@
+package openapi
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"slices"
+	"strings"
+	"task-manager/internal/controllers"
+	"task-manager/internal/graph"
+	"task-manager/internal/health"
+	"task-manager/internal/models"
+	"task-manager/internal/requests"
+	"testing"
+
+	"github.com/google/go-cmp/cmp"
+)
+
+// jsonFields returns the names the fields of the struct type t are encoded with.
+func jsonFields(t reflect.Type) []string {
+	var names []string
+	for _, f := range reflect.VisibleFields(t) {
+		if !f.IsExported() || f.Anonymous {
+			continue
+		}
+		name, _, _ := strings.Cut(f.Tag.Get("json"), ",")
+		switch name {
+		case "-":
+			continue
+		case "":
+			name = f.Name
+		}
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+func TestSchemasMatchTypes(t *testing.T) {
+	var doc struct {
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]json.RawMessage `json:"properties"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(Spec, &doc); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		schema string
+		value  any
+		// requests may ignore some fields of their type, responses have to document all of them
+		request bool
+	}{
+		{schema: "Task", value: models.Task{}},
+		{schema: "TasksList", value: models.TasksList{}},
+		{schema: "Profile", value: models.Profile{}},
+		{schema: "ProfileResponse", value: controllers.ProfileResponse{}},
+		{schema: "AuthResponse", value: controllers.AuthResponse{}},
+		{schema: "SessionResponse", value: controllers.SessionResponse{}},
+		{schema: "MFAChallengeResponse", value: controllers.MFAChallengeResponse{}},
+		{schema: "RecoveryCodes", value: controllers.RecoveryCodesResponse{}},
+		{schema: "TOTPEnrolment", value: models.TOTPEnrolment{}},
+		{schema: "HealthReport", value: health.Report{}},
+		{schema: "HealthResult", value: health.Result{}},
+		{schema: "TaskEvent", value: models.TaskEvent{}},
+		{schema: "TaskRequest", value: requests.CreateTasksRequest{}, request: true},
+		{schema: "TaskRequest", value: requests.UpdateTaskRequest{}, request: true},
+		{schema: "RegisterRequest", value: requests.CreateUserRequest{}, request: true},
+		{schema: "Credentials", value: requests.Credentials{}, request: true},
+		{schema: "MFALoginRequest", value: requests.MFALoginRequest{}, request: true},
+		{schema: "VerifyEmailRequest", value: requests.VerifyEmailRequest{}, request: true},
+		{schema: "ForgotPasswordRequest", value: requests.ForgotPasswordRequest{}, request: true},
+		{schema: "ResetPasswordRequest", value: requests.ResetPasswordRequest{}, request: true},
+		{schema: "UpdateProfileRequest", value: requests.UpdateUserRequest{}, request: true},
+		{schema: "ChangePasswordRequest", value: requests.ChangePasswordRequest{}, request: true},
+		{schema: "MFACodeRequest", value: requests.MFACodeRequest{}, request: true},
+		{schema: "GraphQLRequest", value: graph.Request{}, request: true},
+	}
+
+	for _, tt := range tests {
+		typ := reflect.TypeOf(tt.value)
+		t.Run(tt.schema+"/"+typ.Name(), func(t *testing.T) {
+			s, ok := doc.Components.Schemas[tt.schema]
+			if !ok {
+				t.Fatalf("schema %s is missing", tt.schema)
+			}
+			var props []string
+			for name := range s.Properties {
+				props = append(props, name)
+			}
+			slices.Sort(props)
+			fields := jsonFields(typ)
+
+			if !tt.request {
+				if diff := cmp.Diff(fields, props); diff != "" {
+					t.Errorf("the properties of %s differ from the fields of %s (-fields +properties):\n%s", tt.schema, typ, diff)
+				}
+				return
+			}
+			for _, p := range props {
+				if !slices.Contains(fields, p) {
+					t.Errorf("%s has no field for the property %s of %s", typ, p, tt.schema)
+				}
+			}
+		})
+	}
+}
+
+func TestDocs(t *testing.T) {
+	w := httptest.NewRecorder()
+	Docs()(w, httptest.NewRequest(http.MethodGet, "/docs", nil))
+
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected 200 but got %d", w.Code)
+	}
+	// browsers only run the inline script when the policy lists its hash
+	sum := sha256.Sum256([]byte(initScript))
+	hash := "'sha256-" + base64.StdEncoding.EncodeToString(sum[:]) + "'"
+	if !strings.Contains(w.Header().Get("Content-Security-Policy"), hash) {
+		t.Errorf("expected the policy to allow the script by %s but got %q", hash, w.Header().Get("Content-Security-Policy"))
+	}
+	if !strings.Contains(w.Body.String(), "<script>"+initScript+"</script>") {
+		t.Errorf("expected the page to start Swagger UI but got %s", w.Body.String())
+	}
+}
@@ -0,0 +1,439 @@
+package openapi
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"golang.org/x/text/language"
+	"golang.org/x/text/message"
+)
+
+// specURL is the location the schemas of Spec are compiled from.
+const specURL = "openapi.json"
+
+var printer = message.NewPrinter(language.English)
+
+// Validator checks requests, and optionally responses, against the operations of Spec.
+type Validator struct {
+	routes            []route
+	validateResponses bool
+}
+
+type route struct {
+	method   string
+	segments []string
+	op       operation
+}
+
+type operation struct {
+	params []parameter
+	// body is nil for operations without a JSON request body.
+	body         *jsonschema.Schema
+	bodyRequired bool
+	// responses maps the documented status codes to the schemas of their JSON bodies, which
+	// are nil for other bodies.
+	responses map[string]*jsonschema.Schema
+}
+
+type parameter struct {
+	name     string
+	in       string
+	required bool
+	typ      string
+	schema   *jsonschema.Schema
+}
+
+// The parts of the document the validator reads. Objects may be references to components.
+type (
+	pathItem struct {
+		Parameters []parameterObject `json:"parameters"`
+	}
+	operationObject struct {
+		Parameters  []parameterObject         `json:"parameters"`
+		RequestBody *requestBodyObject        `json:"requestBody"`
+		Responses   map[string]responseObject `json:"responses"`
+	}
+	parameterObject struct {
+		Ref      string `json:"$ref"`
+		Name     string `json:"name"`
+		In       string `json:"in"`
+		Required bool   `json:"required"`
+		Schema   *struct {
+			Type any `json:"type"`
+		} `json:"schema"`
+	}
+	requestBodyObject struct {
+		Ref      string                     `json:"$ref"`
+		Required bool                       `json:"required"`
+		Content  map[string]json.RawMessage `json:"content"`
+	}
+	responseObject struct {
+		Ref     string                     `json:"$ref"`
+		Content map[string]json.RawMessage `json:"content"`
+	}
+)
+
+// NewValidator compiles the schemas of Spec. validateResponses makes the middleware check the
+// responses of the handlers as well, which buffers them; it is meant for tests.
+func NewValidator(validateResponses bool) (*Validator, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(Spec))
+	if err != nil {
+		return nil, fmt.Errorf("NewValidator: failed to read the document: %v", err)
+	}
+	c := jsonschema.NewCompiler()
+	c.DefaultDraft(jsonschema.Draft2020)
+	c.AssertFormat()
+	if err := c.AddResource(specURL, doc); err != nil {
+		return nil, fmt.Errorf("NewValidator: %v", err)
+	}
+
+	var spec struct {
+		Paths      map[string]map[string]json.RawMessage `json:"paths"`
+		Components struct {
+			Parameters    map[string]parameterObject   `json:"parameters"`
+			RequestBodies map[string]requestBodyObject `json:"requestBodies"`
+			Responses     map[string]responseObject    `json:"responses"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(Spec, &spec); err != nil {
+		return nil, fmt.Errorf("NewValidator: failed to read the document: %v", err)
+	}
+
+	b := builder{c: c}
+	b.parameters = spec.Components.Parameters
+	b.requestBodies = spec.Components.RequestBodies
+	b.responses = spec.Components.Responses
+
+	v := &Validator{validateResponses: validateResponses}
+	for path, item := range spec.Paths {
+		var common pathItem
+		if raw, ok := item["parameters"]; ok {
+			if err := json.Unmarshal(raw, &common.Parameters); err != nil {
+				return nil, fmt.Errorf("NewValidator: %s: %v", path, err)
+			}
+		}
+		for method, raw := range item {
+			if method == "parameters" || method == "summary" || method == "description" {
+				continue
+			}
+			var o operationObject
+			if err := json.Unmarshal(raw, &o); err != nil {
+				return nil, fmt.Errorf("NewValidator: %s %s: %v", method, path, err)
+			}
+			op, err := b.operation("/paths/"+escape(path), method, common, o)
+			if err != nil {
+				return nil, fmt.Errorf("NewValidator: %s %s: %v", method, path, err)
+			}
+			v.routes = append(v.routes, route{
+				method:   strings.ToUpper(method),
+				segments: strings.Split(path, "/"),
+				op:       op,
+			})
+		}
+	}
+	return v, nil
+}
+
+// builder compiles the schemas of operations, resolving references to components.
+type builder struct {
+	c             *jsonschema.Compiler
+	parameters    map[string]parameterObject
+	requestBodies map[string]requestBodyObject
+	responses     map[string]responseObject
+}
+
+func (b builder) operation(ptr, method string, common pathItem, o operationObject) (operation, error) {
+	op := operation{responses: make(map[string]*jsonschema.Schema)}
+
+	params := func(base string, ps []parameterObject) error {
+		for i, p := range ps {
+			loc := fmt.Sprintf("%s/parameters/%d", base, i)
+			if p.Ref != "" {
+				loc = strings.TrimPrefix(p.Ref, "#")
+				p = b.parameters[strings.TrimPrefix(p.Ref, "#/components/parameters/")]
+			}
+			sch, err := b.c.Compile(specURL + "#" + loc + "/schema")
+			if err != nil {
+				return err
+			}
+			typ := ""
+			if p.Schema != nil {
+				typ, _ = p.Schema.Type.(string)
+			}
+			op.params = append(op.params, parameter{name: p.Name, in: p.In, required: p.Required, typ: typ, schema: sch})
+		}
+		return nil
+	}
+	if err := params(ptr, common.Parameters); err != nil {
+		return operation{}, err
+	}
+	if err := params(ptr+"/"+method, o.Parameters); err != nil {
+		return operation{}, err
+	}
+
+	if body := o.RequestBody; body != nil {
+		loc := ptr + "/" + method + "/requestBody"
+		if body.Ref != "" {
+			loc = strings.TrimPrefix(body.Ref, "#")
+			resolved := b.requestBodies[strings.TrimPrefix(body.Ref, "#/components/requestBodies/")]
+			body = &resolved
+		}
+		if _, ok := body.Content["application/json"]; ok {
+			sch, err := b.c.Compile(specURL + "#" + loc + "/content/application~1json/schema")
+			if err != nil {
+				return operation{}, err
+			}
+			op.body = sch
+			op.bodyRequired = body.Required
+		}
+	}
+
+	for status, res := range o.Responses {
+		loc := ptr + "/" + method + "/responses/" + status
+		if res.Ref != "" {
+			loc = strings.TrimPrefix(res.Ref, "#")
+			res = b.responses[strings.TrimPrefix(res.Ref, "#/components/responses/")]
+		}
+		var sch *jsonschema.Schema
+		if _, ok := res.Content["application/json"]; ok {
+			var err error
+			sch, err = b.c.Compile(specURL + "#" + loc + "/content/application~1json/schema")
+			if err != nil {
+				return operation{}, err
+			}
+		}
+		op.responses[status] = sch
+	}
+	return op, nil
+}
+
+// escape escapes s for use as a token of a JSON pointer.
+func escape(s string) string {
+	return strings.NewReplacer("~", "~0", "/", "~1").Replace(s)
+}
+
+// find returns the operation serving the request and the values of its path parameters, or
+// nil when there is none. Literal segments take precedence over parameters, like in the router.
+func (v *Validator) find(method, path string) (*operation, map[string]string) {
+	if path != "/" {
+		path = strings.TrimSuffix(path, "/")
+	}
+	segments := strings.Split(path, "/")
+
+	var best *route
+	bestLiterals := -1
+	for i := range v.routes {
+		rt := &v.routes[i]
+		if rt.method != method || len(rt.segments) != len(segments) {
+			continue
+		}
+		literals, ok := 0, true
+		for j, s := range rt.segments {
+			if strings.HasPrefix(s, "{") {
+				ok = segments[j] != ""
+			} else {
+				ok = s == segments[j]
+				literals++
+			}
+			if !ok {
+				break
+			}
+		}
+		if ok && literals > bestLiterals {
+			best, bestLiterals = rt, literals
+		}
+	}
+	if best == nil {
+		return nil, nil
+	}
+
+	values := make(map[string]string)
+	for j, s := range best.segments {
+		if strings.HasPrefix(s, "{") {
+			values[strings.Trim(s, "{}")] = segments[j]
+		}
+	}
+	return &best.op, values
+}
+
+// Middleware rejects requests not matching their operation with 400, before they reach the
+// handlers; bodies larger than bodySizeLimit are rejected with 413. Requests without an
+// operation are left to the router. When enabled, responses not matching the operation are
+// replaced by a 500.
+func (v *Validator) Middleware(bodySizeLimit int64) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			op, pathValues := v.find(r.Method, r.URL.Path)
+			if op == nil {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if err := op.checkParams(r, pathValues); err != nil {
+				helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("request: %v", err))
+				return
+			}
+			if op.body != nil {
+				body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, bodySizeLimit))
+				var tooLarge *http.MaxBytesError
+				if errors.As(err, &tooLarge) {
+					helpers.JsonResponse(w, http.StatusRequestEntityTooLarge, fmt.Sprintf("request: body too large"))
+					return
+				}
+				if err != nil {
+					helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("request: failed to read body: %v", err))
+					return
+				}
+				if err := op.checkBody(body); err != nil {
+					helpers.JsonResponse(w, http.StatusBadRequest, fmt.Sprintf("request: %v", err))
+					return
+				}
+				r.Body = io.NopCloser(bytes.NewReader(body))
+			}
+
+			if !v.validateResponses {
+				next.ServeHTTP(w, r)
+				return
+			}
+			buf := &responseBuffer{ResponseWriter: w, status: http.StatusOK}
+			next.ServeHTTP(buf, r)
+			if err := op.checkResponse(buf); err != nil {
+				logging.FromContext(r.Context()).Error("response does not match the API specification", "err", err, "status", buf.status)
+				helpers.JsonResponse(w, http.StatusInternalServerError, fmt.Sprintf("response does not match the API specification: %v", err))
+				return
+			}
+			w.WriteHeader(buf.status)
+			_, _ = w.Write(buf.body.Bytes())
+		})
+	}
+}
+
+func (op operation) checkParams(r *http.Request, pathValues map[string]string) error {
+	query := r.URL.Query()
+	for _, p := range op.params {
+		var value string
+		var present bool
+		switch p.in {
+		case "path":
+			value, present = pathValues[p.name]
+		case "query":
+			present = query.Has(p.name)
+			value = query.Get(p.name)
+		case "header":
+			value = r.Header.Get(p.name)
+			present = value != ""
+		default:
+			continue
+		}
+		if !present {
+			if p.required {
+				return fmt.Errorf("missing %s parameter %s", p.in, p.name)
+			}
+			continue
+		}
+
+		// parameters arrive as strings; numbers are checked as such so their bounds apply
+		var instance any = value
+		if p.typ == "integer" || p.typ == "number" {
+			if _, err := strconv.ParseFloat(value, 64); err == nil {
+				instance = json.Number(value)
+			}
+		}
+		if err := p.schema.Validate(instance); err != nil {
+			return fmt.Errorf("invalid %s parameter %s: %s", p.in, p.name, describe(err))
+		}
+	}
+	return nil
+}
+
+func (op operation) checkBody(body []byte) error {
+	if len(bytes.TrimSpace(body)) == 0 {
+		if op.bodyRequired {
+			return fmt.Errorf("missing body")
+		}
+		return nil
+	}
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("body is not valid JSON: %v", err)
+	}
+	if err := op.body.Validate(instance); err != nil {
+		return fmt.Errorf("invalid body: %s", describe(err))
+	}
+	return nil
+}
+
+func (op operation) checkResponse(buf *responseBuffer) error {
+	sch, ok := op.responses[strconv.Itoa(buf.status)]
+	if !ok {
+		sch, ok = op.responses["default"]
+	}
+	if !ok {
+		return fmt.Errorf("status %d is not documented", buf.status)
+	}
+	if sch == nil || !strings.HasPrefix(buf.Header().Get("Content-Type"), "application/json") {
+		return nil
+	}
+
+	instance, err := jsonschema.UnmarshalJSON(bytes.NewReader(buf.body.Bytes()))
+	if err != nil {
+		return fmt.Errorf("body is not valid JSON: %v", err)
+	}
+	if err := sch.Validate(instance); err != nil {
+		return fmt.Errorf("invalid body: %s", describe(err))
+	}
+	return nil
+}
+
+// describe lists the failed checks of a schema validation error, e.g. "/name: got number,
+// want string".
+func describe(err error) string {
+	var ve *jsonschema.ValidationError
+	if !errors.As(err, &ve) {
+		return err.Error()
+	}
+
+	var msgs []string
+	var walk func(e *jsonschema.ValidationError)
+	walk = func(e *jsonschema.ValidationError) {
+		if len(e.Causes) == 0 {
+			msgs = append(msgs, "/"+strings.Join(e.InstanceLocation, "/")+": "+e.ErrorKind.LocalizedString(printer))
+			return
+		}
+		for _, c := range e.Causes {
+			walk(c)
+		}
+	}
+	walk(ve)
+	return strings.Join(msgs, "; ")
+}
+
+// responseBuffer holds back a response until it is validated. Headers are set on the wrapped
+// writer directly.
+type responseBuffer struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+func (b *responseBuffer) WriteHeader(status int) {
+	if !b.wroteHeader {
+		b.status = status
+		b.wroteHeader = true
+	}
+}
+
+func (b *responseBuffer) Write(p []byte) (int, error) {
+	b.wroteHeader = true
+	return b.body.Write(p)
+}
@@ -0,0 +1,254 @@
+package openapi
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-manager/internal/helpers"
+	"testing"
+)
+
+func TestValidator_Requests(t *testing.T) {
+	v, err := NewValidator(false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		header         map[string]string
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "valid body",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "priority": "high", "due_date": "2030-01-02T15:04:05Z"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "priority in capitals",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "priority": "HIGH", "due_date": "2030-01-02T15:04:05Z"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown priority",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "priority": "urgent"}`,
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "creation time sent by older clients",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "created-at": "2030-01-02T15:04:05Z"}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "priority by number",
+			method:         http.MethodPatch,
+			target:         "/tasks/1",
+			body:           `{"name": "Task", "priority": 1}`,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "unknown field",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "owner": 2}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "additional properties 'owner' not allowed",
+		},
+		{
+			name:           "wrong type",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": 12}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "/name: got number, want string",
+		},
+		{
+			name:           "invalid format",
+			method:         http.MethodPost,
+			target:         "/register",
+			body:           `{"name": "Lorem", "email": "not-an-email", "password": "password"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "/email: 'not-an-email' is not valid email",
+		},
+		{
+			name:           "invalid date",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "Task", "due_date": "tomorrow"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "/due_date: 'tomorrow' is not valid date-time",
+		},
+		{
+			name:           "missing required field",
+			method:         http.MethodPost,
+			target:         "/login",
+			body:           `{"email": "lorem@example.com"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "missing property 'password'",
+		},
+		{
+			name:           "missing body",
+			method:         http.MethodPost,
+			target:         "/login",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "request: missing body",
+		},
+		{
+			name:           "malformed body",
+			method:         http.MethodPost,
+			target:         "/login",
+			body:           `{"email": `,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "body is not valid JSON",
+		},
+		{
+			name:           "body too large",
+			method:         http.MethodPost,
+			target:         "/tasks",
+			body:           `{"name": "` + strings.Repeat("a", 1024) + `"}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "invalid path parameter",
+			method:         http.MethodGet,
+			target:         "/tasks/abc",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid path parameter task_id",
+		},
+		{
+			name:           "path parameter out of bounds",
+			method:         http.MethodDelete,
+			target:         "/tasks/0",
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "/: minimum: got 0, want 1",
+		},
+		{
+			name:           "invalid query parameter",
+			method:         http.MethodPost,
+			target:         "/login?session=jar",
+			body:           `{"email": "lorem@example.com", "password": "password"}`,
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid query parameter session",
+		},
+		{
+			name:           "invalid header parameter",
+			method:         http.MethodGet,
+			target:         "/events",
+			header:         map[string]string{"Last-Event-ID": "-1"},
+			expectedStatus: http.StatusBadRequest,
+			expectedBody:   "invalid header parameter Last-Event-ID",
+		},
+		{
+			name:           "undocumented routes are left to the router",
+			method:         http.MethodPost,
+			target:         "/unknown",
+			body:           `not json`,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := v.Middleware(512)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				// handlers get to read the body after it was validated
+				b, err := io.ReadAll(r.Body)
+				if err != nil || string(b) != tt.body {
+					t.Errorf("expected the body %q but got %q, %v", tt.body, b, err)
+				}
+				helpers.JsonResponse(w, http.StatusOK, "ok")
+			}))
+			r := httptest.NewRequest(tt.method, tt.target, strings.NewReader(tt.body))
+			for k, val := range tt.header {
+				r.Header.Set(k, val)
+			}
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, r)
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d but got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("expected the body to contain %q but got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestValidator_Responses(t *testing.T) {
+	v, err := NewValidator(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name           string
+		target         string
+		status         int
+		payload        any
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:           "valid response",
+			target:         "/tasks/1",
+			status:         http.StatusOK,
+			payload:        map[string]any{"id": 1, "name": "Task", "priority": 2, "due_date": "2030-01-02T15:04:05Z", "created_at": nil, "created_by": 1},
+			expectedStatus: http.StatusOK,
+			expectedBody:   `"name":"Task"`,
+		},
+		{
+			name:           "documented error",
+			target:         "/tasks/1",
+			status:         http.StatusUnauthorized,
+			payload:        "invalid token",
+			expectedStatus: http.StatusUnauthorized,
+			expectedBody:   "invalid token",
+		},
+		{
+			name:           "undocumented field",
+			target:         "/tasks/1",
+			status:         http.StatusOK,
+			payload:        map[string]any{"id": 1, "name": "Task", "priority": 2, "due_date": nil, "created_at": nil, "created_by": 1, "owner": "Lorem"},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "additional properties 'owner' not allowed",
+		},
+		{
+			name:           "undocumented status",
+			target:         "/tasks/1",
+			status:         http.StatusTeapot,
+			payload:        "short and stout",
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "status 418 is not documented",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := v.Middleware(512)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				helpers.JsonResponse(w, tt.status, tt.payload)
+			}))
+			w := httptest.NewRecorder()
+			h.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.target, nil))
+
+			if w.Code != tt.expectedStatus {
+				t.Errorf("expected status %d but got %d: %s", tt.expectedStatus, w.Code, w.Body.String())
+			}
+			if !strings.Contains(w.Body.String(), tt.expectedBody) {
+				t.Errorf("expected the body to contain %q but got %s", tt.expectedBody, w.Body.String())
+			}
+		})
+	}
+}
@@ -0,0 +1,69 @@
+package password
+
+import (
+	"errors"
+	"strings"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// DefaultBcryptCost is used when no cost is configured.
+const DefaultBcryptCost = 12
+
+// maxBcryptLength is the number of bytes bcrypt takes into account.
+const maxBcryptLength = 72
+
+type bcryptHasher struct {
+	cost int
+}
+
+// NewBcrypt returns a hasher using bcrypt with the given cost.
+func NewBcrypt(cost int) Hasher {
+	return &bcryptHasher{cost: cost}
+}
+
+func (h bcryptHasher) Hash(password string) (string, error) {
+	if len(password) > maxBcryptLength {
+		return "", ErrTooLong
+	}
+	b, err := bcrypt.GenerateFromPassword([]byte(password), h.cost)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+func (h bcryptHasher) Verify(password, hash string) (bool, error) {
+	if !isBcrypt(hash) {
+		return false, ErrUnknownHash
+	}
+	// bcrypt ignores everything after 72 bytes, such a password was never hashed by us
+	if len(password) > maxBcryptLength {
+		return false, nil
+	}
+
+	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
+	if errors.Is(err, bcrypt.ErrMismatchedHashAndPassword) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+func (h bcryptHasher) NeedsRehash(hash string) bool {
+	cost, err := bcrypt.Cost([]byte(hash))
+	return err != nil || cost != h.cost
+}
+
+func isBcrypt(hash string) bool {
+	for _, prefix := range []string{"$2a$", "$2b$", "$2y$"} {
+		if strings.HasPrefix(hash, prefix) {
+			return true
+		}
+	}
+	return false
+}
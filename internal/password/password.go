@@ -0,0 +1,90 @@
+// Package password hashes and verifies user passwords. New hashes use the configured
+// algorithm; stored hashes are verified with whichever algorithm produced them, so existing
+// accounts keep working and can be upgraded on their next login.
+package password
+
+import (
+	"errors"
+	"strings"
+	"task-manager/internal/config"
+)
+
+var (
+	// ErrUnknownHash is returned when a stored hash was not produced by a supported algorithm.
+	ErrUnknownHash = errors.New("password: unknown hash format")
+	// ErrTooLong is returned by bcrypt for passwords it would otherwise truncate.
+	ErrTooLong = errors.New("password: longer than 72 bytes")
+)
+
+// Hasher hashes passwords and checks them against stored hashes.
+type Hasher interface {
+	Hash(password string) (string, error)
+	// Verify reports whether password matches hash. A mismatch is not an error.
+	Verify(password, hash string) (bool, error)
+	// NeedsRehash reports whether hash was not produced by this hasher with its current
+	// parameters and should be replaced once the plain password is known.
+	NeedsRehash(hash string) bool
+}
+
+// New returns a hasher producing hashes with the algorithm of cfg and verifying hashes of
+// every supported algorithm. Zero parameters fall back to the defaults.
+func New(cfg config.PasswordConfig) Hasher {
+	p := DefaultArgon2idParams
+	if cfg.Argon2Memory > 0 {
+		p.Memory = uint32(cfg.Argon2Memory)
+	}
+	if cfg.Argon2Iterations > 0 {
+		p.Iterations = uint32(cfg.Argon2Iterations)
+	}
+	if cfg.Argon2Parallelism > 0 {
+		p.Parallelism = uint8(cfg.Argon2Parallelism)
+	}
+	cost := DefaultBcryptCost
+	if cfg.BcryptCost > 0 {
+		cost = cfg.BcryptCost
+	}
+
+	h := &hasher{
+		argon2id: NewArgon2id(p),
+		bcrypt:   NewBcrypt(cost),
+	}
+	h.preferred = h.argon2id
+	if cfg.Algorithm == config.PasswordAlgorithmBcrypt {
+		h.preferred = h.bcrypt
+	}
+
+	return h
+}
+
+type hasher struct {
+	preferred Hasher
+	argon2id  Hasher
+	bcrypt    Hasher
+}
+
+func (h hasher) Hash(password string) (string, error) {
+	return h.preferred.Hash(password)
+}
+
+func (h hasher) Verify(password, hash string) (bool, error) {
+	alg := h.algorithm(hash)
+	if alg == nil {
+		return false, ErrUnknownHash
+	}
+	return alg.Verify(password, hash)
+}
+
+func (h hasher) NeedsRehash(hash string) bool {
+	return h.algorithm(hash) != h.preferred || h.preferred.NeedsRehash(hash)
+}
+
+func (h hasher) algorithm(hash string) Hasher {
+	switch {
+	case strings.HasPrefix(hash, argon2idPrefix):
+		return h.argon2id
+	case isBcrypt(hash):
+		return h.bcrypt
+	default:
+		return nil
+	}
+}
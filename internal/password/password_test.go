@@ -0,0 +1,138 @@
+package password
+
+import (
+	"errors"
+	"strings"
+	"task-manager/internal/config"
+	"testing"
+
+	"golang.org/x/crypto/bcrypt"
+)
+
+// cheap parameters keep the tests fast, the encoding does not depend on them
+var testArgon2idParams = Argon2idParams{Memory: 64, Iterations: 1, Parallelism: 1, SaltLength: 16, KeyLength: 32}
+
+func TestHasher_Hash(t *testing.T) {
+	var tests = []struct {
+		name         string
+		h            Hasher
+		password     string
+		prefix       string
+		expectsError bool
+	}{
+		{"argon2id", NewArgon2id(testArgon2idParams), "loremipsum", "$argon2id$v=19$m=64,t=1,p=1$", false},
+		{"argon2id empty string", NewArgon2id(testArgon2idParams), "", "$argon2id$", false},
+		{"argon2id long password", NewArgon2id(testArgon2idParams), strings.Repeat("a", 100), "$argon2id$", false},
+		{"bcrypt", NewBcrypt(bcrypt.MinCost), "loremipsum", "$2a$04$", false},
+		{"bcrypt empty string", NewBcrypt(bcrypt.MinCost), "", "$2a$04$", false},
+		{"bcrypt long password", NewBcrypt(bcrypt.MinCost), strings.Repeat("a", 73), "", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			hash, err := tc.h.Hash(tc.password)
+			if tc.expectsError {
+				if !errors.Is(err, ErrTooLong) {
+					t.Errorf("expected ErrTooLong but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error while hashing password: %s", err)
+			}
+			if !strings.HasPrefix(hash, tc.prefix) {
+				t.Errorf("expected hash to start with %q but got %q", tc.prefix, hash)
+			}
+
+			ok, err := tc.h.Verify(tc.password, hash)
+			if err != nil || !ok {
+				t.Errorf("password should match its own hash, got %t (%v)", ok, err)
+			}
+			if tc.h.NeedsRehash(hash) {
+				t.Errorf("fresh hash should not need a rehash")
+			}
+
+			other, _ := tc.h.Hash(tc.password)
+			if other == hash {
+				t.Errorf("hashes of the same password should be salted")
+			}
+		})
+	}
+}
+
+func TestHasher_Verify(t *testing.T) {
+	h := New(config.PasswordConfig{Argon2Memory: 64, Argon2Iterations: 1})
+	argon2Hash, _ := NewArgon2id(testArgon2idParams).Hash("loremipsum")
+	bcryptHash, _ := NewBcrypt(bcrypt.MinCost).Hash("loremipsum")
+	emptyHash, _ := NewArgon2id(testArgon2idParams).Hash("")
+
+	var tests = []struct {
+		name           string
+		password       string
+		hash           string
+		expectedResult bool
+		expectedError  error
+	}{
+		{"argon2id match", "loremipsum", argon2Hash, true, nil},
+		{"argon2id mismatch", "doloret", argon2Hash, false, nil},
+		{"bcrypt match", "loremipsum", bcryptHash, true, nil},
+		{"bcrypt mismatch", "doloret", bcryptHash, false, nil},
+		{"bcrypt too long password", "loremipsum" + strings.Repeat("a", 72), bcryptHash, false, nil},
+		{"empty password, empty hash", "", emptyHash, true, nil},
+		{"not empty password, empty hash", "loremipsum", emptyHash, false, nil},
+		{"hash given as password", argon2Hash, argon2Hash, false, nil},
+		{"truncated argon2id hash", "loremipsum", argon2Hash[:20], false, ErrUnknownHash},
+		{"argon2id with other version", "loremipsum", strings.Replace(argon2Hash, "v=19", "v=16", 1), false, ErrUnknownHash},
+		{"argon2i hash", "loremipsum", strings.Replace(argon2Hash, "argon2id", "argon2i", 1), false, ErrUnknownHash},
+		{"completely wrong hash", "loremipsum", "totalywronghash", false, ErrUnknownHash},
+		{"empty hash", "loremipsum", "", false, ErrUnknownHash},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := h.Verify(tc.password, tc.hash)
+			if tc.expectedError != nil && !errors.Is(err, tc.expectedError) {
+				t.Errorf("expected %v but got %v", tc.expectedError, err)
+			}
+			if tc.expectedError == nil && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if ok != tc.expectedResult {
+				t.Errorf("expected result %t but got %t", tc.expectedResult, ok)
+			}
+		})
+	}
+
+	if _, err := h.Verify("loremipsum", "$2a$06$invalidhashformat"); err == nil {
+		t.Errorf("malformed bcrypt hash should return an error")
+	}
+}
+
+func TestHasher_NeedsRehash(t *testing.T) {
+	argon2Hash, _ := NewArgon2id(testArgon2idParams).Hash("loremipsum")
+	bcryptHash, _ := NewBcrypt(bcrypt.MinCost).Hash("loremipsum")
+
+	var tests = []struct {
+		name     string
+		cfg      config.PasswordConfig
+		hash     string
+		expected bool
+	}{
+		{"argon2id with same parameters", config.PasswordConfig{Algorithm: config.PasswordAlgorithmArgon2id, Argon2Memory: 64, Argon2Iterations: 1}, argon2Hash, false},
+		{"argon2id with more memory", config.PasswordConfig{Algorithm: config.PasswordAlgorithmArgon2id, Argon2Memory: 128, Argon2Iterations: 1}, argon2Hash, true},
+		{"argon2id with more iterations", config.PasswordConfig{Algorithm: config.PasswordAlgorithmArgon2id, Argon2Memory: 64, Argon2Iterations: 2}, argon2Hash, true},
+		{"bcrypt upgraded to argon2id", config.PasswordConfig{Algorithm: config.PasswordAlgorithmArgon2id}, bcryptHash, true},
+		{"bcrypt with same cost", config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, bcryptHash, false},
+		{"bcrypt with higher cost", config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 10}, bcryptHash, true},
+		{"argon2id when bcrypt is configured", config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: bcrypt.MinCost}, argon2Hash, true},
+		{"unknown hash", config.PasswordConfig{}, "totalywronghash", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if r := New(tc.cfg).NeedsRehash(tc.hash); r != tc.expected {
+				t.Errorf("expected %t but got %t", tc.expected, r)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// LockoutStore counts consecutive failed logins per account. RecordFailure starts counting
+// again when the previous failure is older than ttl, so counters decay and can be dropped.
+type LockoutStore interface {
+	Status(ctx context.Context, key string) (failures int, lockedUntil time.Time, err error)
+	RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (failures int, err error)
+	Lock(ctx context.Context, key string, until time.Time) error
+	Reset(ctx context.Context, key string) error
+}
+
+// Lockout locks an account once it reaches threshold consecutive failed logins. The lock starts
+// at base and doubles with every further failure, up to max. Failures are forgotten once the
+// account has gone twice max without one, which outlasts the longest lock.
+type Lockout struct {
+	store     LockoutStore
+	threshold int
+	base      time.Duration
+	max       time.Duration
+	ttl       time.Duration
+}
+
+func NewLockout(s LockoutStore, threshold int, base, max time.Duration) *Lockout {
+	return &Lockout{store: s, threshold: threshold, base: base, max: max, ttl: 2 * max}
+}
+
+// Check returns how long the account stays locked, zero when logins are allowed.
+func (l *Lockout) Check(ctx context.Context, account string, now time.Time) (time.Duration, error) {
+	_, until, err := l.store.Status(ctx, lockoutKey(account))
+	if err != nil {
+		return 0, fmt.Errorf("lockout: failed to read status: %v", err)
+	}
+	if until.After(now) {
+		return until.Sub(now), nil
+	}
+	return 0, nil
+}
+
+func (l *Lockout) Failure(ctx context.Context, account string, now time.Time) error {
+	key := lockoutKey(account)
+	n, err := l.store.RecordFailure(ctx, key, now, l.ttl)
+	if err != nil {
+		return fmt.Errorf("lockout: failed to record failure: %v", err)
+	}
+	if n < l.threshold {
+		return nil
+	}
+
+	d := l.base
+	for i := l.threshold; i < n && d < l.max; i++ {
+		d *= 2
+	}
+	if d > l.max {
+		d = l.max
+	}
+
+	if err := l.store.Lock(ctx, key, now.Add(d)); err != nil {
+		return fmt.Errorf("lockout: failed to lock account: %v", err)
+	}
+	return nil
+}
+
+func (l *Lockout) Success(ctx context.Context, account string) error {
+	if err := l.store.Reset(ctx, lockoutKey(account)); err != nil {
+		return fmt.Errorf("lockout: failed to reset failures: %v", err)
+	}
+	return nil
+}
+
+// lockoutPruner is implemented by the stores that don't sweep stale counters themselves.
+type lockoutPruner interface {
+	Prune(ctx context.Context, before, now time.Time) (int64, error)
+}
+
+// Prune deletes the counters that are forgotten anyway, for stores that keep them until told
+// otherwise. It returns how many were deleted.
+func (l *Lockout) Prune(ctx context.Context, now time.Time) (int64, error) {
+	p, ok := l.store.(lockoutPruner)
+	if !ok {
+		return 0, nil
+	}
+	n, err := p.Prune(ctx, now.Add(-l.ttl), now)
+	if err != nil {
+		return 0, fmt.Errorf("lockout: failed to prune counters: %v", err)
+	}
+	return n, nil
+}
+
+func lockoutKey(account string) string {
+	return strings.ToLower(strings.TrimSpace(account))
+}
+
+type memoryLockoutEntry struct {
+	failures    int
+	lockedUntil time.Time
+	updated     time.Time
+	ttl         time.Duration
+}
+
+// MemoryLockoutStore keeps failed login counters in process memory. Counters untouched for
+// longer than their ttl are swept, so unknown accounts don't pile up.
+type MemoryLockoutStore struct {
+	mu        sync.Mutex
+	entries   map[string]memoryLockoutEntry
+	lastSweep time.Time
+}
+
+func NewMemoryLockoutStore() *MemoryLockoutStore {
+	return &MemoryLockoutStore{entries: make(map[string]memoryLockoutEntry)}
+}
+
+func (s *MemoryLockoutStore) Status(_ context.Context, key string) (int, time.Time, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	return e.failures, e.lockedUntil, nil
+}
+
+func (s *MemoryLockoutStore) RecordFailure(_ context.Context, key string, now time.Time, ttl time.Duration) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.sweep(now)
+
+	e := s.entries[key]
+	if now.Sub(e.updated) > ttl {
+		e.failures = 0
+	}
+	e.failures++
+	e.updated = now
+	e.ttl = ttl
+	s.entries[key] = e
+	return e.failures, nil
+}
+
+func (s *MemoryLockoutStore) Lock(_ context.Context, key string, until time.Time) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e := s.entries[key]
+	e.lockedUntil = until
+	s.entries[key] = e
+	return nil
+}
+
+func (s *MemoryLockoutStore) Reset(_ context.Context, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.entries, key)
+	return nil
+}
+
+// sweep drops counters whose last failure is older than their ttl, whose lock ran out as well.
+func (s *MemoryLockoutStore) sweep(now time.Time) {
+	if now.Sub(s.lastSweep) < sweepInterval {
+		return
+	}
+	s.lastSweep = now
+	for k, e := range s.entries {
+		if now.Sub(e.updated) > e.ttl && !e.lockedUntil.After(now) {
+			delete(s.entries, k)
+		}
+	}
+}
@@ -0,0 +1,79 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"time"
+)
+
+type Limiter struct {
+	store Store
+	now   func() time.Time
+}
+
+func NewLimiter(s Store) *Limiter {
+	return &Limiter{store: s, now: time.Now}
+}
+
+// Limit applies p to every request going through the returned middleware. Buckets are keyed by
+// user ID when Authenticate ran before it, by client IP otherwise, and are separate per name.
+// When the store fails the request is let through, so a storage outage doesn't take the API down.
+func (l *Limiter) Limit(name string, p Policy) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			res, err := l.Take(r.Context(), name, subject(r), p)
+			if err != nil {
+				logging.FromContext(r.Context()).Error("rate limit store failed", "err", err, "policy", name)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Set("RateLimit-Policy", p.String())
+			h.Set("RateLimit-Limit", strconv.Itoa(res.Limit))
+			h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+			h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+
+			if !res.Allowed {
+				h.Set("Retry-After", strconv.Itoa(ceilSeconds(res.RetryAfter)))
+				helpers.JsonResponse(w, http.StatusTooManyRequests, fmt.Sprintf("too many requests, retry later"))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// Take takes a token from the bucket of subject under the given name, for callers other than
+// the HTTP middleware that share its buckets.
+func (l *Limiter) Take(ctx context.Context, name, subject string, p Policy) (Result, error) {
+	return l.store.Take(ctx, name+":"+subject, p, l.now())
+}
+
+// IPSubject returns the subject anonymous callers at the "host:port" address addr are limited
+// by, the one Limit uses for requests without a user.
+func IPSubject(addr string) string {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		host = addr
+	}
+	return "ip:" + host
+}
+
+func subject(r *http.Request) string {
+	if uID, ok := r.Context().Value(contextkeys.UserID).(int64); ok {
+		return "user:" + strconv.FormatInt(uID, 10)
+	}
+	return IPSubject(r.RemoteAddr)
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int((d + time.Second - 1) / time.Second)
+}
@@ -0,0 +1,170 @@
+package ratelimit
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-manager/internal/db"
+	"time"
+)
+
+// PostgresStore keeps buckets in the rate_limits table so every instance shares the same limits.
+type PostgresStore struct {
+	d db.DB
+}
+
+func NewPostgresStore(d db.DB) *PostgresStore {
+	return &PostgresStore{d: d}
+}
+
+func (s *PostgresStore) Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error) {
+	iq, err := db.GetQuery("queries/ratelimit/InsertBucket.sql")
+	if err != nil {
+		return Result{}, fmt.Errorf("take: failed to read query: %v", err)
+	}
+	gq, err := db.GetQuery("queries/ratelimit/GetBucket.sql")
+	if err != nil {
+		return Result{}, fmt.Errorf("take: failed to read query: %v", err)
+	}
+	uq, err := db.GetQuery("queries/ratelimit/UpdateBucket.sql")
+	if err != nil {
+		return Result{}, fmt.Errorf("take: failed to read query: %v", err)
+	}
+
+	tx, err := s.d.BeginTx(ctx, nil)
+	if err != nil {
+		return Result{}, fmt.Errorf("take: failed to begin tx: %v", err)
+	}
+	defer func() {
+		_ = tx.Rollback()
+	}()
+
+	fresh := newBucket(p, now)
+	if _, err := tx.ExecContext(ctx, iq, key, fresh.tokens, fresh.updated); err != nil {
+		return Result{}, fmt.Errorf("take: failed to create bucket: %v", err)
+	}
+
+	var b bucket
+	if err := tx.QueryRowContext(ctx, gq, key).Scan(&b.tokens, &b.updated); err != nil {
+		return Result{}, fmt.Errorf("take: failed to read bucket: %v", err)
+	}
+
+	b, res := take(b, p, now)
+	if _, err := tx.ExecContext(ctx, uq, key, b.tokens, b.updated); err != nil {
+		return Result{}, fmt.Errorf("take: failed to update bucket: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return Result{}, fmt.Errorf("take: failed to commit tx: %v", err)
+	}
+
+	return res, nil
+}
+
+// Prune deletes the buckets untouched since before. A bucket idle for longer than the window
+// of its policy is full again, so pruning them changes no limit.
+func (s *PostgresStore) Prune(ctx context.Context, before time.Time) (int64, error) {
+	q, err := db.GetQuery("queries/ratelimit/DeleteStaleBuckets.sql")
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to read query: %v", err)
+	}
+
+	res, err := s.d.ExecContext(ctx, q, before)
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to count deleted buckets: %v", err)
+	}
+
+	return n, nil
+}
+
+// PostgresLockoutStore keeps failed login counters in the login_failures table.
+type PostgresLockoutStore struct {
+	d db.DB
+}
+
+func NewPostgresLockoutStore(d db.DB) *PostgresLockoutStore {
+	return &PostgresLockoutStore{d: d}
+}
+
+func (s *PostgresLockoutStore) Status(ctx context.Context, key string) (int, time.Time, error) {
+	q, err := db.GetQuery("queries/ratelimit/GetLoginFailures.sql")
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("status: failed to read query: %v", err)
+	}
+
+	var n int
+	var until sql.NullTime
+	err = s.d.QueryRowContext(ctx, q, key).Scan(&n, &until)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, time.Time{}, nil
+	}
+	if err != nil {
+		return 0, time.Time{}, fmt.Errorf("status: failed to execute query: %v", err)
+	}
+
+	return n, until.Time, nil
+}
+
+func (s *PostgresLockoutStore) RecordFailure(ctx context.Context, key string, now time.Time, ttl time.Duration) (int, error) {
+	q, err := db.GetQuery("queries/ratelimit/RecordLoginFailure.sql")
+	if err != nil {
+		return 0, fmt.Errorf("recordFailure: failed to read query: %v", err)
+	}
+
+	var n int
+	if err := s.d.QueryRowContext(ctx, q, key, now, now.Add(-ttl)).Scan(&n); err != nil {
+		return 0, fmt.Errorf("recordFailure: failed to execute query: %v", err)
+	}
+
+	return n, nil
+}
+
+func (s *PostgresLockoutStore) Lock(ctx context.Context, key string, until time.Time) error {
+	q, err := db.GetQuery("queries/ratelimit/LockLogin.sql")
+	if err != nil {
+		return fmt.Errorf("lock: failed to read query: %v", err)
+	}
+
+	if _, err := s.d.ExecContext(ctx, q, key, until); err != nil {
+		return fmt.Errorf("lock: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+
+func (s *PostgresLockoutStore) Reset(ctx context.Context, key string) error {
+	q, err := db.GetQuery("queries/ratelimit/ResetLoginFailures.sql")
+	if err != nil {
+		return fmt.Errorf("reset: failed to read query: %v", err)
+	}
+
+	if _, err := s.d.ExecContext(ctx, q, key); err != nil {
+		return fmt.Errorf("reset: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+
+// Prune deletes the counters whose last failure is older than before and whose lock ran out.
+func (s *PostgresLockoutStore) Prune(ctx context.Context, before, now time.Time) (int64, error) {
+	q, err := db.GetQuery("queries/ratelimit/DeleteStaleLoginFailures.sql")
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to read query: %v", err)
+	}
+
+	res, err := s.d.ExecContext(ctx, q, before, now)
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("prune: failed to count deleted counters: %v", err)
+	}
+
+	return n, nil
+}
@@ -0,0 +1,93 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Policy describes a token bucket holding up to Limit tokens and refilled at Limit tokens per Window.
+type Policy struct {
+	Limit  int
+	Window time.Duration
+}
+
+// ParsePolicy reads a policy written as "<limit>/<window>", e.g. "10/1m".
+func ParsePolicy(s string) (Policy, error) {
+	l, w, ok := strings.Cut(strings.TrimSpace(s), "/")
+	if !ok {
+		return Policy{}, fmt.Errorf("invalid rate limit policy %q, expected <limit>/<window>", s)
+	}
+	limit, err := strconv.Atoi(l)
+	if err != nil || limit < 1 {
+		return Policy{}, fmt.Errorf("invalid rate limit %q in policy %q", l, s)
+	}
+	window, err := time.ParseDuration(w)
+	if err != nil || window <= 0 {
+		return Policy{}, fmt.Errorf("invalid rate limit window %q in policy %q", w, s)
+	}
+	return Policy{Limit: limit, Window: window}, nil
+}
+
+func (p Policy) String() string {
+	return fmt.Sprintf("%d;w=%d", p.Limit, int(p.Window.Seconds()))
+}
+
+func (p Policy) ratePerSecond() float64 {
+	return float64(p.Limit) / p.Window.Seconds()
+}
+
+type Result struct {
+	Allowed   bool
+	Limit     int
+	Remaining int
+	// Reset is the time until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is the time until the next token is available, zero when Allowed.
+	RetryAfter time.Duration
+}
+
+// Store keeps token buckets. Take removes one token from the bucket under key if one is available.
+type Store interface {
+	Take(ctx context.Context, key string, p Policy, now time.Time) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+}
+
+// take refills b up to now and tries to consume a token. It is shared by every Store so the
+// buckets behave the same whatever backend holds them.
+func take(b bucket, p Policy, now time.Time) (bucket, Result) {
+	rate := p.ratePerSecond()
+	elapsed := now.Sub(b.updated).Seconds()
+	if elapsed < 0 {
+		elapsed = 0
+	}
+	b.tokens = math.Min(float64(p.Limit), b.tokens+elapsed*rate)
+	b.updated = now
+
+	res := Result{Limit: p.Limit}
+	if b.tokens >= 1 {
+		b.tokens--
+		res.Allowed = true
+	} else {
+		res.RetryAfter = secondsToDuration((1 - b.tokens) / rate)
+	}
+	res.Remaining = int(math.Floor(b.tokens))
+	res.Reset = secondsToDuration((float64(p.Limit) - b.tokens) / rate)
+
+	return b, res
+}
+
+func newBucket(p Policy, now time.Time) bucket {
+	return bucket{tokens: float64(p.Limit), updated: now}
+}
+
+func secondsToDuration(s float64) time.Duration {
+	return time.Duration(math.Ceil(s * float64(time.Second)))
+}
@@ -0,0 +1,197 @@
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/internal/contextkeys"
+	"testing"
+	"time"
+)
+
+func TestParsePolicy(t *testing.T) {
+	var tests = []struct {
+		name         string
+		input        string
+		expected     Policy
+		expectsError bool
+	}{
+		{"per minute", "10/1m", Policy{Limit: 10, Window: time.Minute}, false},
+		{"per second with spaces", " 5/1s ", Policy{Limit: 5, Window: time.Second}, false},
+		{"missing window", "10", Policy{}, true},
+		{"zero limit", "0/1m", Policy{}, true},
+		{"invalid window", "10/minute", Policy{}, true},
+		{"negative window", "10/-1m", Policy{}, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p, err := ParsePolicy(tc.input)
+			if tc.expectsError {
+				if err == nil {
+					t.Errorf("function should return an error but it did not")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if p != tc.expected {
+				t.Errorf("expected %+v but got %+v", tc.expected, p)
+			}
+		})
+	}
+}
+
+func TestMemoryStore_Take(t *testing.T) {
+	s := NewMemoryStore()
+	p := Policy{Limit: 3, Window: 3 * time.Second}
+	now := time.Now()
+	ctx := context.Background()
+
+	for i := 0; i < 3; i++ {
+		res, _ := s.Take(ctx, "key", p, now)
+		if !res.Allowed {
+			t.Fatalf("request %d should be allowed", i+1)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("expected %d remaining but got %d", 2-i, res.Remaining)
+		}
+	}
+
+	res, _ := s.Take(ctx, "key", p, now)
+	if res.Allowed {
+		t.Errorf("bucket should be empty")
+	}
+	if res.RetryAfter != time.Second {
+		t.Errorf("expected retry after 1s but got %s", res.RetryAfter)
+	}
+
+	res, _ = s.Take(ctx, "other", p, now)
+	if !res.Allowed {
+		t.Errorf("buckets should be separate per key")
+	}
+
+	res, _ = s.Take(ctx, "key", p, now.Add(time.Second))
+	if !res.Allowed {
+		t.Errorf("bucket should be refilled after a second")
+	}
+}
+
+func TestLimiter_Limit(t *testing.T) {
+	l := NewLimiter(NewMemoryStore())
+	h := l.Limit("auth", Policy{Limit: 2, Window: time.Minute})(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr string, uID int64) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodPost, "/login", nil)
+		req.RemoteAddr = remoteAddr
+		if uID != 0 {
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.UserID, uID))
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	var tests = []struct {
+		name              string
+		remoteAddr        string
+		uID               int64
+		expectedStatus    int
+		expectedRemaining string
+	}{
+		{"first request", "10.0.0.1:1234", 0, http.StatusOK, "1"},
+		{"second request from another port", "10.0.0.1:4321", 0, http.StatusOK, "0"},
+		{"third request is limited", "10.0.0.1:1234", 0, http.StatusTooManyRequests, "0"},
+		{"other ip has its own bucket", "10.0.0.2:1234", 0, http.StatusOK, "1"},
+		{"authenticated user keyed by id", "10.0.0.1:1234", 7, http.StatusOK, "1"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			rec := send(tc.remoteAddr, tc.uID)
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d but got %d", tc.expectedStatus, rec.Code)
+			}
+			if rec.Header().Get("RateLimit-Limit") != "2" {
+				t.Errorf("expected RateLimit-Limit 2 but got %q", rec.Header().Get("RateLimit-Limit"))
+			}
+			if rec.Header().Get("RateLimit-Remaining") != tc.expectedRemaining {
+				t.Errorf("expected RateLimit-Remaining %s but got %q", tc.expectedRemaining, rec.Header().Get("RateLimit-Remaining"))
+			}
+			if rec.Header().Get("RateLimit-Policy") != "2;w=60" {
+				t.Errorf("unexpected RateLimit-Policy %q", rec.Header().Get("RateLimit-Policy"))
+			}
+			if tc.expectedStatus == http.StatusTooManyRequests && rec.Header().Get("Retry-After") == "" {
+				t.Errorf("limited response should carry Retry-After")
+			}
+		})
+	}
+}
+
+func TestLockout(t *testing.T) {
+	l := NewLockout(NewMemoryLockoutStore(), 3, time.Minute, 3*time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		_ = l.Failure(ctx, "lorem@ipsum.com", now)
+	}
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now); wait != 0 {
+		t.Errorf("account should not be locked below threshold")
+	}
+
+	_ = l.Failure(ctx, "Lorem@Ipsum.com", now)
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now); wait != time.Minute {
+		t.Errorf("expected 1m lock at threshold but got %s", wait)
+	}
+
+	_ = l.Failure(ctx, "lorem@ipsum.com", now)
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now); wait != 2*time.Minute {
+		t.Errorf("expected lock to double to 2m but got %s", wait)
+	}
+
+	_ = l.Failure(ctx, "lorem@ipsum.com", now)
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now); wait != 3*time.Minute {
+		t.Errorf("expected lock to be capped at 3m but got %s", wait)
+	}
+
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now.Add(4*time.Minute)); wait != 0 {
+		t.Errorf("lock should expire")
+	}
+
+	_ = l.Success(ctx, "lorem@ipsum.com")
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", now); wait != 0 {
+		t.Errorf("successful login should reset the lockout")
+	}
+}
+
+func TestLockout_Decay(t *testing.T) {
+	s := NewMemoryLockoutStore()
+	l := NewLockout(s, 3, time.Minute, 3*time.Minute)
+	ctx := context.Background()
+	now := time.Now()
+
+	for i := 0; i < 2; i++ {
+		_ = l.Failure(ctx, "lorem@ipsum.com", now)
+	}
+	// failures spread out over more than the ttl don't add up to a lock
+	later := now.Add(7 * time.Minute)
+	_ = l.Failure(ctx, "lorem@ipsum.com", later)
+	if wait, _ := l.Check(ctx, "lorem@ipsum.com", later); wait != 0 {
+		t.Errorf("old failures should be forgotten but the account is locked for %s", wait)
+	}
+
+	for i := 0; i < 100; i++ {
+		_ = l.Failure(ctx, fmt.Sprintf("unknown-%d@ipsum.com", i), now)
+	}
+	_ = l.Failure(ctx, "ipsum@lorem.com", later.Add(7*time.Minute))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if len(s.entries) != 1 {
+		t.Errorf("expected stale counters to be swept but %d are left", len(s.entries))
+	}
+}
@@ -0,0 +1,13 @@
+package repository_test
+
+import (
+	"task-manager/internal/repository"
+	"task-manager/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestConformance_postgres(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repositories {
+		return repository.New(repository.PostgresDB())
+	})
+}
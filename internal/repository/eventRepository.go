@@ -0,0 +1,98 @@
+package repository
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/db"
+	"task-manager/internal/models"
+	"time"
+)
+
+// EventRepository reads the changes to tasks, which the storage records as they are made.
+type EventRepository interface {
+	// After returns at most limit events of the user following the event with the given id.
+	// Events are returned in an order no event committed later can go before, which need not
+	// be the order of their ids; those that a transaction still running could yet precede are
+	// held back, and pending reports there are some.
+	After(ctx context.Context, uID, id int64, limit int) (events []models.TaskEvent, pending bool, err error)
+	// LastID returns the id of the latest event of any user that After would return, 0
+	// without events.
+	LastID(ctx context.Context) (int64, error)
+	DeleteBefore(ctx context.Context, t time.Time) (int64, error)
+}
+
+type eventRepository struct {
+	d db.DB
+}
+
+func NewEventRepository(d db.DB) EventRepository {
+	return &eventRepository{d: d}
+}
+
+// After reads from the primary: streams call it as soon as a change is announced, which a
+// replica may not have received yet.
+func (r eventRepository) After(ctx context.Context, uID, id int64, limit int) ([]models.TaskEvent, bool, error) {
+	q, err := r.d.GetQuery("queries/event/GetEventsAfter.sql")
+	if err != nil {
+		return nil, false, fmt.Errorf("after: failed to read query: %v", err)
+	}
+
+	rows, err := r.d.QueryContext(ctx, q, uID, id, limit)
+	if err != nil {
+		return nil, false, fmt.Errorf("after: failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	var events []models.TaskEvent
+	var pending bool
+	for rows.Next() {
+		var e models.TaskEvent
+		var settled bool
+		if err := rows.Scan(&e.ID, &e.Type, &e.TaskID, &e.UserID, &e.CreatedAt, &settled); err != nil {
+			return nil, false, fmt.Errorf("after: failed to read results: %v", err)
+		}
+		// the events after a pending one are pending as well
+		if !settled {
+			pending = true
+			break
+		}
+		events = append(events, e)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, false, fmt.Errorf("after: query failed: %v", err)
+	}
+
+	return events, pending, nil
+}
+
+func (r eventRepository) LastID(ctx context.Context) (int64, error) {
+	q, err := r.d.GetQuery("queries/event/GetLastEventID.sql")
+	if err != nil {
+		return 0, fmt.Errorf("lastID: failed to read query: %v", err)
+	}
+
+	var id int64
+	if err := r.d.QueryRowContext(ctx, q).Scan(&id); err != nil {
+		return 0, fmt.Errorf("lastID: failed to execute query: %v", err)
+	}
+
+	return id, nil
+}
+
+func (r eventRepository) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	q, err := r.d.GetQuery("queries/event/DeleteEventsBefore.sql")
+	if err != nil {
+		return 0, fmt.Errorf("deleteBefore: failed to read query: %v", err)
+	}
+
+	res, err := r.d.ExecContext(ctx, q, t)
+	if err != nil {
+		return 0, fmt.Errorf("deleteBefore: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("deleteBefore: %v", err)
+	}
+
+	return n, nil
+}
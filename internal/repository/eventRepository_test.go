@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"context"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/models"
+	"testing"
+)
+
+// A transaction that started first but records its event last can commit after one that
+// recorded its event later. Streams are held back until it ends, so they cannot move past its
+// event, and then get both in transaction order rather than by id.
+func TestEventRepository_pendingTransactions(t *testing.T) {
+	ctx := context.Background()
+	ur := NewUserRepository(*testDB)
+	if err := ur.CreateUser(ctx, models.CreateUserPayload{Name: "Events", Email: "events@commit-order.com", Password: "hash"}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := ur.GetUserByEmail(ctx, "events@commit-order.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uID := int64(u.ID)
+
+	tr := NewTaskRepository(*testDB)
+	uCtx := context.WithValue(ctx, contextkeys.UserID, uID)
+	for _, name := range []string{"first", "second"} {
+		if _, err := tr.Store(uCtx, models.TaskPayload{Name: name, Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var first, second int
+	if err := testDB.QueryRowContext(ctx, "select min(id), max(id) from tasks where created_by = $1", uID).Scan(&first, &second); err != nil {
+		t.Fatal(err)
+	}
+
+	er := NewEventRepository(*testDB)
+	last, err := er.LastID(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the first transaction takes its id before the second one starts
+	tx1, err := testDB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		_ = tx1.Rollback()
+	}()
+	if _, err := tx1.ExecContext(ctx, "select pg_current_xact_id()"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the second one records its event and commits first
+	tx2, err := testDB.BeginTx(ctx, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tx2.ExecContext(ctx, "update tasks set name = 'second updated' where id = $1", second); err != nil {
+		_ = tx2.Rollback()
+		t.Fatal(err)
+	}
+	if err := tx2.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	// then the first one records its event, with a higher id
+	if _, err := tx1.ExecContext(ctx, "update tasks set name = 'first updated' where id = $1", first); err != nil {
+		t.Fatal(err)
+	}
+
+	events, pending, err := er.After(ctx, uID, last, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(events) != 0 || !pending {
+		t.Fatalf("expected the committed event to be pending but got %+v, %v", events, pending)
+	}
+	if id, err := er.LastID(ctx); err != nil || id != last {
+		t.Errorf("the last id should stay at %d while the first transaction runs, got %d, %v", last, id, err)
+	}
+
+	if err := tx1.Commit(); err != nil {
+		t.Fatal(err)
+	}
+
+	events, pending, err = er.After(ctx, uID, last, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if pending || len(events) != 2 || events[0].TaskID != first || events[1].TaskID != second {
+		t.Fatalf("expected the events of %d and %d in transaction order but got %+v, %v", first, second, events, pending)
+	}
+	if events[0].ID < events[1].ID {
+		t.Errorf("the event of the first transaction should have the higher id, got %+v", events)
+	}
+
+	// resuming from either event goes on from its place in that order
+	rest, _, err := er.After(ctx, uID, events[0].ID, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(rest) != 1 || rest[0].ID != events[1].ID {
+		t.Errorf("expected the event of %d after the first one but got %+v", second, rest)
+	}
+	if id, err := er.LastID(ctx); err != nil || id != events[1].ID {
+		t.Errorf("the last id should be %d, got %d, %v", events[1].ID, id, err)
+	}
+}
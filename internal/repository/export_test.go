@@ -0,0 +1,8 @@
+package repository
+
+import "task-manager/internal/db"
+
+// PostgresDB exposes the database started by TestMain to the external test package.
+func PostgresDB() db.DB {
+	return *testDB
+}
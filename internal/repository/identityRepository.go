@@ -0,0 +1,91 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-manager/internal/db"
+	"task-manager/internal/models"
+	"time"
+)
+
+// IdentityRepository maps accounts at external identity providers to local users.
+type IdentityRepository interface {
+	GetUserID(ctx context.Context, issuer, subject string) (int64, error)
+	Link(ctx context.Context, uID int64, issuer, subject string, now time.Time) error
+	Provision(ctx context.Context, p models.CreateUserPayload, issuer, subject string, now time.Time) (models.User, error)
+}
+
+type identityRepository struct {
+	d db.DB
+}
+
+func NewIdentityRepository(d db.DB) IdentityRepository {
+	return &identityRepository{d: d}
+}
+
+func (r identityRepository) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	q, err := r.d.GetQuery("queries/identity/GetIdentityUser.sql")
+	if err != nil {
+		return 0, fmt.Errorf("getUserID: failed to read query: %v", err)
+	}
+
+	var uID int64
+	err = r.d.QueryRowContext(ctx, q, issuer, subject).Scan(&uID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("getUserID: %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("getUserID: failed to execute query: %v", err)
+	}
+
+	return uID, nil
+}
+
+func (r identityRepository) Link(ctx context.Context, uID int64, issuer, subject string, now time.Time) error {
+	q, err := r.d.GetQuery("queries/identity/InsertIdentity.sql")
+	if err != nil {
+		return fmt.Errorf("link: failed to read query: %v", err)
+	}
+
+	if _, err := r.d.ExecContext(ctx, q, uID, issuer, subject, now); err != nil {
+		return fmt.Errorf("link: failed to insert identity: %v", err)
+	}
+
+	return nil
+}
+
+// Provision creates a verified user together with its identity in a single transaction.
+func (r identityRepository) Provision(ctx context.Context, p models.CreateUserPayload, issuer, subject string, now time.Time) (models.User, error) {
+	uq, err := r.d.GetQuery("queries/user/InsertVerifiedUser.sql")
+	if err != nil {
+		return models.User{}, fmt.Errorf("provision: failed to read query: %v", err)
+	}
+	iq, err := r.d.GetQuery("queries/identity/InsertIdentity.sql")
+	if err != nil {
+		return models.User{}, fmt.Errorf("provision: failed to read query: %v", err)
+	}
+
+	tx, err := r.d.BeginTx(ctx, nil)
+	if err != nil {
+		return models.User{}, fmt.Errorf("provision: failed to begin tx: %v", err)
+	}
+
+	var u models.User
+	err = tx.QueryRowContext(ctx, uq, p.Name, p.Email, p.Password, now, now).Scan(&u.ID, &u.Name, &u.Email, &u.Password, &u.CreatedAt, &u.EmailVerifiedAt, &u.LastLoginAt)
+	if err != nil {
+		_ = tx.Rollback()
+		return models.User{}, fmt.Errorf("provision: failed to insert user: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, iq, u.ID, issuer, subject, now); err != nil {
+		_ = tx.Rollback()
+		return models.User{}, fmt.Errorf("provision: failed to insert identity: %v", err)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return models.User{}, fmt.Errorf("provision: failed to commit tx: %v", err)
+	}
+
+	return u, nil
+}
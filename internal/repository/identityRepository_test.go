@@ -0,0 +1,55 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"testing"
+	"time"
+)
+
+func TestIdentityRepository(t *testing.T) {
+	ctx := context.Background()
+	userRepo := NewUserRepository(*testDB)
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
+	_ = userRepo.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem Ipsum", Email: "identity@ipsum.com", Password: hash})
+	u, err := userRepo.GetUserByEmail(ctx, "identity@ipsum.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	idRepo := NewIdentityRepository(*testDB)
+	now := time.Now()
+	issuer := "https://idp.example.com"
+
+	if _, err := idRepo.GetUserID(ctx, issuer, "linked"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound before linking, got %v", err)
+	}
+	if err := idRepo.Link(ctx, int64(u.ID), issuer, "linked", now); err != nil {
+		t.Fatal(err)
+	}
+	if uID, err := idRepo.GetUserID(ctx, issuer, "linked"); err != nil || uID != int64(u.ID) {
+		t.Errorf("expected user %d but got %d (%v)", u.ID, uID, err)
+	}
+	if err := idRepo.Link(ctx, int64(u.ID), issuer, "linked", now); err == nil {
+		t.Errorf("an identity should only be linked once")
+	}
+
+	p, err := idRepo.Provision(ctx, models.CreateUserPayload{Name: "Dolor Sit", Email: "provisioned@ipsum.com", Password: hash}, issuer, "provisioned", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if p.ID == 0 || p.Email != "provisioned@ipsum.com" || p.EmailVerifiedAt == nil {
+		t.Errorf("unexpected provisioned user: %v", p)
+	}
+	if uID, err := idRepo.GetUserID(ctx, issuer, "provisioned"); err != nil || uID != int64(p.ID) {
+		t.Errorf("expected user %d but got %d (%v)", p.ID, uID, err)
+	}
+
+	if _, err := idRepo.Provision(ctx, models.CreateUserPayload{Name: "Dolor Sit", Email: "provisioned@ipsum.com", Password: hash}, issuer, "other", now); err == nil {
+		t.Errorf("provisioning an existing e-mail should fail")
+	}
+	if _, err := idRepo.GetUserID(ctx, issuer, "other"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("identity should not be stored when provisioning fails, got %v", err)
+	}
+}
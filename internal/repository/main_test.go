@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"log"
 	"os"
 	"task-manager/internal/config"
@@ -14,6 +15,7 @@ import (
 var (
 	dsn = "host=%s port=%s user=%s password=%s dbname=%s sslmode=disable timezone=UTC connect_timeout=5"
 	cfg = config.DBConfig{
+		Driver:   config.DBDriverPostgres,
 		Name:     "test_db",
 		User:     "postgres",
 		Password: "postgres",
@@ -59,12 +61,16 @@ func TestMain(m *testing.M) {
 
 	if err := pool.Retry(func() error {
 		var err error
-		testDB, err = db.InitDb(cfg)
+		testDB, err = db.Open(cfg)
 		if err != nil {
 			log.Println(err)
 			return err
 		}
-		return testDB.Ping()
+		if err := testDB.Ping(); err != nil {
+			_ = testDB.Close()
+			return err
+		}
+		return nil
 	}); err != nil {
 		if resource != nil {
 			_ = pool.Purge(resource)
@@ -73,7 +79,7 @@ func TestMain(m *testing.M) {
 		log.Fatalf("could not connect to database: %s", err)
 	}
 
-	err = testDB.RunMigrations()
+	err = testDB.RunMigrations(context.Background())
 	if err != nil {
 		if resource != nil {
 			_ = pool.Purge(resource)
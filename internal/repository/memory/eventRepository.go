@@ -0,0 +1,46 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"task-manager/internal/models"
+	"time"
+)
+
+type eventRepository struct {
+	s *store
+}
+
+// After never reports pending events, as they are recorded in order under the lock of the store.
+func (r *eventRepository) After(ctx context.Context, uID, id int64, limit int) ([]models.TaskEvent, bool, error) {
+	defer r.s.lock(ctx)()
+
+	var events []models.TaskEvent
+	for _, e := range r.s.events {
+		if len(events) == limit {
+			break
+		}
+		if e.UserID == uID && e.ID > id {
+			events = append(events, e)
+		}
+	}
+
+	return events, false, nil
+}
+
+func (r *eventRepository) LastID(ctx context.Context) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	return r.s.lastEventID, nil
+}
+
+func (r *eventRepository) DeleteBefore(ctx context.Context, t time.Time) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	n := len(r.s.events)
+	r.s.events = slices.DeleteFunc(r.s.events, func(e models.TaskEvent) bool {
+		return e.CreatedAt.Before(t)
+	})
+
+	return int64(n - len(r.s.events)), nil
+}
@@ -0,0 +1,57 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"time"
+)
+
+type identityRepository struct {
+	s *store
+}
+
+func (r *identityRepository) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	uID, ok := r.s.identities[identity{issuer: issuer, subject: subject}]
+	if !ok {
+		return 0, fmt.Errorf("getUserID: %w", repository.ErrNotFound)
+	}
+
+	return uID, nil
+}
+
+func (r *identityRepository) Link(ctx context.Context, uID int64, issuer, subject string, _ time.Time) error {
+	defer r.s.lock(ctx)()
+
+	if _, ok := r.s.users[uID]; !ok {
+		return fmt.Errorf("link: failed to insert identity: user %d does not exist", uID)
+	}
+	k := identity{issuer: issuer, subject: subject}
+	if _, ok := r.s.identities[k]; ok {
+		return fmt.Errorf("link: failed to insert identity: identity already linked")
+	}
+	r.s.identities[k] = uID
+
+	return nil
+}
+
+func (r *identityRepository) Provision(ctx context.Context, p models.CreateUserPayload, issuer, subject string, now time.Time) (models.User, error) {
+	defer r.s.lock(ctx)()
+
+	if r.s.userByEmail(p.Email) != nil {
+		return models.User{}, fmt.Errorf("provision: failed to insert user: email %s already exists", p.Email)
+	}
+	k := identity{issuer: issuer, subject: subject}
+	if _, ok := r.s.identities[k]; ok {
+		return models.User{}, fmt.Errorf("provision: failed to insert identity: identity already linked")
+	}
+
+	verifiedAt := now
+	u := r.s.insertUser(p, now, &verifiedAt)
+	r.s.identities[k] = int64(u.ID)
+
+	return u.model(), nil
+}
@@ -0,0 +1,155 @@
+// Package memory implements the repositories in process. Data is lost on restart, so it is meant
+// for development and tests that should not need a database.
+package memory
+
+import (
+	"slices"
+	"sync"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"time"
+)
+
+type user struct {
+	models.User
+	lastFailedLoginAt *time.Time
+}
+
+// trashed is a deleted task kept until the trash is purged.
+type trashed struct {
+	task      models.Task
+	deletedAt time.Time
+}
+
+type token struct {
+	models.UserToken
+	usedAt *time.Time
+}
+
+type recoveryCode struct {
+	hash   string
+	usedAt *time.Time
+}
+
+type identity struct {
+	issuer  string
+	subject string
+}
+
+// store holds the data of all repositories behind a single lock, so operations spanning
+// several of them, like deleting a user with their tasks, stay atomic.
+type store struct {
+	mu sync.Mutex
+
+	users      map[int64]*user
+	tasks      map[int]*models.Task
+	trash      map[int]*trashed
+	tokens     map[string]*token
+	totp       map[int64]*models.TOTP
+	codes      map[int64][]*recoveryCode
+	identities map[identity]int64
+	events     []models.TaskEvent
+
+	lastUserID  int64
+	lastTaskID  int
+	lastEventID int64
+}
+
+func New() repository.Repositories {
+	s := &store{
+		users:      make(map[int64]*user),
+		tasks:      make(map[int]*models.Task),
+		trash:      make(map[int]*trashed),
+		tokens:     make(map[string]*token),
+		totp:       make(map[int64]*models.TOTP),
+		codes:      make(map[int64][]*recoveryCode),
+		identities: make(map[identity]int64),
+	}
+
+	return repository.Repositories{
+		Ur: &userRepository{s: s},
+		Tr: &taskRepository{s: s},
+		Tk: &tokenRepository{s: s},
+		Mf: &mfaRepository{s: s},
+		Id: &identityRepository{s: s},
+		Ev: &eventRepository{s: s},
+		Uw: &unitOfWork{s: s},
+	}
+}
+
+func (s *store) userByEmail(email string) *user {
+	for _, u := range s.users {
+		if u.Email == email {
+			return u
+		}
+	}
+	return nil
+}
+
+func (s *store) insertUser(p models.CreateUserPayload, createdAt time.Time, verifiedAt *time.Time) *user {
+	s.lastUserID++
+	u := &user{User: models.User{
+		ID:              int(s.lastUserID),
+		Name:            p.Name,
+		Email:           p.Email,
+		Password:        p.Password,
+		CreatedAt:       &createdAt,
+		EmailVerifiedAt: verifiedAt,
+		Role:            models.RoleUser,
+	}}
+	s.users[s.lastUserID] = u
+
+	return u
+}
+
+// model returns a copy of u that shares no pointers with the stored user.
+func (u *user) model() models.User {
+	m := u.User
+	m.CreatedAt = copyTime(u.CreatedAt)
+	m.EmailVerifiedAt = copyTime(u.EmailVerifiedAt)
+	m.LastLoginAt = copyTime(u.LastLoginAt)
+	return m
+}
+
+// deleteUserData removes what the database would cascade when a user is deleted.
+func (s *store) deleteUserData(uID int64) {
+	for h, t := range s.tokens {
+		if t.UserID == uID {
+			delete(s.tokens, h)
+		}
+	}
+	delete(s.totp, uID)
+	delete(s.codes, uID)
+	for k, id := range s.identities {
+		if id == uID {
+			delete(s.identities, k)
+		}
+	}
+	s.events = slices.DeleteFunc(s.events, func(e models.TaskEvent) bool {
+		return e.UserID == uID
+	})
+}
+
+// recordEvent stands in for the database triggers recording every change to a task.
+func (s *store) recordEvent(typ string, t *models.Task) {
+	if t.CreatedBy == 0 {
+		return
+	}
+	s.lastEventID++
+	s.events = append(s.events, models.TaskEvent{
+		ID:        s.lastEventID,
+		Type:      typ,
+		TaskID:    t.ID,
+		UserID:    t.CreatedBy,
+		CreatedAt: time.Now(),
+	})
+}
+
+// copyTime returns a pointer to a copy of t, so callers cannot change stored values.
+func copyTime(t *time.Time) *time.Time {
+	if t == nil {
+		return nil
+	}
+	c := *t
+	return &c
+}
@@ -0,0 +1,13 @@
+package memory
+
+import (
+	"task-manager/internal/repository"
+	"task-manager/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestConformance(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repositories {
+		return New()
+	})
+}
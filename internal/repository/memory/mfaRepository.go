@@ -0,0 +1,104 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"time"
+)
+
+type mfaRepository struct {
+	s *store
+}
+
+func (r *mfaRepository) UpsertTOTP(ctx context.Context, uID int64, secret string, _ time.Time) error {
+	defer r.s.lock(ctx)()
+
+	if _, ok := r.s.users[uID]; !ok {
+		return fmt.Errorf("upsertTOTP: failed to execute query: user %d does not exist", uID)
+	}
+	if t, ok := r.s.totp[uID]; ok && t.ConfirmedAt != nil {
+		return nil
+	}
+	r.s.totp[uID] = &models.TOTP{UserID: uID, Secret: secret}
+
+	return nil
+}
+
+func (r *mfaRepository) GetTOTP(ctx context.Context, uID int64) (models.TOTP, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.totp[uID]
+	if !ok {
+		return models.TOTP{}, fmt.Errorf("getTOTP: %w", repository.ErrNotFound)
+	}
+
+	m := *t
+	m.ConfirmedAt = copyTime(t.ConfirmedAt)
+	if t.LastStep != nil {
+		step := *t.LastStep
+		m.LastStep = &step
+	}
+
+	return m, nil
+}
+
+func (r *mfaRepository) ConfirmTOTP(ctx context.Context, uID, step int64, codeHashes []string, now time.Time) error {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.totp[uID]
+	if !ok || t.ConfirmedAt != nil {
+		return fmt.Errorf("confirmTOTP: %w", repository.ErrNotFound)
+	}
+
+	codes := make([]*recoveryCode, 0, len(codeHashes))
+	seen := make(map[string]bool, len(codeHashes))
+	for _, h := range codeHashes {
+		if seen[h] {
+			return fmt.Errorf("confirmTOTP: failed to insert recovery code: duplicate code")
+		}
+		seen[h] = true
+		codes = append(codes, &recoveryCode{hash: h})
+	}
+
+	t.ConfirmedAt = &now
+	t.LastStep = &step
+	r.s.codes[uID] = codes
+
+	return nil
+}
+
+func (r *mfaRepository) UseTOTPStep(ctx context.Context, uID, step int64) (bool, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.totp[uID]
+	if !ok || t.ConfirmedAt == nil || (t.LastStep != nil && *t.LastStep >= step) {
+		return false, nil
+	}
+	t.LastStep = &step
+
+	return true, nil
+}
+
+func (r *mfaRepository) UseRecoveryCode(ctx context.Context, uID int64, hash string, now time.Time) (bool, error) {
+	defer r.s.lock(ctx)()
+
+	for _, c := range r.s.codes[uID] {
+		if c.hash == hash && c.usedAt == nil {
+			c.usedAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (r *mfaRepository) DeleteTOTP(ctx context.Context, uID int64) error {
+	defer r.s.lock(ctx)()
+
+	delete(r.s.totp, uID)
+	delete(r.s.codes, uID)
+
+	return nil
+}
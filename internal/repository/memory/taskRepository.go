@@ -0,0 +1,144 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/models"
+	"time"
+)
+
+type taskRepository struct {
+	s *store
+}
+
+func (r *taskRepository) Store(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+	uID, ok := ctx.Value(contextkeys.UserID).(int64)
+	if !ok || uID == 0 {
+		return models.Task{}, fmt.Errorf("store: failed to get user id")
+	}
+
+	defer r.s.lock(ctx)()
+
+	if _, ok := r.s.users[uID]; !ok {
+		return models.Task{}, fmt.Errorf("store: failed to insert a new task: user %d does not exist", uID)
+	}
+
+	r.s.lastTaskID++
+	t := &models.Task{
+		ID:          r.s.lastTaskID,
+		Name:        p.Name,
+		Priority:    p.Priority,
+		Description: p.Description,
+		DueDate:     copyTime(p.DueDate),
+		CreatedAt:   copyTime(p.CreatedAt),
+		CreatedBy:   uID,
+	}
+	r.s.tasks[t.ID] = t
+	r.s.recordEvent(models.TaskEventCreated, t)
+
+	return taskModel(t), nil
+}
+
+func (r *taskRepository) Update(ctx context.Context, p models.UpdateTask) (models.Task, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.tasks[int(p.ID)]
+	if !ok {
+		return models.Task{}, fmt.Errorf("update: failed to get task from db: task %d does not exist", p.ID)
+	}
+
+	uID, _ := ctx.Value(contextkeys.UserID).(int64)
+	if uID != t.CreatedBy {
+		return models.Task{}, fmt.Errorf("user not authorized for this action")
+	}
+
+	t.Name = p.Name
+	t.Priority = p.Priority
+	t.Description = p.Description
+	t.DueDate = copyTime(p.DueDate)
+	r.s.recordEvent(models.TaskEventUpdated, t)
+
+	return taskModel(t), nil
+}
+
+func (r *taskRepository) Show(ctx context.Context, id int) (models.Task, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.tasks[id]
+	if !ok {
+		return models.Task{}, fmt.Errorf("no results for given ID")
+	}
+
+	return taskModel(t), nil
+}
+
+func (r *taskRepository) Index(ctx context.Context, uID int64) (models.TasksList, error) {
+	defer r.s.lock(ctx)()
+
+	var l models.TasksList
+	for _, t := range r.s.tasks {
+		if t.CreatedBy == uID {
+			l.Tasks = append(l.Tasks, taskModel(t))
+		}
+	}
+	slices.SortFunc(l.Tasks, func(a, b models.Task) int {
+		return a.ID - b.ID
+	})
+
+	return l, nil
+}
+
+func (r *taskRepository) Delete(ctx context.Context, id int) error {
+	defer r.s.lock(ctx)()
+
+	if t, ok := r.s.tasks[id]; ok {
+		delete(r.s.tasks, id)
+		r.s.trash[id] = &trashed{task: *t, deletedAt: time.Now()}
+		r.s.recordEvent(models.TaskEventDeleted, t)
+	}
+
+	return nil
+}
+
+func (r *taskRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	var n int64
+	for id, t := range r.s.trash {
+		if t.deletedAt.Before(before) {
+			delete(r.s.trash, id)
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func (r *taskRepository) IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.tasks[id]
+	return ok && t.CreatedBy == uID, nil
+}
+
+func (r *taskRepository) CountOverdue(ctx context.Context, now time.Time) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	var n int64
+	for _, t := range r.s.tasks {
+		if t.DueDate != nil && t.DueDate.Before(now) {
+			n++
+		}
+	}
+
+	return n, nil
+}
+
+func taskModel(t *models.Task) models.Task {
+	m := *t
+	m.DueDate = copyTime(t.DueDate)
+	m.CreatedAt = copyTime(t.CreatedAt)
+	return m
+}
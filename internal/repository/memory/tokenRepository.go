@@ -0,0 +1,51 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"time"
+)
+
+type tokenRepository struct {
+	s *store
+}
+
+func (r *tokenRepository) Store(ctx context.Context, t models.UserToken) error {
+	defer r.s.lock(ctx)()
+
+	if _, ok := r.s.users[t.UserID]; !ok {
+		return fmt.Errorf("store: failed to insert token: user %d does not exist", t.UserID)
+	}
+	if _, ok := r.s.tokens[t.Hash]; ok {
+		return fmt.Errorf("store: failed to insert token: duplicate token hash")
+	}
+	r.s.tokens[t.Hash] = &token{UserToken: t}
+
+	return nil
+}
+
+func (r *tokenRepository) Consume(ctx context.Context, purpose, hash string, now time.Time) (int64, error) {
+	defer r.s.lock(ctx)()
+
+	t, ok := r.s.tokens[hash]
+	if !ok || t.Purpose != purpose || t.usedAt != nil || !t.ExpiresAt.After(now) {
+		return 0, fmt.Errorf("consume: %w", repository.ErrNotFound)
+	}
+	t.usedAt = &now
+
+	return t.UserID, nil
+}
+
+func (r *tokenRepository) DeleteForUser(ctx context.Context, uID int64, purpose string) error {
+	defer r.s.lock(ctx)()
+
+	for h, t := range r.s.tokens {
+		if t.UserID == uID && t.Purpose == purpose && t.usedAt == nil {
+			delete(r.s.tokens, h)
+		}
+	}
+
+	return nil
+}
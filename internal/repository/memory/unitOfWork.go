@@ -0,0 +1,116 @@
+package memory
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"task-manager/internal/models"
+)
+
+type unitKey struct{}
+
+type unitOfWork struct {
+	s *store
+}
+
+// Do holds the store lock while fn runs, so units are isolated from every other call, and
+// restores a snapshot of the data when fn fails.
+func (u *unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	if ctx.Value(unitKey{}) == u.s {
+		return fn(ctx)
+	}
+
+	u.s.mu.Lock()
+	defer u.s.mu.Unlock()
+
+	snapshot := u.s.snapshot()
+	if err := fn(context.WithValue(ctx, unitKey{}, u.s)); err != nil {
+		u.s.restore(snapshot)
+		return err
+	}
+	return nil
+}
+
+// lock acquires the store lock and returns the func releasing it. Calls made inside a unit of
+// work already hold it.
+func (s *store) lock(ctx context.Context) func() {
+	if ctx.Value(unitKey{}) == s {
+		return func() {}
+	}
+	s.mu.Lock()
+	return s.mu.Unlock
+}
+
+type snapshot struct {
+	users      map[int64]user
+	tasks      map[int]models.Task
+	trash      map[int]trashed
+	tokens     map[string]token
+	totp       map[int64]models.TOTP
+	codes      map[int64][]recoveryCode
+	identities map[identity]int64
+	events     []models.TaskEvent
+
+	lastUserID  int64
+	lastTaskID  int
+	lastEventID int64
+}
+
+// snapshot copies the stored values. The times they point to are replaced rather than
+// written through, so copying the values themselves is enough.
+func (s *store) snapshot() snapshot {
+	c := snapshot{
+		users:       copyValues(s.users),
+		tasks:       copyValues(s.tasks),
+		trash:       copyValues(s.trash),
+		tokens:      copyValues(s.tokens),
+		totp:        copyValues(s.totp),
+		codes:       make(map[int64][]recoveryCode, len(s.codes)),
+		identities:  maps.Clone(s.identities),
+		events:      slices.Clone(s.events),
+		lastUserID:  s.lastUserID,
+		lastTaskID:  s.lastTaskID,
+		lastEventID: s.lastEventID,
+	}
+	for uID, codes := range s.codes {
+		for _, rc := range codes {
+			c.codes[uID] = append(c.codes[uID], *rc)
+		}
+	}
+	return c
+}
+
+func (s *store) restore(c snapshot) {
+	s.users = pointValues(c.users)
+	s.tasks = pointValues(c.tasks)
+	s.trash = pointValues(c.trash)
+	s.tokens = pointValues(c.tokens)
+	s.totp = pointValues(c.totp)
+	s.codes = make(map[int64][]*recoveryCode, len(c.codes))
+	for uID, codes := range c.codes {
+		for _, rc := range codes {
+			s.codes[uID] = append(s.codes[uID], &rc)
+		}
+	}
+	s.identities = c.identities
+	s.lastUserID = c.lastUserID
+	s.lastTaskID = c.lastTaskID
+	s.events = c.events
+	s.lastEventID = c.lastEventID
+}
+
+func copyValues[K comparable, V any](m map[K]*V) map[K]V {
+	c := make(map[K]V, len(m))
+	for k, v := range m {
+		c[k] = *v
+	}
+	return c
+}
+
+func pointValues[K comparable, V any](m map[K]V) map[K]*V {
+	c := make(map[K]*V, len(m))
+	for k, v := range m {
+		c[k] = &v
+	}
+	return c
+}
@@ -0,0 +1,186 @@
+package memory
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"time"
+)
+
+type userRepository struct {
+	s *store
+}
+
+func (r *userRepository) CreateUser(ctx context.Context, p models.CreateUserPayload) error {
+	defer r.s.lock(ctx)()
+
+	if r.s.userByEmail(p.Email) != nil {
+		return fmt.Errorf("CreateUser: failed to insert a new user: email %s already exists", p.Email)
+	}
+	r.s.insertUser(p, time.Now(), nil)
+
+	return nil
+}
+
+func (r *userRepository) CheckIfEmailExists(ctx context.Context, email string) (bool, error) {
+	defer r.s.lock(ctx)()
+
+	return r.s.userByEmail(email) != nil, nil
+}
+
+func (r *userRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	defer r.s.lock(ctx)()
+
+	u := r.s.userByEmail(email)
+	if u == nil {
+		return models.User{}, fmt.Errorf("getUser: %w", repository.ErrNotFound)
+	}
+
+	return u.model(), nil
+}
+
+func (r *userRepository) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	defer r.s.lock(ctx)()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("getUser: %w", repository.ErrNotFound)
+	}
+
+	return u.model(), nil
+}
+
+func (r *userRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	defer r.s.lock(ctx)()
+
+	ids = slices.Compact(slices.Sorted(slices.Values(ids)))
+	list := make([]models.User, 0, len(ids))
+	for _, id := range ids {
+		if u, ok := r.s.users[id]; ok {
+			list = append(list, u.model())
+		}
+	}
+
+	return list, nil
+}
+
+func (r *userRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+	defer r.s.lock(ctx)()
+
+	list := make([]models.User, 0, len(r.s.users))
+	for _, u := range r.s.users {
+		list = append(list, u.model())
+	}
+	slices.SortFunc(list, func(a, b models.User) int {
+		return a.ID - b.ID
+	})
+
+	return list, nil
+}
+
+func (r *userRepository) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	defer r.s.lock(ctx)()
+
+	if u, ok := r.s.users[id]; ok {
+		u.EmailVerifiedAt = &at
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	defer r.s.lock(ctx)()
+
+	if u, ok := r.s.users[id]; ok {
+		u.Password = hash
+	}
+
+	return nil
+}
+
+func (r *userRepository) RecordLoginSuccess(ctx context.Context, id int64, at time.Time) error {
+	defer r.s.lock(ctx)()
+
+	if u, ok := r.s.users[id]; ok {
+		u.LastLoginAt = &at
+	}
+
+	return nil
+}
+
+func (r *userRepository) RecordLoginFailure(ctx context.Context, id int64, at time.Time) error {
+	defer r.s.lock(ctx)()
+
+	if u, ok := r.s.users[id]; ok {
+		u.lastFailedLoginAt = &at
+	}
+
+	return nil
+}
+
+func (r *userRepository) UpdateProfile(ctx context.Context, id int64, name, email string) (models.User, error) {
+	defer r.s.lock(ctx)()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return models.User{}, fmt.Errorf("UpdateProfile: %w", repository.ErrNotFound)
+	}
+	if other := r.s.userByEmail(email); other != nil && other != u {
+		return models.User{}, fmt.Errorf("UpdateProfile: failed to execute query: email %s already exists", email)
+	}
+
+	if u.Email != email {
+		u.EmailVerifiedAt = nil
+	}
+	u.Name = name
+	u.Email = email
+
+	return u.model(), nil
+}
+
+func (r *userRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	defer r.s.lock(ctx)()
+
+	u, ok := r.s.users[id]
+	if !ok {
+		return fmt.Errorf("UpdateRole: %w", repository.ErrNotFound)
+	}
+	u.Role = role
+
+	return nil
+}
+
+func (r *userRepository) DeleteUser(ctx context.Context, id int64, anonymizeTasks bool) error {
+	defer r.s.lock(ctx)()
+
+	if _, ok := r.s.users[id]; !ok {
+		return fmt.Errorf("DeleteUser: %w", repository.ErrNotFound)
+	}
+
+	for tID, t := range r.s.tasks {
+		if t.CreatedBy != id {
+			continue
+		}
+		if anonymizeTasks {
+			t.CreatedBy = 0
+		} else {
+			delete(r.s.tasks, tID)
+		}
+	}
+	for tID, t := range r.s.trash {
+		if t.task.CreatedBy != id {
+			continue
+		}
+		if anonymizeTasks {
+			t.task.CreatedBy = 0
+		} else {
+			delete(r.s.trash, tID)
+		}
+	}
+	r.s.deleteUserData(id)
+	delete(r.s.users, id)
+
+	return nil
+}
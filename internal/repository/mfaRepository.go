@@ -0,0 +1,178 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-manager/internal/db"
+	"task-manager/internal/models"
+	"time"
+)
+
+type MFARepository interface {
+	UpsertTOTP(ctx context.Context, uID int64, secret string, now time.Time) error
+	GetTOTP(ctx context.Context, uID int64) (models.TOTP, error)
+	ConfirmTOTP(ctx context.Context, uID, step int64, codeHashes []string, now time.Time) error
+	UseTOTPStep(ctx context.Context, uID, step int64) (bool, error)
+	UseRecoveryCode(ctx context.Context, uID int64, hash string, now time.Time) (bool, error)
+	DeleteTOTP(ctx context.Context, uID int64) error
+}
+
+type mfaRepository struct {
+	d db.DB
+}
+
+func NewMFARepository(d db.DB) MFARepository {
+	return &mfaRepository{d: d}
+}
+
+// UpsertTOTP stores a pending secret, replacing a previous unconfirmed one. A confirmed
+// secret is left untouched.
+func (r mfaRepository) UpsertTOTP(ctx context.Context, uID int64, secret string, now time.Time) error {
+	q, err := r.d.GetQuery("queries/mfa/UpsertTOTP.sql")
+	if err != nil {
+		return fmt.Errorf("upsertTOTP: failed to read query: %v", err)
+	}
+
+	if _, err := r.d.ExecContext(ctx, q, uID, secret, now); err != nil {
+		return fmt.Errorf("upsertTOTP: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+
+func (r mfaRepository) GetTOTP(ctx context.Context, uID int64) (models.TOTP, error) {
+	q, err := r.d.GetQuery("queries/mfa/GetTOTP.sql")
+	if err != nil {
+		return models.TOTP{}, fmt.Errorf("getTOTP: failed to read query: %v", err)
+	}
+
+	var t models.TOTP
+	err = r.d.QueryRowContext(ctx, q, uID).Scan(&t.UserID, &t.Secret, &t.ConfirmedAt, &t.LastStep)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.TOTP{}, fmt.Errorf("getTOTP: %w", ErrNotFound)
+	}
+	if err != nil {
+		return models.TOTP{}, fmt.Errorf("getTOTP: failed to execute query: %v", err)
+	}
+
+	return t, nil
+}
+
+// ConfirmTOTP activates the pending secret and replaces the user's recovery codes in one
+// transaction.
+func (r mfaRepository) ConfirmTOTP(ctx context.Context, uID, step int64, codeHashes []string, now time.Time) error {
+	cq, err := r.d.GetQuery("queries/mfa/ConfirmTOTP.sql")
+	if err != nil {
+		return fmt.Errorf("confirmTOTP: failed to read query: %v", err)
+	}
+	dq, err := r.d.GetQuery("queries/mfa/DeleteRecoveryCodes.sql")
+	if err != nil {
+		return fmt.Errorf("confirmTOTP: failed to read query: %v", err)
+	}
+	iq, err := r.d.GetQuery("queries/mfa/InsertRecoveryCode.sql")
+	if err != nil {
+		return fmt.Errorf("confirmTOTP: failed to read query: %v", err)
+	}
+
+	tx, err := r.d.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("confirmTOTP: failed to begin tx: %v", err)
+	}
+
+	res, err := tx.ExecContext(ctx, cq, uID, now, step)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("confirmTOTP: failed to confirm secret: %v", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		_ = tx.Rollback()
+		return fmt.Errorf("confirmTOTP: %w", ErrNotFound)
+	}
+
+	if _, err := tx.ExecContext(ctx, dq, uID); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("confirmTOTP: failed to delete recovery codes: %v", err)
+	}
+	for _, h := range codeHashes {
+		if _, err := tx.ExecContext(ctx, iq, uID, h, now); err != nil {
+			_ = tx.Rollback()
+			return fmt.Errorf("confirmTOTP: failed to insert recovery code: %v", err)
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("confirmTOTP: failed to commit tx: %v", err)
+	}
+
+	return nil
+}
+
+// UseTOTPStep records step as the last one used and reports false when it is not newer than
+// the stored one, so every code is accepted only once.
+func (r mfaRepository) UseTOTPStep(ctx context.Context, uID, step int64) (bool, error) {
+	q, err := r.d.GetQuery("queries/mfa/UseTOTPStep.sql")
+	if err != nil {
+		return false, fmt.Errorf("useTOTPStep: failed to read query: %v", err)
+	}
+
+	res, err := r.d.ExecContext(ctx, q, uID, step)
+	if err != nil {
+		return false, fmt.Errorf("useTOTPStep: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("useTOTPStep: failed to read affected rows: %v", err)
+	}
+
+	return n == 1, nil
+}
+
+func (r mfaRepository) UseRecoveryCode(ctx context.Context, uID int64, hash string, now time.Time) (bool, error) {
+	q, err := r.d.GetQuery("queries/mfa/UseRecoveryCode.sql")
+	if err != nil {
+		return false, fmt.Errorf("useRecoveryCode: failed to read query: %v", err)
+	}
+
+	res, err := r.d.ExecContext(ctx, q, uID, hash, now)
+	if err != nil {
+		return false, fmt.Errorf("useRecoveryCode: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("useRecoveryCode: failed to read affected rows: %v", err)
+	}
+
+	return n == 1, nil
+}
+
+// DeleteTOTP disables two-factor authentication, removing the secret and recovery codes.
+func (r mfaRepository) DeleteTOTP(ctx context.Context, uID int64) error {
+	tq, err := r.d.GetQuery("queries/mfa/DeleteTOTP.sql")
+	if err != nil {
+		return fmt.Errorf("deleteTOTP: failed to read query: %v", err)
+	}
+	cq, err := r.d.GetQuery("queries/mfa/DeleteRecoveryCodes.sql")
+	if err != nil {
+		return fmt.Errorf("deleteTOTP: failed to read query: %v", err)
+	}
+
+	tx, err := r.d.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("deleteTOTP: failed to begin tx: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, tq, uID); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("deleteTOTP: failed to delete secret: %v", err)
+	}
+	if _, err := tx.ExecContext(ctx, cq, uID); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("deleteTOTP: failed to delete recovery codes: %v", err)
+	}
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("deleteTOTP: failed to commit tx: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,81 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"testing"
+	"time"
+)
+
+func TestMFARepository(t *testing.T) {
+	ctx := context.Background()
+	userRepo := NewUserRepository(*testDB)
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
+	_ = userRepo.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem Ipsum", Email: "mfa@ipsum.com", Password: hash})
+	u, err := userRepo.GetUserByEmail(ctx, "mfa@ipsum.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uID := int64(u.ID)
+	mfaRepo := NewMFARepository(*testDB)
+	now := time.Now()
+
+	if _, err := mfaRepo.GetTOTP(ctx, uID); !errors.Is(err, ErrNotFound) {
+		t.Errorf("expected ErrNotFound before enrolment, got %v", err)
+	}
+
+	if err := mfaRepo.UpsertTOTP(ctx, uID, "FIRSTSECRET", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfaRepo.UpsertTOTP(ctx, uID, "SECONDSECRET", now); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfaRepo.ConfirmTOTP(ctx, uID, 10, []string{"hash-a", "hash-b"}, now); err != nil {
+		t.Fatal(err)
+	}
+	if err := mfaRepo.UpsertTOTP(ctx, uID, "THIRDSECRET", now); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := mfaRepo.GetTOTP(ctx, uID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Secret != "SECONDSECRET" || got.ConfirmedAt == nil {
+		t.Errorf("confirmed secret should not be replaced: %v", got)
+	}
+
+	var tests = []struct {
+		name     string
+		use      func() (bool, error)
+		expected bool
+	}{
+		{"newer step", func() (bool, error) { return mfaRepo.UseTOTPStep(ctx, uID, 11) }, true},
+		{"same step replayed", func() (bool, error) { return mfaRepo.UseTOTPStep(ctx, uID, 11) }, false},
+		{"older step", func() (bool, error) { return mfaRepo.UseTOTPStep(ctx, uID, 9) }, false},
+		{"recovery code", func() (bool, error) { return mfaRepo.UseRecoveryCode(ctx, uID, "hash-a", now) }, true},
+		{"recovery code used twice", func() (bool, error) { return mfaRepo.UseRecoveryCode(ctx, uID, "hash-a", now) }, false},
+		{"unknown recovery code", func() (bool, error) { return mfaRepo.UseRecoveryCode(ctx, uID, "hash-c", now) }, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ok, err := tc.use()
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if ok != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, ok)
+			}
+		})
+	}
+
+	if err := mfaRepo.DeleteTOTP(ctx, uID); err != nil {
+		t.Fatal(err)
+	}
+	if ok, _ := mfaRepo.UseRecoveryCode(ctx, uID, "hash-b", now); ok {
+		t.Errorf("recovery codes should be removed with the secret")
+	}
+}
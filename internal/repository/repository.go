@@ -1,15 +1,31 @@
 package repository
 
-import "task-manager/internal/db"
+import (
+	"errors"
+	"task-manager/internal/db"
+)
+
+// ErrNotFound is wrapped by lookups that match no row, so callers can tell it apart from failures.
+var ErrNotFound = errors.New("not found")
 
 type Repositories struct {
 	Ur UserRepository
 	Tr TaskRepository
+	Tk TokenRepository
+	Mf MFARepository
+	Id IdentityRepository
+	Ev EventRepository
+	Uw UnitOfWork
 }
 
 func New(d db.DB) Repositories {
 	return Repositories{
 		Ur: NewUserRepository(d),
 		Tr: NewTaskRepository(d),
+		Tk: NewTokenRepository(d),
+		Mf: NewMFARepository(d),
+		Id: NewIdentityRepository(d),
+		Ev: NewEventRepository(d),
+		Uw: NewUnitOfWork(d),
 	}
 }
@@ -14,4 +14,16 @@ func TestNewTaskRepository(t *testing.T) {
 	if repository.Tr == nil {
 		t.Errorf("taskRepository should not be nil")
 	}
+
+	if repository.Tk == nil {
+		t.Errorf("tokenRepository should not be nil")
+	}
+
+	if repository.Mf == nil {
+		t.Errorf("mfaRepository should not be nil")
+	}
+
+	if repository.Id == nil {
+		t.Errorf("identityRepository should not be nil")
+	}
 }
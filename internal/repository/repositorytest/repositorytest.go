@@ -0,0 +1,995 @@
+// Package repositorytest holds the conformance suite every storage backend has to pass. Tests
+// only rely on data they created themselves, so the suite can run against a shared database.
+package repositorytest
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"testing"
+	"time"
+)
+
+// Factory returns the repositories of the backend under test.
+type Factory func(t *testing.T) repository.Repositories
+
+var seq atomic.Int64
+
+// Run executes the conformance suite, calling newRepos once for every test.
+func Run(t *testing.T, newRepos Factory) {
+	t.Run("users", func(t *testing.T) { testUsers(t, newRepos) })
+	t.Run("tasks", func(t *testing.T) { testTasks(t, newRepos) })
+	t.Run("tokens", func(t *testing.T) { testTokens(t, newRepos) })
+	t.Run("mfa", func(t *testing.T) { testMFA(t, newRepos) })
+	t.Run("identities", func(t *testing.T) { testIdentities(t, newRepos) })
+	t.Run("unit of work", func(t *testing.T) { testUnitOfWork(t, newRepos) })
+	t.Run("events", func(t *testing.T) { testEvents(t, newRepos) })
+}
+
+// at returns a fixed point in time in UTC and truncated to seconds, which every backend stores
+// without loss.
+func at(offset time.Duration) time.Time {
+	return time.Date(2030, time.January, 2, 3, 4, 5, 0, time.UTC).Add(offset)
+}
+
+func uniqueEmail() string {
+	return fmt.Sprintf("conformance-%d-%d@example.com", time.Now().UnixNano(), seq.Add(1))
+}
+
+func uniqueValue(prefix string) string {
+	return fmt.Sprintf("%s-%d-%d", prefix, time.Now().UnixNano(), seq.Add(1))
+}
+
+func createUser(t *testing.T, r repository.Repositories) models.User {
+	t.Helper()
+	ctx := context.Background()
+	email := uniqueEmail()
+	if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Conformance", Email: email, Password: "hash"}); err != nil {
+		t.Fatalf("failed to create user: %s", err)
+	}
+	u, err := r.Ur.GetUserByEmail(ctx, email)
+	if err != nil {
+		t.Fatalf("failed to read created user: %s", err)
+	}
+	return u
+}
+
+func userContext(u models.User) context.Context {
+	return context.WithValue(context.Background(), contextkeys.UserID, int64(u.ID))
+}
+
+func expectNotFound(t *testing.T, err error) {
+	t.Helper()
+	if !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func expectTime(t *testing.T, field string, got *time.Time, want time.Time) {
+	t.Helper()
+	if got == nil || !got.Equal(want) {
+		t.Errorf("expected %s %s but got %v", field, want, got)
+	}
+}
+
+func testUsers(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("create and read", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+
+		byID, err := r.Ur.GetUserByID(ctx, int64(u.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if byID.Email != u.Email || byID.Name != "Conformance" || byID.Password != "hash" {
+			t.Errorf("unexpected user %+v", byID)
+		}
+		if byID.CreatedAt == nil || byID.EmailVerifiedAt != nil || byID.LastLoginAt != nil || byID.Role != models.RoleUser {
+			t.Errorf("unexpected defaults of a new user %+v", byID)
+		}
+
+		exists, err := r.Ur.CheckIfEmailExists(ctx, u.Email)
+		if err != nil || !exists {
+			t.Errorf("email should exist, got %v, %v", exists, err)
+		}
+		exists, err = r.Ur.CheckIfEmailExists(ctx, uniqueEmail())
+		if err != nil || exists {
+			t.Errorf("email should not exist, got %v, %v", exists, err)
+		}
+	})
+
+	t.Run("list", func(t *testing.T) {
+		r := newRepos(t)
+		first := createUser(t, r)
+		second := createUser(t, r)
+
+		list, err := r.Ur.ListUsers(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var found []string
+		for i, u := range list {
+			if i > 0 && list[i-1].ID >= u.ID {
+				t.Errorf("users should be ordered by id")
+			}
+			if u.ID == first.ID || u.ID == second.ID {
+				found = append(found, u.Email)
+			}
+		}
+		if len(found) != 2 || found[0] != first.Email || found[1] != second.Email {
+			t.Errorf("expected %s and %s in the list but got %v", first.Email, second.Email, found)
+		}
+	})
+
+	t.Run("get by ids", func(t *testing.T) {
+		r := newRepos(t)
+		first := createUser(t, r)
+		second := createUser(t, r)
+
+		list, err := r.Ur.GetUsersByIDs(ctx, []int64{int64(second.ID), 1 << 30, int64(first.ID), int64(second.ID)})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(list) != 2 || list[0].Email != first.Email || list[1].Email != second.Email {
+			t.Errorf("expected %s and %s ordered by id but got %+v", first.Email, second.Email, list)
+		}
+
+		list, err = r.Ur.GetUsersByIDs(ctx, nil)
+		if err != nil || len(list) != 0 {
+			t.Errorf("expected no users for no ids but got %+v, %v", list, err)
+		}
+	})
+
+	t.Run("duplicate email", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Other", Email: u.Email, Password: "hash"}); err == nil {
+			t.Errorf("creating a user with a taken email should fail")
+		}
+	})
+
+	t.Run("unknown user", func(t *testing.T) {
+		r := newRepos(t)
+		_, err := r.Ur.GetUserByEmail(ctx, uniqueEmail())
+		expectNotFound(t, err)
+		_, err = r.Ur.GetUserByID(ctx, 1<<30)
+		expectNotFound(t, err)
+		_, err = r.Ur.UpdateProfile(ctx, 1<<30, "Nobody", uniqueEmail())
+		expectNotFound(t, err)
+		expectNotFound(t, r.Ur.DeleteUser(ctx, 1<<30, false))
+	})
+
+	t.Run("verification, password and logins", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		id := int64(u.ID)
+
+		if err := r.Ur.MarkEmailVerified(ctx, id, at(0)); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Ur.UpdatePassword(ctx, id, "new-hash"); err != nil {
+			t.Fatal(err)
+		}
+		for range 2 {
+			if err := r.Ur.RecordLoginFailure(ctx, id, at(time.Minute)); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		got, err := r.Ur.GetUserByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectTime(t, "email_verified_at", got.EmailVerifiedAt, at(0))
+		if got.Password != "new-hash" {
+			t.Errorf("password was not updated")
+		}
+
+		if err := r.Ur.RecordLoginSuccess(ctx, id, at(time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		got, err = r.Ur.GetUserByID(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		expectTime(t, "last_login_at", got.LastLoginAt, at(time.Hour))
+	})
+
+	t.Run("update role", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+
+		if err := r.Ur.UpdateRole(ctx, int64(u.ID), models.RoleAdmin); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Ur.GetUserByEmail(ctx, u.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Role != models.RoleAdmin {
+			t.Errorf("expected role %s but got %s", models.RoleAdmin, got.Role)
+		}
+		list, err := r.Ur.GetUsersByIDs(ctx, []int64{int64(u.ID)})
+		if err != nil || len(list) != 1 || list[0].Role != models.RoleAdmin {
+			t.Errorf("the role should be read with the user, got %+v, %v", list, err)
+		}
+
+		expectNotFound(t, r.Ur.UpdateRole(ctx, int64(u.ID)+1000000, models.RoleAdmin))
+	})
+
+	t.Run("update profile", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		other := createUser(t, r)
+		id := int64(u.ID)
+		if err := r.Ur.MarkEmailVerified(ctx, id, at(0)); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err := r.Ur.UpdateProfile(ctx, id, "Renamed", u.Email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Renamed" {
+			t.Errorf("expected name Renamed but got %s", got.Name)
+		}
+		expectTime(t, "email_verified_at", got.EmailVerifiedAt, at(0))
+
+		email := uniqueEmail()
+		got, err = r.Ur.UpdateProfile(ctx, id, "Renamed", email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Email != email || got.EmailVerifiedAt != nil {
+			t.Errorf("changing the email should clear its verification, got %+v", got)
+		}
+
+		if _, err := r.Ur.UpdateProfile(ctx, id, "Renamed", other.Email); err == nil {
+			t.Errorf("taking the email of another user should fail")
+		}
+	})
+
+	t.Run("delete with tasks", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		uCtx := userContext(u)
+		if _, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Tk.Store(ctx, models.UserToken{UserID: int64(u.ID), Purpose: "verify", Hash: uniqueValue("hash"), ExpiresAt: at(time.Hour), CreatedAt: at(0)}); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Ur.DeleteUser(ctx, int64(u.ID), false); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.Ur.GetUserByID(ctx, int64(u.ID))
+		expectNotFound(t, err)
+		l, err := r.Tr.Index(uCtx, int64(u.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Tasks) != 0 {
+			t.Errorf("tasks of a deleted user should be deleted, got %d", len(l.Tasks))
+		}
+	})
+
+	t.Run("delete anonymizing tasks", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		uCtx := userContext(u)
+		due := at(-time.Hour)
+		name := uniqueValue("task")
+		if _, err := r.Tr.Store(uCtx, models.TaskPayload{Name: name, Priority: models.PriorityLow, DueDate: &due}); err != nil {
+			t.Fatal(err)
+		}
+		before, err := r.Tr.CountOverdue(ctx, at(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Ur.DeleteUser(ctx, int64(u.ID), true); err != nil {
+			t.Fatal(err)
+		}
+		after, err := r.Tr.CountOverdue(ctx, at(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after != before {
+			t.Errorf("anonymized tasks should be kept, overdue count went from %d to %d", before, after)
+		}
+	})
+}
+
+func testTasks(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("store, list and show", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		uCtx := userContext(u)
+		due := at(24 * time.Hour)
+		created := at(0)
+		var stored []models.Task
+		for _, name := range []string{"first", "second"} {
+			p := models.TaskPayload{Name: name, Priority: models.PriorityHigh, Description: "desc", DueDate: &due, CreatedAt: &created}
+			task, err := r.Tr.Store(uCtx, p)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if task.ID == 0 || task.Name != name || task.CreatedBy != int64(u.ID) {
+				t.Errorf("unexpected stored task %+v", task)
+			}
+			stored = append(stored, task)
+		}
+
+		l, err := r.Tr.Index(uCtx, int64(u.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Tasks) != 2 {
+			t.Fatalf("expected 2 tasks but got %d", len(l.Tasks))
+		}
+
+		got, err := r.Tr.Show(uCtx, stored[0].ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != stored[0].Name || got.Priority != models.PriorityHigh || got.Description != "desc" || got.CreatedBy != int64(u.ID) {
+			t.Errorf("unexpected task %+v", got)
+		}
+		expectTime(t, "due_date", got.DueDate, due)
+		expectTime(t, "created_at", got.CreatedAt, created)
+	})
+
+	t.Run("store without user", func(t *testing.T) {
+		r := newRepos(t)
+		if _, err := r.Tr.Store(ctx, models.TaskPayload{Name: "Task"}); err == nil {
+			t.Errorf("storing a task without a user should fail")
+		}
+	})
+
+	t.Run("unknown task", func(t *testing.T) {
+		r := newRepos(t)
+		if _, err := r.Tr.Show(ctx, 1<<30); err == nil {
+			t.Errorf("showing an unknown task should fail")
+		}
+	})
+
+	t.Run("update, ownership and delete", func(t *testing.T) {
+		r := newRepos(t)
+		owner := createUser(t, r)
+		other := createUser(t, r)
+		oCtx := userContext(owner)
+		if _, err := r.Tr.Store(oCtx, models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+		l, err := r.Tr.Index(oCtx, int64(owner.ID))
+		if err != nil || len(l.Tasks) != 1 {
+			t.Fatalf("expected 1 task but got %v, %v", l, err)
+		}
+		id := l.Tasks[0].ID
+
+		isOwner, err := r.Tr.IsTaskOwner(ctx, int64(owner.ID), id)
+		if err != nil || !isOwner {
+			t.Errorf("owner should own the task, got %v, %v", isOwner, err)
+		}
+		isOwner, err = r.Tr.IsTaskOwner(ctx, int64(other.ID), id)
+		if err != nil || isOwner {
+			t.Errorf("other user should not own the task, got %v, %v", isOwner, err)
+		}
+
+		due := at(time.Hour)
+		p := models.UpdateTask{ID: int64(id), Name: "Renamed", Priority: models.PriorityMedium, Description: "updated", DueDate: &due}
+		if _, err := r.Tr.Update(userContext(other), p); err == nil {
+			t.Errorf("updating the task of another user should fail")
+		}
+		updated, err := r.Tr.Update(oCtx, p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if updated.Name != "Renamed" || updated.Priority != models.PriorityMedium || updated.Description != "updated" {
+			t.Errorf("unexpected updated task %+v", updated)
+		}
+		got, err := r.Tr.Show(oCtx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Name != "Renamed" {
+			t.Errorf("update was not stored, got %+v", got)
+		}
+		expectTime(t, "due_date", got.DueDate, due)
+
+		if err := r.Tr.Delete(oCtx, id); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Tr.Show(oCtx, id); err == nil {
+			t.Errorf("deleted task should not be found")
+		}
+	})
+
+	t.Run("trash", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		uCtx := userContext(u)
+		overdue, err := r.Tr.CountOverdue(ctx, at(0))
+		if err != nil {
+			t.Fatal(err)
+		}
+		due := at(-time.Hour)
+		task, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Trashed", Priority: models.PriorityLow, DueDate: &due})
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Tr.Delete(uCtx, task.ID); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Tr.Delete(uCtx, task.ID); err != nil {
+			t.Errorf("deleting a trashed task again should do nothing, got %v", err)
+		}
+		if _, err := r.Tr.Show(uCtx, task.ID); err == nil {
+			t.Errorf("trashed task should not be found")
+		}
+		if l, err := r.Tr.Index(uCtx, int64(u.ID)); err != nil || len(l.Tasks) != 0 {
+			t.Errorf("trashed task should not be listed, got %+v, %v", l.Tasks, err)
+		}
+		if isOwner, err := r.Tr.IsTaskOwner(ctx, int64(u.ID), task.ID); err != nil || isOwner {
+			t.Errorf("trashed task should not be owned, got %v, %v", isOwner, err)
+		}
+		if _, err := r.Tr.Update(uCtx, models.UpdateTask{ID: int64(task.ID), Name: "Renamed", Priority: models.PriorityLow}); err == nil {
+			t.Errorf("updating a trashed task should fail")
+		}
+		if n, err := r.Tr.CountOverdue(ctx, at(0)); err != nil || n != overdue {
+			t.Errorf("trashed task should not be overdue, count went from %d to %d, %v", overdue, n, err)
+		}
+
+		n, err := r.Tr.PurgeTrash(ctx, time.Now().Add(-time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n != 0 {
+			t.Errorf("tasks trashed after the cutoff should be kept, purged %d", n)
+		}
+		n, err = r.Tr.PurgeTrash(ctx, time.Now().Add(time.Minute))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 1 {
+			t.Errorf("expected the trashed task to be purged, purged %d", n)
+		}
+		if n, err := r.Tr.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil || n != 0 {
+			t.Errorf("the trash should be empty, purged %d, %v", n, err)
+		}
+	})
+
+	t.Run("count overdue", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		now := at(0)
+		before, err := r.Tr.CountOverdue(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		past, future := at(-time.Minute), at(time.Minute)
+		for _, due := range []*time.Time{&past, &future, nil} {
+			if _, err := r.Tr.Store(userContext(u), models.TaskPayload{Name: "Task", DueDate: due}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		after, err := r.Tr.CountOverdue(ctx, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if after-before != 1 {
+			t.Errorf("expected 1 more overdue task but got %d", after-before)
+		}
+	})
+}
+
+func testTokens(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	now := at(0)
+
+	t.Run("consume once", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		hash := uniqueValue("hash")
+		if err := r.Tk.Store(ctx, models.UserToken{UserID: int64(u.ID), Purpose: "verify", Hash: hash, ExpiresAt: now.Add(time.Hour), CreatedAt: now}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := r.Tk.Consume(ctx, "reset", hash, now)
+		expectNotFound(t, err)
+
+		uID, err := r.Tk.Consume(ctx, "verify", hash, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uID != int64(u.ID) {
+			t.Errorf("expected user %d but got %d", u.ID, uID)
+		}
+
+		_, err = r.Tk.Consume(ctx, "verify", hash, now)
+		expectNotFound(t, err)
+	})
+
+	t.Run("expired", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		hash := uniqueValue("hash")
+		if err := r.Tk.Store(ctx, models.UserToken{UserID: int64(u.ID), Purpose: "verify", Hash: hash, ExpiresAt: now.Add(-time.Second), CreatedAt: now.Add(-time.Hour)}); err != nil {
+			t.Fatal(err)
+		}
+
+		_, err := r.Tk.Consume(ctx, "verify", hash, now)
+		expectNotFound(t, err)
+	})
+
+	t.Run("delete for user", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		verify, reset := uniqueValue("hash"), uniqueValue("hash")
+		for h, purpose := range map[string]string{verify: "verify", reset: "reset"} {
+			if err := r.Tk.Store(ctx, models.UserToken{UserID: int64(u.ID), Purpose: purpose, Hash: h, ExpiresAt: now.Add(time.Hour), CreatedAt: now}); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if err := r.Tk.DeleteForUser(ctx, int64(u.ID), "verify"); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.Tk.Consume(ctx, "verify", verify, now)
+		expectNotFound(t, err)
+		if _, err := r.Tk.Consume(ctx, "reset", reset, now); err != nil {
+			t.Errorf("tokens of other purposes should be kept: %s", err)
+		}
+	})
+
+	t.Run("duplicate hash", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		tk := models.UserToken{UserID: int64(u.ID), Purpose: "verify", Hash: uniqueValue("hash"), ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+		if err := r.Tk.Store(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Tk.Store(ctx, tk); err == nil {
+			t.Errorf("storing the same token hash twice should fail")
+		}
+	})
+}
+
+func testMFA(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	now := at(0)
+
+	t.Run("enrol and confirm", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		id := int64(u.ID)
+
+		_, err := r.Mf.GetTOTP(ctx, id)
+		expectNotFound(t, err)
+
+		if err := r.Mf.UpsertTOTP(ctx, id, "first", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.UpsertTOTP(ctx, id, "second", now); err != nil {
+			t.Fatal(err)
+		}
+		got, err := r.Mf.GetTOTP(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.UserID != id || got.Secret != "second" || got.ConfirmedAt != nil || got.LastStep != nil {
+			t.Errorf("a pending secret should be replaced, got %+v", got)
+		}
+
+		if err := r.Mf.ConfirmTOTP(ctx, id, 100, []string{"code-a", "code-b"}, now); err != nil {
+			t.Fatal(err)
+		}
+		expectNotFound(t, r.Mf.ConfirmTOTP(ctx, id, 101, nil, now))
+		if err := r.Mf.UpsertTOTP(ctx, id, "third", now); err != nil {
+			t.Fatal(err)
+		}
+
+		got, err = r.Mf.GetTOTP(ctx, id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Secret != "second" || got.LastStep == nil || *got.LastStep != 100 {
+			t.Errorf("a confirmed secret should be kept, got %+v", got)
+		}
+		expectTime(t, "confirmed_at", got.ConfirmedAt, now)
+	})
+
+	t.Run("confirm without enrolment", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		expectNotFound(t, r.Mf.ConfirmTOTP(ctx, int64(u.ID), 1, nil, now))
+	})
+
+	t.Run("steps and recovery codes are used once", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		id := int64(u.ID)
+		if err := r.Mf.UpsertTOTP(ctx, id, "secret", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.ConfirmTOTP(ctx, id, 100, []string{"code-a", "code-b"}, now); err != nil {
+			t.Fatal(err)
+		}
+
+		var steps = []struct {
+			step     int64
+			expected bool
+		}{
+			{100, false},
+			{99, false},
+			{101, true},
+			{101, false},
+		}
+		for _, s := range steps {
+			ok, err := r.Mf.UseTOTPStep(ctx, id, s.step)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != s.expected {
+				t.Errorf("step %d: expected %v but got %v", s.step, s.expected, ok)
+			}
+		}
+
+		var codes = []struct {
+			hash     string
+			expected bool
+		}{
+			{"code-a", true},
+			{"code-a", false},
+			{"unknown", false},
+			{"code-b", true},
+		}
+		for _, c := range codes {
+			ok, err := r.Mf.UseRecoveryCode(ctx, id, c.hash, now)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if ok != c.expected {
+				t.Errorf("code %s: expected %v but got %v", c.hash, c.expected, ok)
+			}
+		}
+	})
+
+	t.Run("confirm replaces recovery codes", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		id := int64(u.ID)
+		if err := r.Mf.UpsertTOTP(ctx, id, "secret", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.ConfirmTOTP(ctx, id, 1, []string{"old"}, now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.DeleteTOTP(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.UpsertTOTP(ctx, id, "secret", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.ConfirmTOTP(ctx, id, 1, []string{"new"}, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if ok, err := r.Mf.UseRecoveryCode(ctx, id, "old", now); err != nil || ok {
+			t.Errorf("codes of a previous enrolment should be gone, got %v, %v", ok, err)
+		}
+		if ok, err := r.Mf.UseRecoveryCode(ctx, id, "new", now); err != nil || !ok {
+			t.Errorf("new code should be accepted, got %v, %v", ok, err)
+		}
+	})
+
+	t.Run("disable", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		id := int64(u.ID)
+		if err := r.Mf.UpsertTOTP(ctx, id, "secret", now); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Mf.ConfirmTOTP(ctx, id, 1, []string{"code"}, now); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := r.Mf.DeleteTOTP(ctx, id); err != nil {
+			t.Fatal(err)
+		}
+		_, err := r.Mf.GetTOTP(ctx, id)
+		expectNotFound(t, err)
+		if ok, err := r.Mf.UseRecoveryCode(ctx, id, "code", now); err != nil || ok {
+			t.Errorf("recovery codes should be deleted, got %v, %v", ok, err)
+		}
+	})
+}
+
+func testIdentities(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	now := at(0)
+
+	t.Run("link", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		subject := uniqueValue("subject")
+
+		_, err := r.Id.GetUserID(ctx, "https://issuer.example.com", subject)
+		expectNotFound(t, err)
+
+		if err := r.Id.Link(ctx, int64(u.ID), "https://issuer.example.com", subject, now); err != nil {
+			t.Fatal(err)
+		}
+		uID, err := r.Id.GetUserID(ctx, "https://issuer.example.com", subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uID != int64(u.ID) {
+			t.Errorf("expected user %d but got %d", u.ID, uID)
+		}
+
+		other := createUser(t, r)
+		if err := r.Id.Link(ctx, int64(other.ID), "https://issuer.example.com", subject, now); err == nil {
+			t.Errorf("linking the same identity twice should fail")
+		}
+	})
+
+	t.Run("provision", func(t *testing.T) {
+		r := newRepos(t)
+		subject := uniqueValue("subject")
+		email := uniqueEmail()
+
+		u, err := r.Id.Provision(ctx, models.CreateUserPayload{Name: "Provisioned", Email: email, Password: "hash"}, "https://issuer.example.com", subject, now)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if u.ID == 0 || u.Email != email || u.Name != "Provisioned" {
+			t.Errorf("unexpected user %+v", u)
+		}
+		expectTime(t, "email_verified_at", u.EmailVerifiedAt, now)
+
+		uID, err := r.Id.GetUserID(ctx, "https://issuer.example.com", subject)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if uID != int64(u.ID) {
+			t.Errorf("expected user %d but got %d", u.ID, uID)
+		}
+	})
+
+	t.Run("provision with taken email", func(t *testing.T) {
+		r := newRepos(t)
+		existing := createUser(t, r)
+		subject := uniqueValue("subject")
+
+		if _, err := r.Id.Provision(ctx, models.CreateUserPayload{Name: "Provisioned", Email: existing.Email, Password: "hash"}, "https://issuer.example.com", subject, now); err == nil {
+			t.Fatalf("provisioning with a taken email should fail")
+		}
+		_, err := r.Id.GetUserID(ctx, "https://issuer.example.com", subject)
+		expectNotFound(t, err)
+	})
+}
+
+func testUnitOfWork(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+	errAbort := errors.New("abort")
+
+	// write creates a user with a task and a token, calling every repository involved with ctx.
+	write := func(ctx context.Context, r repository.Repositories, email, hash string) error {
+		if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Unit", Email: email, Password: "hash"}); err != nil {
+			return err
+		}
+		u, err := r.Ur.GetUserByEmail(ctx, email)
+		if err != nil {
+			return err
+		}
+		uCtx := context.WithValue(ctx, contextkeys.UserID, int64(u.ID))
+		if _, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			return err
+		}
+		return r.Tk.Store(ctx, models.UserToken{UserID: int64(u.ID), Purpose: "verify", Hash: hash, ExpiresAt: at(time.Hour), CreatedAt: at(0)})
+	}
+
+	t.Run("commit", func(t *testing.T) {
+		r := newRepos(t)
+		email, hash := uniqueEmail(), uniqueValue("hash")
+
+		if err := r.Uw.Do(ctx, func(ctx context.Context) error { return write(ctx, r, email, hash) }); err != nil {
+			t.Fatal(err)
+		}
+		u, err := r.Ur.GetUserByEmail(ctx, email)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := r.Tr.Index(ctx, int64(u.ID))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(l.Tasks) != 1 {
+			t.Errorf("expected the task to be committed, got %d tasks", len(l.Tasks))
+		}
+		if _, err := r.Tk.Consume(ctx, "verify", hash, at(0)); err != nil {
+			t.Errorf("expected the token to be committed but got %v", err)
+		}
+	})
+
+	t.Run("rollback spans repositories", func(t *testing.T) {
+		r := newRepos(t)
+		email, hash := uniqueEmail(), uniqueValue("hash")
+
+		err := r.Uw.Do(ctx, func(ctx context.Context) error {
+			if err := write(ctx, r, email, hash); err != nil {
+				return err
+			}
+			return errAbort
+		})
+		if !errors.Is(err, errAbort) {
+			t.Fatalf("expected the error of fn but got %v", err)
+		}
+		if exists, err := r.Ur.CheckIfEmailExists(ctx, email); err != nil || exists {
+			t.Errorf("the user should be rolled back, exists %v, err %v", exists, err)
+		}
+		_, err = r.Tk.Consume(ctx, "verify", hash, at(0))
+		expectNotFound(t, err)
+
+		// the store has to stay usable after a rollback
+		other := createUser(t, r)
+		if _, err := r.Tr.Store(userContext(other), models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+	})
+
+	t.Run("failed call inside a unit", func(t *testing.T) {
+		r := newRepos(t)
+		existing := createUser(t, r)
+		email := uniqueEmail()
+
+		err := r.Uw.Do(ctx, func(ctx context.Context) error {
+			if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Unit", Email: email, Password: "hash"}); err != nil {
+				return err
+			}
+			if err := r.Ur.CreateUser(ctx, models.CreateUserPayload{Name: "Unit", Email: existing.Email, Password: "hash"}); err == nil {
+				return errors.New("creating a user with a taken email should fail")
+			}
+			return r.Uw.Do(ctx, func(ctx context.Context) error {
+				_, err := r.Ur.GetUserByEmail(ctx, email)
+				return err
+			})
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if exists, _ := r.Ur.CheckIfEmailExists(ctx, email); !exists {
+			t.Errorf("writes before a failed call should be committed")
+		}
+	})
+}
+
+func testEvents(t *testing.T, newRepos Factory) {
+	ctx := context.Background()
+
+	t.Run("task changes are recorded", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		other := createUser(t, r)
+		uCtx := userContext(u)
+		last, err := r.Ev.LastID(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Tr.Store(userContext(other), models.TaskPayload{Name: "Other", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+		l, err := r.Tr.Index(uCtx, int64(u.ID))
+		if err != nil || len(l.Tasks) != 1 {
+			t.Fatalf("expected one task but got %v, %v", l.Tasks, err)
+		}
+		id := l.Tasks[0].ID
+		if _, err := r.Tr.Update(uCtx, models.UpdateTask{ID: int64(id), Name: "Renamed", Priority: models.PriorityHigh}); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Tr.Delete(uCtx, id); err != nil {
+			t.Fatal(err)
+		}
+
+		events, _, err := r.Ev.After(ctx, int64(u.ID), last, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		types := []string{models.TaskEventCreated, models.TaskEventUpdated, models.TaskEventDeleted}
+		if len(events) != len(types) {
+			t.Fatalf("expected %d events but got %+v", len(types), events)
+		}
+		for i, e := range events {
+			if e.Type != types[i] || e.TaskID != id || e.UserID != int64(u.ID) || e.CreatedAt.IsZero() {
+				t.Errorf("unexpected event %d: %+v", i, e)
+			}
+			if i > 0 && e.ID <= events[i-1].ID {
+				t.Errorf("event ids should increase, got %d after %d", e.ID, events[i-1].ID)
+			}
+		}
+
+		rest, _, err := r.Ev.After(ctx, int64(u.ID), events[0].ID, 1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(rest) != 1 || rest[0].ID != events[1].ID {
+			t.Errorf("expected the event after the first one but got %+v", rest)
+		}
+		newest, err := r.Ev.LastID(ctx)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if newest < events[2].ID {
+			t.Errorf("the last id should be at least %d, got %d", events[2].ID, newest)
+		}
+	})
+
+	t.Run("purging the trash is not announced", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		uCtx := userContext(u)
+		task, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Task", Priority: models.PriorityLow})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Tr.Delete(uCtx, task.ID); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := r.Tr.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil {
+			t.Fatal(err)
+		}
+
+		events, _, err := r.Ev.After(ctx, int64(u.ID), 0, 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(events) != 2 || events[0].Type != models.TaskEventCreated || events[1].Type != models.TaskEventDeleted {
+			t.Errorf("expected the task to be created and deleted once but got %+v", events)
+		}
+	})
+
+	t.Run("delete before", func(t *testing.T) {
+		r := newRepos(t)
+		u := createUser(t, r)
+		if _, err := r.Tr.Store(userContext(u), models.TaskPayload{Name: "Task", Priority: models.PriorityLow}); err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := r.Ev.DeleteBefore(ctx, time.Now().Add(-time.Hour)); err != nil {
+			t.Fatal(err)
+		}
+		events, _, _ := r.Ev.After(ctx, int64(u.ID), 0, 10)
+		if len(events) != 1 {
+			t.Fatalf("recent events should be kept, got %+v", events)
+		}
+
+		n, err := r.Ev.DeleteBefore(ctx, time.Now().Add(time.Hour))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n < 1 {
+			t.Errorf("expected old events to be deleted, got %d", n)
+		}
+		events, _, _ = r.Ev.After(ctx, int64(u.ID), 0, 10)
+		if len(events) != 0 {
+			t.Errorf("expected no events but got %+v", events)
+		}
+	})
+}
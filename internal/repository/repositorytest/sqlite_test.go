@@ -0,0 +1,28 @@
+package repositorytest_test
+
+import (
+	"context"
+	"path/filepath"
+	"task-manager/internal/config"
+	"task-manager/internal/db"
+	"task-manager/internal/repository"
+	"task-manager/internal/repository/repositorytest"
+	"testing"
+)
+
+func TestRun_sqlite(t *testing.T) {
+	repositorytest.Run(t, func(t *testing.T) repository.Repositories {
+		d, err := db.Open(config.DBConfig{Driver: config.DBDriverSQLite, Path: filepath.Join(t.TempDir(), "test.db")})
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() {
+			_ = d.Close()
+		})
+		if err := d.RunMigrations(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+
+		return repository.New(*d)
+	})
+}
@@ -8,15 +8,22 @@ import (
 	"task-manager/internal/contextkeys"
 	"task-manager/internal/db"
 	"task-manager/internal/models"
+	"time"
 )
 
 type TaskRepository interface {
-	Store(ctx context.Context, p models.TaskPayload) error
+	Store(ctx context.Context, p models.TaskPayload) (models.Task, error)
 	Update(ctx context.Context, p models.UpdateTask) (models.Task, error)
-	Show(id int) (models.Task, error)
-	Index(uID int64) (models.TasksList, error)
-	Delete(id int) error
-	IsTaskOwner(uID int64, id int) (bool, error)
+	Show(ctx context.Context, id int) (models.Task, error)
+	Index(ctx context.Context, uID int64) (models.TasksList, error)
+	// Delete moves the task to the trash, where it is left out of every other method until
+	// PurgeTrash removes it for good.
+	Delete(ctx context.Context, id int) error
+	// PurgeTrash removes the tasks moved to the trash before the given time and returns how
+	// many there were.
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error)
+	CountOverdue(ctx context.Context, now time.Time) (int64, error)
 }
 
 type taskRepository struct {
@@ -29,21 +36,23 @@ func NewTaskRepository(d db.DB) TaskRepository {
 	}
 }
 
-func (r taskRepository) Store(ctx context.Context, p models.TaskPayload) error {
-	q, err := db.GetQuery("queries/task/InsertTask.sql")
+func (r taskRepository) Store(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+	q, err := r.d.GetQuery("queries/task/InsertTask.sql")
 	if err != nil {
-		return fmt.Errorf("store: failed to read query: %v", err)
+		return models.Task{}, fmt.Errorf("store: failed to read query: %v", err)
 	}
 	uID, ok := ctx.Value(contextkeys.UserID).(int64)
 	if !ok || uID == 0 {
-		return fmt.Errorf("store: failed to get user id")
+		return models.Task{}, fmt.Errorf("store: failed to get user id")
 	}
 
 	tx, err := r.d.BeginTx(ctx, nil)
 	if err != nil {
-		return fmt.Errorf("store: failed to begin tx: %v", err)
+		return models.Task{}, fmt.Errorf("store: failed to begin tx: %v", err)
 	}
-	_, err = tx.ExecContext(
+	var t models.Task
+	var desc sql.NullString
+	err = tx.QueryRowContext(
 		ctx,
 		q,
 		p.Name,
@@ -52,29 +61,32 @@ func (r taskRepository) Store(ctx context.Context, p models.TaskPayload) error {
 		p.DueDate,
 		p.CreatedAt,
 		uID,
-	)
+	).Scan(&t.ID, &t.Name, &t.Priority, &desc, &t.DueDate, &t.CreatedAt, &t.CreatedBy)
 	if err != nil {
 		_ = tx.Rollback()
-		return fmt.Errorf("store: failed to insert a new task: %v", err)
+		return models.Task{}, fmt.Errorf("store: failed to insert a new task: %v", err)
+	}
+	if desc.Valid {
+		t.Description = desc.String
 	}
 
 	if err := tx.Commit(); err != nil {
-		return fmt.Errorf("store: failed to commit tx: %v", err)
+		return models.Task{}, fmt.Errorf("store: failed to commit tx: %v", err)
 	}
 
-	return nil
+	return t, nil
 }
 func (r taskRepository) Update(ctx context.Context, p models.UpdateTask) (models.Task, error) {
-	q, err := db.GetQuery("queries/task/GetTask.sql")
+	q, err := r.d.GetQuery("queries/task/GetTask.sql")
 	if err != nil {
 		return models.Task{}, fmt.Errorf("update: failed to read query: %v", err)
 	}
 	var t models.Task
 	var desc sql.NullString
 
-	q = fmt.Sprintf("%s for update", q)
+	q = r.d.ForUpdate(q)
 
-	if err := r.d.QueryRow(q, p.ID).Scan(&t.ID, &t.Name, &t.Priority, &desc, &t.DueDate, &t.CreatedAt, &t.CreatedBy); err != nil {
+	if err := r.d.QueryRowContext(ctx, q, p.ID).Scan(&t.ID, &t.Name, &t.Priority, &desc, &t.DueDate, &t.CreatedAt, &t.CreatedBy); err != nil {
 		return models.Task{}, fmt.Errorf("update: failed to get task from db: %v", err)
 	}
 	if desc.Valid {
@@ -91,7 +103,7 @@ func (r taskRepository) Update(ctx context.Context, p models.UpdateTask) (models
 		return models.Task{}, fmt.Errorf("user not authorized for this action")
 	}
 
-	uq, err := db.GetQuery("queries/task/UpdateTask.sql")
+	uq, err := r.d.GetQuery("queries/task/UpdateTask.sql")
 	if err != nil {
 		return models.Task{}, fmt.Errorf("update: failed to read update query, %v", err)
 	}
@@ -120,15 +132,15 @@ func (r taskRepository) Update(ctx context.Context, p models.UpdateTask) (models
 	}
 	return t, nil
 }
-func (r taskRepository) Show(id int) (models.Task, error) {
-	q, err := db.GetQuery("queries/task/GetTask.sql")
+func (r taskRepository) Show(ctx context.Context, id int) (models.Task, error) {
+	q, err := r.d.GetQuery("queries/task/GetTask.sql")
 	if err != nil {
 		return models.Task{}, fmt.Errorf("show: failed to read query:%v", err)
 	}
 	var t models.Task
 	var desc sql.NullString
 
-	err = r.d.QueryRow(q, id).Scan(&t.ID, &t.Name, &t.Priority, &desc, &t.DueDate, &t.CreatedAt, &t.CreatedBy)
+	err = r.d.QueryRowContext(ctx, q, id).Scan(&t.ID, &t.Name, &t.Priority, &desc, &t.DueDate, &t.CreatedAt, &t.CreatedBy)
 	if errors.Is(err, sql.ErrNoRows) {
 		return models.Task{}, fmt.Errorf("no results for given ID")
 	}
@@ -141,14 +153,17 @@ func (r taskRepository) Show(id int) (models.Task, error) {
 
 	return t, nil
 }
-func (r taskRepository) Index(uID int64) (models.TasksList, error) {
-	q, err := db.GetQuery("queries/task/GetTasksList.sql")
+
+// Index reads from a replica when one is configured, so a task stored a moment ago may be
+// missing until the replica catches up.
+func (r taskRepository) Index(ctx context.Context, uID int64) (models.TasksList, error) {
+	q, err := r.d.GetQuery("queries/task/GetTasksList.sql")
 	if err != nil {
 		return models.TasksList{}, fmt.Errorf("index: failed to read query: %v", err)
 	}
 	var l models.TasksList
 
-	rows, err := r.d.Query(q, uID)
+	rows, err := r.d.Reader().QueryContext(ctx, q, uID)
 	if err != nil {
 		return models.TasksList{}, fmt.Errorf("index: failed to execute query: %v", err)
 	}
@@ -173,30 +188,62 @@ func (r taskRepository) Index(uID int64) (models.TasksList, error) {
 
 	return l, nil
 }
-func (r taskRepository) Delete(id int) error {
-	q, err := db.GetQuery("queries/task/DeleteTask.sql")
+func (r taskRepository) Delete(ctx context.Context, id int) error {
+	q, err := r.d.GetQuery("queries/task/DeleteTask.sql")
 	if err != nil {
 		return fmt.Errorf("delete: failed to read query:%v", err)
 	}
 
-	_, err = r.d.Exec(q, id)
+	_, err = r.d.ExecContext(ctx, q, id, time.Now())
 	if err != nil {
 		return fmt.Errorf("delete: failed to execute query:%v", err)
 	}
 
 	return nil
 }
-func (r taskRepository) IsTaskOwner(uID int64, id int) (bool, error) {
-	q, err := db.GetQuery("queries/task/GetTaskOwner.sql")
+func (r taskRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	q, err := r.d.GetQuery("queries/task/PurgeDeletedTasks.sql")
+	if err != nil {
+		return 0, fmt.Errorf("purgeTrash: failed to read query: %v", err)
+	}
+
+	res, err := r.d.ExecContext(ctx, q, before)
+	if err != nil {
+		return 0, fmt.Errorf("purgeTrash: failed to execute query: %v", err)
+	}
+	n, err := res.RowsAffected()
+	if err != nil {
+		return 0, fmt.Errorf("purgeTrash: %v", err)
+	}
+
+	return n, nil
+}
+func (r taskRepository) IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error) {
+	q, err := r.d.GetQuery("queries/task/GetTaskOwner.sql")
 	if err != nil {
 		return false, fmt.Errorf("isTaskOwner: failed to read query: %v", err)
 	}
 
 	var isOwner bool
 
-	if err := r.d.QueryRow(q, uID, id).Scan(&isOwner); err != nil {
+	if err := r.d.QueryRowContext(ctx, q, uID, id).Scan(&isOwner); err != nil {
 		return false, fmt.Errorf("isTaskOwner: failed to execute query: %v", err)
 	}
 
 	return isOwner, nil
 }
+
+// CountOverdue reads from a replica when one is configured.
+func (r taskRepository) CountOverdue(ctx context.Context, now time.Time) (int64, error) {
+	q, err := r.d.GetQuery("queries/task/CountOverdueTasks.sql")
+	if err != nil {
+		return 0, fmt.Errorf("countOverdue: failed to read query: %v", err)
+	}
+
+	var n int64
+	if err := r.d.Reader().QueryRowContext(ctx, q, now).Scan(&n); err != nil {
+		return 0, fmt.Errorf("countOverdue: failed to execute query: %v", err)
+	}
+
+	return n, nil
+}
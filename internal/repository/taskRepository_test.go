@@ -13,7 +13,7 @@ import (
 
 func storeTask(t *testing.T, r TaskRepository, p models.TaskPayload, ctx context.Context) error {
 	t.Helper()
-	err := r.Store(ctx, p)
+	_, err := r.Store(ctx, p)
 
 	return err
 }
@@ -253,7 +253,7 @@ func TestTaskRepository_Show(t *testing.T) {
 				_ = storeTask(t, taskRepo, p, ctx)
 			}
 
-			task, err := taskRepo.Show(tc.taskID)
+			task, err := taskRepo.Show(context.Background(), tc.taskID)
 			if tc.expectsError {
 				if err == nil {
 					t.Error("function was supposed to return an error but it did not")
@@ -397,7 +397,7 @@ func TestTaskRepository_Index(t *testing.T) {
 				}
 			}
 
-			taskList, err := taskRepo.Index(tc.userID)
+			taskList, err := taskRepo.Index(context.Background(), tc.userID)
 			if tc.expectsError {
 				if err == nil {
 					t.Error("function was supposed to return an error but it did not")
@@ -462,7 +462,7 @@ func TestTaskRepository_Delete(t *testing.T) {
 				_ = storeTask(t, taskRepo, p, ctx)
 			}
 
-			err := taskRepo.Delete(tc.taskID)
+			err := taskRepo.Delete(context.Background(), tc.taskID)
 
 			log.Println(err)
 		})
@@ -520,7 +520,7 @@ func TestTaskRepository_IsTaskOwner(t *testing.T) {
 
 			_ = storeTask(t, taskRepo, p, ctx)
 
-			result, err := taskRepo.IsTaskOwner(tc.userID, tc.taskID)
+			result, err := taskRepo.IsTaskOwner(context.Background(), tc.userID, tc.taskID)
 			if err != nil {
 				t.Errorf("unexpected error: %s", err)
 			}
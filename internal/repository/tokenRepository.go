@@ -0,0 +1,71 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"task-manager/internal/db"
+	"task-manager/internal/models"
+	"time"
+)
+
+type TokenRepository interface {
+	Store(ctx context.Context, t models.UserToken) error
+	Consume(ctx context.Context, purpose, hash string, now time.Time) (int64, error)
+	DeleteForUser(ctx context.Context, uID int64, purpose string) error
+}
+
+type tokenRepository struct {
+	d db.DB
+}
+
+func NewTokenRepository(d db.DB) TokenRepository {
+	return &tokenRepository{d: d}
+}
+
+func (r tokenRepository) Store(ctx context.Context, t models.UserToken) error {
+	q, err := r.d.GetQuery("queries/token/InsertToken.sql")
+	if err != nil {
+		return fmt.Errorf("store: failed to read query: %v", err)
+	}
+
+	if _, err := r.d.ExecContext(ctx, q, t.UserID, t.Purpose, t.Hash, t.ExpiresAt, t.CreatedAt); err != nil {
+		return fmt.Errorf("store: failed to insert token: %v", err)
+	}
+
+	return nil
+}
+
+// Consume marks the unused, unexpired token with the given hash as used and returns its owner.
+// It is a single statement, so two concurrent requests cannot both use the same token.
+func (r tokenRepository) Consume(ctx context.Context, purpose, hash string, now time.Time) (int64, error) {
+	q, err := r.d.GetQuery("queries/token/ConsumeToken.sql")
+	if err != nil {
+		return 0, fmt.Errorf("consume: failed to read query: %v", err)
+	}
+
+	var uID int64
+	err = r.d.QueryRowContext(ctx, q, hash, purpose, now).Scan(&uID)
+	if errors.Is(err, sql.ErrNoRows) {
+		return 0, fmt.Errorf("consume: %w", ErrNotFound)
+	}
+	if err != nil {
+		return 0, fmt.Errorf("consume: failed to execute query: %v", err)
+	}
+
+	return uID, nil
+}
+
+func (r tokenRepository) DeleteForUser(ctx context.Context, uID int64, purpose string) error {
+	q, err := r.d.GetQuery("queries/token/DeleteUserTokens.sql")
+	if err != nil {
+		return fmt.Errorf("deleteForUser: failed to read query: %v", err)
+	}
+
+	if _, err := r.d.ExecContext(ctx, q, uID, purpose); err != nil {
+		return fmt.Errorf("deleteForUser: failed to execute query: %v", err)
+	}
+
+	return nil
+}
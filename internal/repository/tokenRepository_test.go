@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"errors"
+	"task-manager/internal/models"
+	"testing"
+	"time"
+)
+
+func TestTokenRepository_Consume(t *testing.T) {
+	testCreateUser(t, *testDB)
+	ctx := context.Background()
+	u, err := NewUserRepository(*testDB).GetUserByEmail(ctx, "lorem@ipsum.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	uID := int64(u.ID)
+
+	tokenRepo := NewTokenRepository(*testDB)
+	now := time.Now()
+	for _, tk := range []models.UserToken{
+		{UserID: uID, Purpose: models.TokenPurposeVerifyEmail, Hash: "valid-hash", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+		{UserID: uID, Purpose: models.TokenPurposeVerifyEmail, Hash: "expired-hash", ExpiresAt: now.Add(-time.Minute), CreatedAt: now},
+		{UserID: uID, Purpose: models.TokenPurposeResetPassword, Hash: "reset-hash", ExpiresAt: now.Add(time.Hour), CreatedAt: now},
+	} {
+		if err := tokenRepo.Store(ctx, tk); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	var tests = []struct {
+		name         string
+		purpose      string
+		hash         string
+		expectsError bool
+	}{
+		{"valid token", models.TokenPurposeVerifyEmail, "valid-hash", false},
+		{"token already used", models.TokenPurposeVerifyEmail, "valid-hash", true},
+		{"expired token", models.TokenPurposeVerifyEmail, "expired-hash", true},
+		{"wrong purpose", models.TokenPurposeVerifyEmail, "reset-hash", true},
+		{"unknown token", models.TokenPurposeVerifyEmail, "unknown-hash", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := tokenRepo.Consume(ctx, tc.purpose, tc.hash, time.Now())
+			if tc.expectsError {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("expected ErrNotFound but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if got != uID {
+				t.Errorf("expected user %d but got %d", uID, got)
+			}
+		})
+	}
+
+	if err := tokenRepo.DeleteForUser(ctx, uID, models.TokenPurposeResetPassword); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := tokenRepo.Consume(ctx, models.TokenPurposeResetPassword, "reset-hash", time.Now()); !errors.Is(err, ErrNotFound) {
+		t.Errorf("deleted token should not be consumable, got %v", err)
+	}
+}
@@ -0,0 +1,27 @@
+package repository
+
+import (
+	"context"
+	"task-manager/internal/db"
+)
+
+// UnitOfWork runs several repository calls atomically. Every call made with the context passed
+// to fn takes part in one transaction, which is committed when fn returns nil and rolled back
+// otherwise. fn may run more than once when the database aborts the transaction because of a
+// conflict with another one, so it must not have side effects outside the repositories, like
+// sending mail. Do called inside fn joins the enclosing unit.
+type UnitOfWork interface {
+	Do(ctx context.Context, fn func(ctx context.Context) error) error
+}
+
+type unitOfWork struct {
+	d db.DB
+}
+
+func NewUnitOfWork(d db.DB) UnitOfWork {
+	return &unitOfWork{d: d}
+}
+
+func (u unitOfWork) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return u.d.Transact(ctx, nil, fn)
+}
@@ -3,18 +3,28 @@ package repository
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"task-manager/internal/db"
-	"task-manager/internal/helpers"
 	"task-manager/internal/models"
 	"time"
 )
 
 type UserRepository interface {
 	CreateUser(ctx context.Context, r models.CreateUserPayload) error
-	CheckIfEmailExists(email string) (bool, error)
-	GetUserData(p models.LoginPayload) (models.User, error)
+	CheckIfEmailExists(ctx context.Context, email string) (bool, error)
+	GetUserByEmail(ctx context.Context, email string) (models.User, error)
+	GetUserByID(ctx context.Context, id int64) (models.User, error)
+	GetUsersByIDs(ctx context.Context, ids []int64) ([]models.User, error)
+	ListUsers(ctx context.Context) ([]models.User, error)
+	MarkEmailVerified(ctx context.Context, id int64, at time.Time) error
+	UpdatePassword(ctx context.Context, id int64, hash string) error
+	RecordLoginSuccess(ctx context.Context, id int64, at time.Time) error
+	RecordLoginFailure(ctx context.Context, id int64, at time.Time) error
+	UpdateProfile(ctx context.Context, id int64, name, email string) (models.User, error)
+	UpdateRole(ctx context.Context, id int64, role string) error
+	DeleteUser(ctx context.Context, id int64, anonymizeTasks bool) error
 }
 
 type userRepository struct {
@@ -28,7 +38,7 @@ func NewUserRepository(d db.DB) UserRepository {
 }
 
 func (u userRepository) CreateUser(ctx context.Context, r models.CreateUserPayload) error {
-	q, err := db.GetQuery("queries/user/InsertUser.sql")
+	q, err := u.db.GetQuery("queries/user/InsertUser.sql")
 	if err != nil {
 		return fmt.Errorf("CreateUser: error while reading query: %v", err)
 	}
@@ -58,41 +68,234 @@ func (u userRepository) CreateUser(ctx context.Context, r models.CreateUserPaylo
 
 	return nil
 }
-func (u userRepository) CheckIfEmailExists(email string) (bool, error) {
-	q, err := db.GetQuery("queries/user/EmailExistsWithinUsers.sql")
+func (u userRepository) CheckIfEmailExists(ctx context.Context, email string) (bool, error) {
+	q, err := u.db.GetQuery("queries/user/EmailExistsWithinUsers.sql")
 	if err != nil {
 		return false, fmt.Errorf("CheckIfEmailExists: error while reading query: %v", err)
 	}
 
 	var exists bool
 
-	err = u.db.QueryRow(q, email).Scan(&exists)
+	err = u.db.QueryRowContext(ctx, q, email).Scan(&exists)
 	if err != nil {
 		return false, fmt.Errorf("CheckIfEmailExists: failed to execute query: %v", err)
 	}
 
 	return exists, nil
 }
-func (u userRepository) GetUserData(p models.LoginPayload) (models.User, error) {
-	q, err := db.GetQuery("queries/user/LoginUser.sql")
+func (u userRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	return u.getUser(ctx, "queries/user/GetUserByEmail.sql", email)
+}
+func (u userRepository) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	return u.getUser(ctx, "queries/user/GetUserByID.sql", id)
+}
+
+// GetUsersByIDs returns the users with the given ids, ordered by id; unknown ids are skipped.
+// The ids are passed as a JSON array, which both databases can expand in a single query.
+func (u userRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	if len(ids) == 0 {
+		return []models.User{}, nil
+	}
+	q, err := u.db.GetQuery("queries/user/GetUsersByIDs.sql")
 	if err != nil {
-		return models.User{}, fmt.Errorf("GetUserData: error while reading query: %v", err)
+		return nil, fmt.Errorf("GetUsersByIDs: error while reading query: %v", err)
+	}
+	arg, err := json.Marshal(ids)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsersByIDs: failed to encode ids: %v", err)
+	}
+
+	rows, err := u.db.QueryContext(ctx, q, string(arg))
+	if err != nil {
+		return nil, fmt.Errorf("GetUsersByIDs: failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	list := make([]models.User, 0, len(ids))
+	for rows.Next() {
+		var uData models.User
+		if err := rows.Scan(&uData.ID, &uData.Name, &uData.Email, &uData.Password, &uData.CreatedAt, &uData.EmailVerifiedAt, &uData.LastLoginAt, &uData.Role); err != nil {
+			return nil, fmt.Errorf("GetUsersByIDs: failed to read results: %v", err)
+		}
+		list = append(list, uData)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("GetUsersByIDs: query failed: %v", err)
 	}
-	var uData models.User
 
-	err = u.db.QueryRow(q, p.Email).Scan(&uData.ID, &uData.Name, &uData.Email, &uData.Password, &uData.CreatedAt)
+	return list, nil
+}
+
+// ListUsers reads from a replica when one is configured.
+func (u userRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+	q, err := u.db.GetQuery("queries/user/ListUsers.sql")
+	if err != nil {
+		return nil, fmt.Errorf("ListUsers: error while reading query: %v", err)
+	}
+
+	rows, err := u.db.Reader().QueryContext(ctx, q)
+	if err != nil {
+		return nil, fmt.Errorf("ListUsers: failed to execute query: %v", err)
+	}
+	defer rows.Close()
+
+	var list []models.User
+	for rows.Next() {
+		var uData models.User
+		if err := rows.Scan(&uData.ID, &uData.Name, &uData.Email, &uData.Password, &uData.CreatedAt, &uData.EmailVerifiedAt, &uData.LastLoginAt, &uData.Role); err != nil {
+			return nil, fmt.Errorf("ListUsers: failed to read results: %v", err)
+		}
+		list = append(list, uData)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("ListUsers: query failed: %v", err)
+	}
+
+	return list, nil
+}
+func (u userRepository) getUser(ctx context.Context, path string, arg any) (models.User, error) {
+	q, err := u.db.GetQuery(path)
+	if err != nil {
+		return models.User{}, fmt.Errorf("getUser: error while reading query: %v", err)
+	}
 
+	var uData models.User
+	err = u.db.QueryRowContext(ctx, q, arg).Scan(&uData.ID, &uData.Name, &uData.Email, &uData.Password, &uData.CreatedAt, &uData.EmailVerifiedAt, &uData.LastLoginAt, &uData.Role)
 	if errors.Is(err, sql.ErrNoRows) {
-		return models.User{}, fmt.Errorf("GetUserData: no entries found")
+		return models.User{}, fmt.Errorf("getUser: %w", ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("getUser: failed to execute query: %v", err)
+	}
+
+	return uData, nil
+}
+func (u userRepository) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	q, err := u.db.GetQuery("queries/user/MarkEmailVerified.sql")
+	if err != nil {
+		return fmt.Errorf("MarkEmailVerified: error while reading query: %v", err)
+	}
+
+	if _, err := u.db.ExecContext(ctx, q, id, at); err != nil {
+		return fmt.Errorf("MarkEmailVerified: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+func (u userRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	q, err := u.db.GetQuery("queries/user/UpdatePassword.sql")
+	if err != nil {
+		return fmt.Errorf("UpdatePassword: error while reading query: %v", err)
+	}
+
+	if _, err := u.db.ExecContext(ctx, q, id, hash); err != nil {
+		return fmt.Errorf("UpdatePassword: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+
+// RecordLoginSuccess stores the time of the login and resets the failed attempts counter.
+func (u userRepository) RecordLoginSuccess(ctx context.Context, id int64, at time.Time) error {
+	q, err := u.db.GetQuery("queries/user/RecordLoginSuccess.sql")
+	if err != nil {
+		return fmt.Errorf("RecordLoginSuccess: error while reading query: %v", err)
 	}
 
+	if _, err := u.db.ExecContext(ctx, q, id, at); err != nil {
+		return fmt.Errorf("RecordLoginSuccess: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+func (u userRepository) RecordLoginFailure(ctx context.Context, id int64, at time.Time) error {
+	q, err := u.db.GetQuery("queries/user/RecordLoginFailure.sql")
 	if err != nil {
-		return models.User{}, fmt.Errorf("GetUserData: failed to execute query: %v", err)
+		return fmt.Errorf("RecordLoginFailure: error while reading query: %v", err)
 	}
 
-	if !helpers.ValidatePassword(p.Password, uData.Password) {
-		return models.User{}, fmt.Errorf("incorrect credentials")
+	if _, err := u.db.ExecContext(ctx, q, id, at); err != nil {
+		return fmt.Errorf("RecordLoginFailure: failed to execute query: %v", err)
+	}
+
+	return nil
+}
+
+// UpdateProfile sets the name and e-mail of a user; changing the e-mail clears its verification.
+func (u userRepository) UpdateProfile(ctx context.Context, id int64, name, email string) (models.User, error) {
+	q, err := u.db.GetQuery("queries/user/UpdateProfile.sql")
+	if err != nil {
+		return models.User{}, fmt.Errorf("UpdateProfile: error while reading query: %v", err)
+	}
+
+	var uData models.User
+	err = u.db.QueryRowContext(ctx, q, id, name, email).Scan(&uData.ID, &uData.Name, &uData.Email, &uData.Password, &uData.CreatedAt, &uData.EmailVerifiedAt, &uData.LastLoginAt, &uData.Role)
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.User{}, fmt.Errorf("UpdateProfile: %w", ErrNotFound)
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("UpdateProfile: failed to execute query: %v", err)
 	}
 
 	return uData, nil
 }
+
+func (u userRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	q, err := u.db.GetQuery("queries/user/UpdateRole.sql")
+	if err != nil {
+		return fmt.Errorf("UpdateRole: error while reading query: %v", err)
+	}
+
+	res, err := u.db.ExecContext(ctx, q, id, role)
+	if err != nil {
+		return fmt.Errorf("UpdateRole: failed to execute query: %v", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		return fmt.Errorf("UpdateRole: %w", ErrNotFound)
+	}
+
+	return nil
+}
+
+// DeleteUser removes a user together with their tasks, or keeps the tasks without an owner
+// when anonymizeTasks is set. Both steps run in a single transaction.
+func (u userRepository) DeleteUser(ctx context.Context, id int64, anonymizeTasks bool) error {
+	tasksQuery := "queries/task/DeleteUserTasks.sql"
+	if anonymizeTasks {
+		tasksQuery = "queries/task/AnonymizeUserTasks.sql"
+	}
+	tq, err := u.db.GetQuery(tasksQuery)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: error while reading query: %v", err)
+	}
+	uq, err := u.db.GetQuery("queries/user/DeleteUser.sql")
+	if err != nil {
+		return fmt.Errorf("DeleteUser: error while reading query: %v", err)
+	}
+
+	tx, err := u.db.BeginTx(ctx, nil)
+	if err != nil {
+		return fmt.Errorf("DeleteUser: failed to begin tx: %v", err)
+	}
+
+	if _, err := tx.ExecContext(ctx, tq, id); err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("DeleteUser: failed to handle tasks: %v", err)
+	}
+
+	res, err := tx.ExecContext(ctx, uq, id)
+	if err != nil {
+		_ = tx.Rollback()
+		return fmt.Errorf("DeleteUser: failed to delete user: %v", err)
+	}
+	if n, err := res.RowsAffected(); err == nil && n == 0 {
+		_ = tx.Rollback()
+		return fmt.Errorf("DeleteUser: %w", ErrNotFound)
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("DeleteUser: failed to commit tx: %v", err)
+	}
+
+	return nil
+}
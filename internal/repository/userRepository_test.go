@@ -2,10 +2,14 @@ package repository
 
 import (
 	"context"
+	"database/sql"
+	"errors"
+	"task-manager/internal/contextkeys"
 	"task-manager/internal/db"
-	"task-manager/internal/helpers"
 	"task-manager/internal/models"
+	"task-manager/internal/password"
 	"testing"
+	"time"
 )
 
 func testCreateUser(t *testing.T, db db.DB) {
@@ -14,7 +18,7 @@ func testCreateUser(t *testing.T, db db.DB) {
 	ur := NewUserRepository(db)
 
 	ctx := context.Background()
-	hash, _ := helpers.HashPassword("secretPassword")
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
 
 	userData := models.CreateUserPayload{
 		Name:     "Lorem Ipsum",
@@ -26,7 +30,7 @@ func testCreateUser(t *testing.T, db db.DB) {
 
 func TestUserRepository_CreateUser(t *testing.T) {
 	userRepo := NewUserRepository(*testDB)
-	hash, _ := helpers.HashPassword("secretPassword")
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
 
 	var tests = []struct {
 		name                    string
@@ -118,7 +122,7 @@ func TestUserRepository_CheckIfEmailExists(t *testing.T) {
 				testCreateUser(t, *testDB)
 			}
 
-			result, err := userRepo.CheckIfEmailExists(tc.email)
+			result, err := userRepo.CheckIfEmailExists(context.Background(), tc.email)
 			if err != nil {
 				t.Errorf("unexpected error: %s", err)
 			}
@@ -130,88 +134,164 @@ func TestUserRepository_CheckIfEmailExists(t *testing.T) {
 	}
 }
 
-func TestUserRepository_GetUserData(t *testing.T) {
+func TestUserRepository_GetUserByEmail(t *testing.T) {
 	userRepo := NewUserRepository(*testDB)
 	testCreateUser(t, *testDB)
-	hash, _ := helpers.HashPassword("secretPassword")
 
 	var tests = []struct {
 		name           string
-		payload        models.LoginPayload
+		email          string
 		expectedResult models.User
-		expectsError   bool
-		errorWanted    string
+		expectedError  error
 	}{
-		{
-			"valid payload",
-			models.LoginPayload{
-				Email:    "lorem@ipsum.com",
-				Password: "secretPassword",
-			},
-			models.User{
-				ID:    1,
-				Name:  "Lorem Ipsum",
-				Email: "lorem@ipsum.com",
-			},
-			false,
-			"",
-		},
-		{
-			"invalid credentials",
-			models.LoginPayload{
-				Email:    "lorem@ipsum.com",
-				Password: "loremIpsum",
-			},
-			models.User{},
-			true,
-			"incorrect credentials",
-		},
-		{
-			"password missing",
-			models.LoginPayload{
-				Email: "lorem@ipsum.com",
-			},
-			models.User{},
-			true,
-			"incorrect credentials",
-		},
-		{
-			"email missing",
-			models.LoginPayload{
-				Password: "secretPassword",
-			},
-			models.User{},
-			true,
-			"GetUserData: no entries found",
-		},
-		{
-			"hashed password provided",
-			models.LoginPayload{
-				Email:    "lorem@ipsum.com",
-				Password: hash,
-			},
-			models.User{},
-			true,
-			"incorrect credentials",
-		},
+		{"existing user", "lorem@ipsum.com", models.User{ID: 1, Name: "Lorem Ipsum", Email: "lorem@ipsum.com"}, nil},
+		{"unknown e-mail", "unknown@ipsum.com", models.User{}, ErrNotFound},
+		{"email missing", "", models.User{}, ErrNotFound},
 	}
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			user, err := userRepo.GetUserData(tc.payload)
-			if tc.expectsError {
-				if err == nil {
-					t.Error("getUserData was supposed to return an error but it did not")
-				} else if tc.errorWanted != err.Error() {
-					t.Errorf("wrong error returned, expected <%s> but got <%s>", tc.errorWanted, err)
+			user, err := userRepo.GetUserByEmail(context.Background(), tc.email)
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Errorf("wrong error returned, expected <%s> but got <%v>", tc.expectedError, err)
 				}
-			} else {
-				if err != nil {
-					t.Errorf("unexpected error: %s", err)
-				}
-				if user.ID != tc.expectedResult.ID || user.Email != tc.expectedResult.Email || user.Name != tc.expectedResult.Name {
-					t.Errorf("wrong user returned: %v", user)
+				return
+			}
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+			if user.ID != tc.expectedResult.ID || user.Email != tc.expectedResult.Email || user.Name != tc.expectedResult.Name || user.Password == "" {
+				t.Errorf("wrong user returned: %v", user)
+			}
+		})
+	}
+}
+
+func TestUserRepository_RecordLogin(t *testing.T) {
+	userRepo := NewUserRepository(*testDB)
+	ctx := context.Background()
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
+	_ = userRepo.CreateUser(ctx, models.CreateUserPayload{Name: "Login Tracking", Email: "login@tracking.com", Password: hash})
+	u, err := userRepo.GetUserByEmail(ctx, "login@tracking.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.LastLoginAt != nil {
+		t.Fatalf("new users should have no logins recorded: %v", u)
+	}
+	uID := int64(u.ID)
+
+	if err := userRepo.RecordLoginFailure(ctx, uID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := userRepo.RecordLoginSuccess(ctx, uID, time.Now()); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ = userRepo.GetUserByID(ctx, uID); u.LastLoginAt == nil {
+		t.Errorf("successful login should be stored: %v", u)
+	}
+}
+
+func TestUserRepository_UpdateProfile(t *testing.T) {
+	userRepo := NewUserRepository(*testDB)
+	ctx := context.Background()
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
+	_ = userRepo.CreateUser(ctx, models.CreateUserPayload{Name: "Dolor Sit", Email: "dolor@sit.com", Password: hash})
+	u, err := userRepo.GetUserByEmail(ctx, "dolor@sit.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := userRepo.MarkEmailVerified(ctx, int64(u.ID), time.Now()); err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name             string
+		email            string
+		expectsVerified  bool
+		expectedNotFound bool
+		id               int64
+	}{
+		{"same e-mail stays verified", "dolor@sit.com", true, false, int64(u.ID)},
+		{"new e-mail loses verification", "amet@sit.com", false, false, int64(u.ID)},
+		{"unknown user", "unknown@sit.com", false, true, 0},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := userRepo.UpdateProfile(ctx, tc.id, "Dolor Sit Amet", tc.email)
+			if tc.expectedNotFound {
+				if !errors.Is(err, ErrNotFound) {
+					t.Errorf("expected ErrNotFound but got %v", err)
 				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.Name != "Dolor Sit Amet" || got.Email != tc.email {
+				t.Errorf("wrong user returned: %v", got)
+			}
+			if (got.EmailVerifiedAt != nil) != tc.expectsVerified {
+				t.Errorf("expected verified %v but got %v", tc.expectsVerified, got.EmailVerifiedAt)
+			}
+		})
+	}
+}
+
+func TestUserRepository_DeleteUser(t *testing.T) {
+	userRepo := NewUserRepository(*testDB)
+	taskRepo := NewTaskRepository(*testDB)
+	hash, _ := password.NewArgon2id(password.DefaultArgon2idParams).Hash("secretPassword")
+
+	var tests = []struct {
+		name           string
+		email          string
+		anonymizeTasks bool
+	}{
+		{"tasks deleted", "delete@tasks.com", false},
+		{"tasks anonymised", "anonymize@tasks.com", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx := context.Background()
+			_ = userRepo.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem Ipsum", Email: tc.email, Password: hash})
+			u, err := userRepo.GetUserByEmail(ctx, tc.email)
+			if err != nil {
+				t.Fatal(err)
+			}
+			uID := int64(u.ID)
+
+			if _, err := taskRepo.Store(context.WithValue(ctx, contextkeys.UserID, uID), models.TaskPayload{Name: "Lorem", Priority: models.PriorityLow}); err != nil {
+				t.Fatal(err)
+			}
+			tasks, err := taskRepo.Index(ctx, uID)
+			if err != nil || len(tasks.Tasks) != 1 {
+				t.Fatalf("expected 1 task, got %v (%v)", tasks, err)
+			}
+			taskID := tasks.Tasks[0].ID
+
+			if err := userRepo.DeleteUser(ctx, uID, tc.anonymizeTasks); err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if _, err := userRepo.GetUserByID(ctx, uID); !errors.Is(err, ErrNotFound) {
+				t.Errorf("user should be deleted, got %v", err)
+			}
+
+			var owner sql.NullInt64
+			err = testDB.QueryRowContext(ctx, "select created_by from tasks where id = $1", taskID).Scan(&owner)
+			switch {
+			case tc.anonymizeTasks && (err != nil || owner.Valid):
+				t.Errorf("task should be kept without owner, got owner %v (%v)", owner, err)
+			case !tc.anonymizeTasks && !errors.Is(err, sql.ErrNoRows):
+				t.Errorf("task should be deleted, got %v", err)
+			}
+
+			if err := userRepo.DeleteUser(ctx, uID, tc.anonymizeTasks); !errors.Is(err, ErrNotFound) {
+				t.Errorf("deleting twice should return ErrNotFound, got %v", err)
 			}
 		})
 	}
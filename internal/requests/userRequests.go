@@ -43,6 +43,54 @@ type UpdateUserRequest struct {
 	Password string `json:"password,omitempty"`
 }
 
+func (u UpdateUserRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+
+	if u.Name == "" && u.Email == "" {
+		r.SetFailed("nothing to update")
+	}
+	if u.Name != "" && len(strings.TrimSpace(u.Name)) < 3 {
+		r.SetFailed("name has to be at least 3 characters long")
+	}
+	if u.Email != "" && !helpers.IsValidEmail(u.Email) {
+		r.SetFailed("email invalid")
+	}
+	if u.Password != "" {
+		r.SetFailed("password has to be changed through /me/password")
+	}
+
+	return r
+}
+
+type ChangePasswordRequest struct {
+	CurrentPassword string `json:"current_password"`
+	Password        string `json:"password"`
+}
+
+func (c ChangePasswordRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+
+	if len(strings.TrimSpace(c.CurrentPassword)) < 1 {
+		r.SetFailed("missing current password")
+	}
+
+	pl := len(strings.TrimSpace(c.Password))
+	if pl < 5 {
+		r.SetFailed("password has to be at least 5 characters long")
+	}
+	if pl > 72 {
+		r.SetFailed("password too long")
+	}
+
+	return r
+}
+
 type Credentials struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -69,3 +117,123 @@ func (c Credentials) Validate() ValidationResult {
 
 	return r
 }
+
+type VerifyEmailRequest struct {
+	Token string `json:"token"`
+}
+
+func (v VerifyEmailRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if len(strings.TrimSpace(v.Token)) < 1 {
+		r.SetFailed("missing token")
+	}
+
+	return r
+}
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email"`
+}
+
+func (f ForgotPasswordRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if !helpers.IsValidEmail(f.Email) {
+		r.SetFailed("e-mail invalid")
+	}
+
+	return r
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+func (p ResetPasswordRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if len(strings.TrimSpace(p.Token)) < 1 {
+		r.SetFailed("missing token")
+	}
+
+	pl := len(strings.TrimSpace(p.Password))
+	if pl < 5 {
+		r.SetFailed("password has to be at least 5 characters long")
+	}
+	if pl > 72 {
+		r.SetFailed("password too long")
+	}
+
+	return r
+}
+
+// SetPasswordRequest is the input of the reset-password CLI command, which sets the password of
+// an account directly instead of going through a mailed token.
+type SetPasswordRequest struct {
+	Email    string
+	Password string
+}
+
+func (p SetPasswordRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if !helpers.IsValidEmail(p.Email) {
+		r.SetFailed("e-mail invalid")
+	}
+
+	pl := len(strings.TrimSpace(p.Password))
+	if pl < 5 {
+		r.SetFailed("password has to be at least 5 characters long")
+	}
+	if pl > 72 {
+		r.SetFailed("password too long")
+	}
+
+	return r
+}
+
+type MFALoginRequest struct {
+	MFAToken string `json:"mfa_token"`
+	Code     string `json:"code"`
+}
+
+func (m MFALoginRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if len(strings.TrimSpace(m.MFAToken)) < 1 {
+		r.SetFailed("missing mfa token")
+	}
+	if len(strings.TrimSpace(m.Code)) < 1 {
+		r.SetFailed("missing code")
+	}
+
+	return r
+}
+
+type MFACodeRequest struct {
+	Code string `json:"code"`
+}
+
+func (m MFACodeRequest) Validate() ValidationResult {
+	r := ValidationResult{
+		Validated: true,
+		Message:   "",
+	}
+	if len(strings.TrimSpace(m.Code)) < 1 {
+		r.SetFailed("missing code")
+	}
+
+	return r
+}
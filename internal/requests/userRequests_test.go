@@ -310,3 +310,187 @@ func TestCredentials_Validate(t *testing.T) {
 		})
 	}
 }
+
+func TestVerifyEmailRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        VerifyEmailRequest
+		expectedResult ValidationResult
+	}{
+		{"valid token", VerifyEmailRequest{Token: "abc.def"}, ValidationResult{Validated: true}},
+		{"missing token", VerifyEmailRequest{}, ValidationResult{Validated: false, Message: "missing token"}},
+		{"token just whitespaces", VerifyEmailRequest{Token: "  "}, ValidationResult{Validated: false, Message: "missing token"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestForgotPasswordRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        ForgotPasswordRequest
+		expectedResult ValidationResult
+	}{
+		{"valid e-mail", ForgotPasswordRequest{Email: "lorem@ipsum.com"}, ValidationResult{Validated: true}},
+		{"invalid e-mail", ForgotPasswordRequest{Email: "lorem"}, ValidationResult{Validated: false, Message: "e-mail invalid"}},
+		{"missing e-mail", ForgotPasswordRequest{}, ValidationResult{Validated: false, Message: "e-mail invalid"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestResetPasswordRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        ResetPasswordRequest
+		expectedResult ValidationResult
+	}{
+		{
+			"valid data",
+			ResetPasswordRequest{Token: "abc.def", Password: "l0r3mIpsum"},
+			ValidationResult{Validated: true},
+		},
+		{
+			"password too short",
+			ResetPasswordRequest{Token: "abc.def", Password: "abc"},
+			ValidationResult{Validated: false, Message: "password has to be at least 5 characters long"},
+		},
+		{
+			"password too long",
+			ResetPasswordRequest{Token: "abc.def", Password: strings.Repeat("a", 73)},
+			ValidationResult{Validated: false, Message: "password too long"},
+		},
+		{
+			"missing everything",
+			ResetPasswordRequest{},
+			ValidationResult{Validated: false, Message: "missing token, password has to be at least 5 characters long"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestSetPasswordRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        SetPasswordRequest
+		expectedResult ValidationResult
+	}{
+		{"valid data", SetPasswordRequest{Email: "lorem@ipsum.com", Password: "l0r3mIpsum"}, ValidationResult{Validated: true}},
+		{"invalid e-mail", SetPasswordRequest{Email: "lorem", Password: "l0r3mIpsum"}, ValidationResult{Validated: false, Message: "e-mail invalid"}},
+		{"password too long", SetPasswordRequest{Email: "lorem@ipsum.com", Password: strings.Repeat("a", 73)}, ValidationResult{Validated: false, Message: "password too long"}},
+		{
+			"missing everything",
+			SetPasswordRequest{},
+			ValidationResult{Validated: false, Message: "e-mail invalid, password has to be at least 5 characters long"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestUpdateUserRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        UpdateUserRequest
+		expectedResult ValidationResult
+	}{
+		{"name only", UpdateUserRequest{Name: "Lorem Ipsum"}, ValidationResult{Validated: true}},
+		{"email only", UpdateUserRequest{Email: "lorem@ipsum.com"}, ValidationResult{Validated: true}},
+		{"empty request", UpdateUserRequest{}, ValidationResult{Validated: false, Message: "nothing to update"}},
+		{"name too short", UpdateUserRequest{Name: "  a "}, ValidationResult{Validated: false, Message: "name has to be at least 3 characters long"}},
+		{"invalid email", UpdateUserRequest{Email: "lorem"}, ValidationResult{Validated: false, Message: "email invalid"}},
+		{
+			"password not allowed",
+			UpdateUserRequest{Name: "Lorem Ipsum", Password: "l0r3mIpsum"},
+			ValidationResult{Validated: false, Message: "password has to be changed through /me/password"},
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestChangePasswordRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        ChangePasswordRequest
+		expectedResult ValidationResult
+	}{
+		{"valid data", ChangePasswordRequest{CurrentPassword: "l0r3mIpsum", Password: "d0l0rS1t"}, ValidationResult{Validated: true}},
+		{"missing current password", ChangePasswordRequest{Password: "d0l0rS1t"}, ValidationResult{Validated: false, Message: "missing current password"}},
+		{"new password too short", ChangePasswordRequest{CurrentPassword: "l0r3mIpsum", Password: "abc"}, ValidationResult{Validated: false, Message: "password has to be at least 5 characters long"}},
+		{"new password too long", ChangePasswordRequest{CurrentPassword: "l0r3mIpsum", Password: strings.Repeat("a", 73)}, ValidationResult{Validated: false, Message: "password too long"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
+
+func TestMFALoginRequest_Validate(t *testing.T) {
+	var tests = []struct {
+		name           string
+		request        MFALoginRequest
+		expectedResult ValidationResult
+	}{
+		{"valid data", MFALoginRequest{MFAToken: "abc.def.ghi", Code: "123456"}, ValidationResult{Validated: true}},
+		{"missing code", MFALoginRequest{MFAToken: "abc.def.ghi"}, ValidationResult{Validated: false, Message: "missing code"}},
+		{"missing everything", MFALoginRequest{}, ValidationResult{Validated: false, Message: "missing mfa token, missing code"}},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			res := tc.request.Validate()
+
+			if diff := cmp.Diff(tc.expectedResult, res); diff != "" {
+				t.Errorf("invalid validation result <-want, +got>\n%s", diff)
+			}
+		})
+	}
+}
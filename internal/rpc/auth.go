@@ -0,0 +1,92 @@
+package rpc
+
+import (
+	"context"
+	"strings"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/logging"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+	"task-manager/internal/security"
+	"task-manager/internal/services"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// publicMethods can be called without a token.
+var publicMethods = map[string]bool{
+	pb.UserService_Register_FullMethodName: true,
+}
+
+// authenticator checks the tokens of calls the way Server.Authenticate checks those of HTTP
+// requests. Calls carry them as "authorization: Bearer <token>" metadata.
+type authenticator struct {
+	as services.AuthService
+	us services.UserService
+}
+
+func (a authenticator) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	ctx, err := a.authenticate(ctx, info.FullMethod)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+func (a authenticator) stream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+	ctx, err := a.authenticate(ss.Context(), info.FullMethod)
+	if err != nil {
+		return err
+	}
+	return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+}
+
+// authenticate returns ctx carrying the user of the token, like the request context behind
+// Server.Authenticate.
+func (a authenticator) authenticate(ctx context.Context, method string) (context.Context, error) {
+	if publicMethods[method] {
+		return ctx, nil
+	}
+
+	md, _ := metadata.FromIncomingContext(ctx)
+	values := md.Get("authorization")
+	if len(values) == 0 || values[0] == "" {
+		return nil, status.Error(codes.Unauthenticated, "token is missing")
+	}
+	token := values[0]
+	if len(token) > 7 && strings.ToUpper(token[0:6]) == "BEARER" {
+		token = token[7:]
+	}
+
+	u, err := a.as.ParseToken(ctx, token)
+	if err != nil {
+		return nil, status.Error(codes.Unauthenticated, "invalid token")
+	}
+	exists, err := a.us.CheckIfEmailExists(ctx, u.Email)
+	if err != nil || !exists {
+		return nil, status.Error(codes.Unauthenticated, "unable to verify user within the token")
+	}
+
+	uID := int64(u.ID)
+	logging.With(ctx, "user_id", uID)
+	ctx = context.WithValue(ctx, contextkeys.UserID, uID)
+	ctx = context.WithValue(ctx, contextkeys.AuthMethod, security.AuthBearer)
+	return ctx, nil
+}
+
+// userID returns the user set by the authenticator.
+func userID(ctx context.Context) (int64, error) {
+	uID, ok := ctx.Value(contextkeys.UserID).(int64)
+	if !ok {
+		return 0, status.Error(codes.Unauthenticated, "invalid user data, please relog")
+	}
+	return uID, nil
+}
+
+// internalError logs err and answers with msg only, like the REST API does for server errors.
+func internalError(ctx context.Context, msg string, err error) error {
+	logging.FromContext(ctx).Error(msg, "err", err)
+	return status.Error(codes.Internal, msg)
+}
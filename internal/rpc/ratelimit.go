@@ -0,0 +1,48 @@
+package rpc
+
+import (
+	"context"
+	"task-manager/internal/logging"
+	"task-manager/internal/ratelimit"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// limitedMethods maps the public methods to the bucket of the REST route they mirror, so a
+// client is limited the same whichever API it calls.
+var limitedMethods = map[string]string{
+	pb.UserService_Register_FullMethodName: "register",
+}
+
+// limiter applies the policies of the auth routes to limitedMethods, keyed by the peer address
+// like anonymous HTTP requests are by client IP.
+type limiter struct {
+	l        *ratelimit.Limiter
+	policies map[string]ratelimit.Policy
+}
+
+func (l limiter) unary(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+	name, ok := limitedMethods[info.FullMethod]
+	if !ok {
+		return handler(ctx, req)
+	}
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return handler(ctx, req)
+	}
+
+	res, err := l.l.Take(ctx, name, ratelimit.IPSubject(p.Addr.String()), l.policies[name])
+	if err != nil {
+		// like the HTTP middleware, a storage outage doesn't take the API down
+		logging.FromContext(ctx).Error("rate limit store failed", "err", err, "policy", name)
+		return handler(ctx, req)
+	}
+	if !res.Allowed {
+		return nil, status.Error(codes.ResourceExhausted, "too many requests, retry later")
+	}
+	return handler(ctx, req)
+}
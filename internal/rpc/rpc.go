@@ -0,0 +1,114 @@
+// Package rpc serves the gRPC API, which mirrors the task and user routes of the REST API for
+// backend services. The services are defined in proto/taskmanager/v1; run make proto after
+// changing them to regenerate the code in taskmanager/v1.
+package rpc
+
+import (
+	"context"
+	"log/slog"
+	"runtime/debug"
+	"task-manager/internal/logging"
+	"task-manager/internal/ratelimit"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+	"task-manager/internal/services"
+	"task-manager/internal/tracing"
+	"time"
+
+	"go.opentelemetry.io/otel/trace"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/reflection"
+	"google.golang.org/grpc/status"
+)
+
+// New creates the gRPC server on top of s. Connections are pinged every heartbeat, which keeps
+// idle WatchTasks streams open through proxies like the heartbeats of the event stream. Public
+// methods take from the buckets of l with the policies of the auth routes, by bucket name.
+func New(s services.Services, l *ratelimit.Limiter, policies map[string]ratelimit.Policy, heartbeat time.Duration, logger *slog.Logger) *grpc.Server {
+	a := authenticator{as: s.As, us: s.Us}
+	lim := limiter{l: l, policies: policies}
+	srv := grpc.NewServer(
+		grpc.ChainUnaryInterceptor(observeUnary(logger), lim.unary, a.unary),
+		grpc.ChainStreamInterceptor(observeStream(logger), a.stream),
+		grpc.KeepaliveParams(keepalive.ServerParameters{Time: heartbeat}),
+	)
+
+	pb.RegisterTaskServiceServer(srv, &taskServer{ts: s.Ts, es: s.Ev})
+	pb.RegisterUserServiceServer(srv, &userServer{us: s.Us, as: s.As, ac: s.Ac})
+	reflection.Register(srv)
+
+	return srv
+}
+
+// observeUnary traces and logs every call like the HTTP middleware does for requests, turning
+// panics into Internal errors.
+func observeUnary(base *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (res any, err error) {
+		ctx, end := observe(ctx, base, info.FullMethod)
+		defer func() {
+			err = end(recover(), err)
+		}()
+		return handler(ctx, req)
+	}
+}
+
+func observeStream(base *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) (err error) {
+		ctx, end := observe(ss.Context(), base, info.FullMethod)
+		defer func() {
+			err = end(recover(), err)
+		}()
+		return handler(srv, &serverStream{ServerStream: ss, ctx: ctx})
+	}
+}
+
+// observe starts the span and request-scoped logger of a call. The returned function ends
+// them, given what the call recovered from and returned, and returns the error to send.
+func observe(ctx context.Context, base *slog.Logger, method string) (context.Context, func(any, error) error) {
+	start := time.Now()
+	ctx, span := tracing.Start(ctx, method)
+
+	l := base.With("method", method)
+	if sc := trace.SpanContextFromContext(ctx); sc.HasTraceID() {
+		l = l.With("trace_id", sc.TraceID().String())
+	}
+	ctx = logging.NewContext(ctx, l)
+
+	return ctx, func(p any, err error) error {
+		defer span.End()
+		log := logging.FromContext(ctx)
+		if p != nil {
+			log.Error("panic while serving call", "panic", p, "stack", string(debug.Stack()))
+			err = status.Error(codes.Internal, "internal error")
+		}
+
+		code := status.Code(err)
+		level := slog.LevelInfo
+		switch code {
+		case codes.Internal, codes.Unknown, codes.DataLoss:
+			level = slog.LevelError
+		}
+		attrs := []slog.Attr{
+			slog.String("code", code.String()),
+			slog.Duration("duration", time.Since(start)),
+		}
+		if p, ok := peer.FromContext(ctx); ok {
+			attrs = append(attrs, slog.String("remote_addr", p.Addr.String()))
+		}
+		log.LogAttrs(ctx, level, "call served", attrs...)
+
+		return err
+	}
+}
+
+// serverStream replaces the context of a stream with one the interceptors added to.
+type serverStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
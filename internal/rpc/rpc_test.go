@@ -0,0 +1,248 @@
+package rpc
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net"
+	"task-manager/internal/config"
+	"task-manager/internal/events"
+	"task-manager/internal/mail"
+	"task-manager/internal/models"
+	"task-manager/internal/ratelimit"
+	"task-manager/internal/repository/memory"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+	"task-manager/internal/services"
+	"testing"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type testEnv struct {
+	tasks pb.TaskServiceClient
+	users pb.UserServiceClient
+	s     services.Services
+}
+
+func newTestEnv(t *testing.T) testEnv {
+	t.Helper()
+	return newLimitedTestEnv(t, ratelimit.Policy{Limit: 100, Window: time.Minute})
+}
+
+// newLimitedTestEnv limits registrations with the given policy.
+func newLimitedTestEnv(t *testing.T, register ratelimit.Policy) testEnv {
+	t.Helper()
+	m, err := mail.NewFileMailer("no-reply@localhost", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		JWT:      config.JWTConfig{Secret: "secret-for-testing"},
+		Auth:     config.AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost"},
+		Password: config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4},
+		Events:   config.EventsConfig{Heartbeat: time.Minute, Retention: time.Hour},
+	}
+	s := services.New(memory.New(), cfg, m, events.NewBroker())
+
+	lis := bufconn.Listen(1 << 20)
+	l := ratelimit.NewLimiter(ratelimit.NewMemoryStore())
+	srv := New(s, l, map[string]ratelimit.Policy{"register": register}, time.Minute, slog.New(slog.DiscardHandler))
+	go func() {
+		_ = srv.Serve(lis)
+	}()
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) {
+			return lis.DialContext(ctx)
+		}),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		_ = conn.Close()
+	})
+
+	return testEnv{tasks: pb.NewTaskServiceClient(conn), users: pb.NewUserServiceClient(conn), s: s}
+}
+
+// login registers a user over gRPC and returns a context authenticated as them.
+func (e testEnv) login(t *testing.T, email string) context.Context {
+	t.Helper()
+	ctx := context.Background()
+	if _, err := e.users.Register(ctx, &pb.RegisterRequest{Name: "Lorem", Email: email, Password: "password"}); err != nil {
+		t.Fatal(err)
+	}
+	u, err := e.s.Au.Authenticate(ctx, models.LoginPayload{Email: email, Password: "password"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	token, err := e.s.As.CreateToken(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+token)
+}
+
+func expectCode(t *testing.T, err error, code codes.Code) {
+	t.Helper()
+	if status.Code(err) != code {
+		t.Errorf("expected %s but got %v", code, err)
+	}
+}
+
+func TestAuthentication(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.login(t, "lorem@example.com")
+	forged := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer not-a-token")
+
+	_, err := env.tasks.ListTasks(context.Background(), &pb.ListTasksRequest{})
+	expectCode(t, err, codes.Unauthenticated)
+	_, err = env.tasks.ListTasks(forged, &pb.ListTasksRequest{})
+	expectCode(t, err, codes.Unauthenticated)
+	stream, err := env.tasks.WatchTasks(forged, &pb.WatchTasksRequest{})
+	if err == nil {
+		_, err = stream.Recv()
+	}
+	expectCode(t, err, codes.Unauthenticated)
+
+	_, err = env.users.Register(context.Background(), &pb.RegisterRequest{Name: "Lorem", Email: "lorem@example.com", Password: "password"})
+	expectCode(t, err, codes.AlreadyExists)
+
+	res, err := env.users.GetProfile(ctx, &pb.GetProfileRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.GetProfile().GetEmail() != "lorem@example.com" {
+		t.Errorf("unexpected profile %v", res.GetProfile())
+	}
+
+	// tokens of deleted users are rejected
+	if _, err := env.users.DeleteAccount(ctx, &pb.DeleteAccountRequest{}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.users.GetProfile(ctx, &pb.GetProfileRequest{})
+	expectCode(t, err, codes.Unauthenticated)
+}
+
+func TestRegisterRateLimit(t *testing.T) {
+	env := newLimitedTestEnv(t, ratelimit.Policy{Limit: 2, Window: time.Hour})
+	ctx := context.Background()
+
+	for i, email := range []string{"lorem@example.com", "ipsum@example.com"} {
+		if _, err := env.users.Register(ctx, &pb.RegisterRequest{Name: "Lorem", Email: email, Password: "password"}); err != nil {
+			t.Fatalf("registration %d: %s", i, err)
+		}
+	}
+	_, err := env.users.Register(ctx, &pb.RegisterRequest{Name: "Lorem", Email: "dolor@example.com", Password: "password"})
+	expectCode(t, err, codes.ResourceExhausted)
+}
+
+func TestTasks(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.login(t, "lorem@example.com")
+	other := env.login(t, "ipsum@example.com")
+
+	_, err := env.tasks.CreateTask(ctx, &pb.CreateTaskRequest{Name: "Task", Priority: pb.Priority_PRIORITY_HIGH})
+	expectCode(t, err, codes.InvalidArgument)
+
+	due := timestamppb.New(time.Now().Add(time.Hour).Truncate(time.Second))
+	created, err := env.tasks.CreateTask(ctx, &pb.CreateTaskRequest{Name: "Task", Priority: pb.Priority_PRIORITY_HIGH, DueDate: due})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if created.GetTask().GetId() == 0 || created.GetTask().GetName() != "Task" {
+		t.Errorf("expected the created task but got %v", created.GetTask())
+	}
+
+	list, err := env.tasks.ListTasks(ctx, &pb.ListTasksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(list.GetTasks()) != 1 {
+		t.Fatalf("expected one task but got %v", list.GetTasks())
+	}
+	task := list.GetTasks()[0]
+	if task.GetId() != created.GetTask().GetId() || task.GetPriority() != pb.Priority_PRIORITY_HIGH || !task.GetDueDate().AsTime().Equal(due.AsTime()) {
+		t.Errorf("unexpected task %v", task)
+	}
+
+	_, err = env.tasks.GetTask(other, &pb.GetTaskRequest{Id: task.GetId()})
+	expectCode(t, err, codes.NotFound)
+	_, err = env.tasks.DeleteTask(other, &pb.DeleteTaskRequest{Id: task.GetId()})
+	expectCode(t, err, codes.NotFound)
+
+	updated, err := env.tasks.UpdateTask(ctx, &pb.UpdateTaskRequest{Id: task.GetId(), Name: "Renamed"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if updated.GetTask().GetName() != "Renamed" || updated.GetTask().GetPriority() != pb.Priority_PRIORITY_LOW {
+		t.Errorf("unexpected task %v", updated.GetTask())
+	}
+
+	if _, err := env.tasks.DeleteTask(ctx, &pb.DeleteTaskRequest{Id: task.GetId()}); err != nil {
+		t.Fatal(err)
+	}
+	_, err = env.tasks.GetTask(ctx, &pb.GetTaskRequest{Id: task.GetId()})
+	expectCode(t, err, codes.NotFound)
+}
+
+func TestWatchTasks(t *testing.T) {
+	env := newTestEnv(t)
+	ctx := env.login(t, "lorem@example.com")
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	if _, err := env.tasks.CreateTask(ctx, &pb.CreateTaskRequest{Name: "Before"}); err != nil {
+		t.Fatal(err)
+	}
+
+	live, err := env.tasks.WatchTasks(ctx, &pb.WatchTasksRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// the stream is open once its headers arrive, changes made before are not sent
+	if _, err := live.Header(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := env.tasks.CreateTask(ctx, &pb.CreateTaskRequest{Name: "After"}); err != nil {
+		t.Fatal(err)
+	}
+	res, err := live.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	e := res.GetEvent()
+	if e.GetType() != models.TaskEventCreated || e.GetTaskId() != 2 {
+		t.Errorf("unexpected event %v", e)
+	}
+
+	// resuming replays the events after the given one
+	replay, err := env.tasks.WatchTasks(ctx, &pb.WatchTasksRequest{AfterId: new(int64)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []int64{1, 2} {
+		res, err := replay.Recv()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if res.GetEvent().GetTaskId() != id {
+			t.Errorf("expected the event of task %d but got %v", id, res.GetEvent())
+		}
+	}
+
+	cancel()
+	if _, err := live.Recv(); err == io.EOF || status.Code(err) != codes.Canceled {
+		t.Errorf("expected the stream to be cancelled but got %v", err)
+	}
+}
@@ -0,0 +1,263 @@
+package rpc
+
+import (
+	"context"
+	"task-manager/internal/models"
+	"task-manager/internal/requests"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+	"task-manager/internal/services"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+type taskServer struct {
+	pb.UnimplementedTaskServiceServer
+	ts services.TaskService
+	es services.EventService
+}
+
+func (s taskServer) ListTasks(ctx context.Context, _ *pb.ListTasksRequest) (*pb.ListTasksResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tl, err := s.ts.GetTasksList(ctx, uID)
+	if err != nil {
+		return nil, internalError(ctx, "failed to retrieve tasks list", err)
+	}
+
+	res := &pb.ListTasksResponse{Tasks: make([]*pb.Task, 0, len(tl.Tasks))}
+	for _, t := range tl.Tasks {
+		res.Tasks = append(res.Tasks, taskMessage(t))
+	}
+	return res, nil
+}
+
+func (s taskServer) GetTask(ctx context.Context, req *pb.GetTaskRequest) (*pb.GetTaskResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.owned(ctx, uID, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	t, err := s.ts.ShowTask(ctx, int(req.GetId()))
+	if err != nil {
+		return nil, internalError(ctx, "failed to get task data", err)
+	}
+	return &pb.GetTaskResponse{Task: taskMessage(t)}, nil
+}
+
+func (s taskServer) CreateTask(ctx context.Context, req *pb.CreateTaskRequest) (*pb.CreateTaskResponse, error) {
+	r := requests.CreateTasksRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Priority:    priorityModel(req.GetPriority()),
+		DueDate:     timeValue(req.GetDueDate()),
+	}
+	if v := r.Validate(); !v.Validated {
+		return nil, status.Errorf(codes.InvalidArgument, "validation failed: %s", v.Message)
+	}
+
+	now := time.Now()
+	p := models.TaskPayload{
+		Name:        r.Name,
+		Priority:    r.Priority,
+		Description: r.Description,
+		DueDate:     r.DueDate,
+		CreatedAt:   &now,
+	}
+	t, err := s.ts.StoreTask(ctx, p)
+	if err != nil {
+		return nil, internalError(ctx, "failed to save task", err)
+	}
+	return &pb.CreateTaskResponse{Task: taskMessage(t)}, nil
+}
+
+func (s taskServer) UpdateTask(ctx context.Context, req *pb.UpdateTaskRequest) (*pb.UpdateTaskResponse, error) {
+	r := requests.UpdateTaskRequest{
+		Name:        req.GetName(),
+		Description: req.GetDescription(),
+		Priority:    priorityModel(req.GetPriority()),
+		DueDate:     timeValue(req.GetDueDate()),
+	}
+	if v := r.Validate(); !v.Validated {
+		return nil, status.Errorf(codes.InvalidArgument, "validation failed: %s", v.Message)
+	}
+
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.owned(ctx, uID, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	t, err := s.ts.UpdateTask(ctx, models.UpdateTask{
+		ID:          req.GetId(),
+		Name:        r.Name,
+		Priority:    r.Priority,
+		Description: r.Description,
+		DueDate:     r.DueDate,
+	})
+	if err != nil {
+		return nil, internalError(ctx, "failed to update task", err)
+	}
+	return &pb.UpdateTaskResponse{Task: taskMessage(t)}, nil
+}
+
+func (s taskServer) DeleteTask(ctx context.Context, req *pb.DeleteTaskRequest) (*pb.DeleteTaskResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.owned(ctx, uID, req.GetId()); err != nil {
+		return nil, err
+	}
+
+	if err := s.ts.DeleteTask(ctx, int(req.GetId()), uID); err != nil {
+		return nil, internalError(ctx, "failed to delete the task", err)
+	}
+	return &pb.DeleteTaskResponse{}, nil
+}
+
+// WatchTasks sends the changes to the tasks of the user, the same events the SSE stream sends,
+// until the call is cancelled.
+func (s taskServer) WatchTasks(req *pb.WatchTasksRequest, stream grpc.ServerStreamingServer[pb.WatchTasksResponse]) error {
+	ctx := stream.Context()
+	uID, err := userID(ctx)
+	if err != nil {
+		return err
+	}
+
+	var lastID int64
+	if req.AfterId != nil {
+		if req.GetAfterId() < 0 {
+			return status.Error(codes.InvalidArgument, "after_id must not be negative")
+		}
+		lastID = req.GetAfterId()
+	} else {
+		lastID, err = s.es.LastID(ctx)
+		if err != nil {
+			return internalError(ctx, "failed to open the event stream", err)
+		}
+	}
+
+	// subscribe before reading, so changes made in between wake the stream
+	sub := s.es.Subscribe(uID)
+	defer sub.Close()
+	// tells the client the stream is open, which it otherwise learns from the first event
+	if err := stream.SendHeader(metadata.MD{}); err != nil {
+		return err
+	}
+
+	send := func() error {
+		for {
+			events, err := s.es.After(ctx, uID, lastID)
+			if err != nil {
+				if ctx.Err() != nil {
+					return status.FromContextError(ctx.Err()).Err()
+				}
+				return internalError(ctx, "failed to read events", err)
+			}
+			for _, e := range events {
+				if err := stream.Send(&pb.WatchTasksResponse{Event: eventMessage(e)}); err != nil {
+					return err
+				}
+				lastID = e.ID
+			}
+			if len(events) < services.EventBatchSize {
+				return nil
+			}
+		}
+	}
+
+	if err := send(); err != nil {
+		return err
+	}
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+		case _, ok := <-sub.C:
+			if !ok {
+				return status.Error(codes.Unavailable, "server is shutting down")
+			}
+			if err := send(); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// owned checks that the task exists and belongs to the user. Both answer NotFound, so the ids
+// of the tasks of other users are not revealed.
+func (s taskServer) owned(ctx context.Context, uID, id int64) error {
+	if id < 1 {
+		return status.Error(codes.InvalidArgument, "invalid task id")
+	}
+	ok, err := s.ts.IsTaskOwner(ctx, uID, int(id))
+	if err != nil {
+		return internalError(ctx, "failed to check ownership", err)
+	}
+	if !ok {
+		return status.Errorf(codes.NotFound, "task %d not found", id)
+	}
+	return nil
+}
+
+func taskMessage(t models.Task) *pb.Task {
+	return &pb.Task{
+		Id:          int64(t.ID),
+		Name:        t.Name,
+		Priority:    priorityMessage(t.Priority),
+		Description: t.Description,
+		DueDate:     timestamp(t.DueDate),
+		CreatedAt:   timestamp(t.CreatedAt),
+		CreatedBy:   t.CreatedBy,
+	}
+}
+
+func eventMessage(e models.TaskEvent) *pb.TaskEvent {
+	return &pb.TaskEvent{
+		Id:        e.ID,
+		Type:      e.Type,
+		TaskId:    int64(e.TaskID),
+		CreatedAt: timestamppb.New(e.CreatedAt),
+	}
+}
+
+// priorityMessage and priorityModel convert between the enums, which are offset by the
+// unspecified value of the protobuf one. Unknown values map to invalid priorities.
+func priorityMessage(p models.Priority) pb.Priority {
+	return pb.Priority(p + 1)
+}
+
+func priorityModel(p pb.Priority) models.Priority {
+	if p == pb.Priority_PRIORITY_UNSPECIFIED {
+		return models.PriorityLow
+	}
+	return models.Priority(p - 1)
+}
+
+func timestamp(t *time.Time) *timestamppb.Timestamp {
+	if t == nil {
+		return nil
+	}
+	return timestamppb.New(*t)
+}
+
+func timeValue(ts *timestamppb.Timestamp) *time.Time {
+	if ts == nil {
+		return nil
+	}
+	t := ts.AsTime()
+	return &t
+}
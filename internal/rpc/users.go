@@ -0,0 +1,143 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"task-manager/internal/logging"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/requests"
+	pb "task-manager/internal/rpc/taskmanager/v1"
+	"task-manager/internal/services"
+
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type userServer struct {
+	pb.UnimplementedUserServiceServer
+	us services.UserService
+	as services.AuthService
+	ac services.AccountService
+}
+
+func (s userServer) Register(ctx context.Context, req *pb.RegisterRequest) (*pb.RegisterResponse, error) {
+	r := requests.CreateUserRequest{
+		Name:     req.GetName(),
+		Email:    req.GetEmail(),
+		Password: req.GetPassword(),
+	}
+	if v := r.Validate(); !v.Validated {
+		return nil, status.Errorf(codes.InvalidArgument, "validation failed: %s", v.Message)
+	}
+
+	err := s.us.RegisterUser(ctx, models.CreateUserPayload{Name: r.Name, Email: r.Email, Password: r.Password})
+	if errors.Is(err, services.ErrEmailInUse) {
+		return nil, status.Error(codes.AlreadyExists, services.ErrEmailInUse.Error())
+	}
+	if err != nil {
+		return nil, internalError(ctx, "failed to register user", err)
+	}
+
+	// the account exists at this point, a mail failure only means the link has to be resent
+	if err := s.ac.SendVerification(ctx, r.Email); err != nil {
+		logging.FromContext(ctx).Error("failed to send verification e-mail", "err", err)
+	}
+	return &pb.RegisterResponse{}, nil
+}
+
+func (s userServer) GetProfile(ctx context.Context, _ *pb.GetProfileRequest) (*pb.GetProfileResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := s.us.GetProfile(ctx, uID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, status.Error(codes.NotFound, "user not found")
+	}
+	if err != nil {
+		return nil, internalError(ctx, "failed to get profile", err)
+	}
+	return &pb.GetProfileResponse{Profile: profileMessage(u.Profile())}, nil
+}
+
+// UpdateProfile changes the name and/or e-mail. Tokens carry the e-mail, so a new one is
+// returned when it changes, and a verification link is sent.
+func (s userServer) UpdateProfile(ctx context.Context, req *pb.UpdateProfileRequest) (*pb.UpdateProfileResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := requests.UpdateUserRequest{Name: req.GetName(), Email: req.GetEmail()}
+	if v := r.Validate(); !v.Validated {
+		return nil, status.Errorf(codes.InvalidArgument, "validation failed: %s", v.Message)
+	}
+
+	u, emailChanged, err := s.us.UpdateProfile(ctx, uID, models.UpdateProfilePayload{
+		Name:  strings.TrimSpace(r.Name),
+		Email: r.Email,
+	})
+	if errors.Is(err, services.ErrEmailInUse) {
+		return nil, status.Error(codes.AlreadyExists, services.ErrEmailInUse.Error())
+	}
+	if err != nil {
+		return nil, internalError(ctx, "failed to update profile", err)
+	}
+
+	res := &pb.UpdateProfileResponse{Profile: profileMessage(u.Profile())}
+	if emailChanged {
+		if err := s.ac.SendVerification(ctx, u.Email); err != nil {
+			logging.FromContext(ctx).Error("failed to send verification e-mail", "err", err)
+		}
+		res.Token, err = s.as.CreateToken(ctx, u)
+		if err != nil {
+			return nil, internalError(ctx, "profile updated, please log in again", err)
+		}
+	}
+	return res, nil
+}
+
+func (s userServer) ChangePassword(ctx context.Context, req *pb.ChangePasswordRequest) (*pb.ChangePasswordResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+	r := requests.ChangePasswordRequest{CurrentPassword: req.GetCurrentPassword(), Password: req.GetPassword()}
+	if v := r.Validate(); !v.Validated {
+		return nil, status.Errorf(codes.InvalidArgument, "validation failed: %s", v.Message)
+	}
+
+	err = s.us.ChangePassword(ctx, uID, r.CurrentPassword, r.Password)
+	if errors.Is(err, services.ErrIncorrectPassword) {
+		return nil, status.Error(codes.PermissionDenied, "current password is incorrect")
+	}
+	if err != nil {
+		return nil, internalError(ctx, "failed to change password", err)
+	}
+	return &pb.ChangePasswordResponse{}, nil
+}
+
+func (s userServer) DeleteAccount(ctx context.Context, _ *pb.DeleteAccountRequest) (*pb.DeleteAccountResponse, error) {
+	uID, err := userID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := s.us.DeleteAccount(ctx, uID); err != nil {
+		return nil, internalError(ctx, "failed to delete account", err)
+	}
+	return &pb.DeleteAccountResponse{}, nil
+}
+
+func profileMessage(p models.Profile) *pb.Profile {
+	return &pb.Profile{
+		Id:              int64(p.ID),
+		Name:            p.Name,
+		Email:           p.Email,
+		CreatedAt:       timestamp(p.CreatedAt),
+		EmailVerifiedAt: timestamp(p.EmailVerifiedAt),
+		LastLoginAt:     timestamp(p.LastLoginAt),
+	}
+}
@@ -0,0 +1,89 @@
+package security
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"task-manager/internal/config"
+)
+
+var (
+	corsMethods        = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch, http.MethodDelete}
+	corsHeaders        = []string{"Authorization", "Content-Type", CSRFHeader}
+	corsExposedHeaders = []string{"RateLimit-Policy", "RateLimit-Limit", "RateLimit-Remaining", "RateLimit-Reset", "Retry-After", "X-Request-Id"}
+)
+
+// CORS answers preflight requests and adds the Access-Control-* headers for allowed origins.
+// Requests from other origins are passed through untouched, so the browser blocks them.
+func CORS(c config.CORSConfig) func(http.Handler) http.Handler {
+	allowAll := false
+	allowed := make(map[string]bool, len(c.AllowedOrigins))
+	for _, o := range c.AllowedOrigins {
+		if o == "*" {
+			allowAll = true
+		}
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			origin := r.Header.Get("Origin")
+			if origin == "" {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			h := w.Header()
+			h.Add("Vary", "Origin")
+			if !allowAll && !allowed[origin] {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			if allowAll {
+				h.Set("Access-Control-Allow-Origin", "*")
+			} else {
+				h.Set("Access-Control-Allow-Origin", origin)
+			}
+			if c.AllowCredentials {
+				h.Set("Access-Control-Allow-Credentials", "true")
+			}
+
+			if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+				h.Add("Vary", "Access-Control-Request-Method")
+				h.Add("Vary", "Access-Control-Request-Headers")
+				h.Set("Access-Control-Allow-Methods", strings.Join(corsMethods, ", "))
+				h.Set("Access-Control-Allow-Headers", strings.Join(corsHeaders, ", "))
+				if c.MaxAge > 0 {
+					h.Set("Access-Control-Max-Age", strconv.Itoa(int(c.MaxAge.Seconds())))
+				}
+				w.WriteHeader(http.StatusNoContent)
+				return
+			}
+
+			h.Set("Access-Control-Expose-Headers", strings.Join(corsExposedHeaders, ", "))
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// WebSocketOrigin reports whether a WebSocket handshake may proceed. Browsers send cookies with
+// handshakes from any site and CORS does not apply to them, so the origin is checked here:
+// handshakes without one come from other clients, others have to come from the server itself
+// or an allowed origin.
+func WebSocketOrigin(c config.CORSConfig) func(r *http.Request) bool {
+	allowed := make(map[string]bool, len(c.AllowedOrigins))
+	for _, o := range c.AllowedOrigins {
+		allowed[strings.TrimRight(o, "/")] = true
+	}
+
+	return func(r *http.Request) bool {
+		origin := r.Header.Get("Origin")
+		if origin == "" || allowed["*"] || allowed[origin] {
+			return true
+		}
+		u, err := url.Parse(origin)
+		return err == nil && strings.EqualFold(u.Host, r.Host)
+	}
+}
@@ -0,0 +1,227 @@
+package security
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"task-manager/internal/config"
+	"task-manager/internal/contextkeys"
+	"testing"
+	"time"
+)
+
+var okHandler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+	w.WriteHeader(http.StatusOK)
+})
+
+func TestCORS(t *testing.T) {
+	var tests = []struct {
+		name           string
+		cfg            config.CORSConfig
+		method         string
+		origin         string
+		preflight      bool
+		expectedStatus int
+		expectedOrigin string
+		expectsCreds   bool
+	}{
+		{
+			"allowed origin",
+			config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowCredentials: true},
+			http.MethodGet,
+			"https://app.example.com",
+			false,
+			http.StatusOK,
+			"https://app.example.com",
+			true,
+		},
+		{
+			"disallowed origin",
+			config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}},
+			http.MethodGet,
+			"https://evil.example.com",
+			false,
+			http.StatusOK,
+			"",
+			false,
+		},
+		{
+			"wildcard",
+			config.CORSConfig{AllowedOrigins: []string{"*"}},
+			http.MethodGet,
+			"https://any.example.com",
+			false,
+			http.StatusOK,
+			"*",
+			false,
+		},
+		{
+			"preflight",
+			config.CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, MaxAge: time.Minute},
+			http.MethodOptions,
+			"https://app.example.com",
+			true,
+			http.StatusNoContent,
+			"https://app.example.com",
+			false,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/tasks", nil)
+			req.Header.Set("Origin", tc.origin)
+			if tc.preflight {
+				req.Header.Set("Access-Control-Request-Method", http.MethodPatch)
+			}
+			rec := httptest.NewRecorder()
+			CORS(tc.cfg)(okHandler).ServeHTTP(rec, req)
+
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d but got %d", tc.expectedStatus, rec.Code)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Origin"); got != tc.expectedOrigin {
+				t.Errorf("expected allowed origin %q but got %q", tc.expectedOrigin, got)
+			}
+			if got := rec.Header().Get("Access-Control-Allow-Credentials") == "true"; got != tc.expectsCreds {
+				t.Errorf("expected allow credentials %t but got %t", tc.expectsCreds, got)
+			}
+			if tc.preflight && rec.Header().Get("Access-Control-Max-Age") != "60" {
+				t.Errorf("preflight should carry max age")
+			}
+		})
+	}
+}
+
+func TestWebSocketOrigin(t *testing.T) {
+	var tests = []struct {
+		name     string
+		allowed  []string
+		origin   string
+		expected bool
+	}{
+		{"no origin", nil, "", true},
+		{"same host", nil, "https://api.example.com", true},
+		{"allowed origin", []string{"https://app.example.com"}, "https://app.example.com", true},
+		{"wildcard", []string{"*"}, "https://any.example.com", true},
+		{"other site", []string{"https://app.example.com"}, "https://evil.example.com", false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "https://api.example.com/ws", nil)
+			if tc.origin != "" {
+				req.Header.Set("Origin", tc.origin)
+			}
+			if got := WebSocketOrigin(config.CORSConfig{AllowedOrigins: tc.allowed})(req); got != tc.expected {
+				t.Errorf("expected %t but got %t", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestHeaders(t *testing.T) {
+	rec := httptest.NewRecorder()
+	Headers(time.Hour)(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+
+	for h, v := range map[string]string{
+		"X-Content-Type-Options":    "nosniff",
+		"X-Frame-Options":           "DENY",
+		"Strict-Transport-Security": "max-age=3600; includeSubDomains",
+	} {
+		if rec.Header().Get(h) != v {
+			t.Errorf("expected %s to be %q but got %q", h, v, rec.Header().Get(h))
+		}
+	}
+
+	rec = httptest.NewRecorder()
+	Headers(0)(okHandler).ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Header().Get("Strict-Transport-Security") != "" {
+		t.Errorf("HSTS should not be sent when disabled")
+	}
+}
+
+func TestSessions_Issue(t *testing.T) {
+	s := NewSessions(config.SessionConfig{CookieEnabled: true, CookieSecure: true, SameSite: config.SameSiteStrict}, time.Hour)
+
+	rec := httptest.NewRecorder()
+	csrf, err := s.Issue(rec, "jwt-token")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cookies := map[string]*http.Cookie{}
+	for _, c := range rec.Result().Cookies() {
+		cookies[c.Name] = c
+	}
+	if c := cookies[SessionCookie]; c == nil || c.Value != "jwt-token" || !c.HttpOnly || !c.Secure || c.SameSite != http.SameSiteStrictMode {
+		t.Errorf("session cookie is not set as expected: %+v", c)
+	}
+	if c := cookies[CSRFCookie]; c == nil || c.Value != csrf || c.HttpOnly {
+		t.Errorf("csrf cookie is not set as expected: %+v", c)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.AddCookie(cookies[SessionCookie])
+	if s.Token(req) != "jwt-token" {
+		t.Errorf("token should be read back from the session cookie")
+	}
+
+	if NewSessions(config.SessionConfig{}, time.Hour).Token(req) != "" {
+		t.Errorf("token should not be read when cookie sessions are disabled")
+	}
+}
+
+func TestSessions_FlowCookie(t *testing.T) {
+	s := NewSessions(config.SessionConfig{CookieSecure: false, SameSite: config.SameSiteStrict}, time.Hour)
+
+	c := s.FlowCookie("flow", "state", "/auth", 10*time.Minute)
+	if !c.HttpOnly || c.Secure || c.SameSite != http.SameSiteLaxMode || c.Path != "/auth" || c.MaxAge != 600 {
+		t.Errorf("flow cookie is not set as expected: %+v", c)
+	}
+	if c := s.FlowCookie("flow", "", "/auth", -1); c.MaxAge >= 0 {
+		t.Errorf("negative max age should delete the cookie: %+v", c)
+	}
+
+	var nilSessions *Sessions
+	if c := nilSessions.FlowCookie("flow", "state", "/auth", time.Minute); !c.Secure {
+		t.Errorf("flow cookie should default to secure: %+v", c)
+	}
+}
+
+func TestCSRF(t *testing.T) {
+	var tests = []struct {
+		name           string
+		method         string
+		authMethod     string
+		cookie         string
+		header         string
+		expectedStatus int
+	}{
+		{"bearer request is not checked", http.MethodPost, AuthBearer, "", "", http.StatusOK},
+		{"safe method is not checked", http.MethodGet, AuthCookie, "", "", http.StatusOK},
+		{"matching token", http.MethodPatch, AuthCookie, "abc", "abc", http.StatusOK},
+		{"missing header", http.MethodDelete, AuthCookie, "abc", "", http.StatusForbidden},
+		{"missing cookie", http.MethodPost, AuthCookie, "", "abc", http.StatusForbidden},
+		{"mismatching token", http.MethodPost, AuthCookie, "abc", "abd", http.StatusForbidden},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			req := httptest.NewRequest(tc.method, "/tasks", nil)
+			req = req.WithContext(context.WithValue(req.Context(), contextkeys.AuthMethod, tc.authMethod))
+			if tc.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: CSRFCookie, Value: tc.cookie})
+			}
+			if tc.header != "" {
+				req.Header.Set(CSRFHeader, tc.header)
+			}
+
+			rec := httptest.NewRecorder()
+			CSRF(okHandler).ServeHTTP(rec, req)
+			if rec.Code != tc.expectedStatus {
+				t.Errorf("expected status %d but got %d", tc.expectedStatus, rec.Code)
+			}
+		})
+	}
+}
@@ -2,19 +2,25 @@ package server
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"strings"
 	"task-manager/internal/contextkeys"
 	"task-manager/internal/helpers"
-
-	"github.com/golang-jwt/jwt/v5"
+	"task-manager/internal/logging"
+	"task-manager/internal/security"
+	"time"
 )
 
 func (s Server) Authenticate(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		jwtSecret := []byte(s.Cfg.JWT.Secret)
 		tString := r.Header.Get("Authorization")
+		method := security.AuthBearer
+		if tString == "" {
+			tString = s.Sessions.Token(r)
+			method = security.AuthCookie
+		}
 		if tString == "" {
 			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("token is missing!"))
 			return
@@ -23,32 +29,70 @@ func (s Server) Authenticate(next http.Handler) http.Handler {
 			tString = tString[7:]
 		}
 
-		t, err := jwt.Parse(tString, func(token *jwt.Token) (interface{}, error) {
-			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-				return nil, fmt.Errorf("authenticate: invalid sign method")
-			}
-			return jwtSecret, nil
-		})
-
-		if err != nil || !t.Valid {
+		u, err := s.S.As.ParseToken(r.Context(), tString)
+		if err != nil {
 			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("invalid token"))
 			return
 		}
-		claims, ok := t.Claims.(jwt.MapClaims)
-		if !ok {
-			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("invalid token"))
-			return
-		}
-		email := claims["username"].(string)
-		fID := claims["userId"].(float64)
-		uID := int64(fID)
+		uID := int64(u.ID)
 
-		exists, err := s.S.Us.CheckIfEmailExists(email)
+		exists, err := s.S.Us.CheckIfEmailExists(r.Context(), u.Email)
 		if err != nil || !exists {
 			helpers.JsonResponse(w, http.StatusUnauthorized, fmt.Sprintf("unable to verify user within the token"))
 			return
 		}
 
-		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextkeys.UserID, uID)))
+		logging.With(r.Context(), "user_id", uID)
+		ctx := context.WithValue(r.Context(), contextkeys.UserID, uID)
+		ctx = context.WithValue(ctx, contextkeys.AuthMethod, method)
+		next.ServeHTTP(w, r.WithContext(ctx))
 	})
 }
+
+// Deadline bounds every request by timeout. Handlers pass the request context down to the
+// database, so the statements of a request are cancelled when the deadline passes or the
+// client disconnects. The errors they fail with are wrapped as plain strings on the way up, so
+// instead of inspecting them, a server error written once the context is done is replaced by
+// 503 when the deadline passed and 499 when the client went away.
+func Deadline(timeout time.Duration) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx, cancel := context.WithTimeout(r.Context(), timeout)
+			defer cancel()
+
+			next.ServeHTTP(&deadlineWriter{ResponseWriter: w, ctx: ctx}, r.WithContext(ctx))
+		})
+	}
+}
+
+type deadlineWriter struct {
+	http.ResponseWriter
+	ctx      context.Context
+	replaced bool
+}
+
+func (w *deadlineWriter) WriteHeader(status int) {
+	if status < http.StatusInternalServerError || w.ctx.Err() == nil {
+		w.ResponseWriter.WriteHeader(status)
+		return
+	}
+
+	w.replaced = true
+	if errors.Is(w.ctx.Err(), context.DeadlineExceeded) {
+		helpers.JsonResponse(w.ResponseWriter, http.StatusServiceUnavailable, fmt.Sprintf("request timed out"))
+		return
+	}
+	helpers.JsonResponse(w.ResponseWriter, helpers.StatusClientClosedRequest, fmt.Sprintf("request canceled"))
+}
+
+// Write drops the body of a replaced response, which describes the error the handler saw.
+func (w *deadlineWriter) Write(b []byte) (int, error) {
+	if w.replaced {
+		return len(b), nil
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *deadlineWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"task-manager/internal/helpers"
+	"testing"
+	"time"
+)
+
+func TestDeadline(t *testing.T) {
+	failAfterDone := func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+		helpers.JsonResponse(w, http.StatusInternalServerError, "failed to get tasks: context canceled")
+	}
+
+	var tests = []struct {
+		name           string
+		timeout        time.Duration
+		cancelled      bool
+		handler        http.HandlerFunc
+		expectedStatus int
+		expectedBody   string
+	}{
+		{
+			name:    "handlers see the deadline",
+			timeout: time.Minute,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				if _, ok := r.Context().Deadline(); !ok {
+					t.Errorf("the request context should have a deadline")
+				}
+				helpers.JsonResponse(w, http.StatusOK, "ok")
+			},
+			expectedStatus: http.StatusOK,
+			expectedBody:   "ok",
+		},
+		{
+			name:    "other server errors are kept",
+			timeout: time.Minute,
+			handler: func(w http.ResponseWriter, r *http.Request) {
+				helpers.JsonResponse(w, http.StatusInternalServerError, "failed to get tasks")
+			},
+			expectedStatus: http.StatusInternalServerError,
+			expectedBody:   "failed to get tasks",
+		},
+		{
+			name:           "expired deadline",
+			timeout:        time.Millisecond,
+			handler:        failAfterDone,
+			expectedStatus: http.StatusServiceUnavailable,
+			expectedBody:   "request timed out",
+		},
+		{
+			name:           "client gone",
+			timeout:        time.Minute,
+			cancelled:      true,
+			handler:        failAfterDone,
+			expectedStatus: helpers.StatusClientClosedRequest,
+			expectedBody:   "request canceled",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+			if tc.cancelled {
+				cancel()
+			}
+			req := httptest.NewRequest(http.MethodGet, "/tasks", nil).WithContext(ctx)
+			rr := httptest.NewRecorder()
+
+			Deadline(tc.timeout)(tc.handler).ServeHTTP(rr, req)
+
+			if rr.Code != tc.expectedStatus {
+				t.Errorf("expected status %d but got %d", tc.expectedStatus, rr.Code)
+			}
+			if body := strings.TrimSpace(rr.Body.String()); body != `"`+tc.expectedBody+`"` {
+				t.Errorf("expected body %q but got %s", tc.expectedBody, body)
+			}
+		})
+	}
+}
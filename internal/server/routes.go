@@ -3,7 +3,13 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"task-manager/internal/health"
 	"task-manager/internal/helpers"
+	"task-manager/internal/logging"
+	"task-manager/internal/metrics"
+	"task-manager/internal/openapi"
+	"task-manager/internal/security"
+	"task-manager/internal/tracing"
 
 	"github.com/go-chi/chi/v5"
 	chimiddlware "github.com/go-chi/chi/v5/middleware"
@@ -17,32 +23,91 @@ func (s Server) CreateServer() *chi.Mux {
 	r := chi.NewRouter()
 
 	r.Use(chimiddlware.RequestID)
+	r.Use(tracing.Middleware)
+	r.Use(logging.Middleware(s.Log))
 	r.Use(chimiddlware.Recoverer)
-	r.Use(chimiddlware.Logger)
+	r.Use(metrics.Middleware)
+	r.Use(security.Headers(s.Cfg.Session.HSTSMaxAge))
+	r.Use(security.CORS(s.Cfg.CORS))
 	r.MethodNotAllowed(func(w http.ResponseWriter, r *http.Request) {
 		helpers.JsonResponse(w, 405, fmt.Sprintf("method not allowed"))
 	})
 
-	r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
-		_, _ = w.Write([]byte("pong"))
-	})
-	r.Post("/register", s.C.Uc.Store(bodySizeLimit))
-	r.Post("/login", s.C.Uc.Login())
-
 	r.Group(func(r chi.Router) {
-		r.Use(s.Authenticate)
-		r.Get("/marco", func(w http.ResponseWriter, r *http.Request) {
-			_, _ = w.Write([]byte("polo!"))
+		r.Use(Deadline(s.Cfg.HTTP.RequestTimeout))
+
+		r.Group(func(r chi.Router) {
+			r.Use(s.validator.Middleware(bodySizeLimit))
+
+			r.Get("/ping", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("pong"))
+			})
+			r.Method(http.MethodGet, "/metrics", metrics.Handler())
+			r.Get("/healthz", s.Health.Handler(health.Liveness))
+			r.Get("/readyz", s.Health.Handler(health.Readiness))
+			r.Get("/openapi.json", openapi.Handler())
+			r.Get("/docs", openapi.Docs())
+
+			// every auth route has a bucket of its own, so one cannot use up another
+			r.With(s.authLimit("register")).Post("/register", s.C.Uc.Store(bodySizeLimit))
+			r.With(s.authLimit("login")).Post("/login", s.C.Uc.Login())
+			r.With(s.authLimit("login-mfa")).Post("/login/mfa", s.C.Uc.LoginMFA())
+			r.With(s.authLimit("verify-email")).Post("/verify-email", s.C.Uc.VerifyEmail())
+			r.With(s.authLimit("password-forgot")).Post("/password/forgot", s.C.Uc.ForgotPassword())
+			r.With(s.authLimit("password-reset")).Post("/password/reset", s.C.Uc.ResetPassword())
+			if s.C.Oc != nil {
+				r.With(s.authLimit("oidc")).Get("/auth/oidc/login", s.C.Oc.Login())
+				r.With(s.authLimit("oidc")).Get("/auth/oidc/callback", s.C.Oc.Callback())
+			}
 		})
 
-		r.Route("/tasks", func(r chi.Router) {
-			r.Get("/", s.C.Tc.Index())
-			r.Get("/{task_id}", s.C.Tc.Show())
-			r.Post("/", s.C.Tc.Store(bodySizeLimit))
-			r.Patch("/{task_id}", s.C.Tc.Update(bodySizeLimit))
-			r.Delete("/{task_id}", s.C.Tc.Delete())
+		// protected routes validate requests only once the caller is known, so anonymous
+		// callers get a 401 before any body is read
+		r.Group(func(r chi.Router) {
+			r.Use(s.Authenticate)
+			r.Use(security.CSRF)
+			r.Use(s.Limiter.Limit("api", s.apiPolicy))
+			r.Use(s.validator.Middleware(bodySizeLimit))
+			r.Post("/logout", s.C.Uc.Logout())
+			r.Route("/me", func(r chi.Router) {
+				r.Get("/", s.C.Uc.Me())
+				r.Patch("/", s.C.Uc.UpdateMe(bodySizeLimit))
+				r.Put("/password", s.C.Uc.ChangePassword(bodySizeLimit))
+				r.Delete("/", s.C.Uc.DeleteMe())
+				r.Route("/mfa/totp", func(r chi.Router) {
+					r.Post("/", s.C.Mc.Enrol())
+					r.Post("/confirm", s.C.Mc.Confirm(bodySizeLimit))
+					r.Delete("/", s.C.Mc.Disable(bodySizeLimit))
+				})
+			})
+			r.Get("/marco", func(w http.ResponseWriter, r *http.Request) {
+				_, _ = w.Write([]byte("polo!"))
+			})
+
+			r.Route("/tasks", func(r chi.Router) {
+				r.Get("/", s.C.Tc.Index())
+				r.Get("/{task_id}", s.C.Tc.Show())
+				r.Post("/", s.C.Tc.Store(bodySizeLimit))
+				r.Patch("/{task_id}", s.C.Tc.Update(bodySizeLimit))
+				r.Delete("/{task_id}", s.C.Tc.Delete())
+			})
+			r.Post("/graphql", s.C.Gc.Query(bodySizeLimit))
 		})
 	})
 
+	// streams outlive the request timeout; they end when the client disconnects. Their
+	// responses cannot be buffered for validation, and they take no body.
+	r.Group(func(r chi.Router) {
+		r.Use(s.Authenticate)
+		r.Use(s.Limiter.Limit("api", s.apiPolicy))
+		r.Get("/events", s.C.Ec.Stream(s.Cfg.Events.Heartbeat))
+		r.Get("/ws", s.C.Cc.Connect())
+	})
+
 	return r
 }
+
+// authLimit limits an anonymous auth route by client IP with the policy of the named bucket.
+func (s Server) authLimit(name string) func(http.Handler) http.Handler {
+	return s.Limiter.Limit(name, s.authPolicies[name])
+}
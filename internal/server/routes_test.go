@@ -0,0 +1,270 @@
+package server
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"task-manager/internal/collab"
+	"task-manager/internal/config"
+	"task-manager/internal/controllers"
+	"task-manager/internal/events"
+	"task-manager/internal/graph"
+	"task-manager/internal/health"
+	"task-manager/internal/mail"
+	"task-manager/internal/openapi"
+	"task-manager/internal/ratelimit"
+	"task-manager/internal/repository/memory"
+	"task-manager/internal/services"
+	"testing"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+)
+
+// newTestServer creates a server on the memory repositories with every optional route enabled.
+// opts adjust the configuration before the services are created.
+func newTestServer(t *testing.T, opts ...func(*config.Config)) Server {
+	t.Helper()
+	m, err := mail.NewFileMailer("no-reply@localhost", t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.Config{
+		JWT:      config.JWTConfig{Secret: "secret-for-testing"},
+		Auth:     config.AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost"},
+		Password: config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4},
+		Events:   config.EventsConfig{Heartbeat: time.Minute, Retention: time.Hour},
+		HTTP:     config.HTTPConfig{RequestTimeout: time.Minute},
+		GraphQL:  config.GraphQLConfig{MaxDepth: 8, MaxComplexity: 1000},
+	}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	svs := services.New(memory.New(), cfg, m, events.NewBroker())
+	g, err := graph.New(svs.Ts, svs.Us, cfg.GraphQL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lockout := ratelimit.NewLockout(ratelimit.NewMemoryLockoutStore(), 3, time.Minute, time.Hour)
+	c := controllers.New(svs, lockout, nil, collab.NewHub(cfg.Collab, svs.Ts, svs.Ev), g, nil, cfg.Auth.AppURL)
+	// OpenID Connect login is documented, so its routes are checked as well
+	c.Oc = controllers.NewOIDCController(nil, svs.As, svs.Mf, nil, cfg.Auth.AppURL)
+	// responses are validated, so the tests fail when the handlers drift from the document
+	v, err := openapi.NewValidator(true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	policies := make(map[string]ratelimit.Policy)
+	for _, name := range []string{"register", "login", "login-mfa", "verify-email", "password-forgot", "password-reset", "oidc"} {
+		policies[name] = ratelimit.Policy{Limit: 1000, Window: time.Minute}
+	}
+
+	return Server{
+		C:            c,
+		S:            svs,
+		Cfg:          cfg,
+		Health:       health.NewRegistry(time.Second),
+		Log:          slog.New(slog.DiscardHandler),
+		Limiter:      ratelimit.NewLimiter(ratelimit.NewMemoryStore()),
+		authPolicies: policies,
+		apiPolicy:    ratelimit.Policy{Limit: 1000, Window: time.Minute},
+		validator:    v,
+	}
+}
+
+// specOperations returns the operations of the OpenAPI document as "METHOD /path".
+func specOperations(t *testing.T) []string {
+	t.Helper()
+	var doc struct {
+		Paths map[string]map[string]json.RawMessage `json:"paths"`
+	}
+	if err := json.Unmarshal(openapi.Spec, &doc); err != nil {
+		t.Fatalf("the OpenAPI document is invalid: %v", err)
+	}
+
+	var ops []string
+	for path, item := range doc.Paths {
+		for method := range item {
+			switch method {
+			case "parameters", "summary", "description":
+				continue
+			}
+			ops = append(ops, strings.ToUpper(method)+" "+path)
+		}
+	}
+	slices.Sort(ops)
+	return ops
+}
+
+// routeOperations returns the routes of the router as "METHOD /path".
+func routeOperations(t *testing.T, r chi.Routes) []string {
+	t.Helper()
+	var ops []string
+	err := chi.Walk(r, func(method, route string, _ http.Handler, _ ...func(http.Handler) http.Handler) error {
+		if route != "/" {
+			route = strings.TrimSuffix(route, "/")
+		}
+		ops = append(ops, method+" "+route)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	slices.Sort(ops)
+	return slices.Compact(ops)
+}
+
+func TestRoutesAreDocumented(t *testing.T) {
+	s := newTestServer(t)
+	routes := routeOperations(t, s.CreateServer())
+	spec := specOperations(t)
+
+	for _, op := range routes {
+		if !slices.Contains(spec, op) {
+			t.Errorf("route %s is missing from internal/openapi/openapi.json", op)
+		}
+	}
+	for _, op := range spec {
+		if !slices.Contains(routes, op) {
+			t.Errorf("operation %s of internal/openapi/openapi.json has no route", op)
+		}
+	}
+}
+
+func TestAPIContract(t *testing.T) {
+	h := newTestServer(t).CreateServer()
+	var token string
+	do := func(method, target, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		r := httptest.NewRequest(method, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			r.Header.Set("Authorization", "Bearer "+token)
+		}
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+
+	tests := []struct {
+		name           string
+		method         string
+		target         string
+		body           string
+		expectedStatus int
+	}{
+		{"register", http.MethodPost, "/register", `{"name": "Lorem", "email": "lorem@example.com", "password": "password"}`, http.StatusOK},
+		{"register with unknown field", http.MethodPost, "/register", `{"name": "Lorem", "email": "ipsum@example.com", "password": "password", "admin": true}`, http.StatusBadRequest},
+		{"without token", http.MethodGet, "/tasks", "", http.StatusUnauthorized},
+		{"invalid body without token", http.MethodPost, "/tasks", `{"name": "Task", "priority": true}`, http.StatusUnauthorized},
+		{"login", http.MethodPost, "/login", `{"email": "lorem@example.com", "password": "password"}`, http.StatusOK},
+		{"wrong password", http.MethodPost, "/login", `{"email": "lorem@example.com", "password": "wrong"}`, http.StatusUnauthorized},
+		{"profile", http.MethodGet, "/me", "", http.StatusOK},
+		{"empty list", http.MethodGet, "/tasks", "", http.StatusOK},
+		{"create task", http.MethodPost, "/tasks", `{"name": "Task", "priority": "high", "due_date": "2030-01-02T15:04:05Z"}`, http.StatusOK},
+		{"create invalid task", http.MethodPost, "/tasks", `{"name": "Task", "priority": "high"}`, http.StatusUnprocessableEntity},
+		{"create task with wrong type", http.MethodPost, "/tasks", `{"name": "Task", "priority": true}`, http.StatusBadRequest},
+		{"list", http.MethodGet, "/tasks", "", http.StatusOK},
+		{"show", http.MethodGet, "/tasks/1", "", http.StatusOK},
+		{"update", http.MethodPatch, "/tasks/1", `{"name": "Renamed", "description": "Lorem ipsum"}`, http.StatusOK},
+		{"update with invalid id", http.MethodPatch, "/tasks/first", `{"name": "Renamed"}`, http.StatusBadRequest},
+		{"graphql", http.MethodPost, "/graphql", `{"query": "{ tasks { name creator { email } } }"}`, http.StatusOK},
+		{"delete", http.MethodDelete, "/tasks/1", "", http.StatusOK},
+		{"update profile", http.MethodPatch, "/me", `{"name": "Ipsum"}`, http.StatusOK},
+		{"change password", http.MethodPut, "/me/password", `{"current_password": "password", "password": "password2"}`, http.StatusOK},
+		{"health", http.MethodGet, "/healthz", "", http.StatusOK},
+		{"document", http.MethodGet, "/openapi.json", "", http.StatusOK},
+		{"docs", http.MethodGet, "/docs", "", http.StatusOK},
+	}
+
+	// the cases run in order, as they build on each other
+	for _, tt := range tests {
+		w := do(tt.method, tt.target, tt.body)
+		if w.Code != tt.expectedStatus {
+			t.Fatalf("%s: expected status %d but got %d: %s", tt.name, tt.expectedStatus, w.Code, w.Body.String())
+		}
+		if tt.name == "login" {
+			var res controllers.AuthResponse
+			if err := json.Unmarshal(w.Body.Bytes(), &res); err != nil {
+				t.Fatal(err)
+			}
+			token = res.Token
+		}
+	}
+}
+
+func TestAuthRoutesHaveSeparateBuckets(t *testing.T) {
+	s := newTestServer(t)
+	s.authPolicies["verify-email"] = ratelimit.Policy{Limit: 1, Window: time.Minute}
+	h := s.CreateServer()
+	do := func(target, body string) int {
+		r := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w.Code
+	}
+
+	for i := 0; i < 2; i++ {
+		do("/verify-email", `{"token": "unknown"}`)
+	}
+	if code := do("/verify-email", `{"token": "unknown"}`); code != http.StatusTooManyRequests {
+		t.Fatalf("expected /verify-email to be limited but got %d", code)
+	}
+	if code := do("/login", `{"email": "lorem@example.com", "password": "password"}`); code == http.StatusTooManyRequests {
+		t.Errorf("expected /login to keep its own bucket")
+	}
+}
+
+func TestLoginFailuresLookAlike(t *testing.T) {
+	h := newTestServer(t, func(c *config.Config) {
+		c.Auth.RequireVerifiedEmail = true
+	}).CreateServer()
+	login := func(email, password string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(http.MethodPost, "/login", strings.NewReader(`{"email": "`+email+`", "password": "`+password+`"}`))
+		r.Header.Set("Content-Type", "application/json")
+		w := httptest.NewRecorder()
+		h.ServeHTTP(w, r)
+		return w
+	}
+	for _, email := range []string{"lorem@example.com", "ipsum@example.com"} {
+		r := httptest.NewRequest(http.MethodPost, "/register", strings.NewReader(`{"name": "Lorem", "email": "`+email+`", "password": "password"}`))
+		r.Header.Set("Content-Type", "application/json")
+		h.ServeHTTP(httptest.NewRecorder(), r)
+	}
+	// ipsum is locked after three wrong passwords
+	for range 3 {
+		login("ipsum@example.com", "wrong")
+	}
+
+	tests := []struct {
+		name     string
+		email    string
+		password string
+	}{
+		{"unknown e-mail", "dolor@example.com", "password"},
+		{"wrong password", "lorem@example.com", "wrong"},
+		{"unverified e-mail", "lorem@example.com", "password"},
+		{"locked account", "ipsum@example.com", "password"},
+	}
+
+	var first string
+	for _, tt := range tests {
+		w := login(tt.email, tt.password)
+		if w.Code != http.StatusUnauthorized {
+			t.Errorf("%s: expected status 401 but got %d: %s", tt.name, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Retry-After") != "" {
+			t.Errorf("%s: expected no Retry-After header", tt.name)
+		}
+		if first == "" {
+			first = w.Body.String()
+		} else if w.Body.String() != first {
+			t.Errorf("%s: expected the body %s but got %s", tt.name, first, w.Body.String())
+		}
+	}
+}
@@ -1,57 +1,316 @@
 package server
 
 import (
+	"context"
 	"fmt"
-	"log"
+	"log/slog"
+	"strings"
+	"task-manager/internal/collab"
 	"task-manager/internal/config"
 	"task-manager/internal/controllers"
 	"task-manager/internal/db"
+	"task-manager/internal/events"
+	"task-manager/internal/graph"
+	"task-manager/internal/health"
+	"task-manager/internal/mail"
+	"task-manager/internal/metrics"
+	"task-manager/internal/openapi"
+	"task-manager/internal/ratelimit"
 	"task-manager/internal/repository"
+	"task-manager/internal/repository/memory"
+	"task-manager/internal/rpc"
+	"task-manager/internal/security"
 	"task-manager/internal/services"
+	"task-manager/internal/tracing"
+	"time"
 
 	"github.com/go-chi/chi/v5"
+	"google.golang.org/grpc"
+)
+
+const (
+	checkTimeout       = 2 * time.Second
+	eventPruneInterval = time.Hour
+	// how often rate limit buckets and login failure counters are pruned from Postgres
+	rateLimitPruneInterval = 10 * time.Minute
+	// workers fail readiness after missing this many heartbeats
+	missedBeats = 3
 )
 
 type Server struct {
-	D   db.DB
+	// D is nil with the memory driver.
+	D   *db.DB
 	C   controllers.Controllers
 	S   services.Services
 	R   repository.Repositories
 	H   *chi.Mux
 	Cfg config.Config
+	// RPC serves the gRPC API; it is only listened on when a gRPC address is configured.
+	RPC *grpc.Server
+
+	Health   *health.Registry
+	Log      *slog.Logger
+	Limiter  *ratelimit.Limiter
+	Sessions *security.Sessions
+	Events   *events.Broker
+	Collab   *collab.Hub
+
+	// authPolicies holds the policy of each auth route by the name of its bucket
+	authPolicies map[string]ratelimit.Policy
+	apiPolicy    ratelimit.Policy
+	validator    *openapi.Validator
+	// buckets and lockout are pruned by a worker when they are kept in Postgres
+	buckets *ratelimit.PostgresStore
+	lockout *ratelimit.Lockout
+
+	// heartbeats of the background workers, listener is nil unless the database is Postgres
+	listenerBeat *health.Heartbeat
+	prunerBeat   *health.Heartbeat
+	collabBeat   *health.Heartbeat
+	// rateLimitBeat is nil unless the rate limit store is Postgres
+	rateLimitBeat *health.Heartbeat
 }
 
-func New(cfg config.Config) *Server {
-	// initialize database
-	d, err := db.InitDb(cfg.DB)
+func New(ctx context.Context, cfg config.Config, logger *slog.Logger) (*Server, error) {
+	if cfg.RateLimit.Store == config.RateLimitStorePostgres && cfg.DB.Driver != config.DBDriverPostgres {
+		return nil, fmt.Errorf("rate limit store %s requires the %s database driver", cfg.RateLimit.Store, config.DBDriverPostgres)
+	}
+
+	// initialize storage
+	d, r, err := openStorage(ctx, cfg.DB)
 	if err != nil {
-		log.Fatalf("unabled to initialize database: %v", err)
+		return nil, err
 	}
 
-	// test connection
-	if err := d.Ping(); err != nil {
-		log.Fatalf("error while connecting to db: %v", err)
+	authPolicies := make(map[string]ratelimit.Policy)
+	for name, p := range map[string]string{
+		"register":        cfg.RateLimit.RegisterPolicy,
+		"login":           cfg.RateLimit.LoginPolicy,
+		"login-mfa":       cfg.RateLimit.LoginMFAPolicy,
+		"verify-email":    cfg.RateLimit.VerifyEmailPolicy,
+		"password-forgot": cfg.RateLimit.ForgotPasswordPolicy,
+		"password-reset":  cfg.RateLimit.ResetPasswordPolicy,
+		"oidc":            cfg.RateLimit.OIDCPolicy,
+	} {
+		if authPolicies[name], err = ratelimit.ParsePolicy(p); err != nil {
+			return nil, err
+		}
+	}
+	apiPolicy, err := ratelimit.ParsePolicy(cfg.RateLimit.APIPolicy)
+	if err != nil {
+		return nil, err
+	}
+	validator, err := openapi.NewValidator(cfg.HTTP.ValidateResponses)
+	if err != nil {
+		return nil, err
 	}
 
-	// run migrations
-	if err := d.RunMigrations(); err != nil {
-		log.Fatalf("%v", err)
+	var store ratelimit.Store
+	var buckets *ratelimit.PostgresStore
+	var lockoutStore ratelimit.LockoutStore
+	switch cfg.RateLimit.Store {
+	case config.RateLimitStorePostgres:
+		buckets = ratelimit.NewPostgresStore(*d)
+		store = buckets
+		lockoutStore = ratelimit.NewPostgresLockoutStore(*d)
+	default:
+		store = ratelimit.NewMemoryStore()
+		lockoutStore = ratelimit.NewMemoryLockoutStore()
 	}
+	lockout := ratelimit.NewLockout(lockoutStore, cfg.RateLimit.LockoutThreshold, cfg.RateLimit.LockoutBase, cfg.RateLimit.LockoutMax)
 
-	fmt.Println("Setting up repository, service and controller")
-	r := repository.New(*d)
-	svs := services.New(r, cfg.JWT)
-	c := controllers.New(svs)
+	logger.Debug("setting up repository, service and controller")
+	mailer, err := mail.New(cfg.Mail)
+	if err != nil {
+		return nil, err
+	}
+
+	broker := events.NewBroker()
+	svs := services.New(r, cfg, mailer, broker)
+	sessions := security.NewSessions(cfg.Session, services.TokenTTL)
+	hub := collab.NewHub(cfg.Collab, svs.Ts, svs.Ev)
+	g, err := graph.New(svs.Ts, svs.Us, cfg.GraphQL)
+	if err != nil {
+		return nil, err
+	}
+	c := controllers.New(svs, lockout, sessions, hub, g, security.WebSocketOrigin(cfg.CORS), cfg.Auth.AppURL)
 
 	s := &Server{
-		D:   *d,
+		D:   d,
 		C:   c,
 		S:   svs,
 		R:   r,
 		Cfg: cfg,
+
+		Health:   health.NewRegistry(checkTimeout),
+		Log:      logger,
+		Limiter:  ratelimit.NewLimiter(store),
+		Sessions: sessions,
+		Events:   broker,
+		Collab:   hub,
+
+		authPolicies: authPolicies,
+		apiPolicy:    apiPolicy,
+		validator:    validator,
+		buckets:      buckets,
+		lockout:      lockout,
+
+		prunerBeat: health.NewHeartbeat(missedBeats * eventPruneInterval),
+		collabBeat: health.NewHeartbeat(missedBeats * collab.RunInterval(cfg.Collab)),
+	}
+	if cfg.DB.Driver == config.DBDriverPostgres {
+		s.listenerBeat = health.NewHeartbeat(missedBeats * events.PingInterval)
+	}
+	if s.buckets != nil {
+		s.rateLimitBeat = health.NewHeartbeat(missedBeats * rateLimitPruneInterval)
 	}
+	s.registerChecks()
+
+	if s.D != nil {
+		tracing.RegisterDB(*s.D)
+		if err := metrics.RegisterDB(*s.D); err != nil {
+			return nil, fmt.Errorf("unable to register database metrics: %v", err)
+		}
+	}
+	if err := metrics.RegisterOverdueTasks(s.R.Tr.CountOverdue); err != nil {
+		return nil, fmt.Errorf("unable to register task metrics: %v", err)
+	}
+	if err := metrics.RegisterEventStreams(s.Events.Subscriptions); err != nil {
+		return nil, fmt.Errorf("unable to register event metrics: %v", err)
+	}
+	if err := metrics.RegisterCollabClients(s.Collab.Clients); err != nil {
+		return nil, fmt.Errorf("unable to register collaboration metrics: %v", err)
+	}
+
+	// other servers sharing the database announce their changes through it
+	if cfg.DB.Driver == config.DBDriverPostgres {
+		if err := events.Listen(ctx, db.DSN(cfg.DB), s.Events, s.listenerBeat, logger); err != nil {
+			return nil, fmt.Errorf("unable to listen for task events: %v", err)
+		}
+	}
+	go s.pruneEvents(ctx)
+	if s.buckets != nil {
+		go s.pruneRateLimits(ctx)
+	}
+	go s.Collab.Run(ctx, s.collabBeat)
 
 	s.H = s.CreateServer()
+	s.RPC = rpc.New(s.S, s.Limiter, authPolicies, cfg.Events.Heartbeat, logger)
+
+	return s, nil
+}
+
+// openStorage connects to the configured database and runs pending migrations. The memory
+// driver needs no database, so no *db.DB is returned for it.
+func openStorage(ctx context.Context, c config.DBConfig) (*db.DB, repository.Repositories, error) {
+	if c.Driver == config.DBDriverMemory {
+		return nil, memory.New(), nil
+	}
+
+	// initialize database
+	d, err := db.Open(c)
+	if err != nil {
+		return nil, repository.Repositories{}, fmt.Errorf("unable to initialize database: %v", err)
+	}
+
+	// test connection
+	if err := d.PingContext(ctx); err != nil {
+		_ = d.Close()
+		return nil, repository.Repositories{}, fmt.Errorf("error while connecting to db: %v", err)
+	}
+
+	// run migrations
+	if err := d.RunMigrations(ctx); err != nil {
+		_ = d.Close()
+		return nil, repository.Repositories{}, err
+	}
 
-	return s
+	return d, repository.New(*d), nil
+}
+
+// pruneEvents deletes expired task events every eventPruneInterval until ctx is done.
+func (s *Server) pruneEvents(ctx context.Context) {
+	t := time.NewTicker(eventPruneInterval)
+	defer t.Stop()
+	for {
+		s.prunerBeat.Beat()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			n, err := s.S.Ev.Prune(ctx, time.Now())
+			if err != nil {
+				s.Log.Error("failed to prune task events", "err", err)
+				continue
+			}
+			s.Log.Debug("pruned task events", "count", n)
+		}
+	}
+}
+
+// pruneRateLimits deletes the rate limit buckets and login failure counters that no longer
+// limit anything every rateLimitPruneInterval until ctx is done. The memory stores sweep
+// themselves, so this only runs for the Postgres store.
+func (s *Server) pruneRateLimits(ctx context.Context) {
+	// a bucket idle for the longest window is full again under every policy
+	window := s.apiPolicy.Window
+	for _, p := range s.authPolicies {
+		window = max(window, p.Window)
+	}
+
+	t := time.NewTicker(rateLimitPruneInterval)
+	defer t.Stop()
+	for {
+		s.rateLimitBeat.Beat()
+		select {
+		case <-ctx.Done():
+			return
+		case <-t.C:
+			now := time.Now()
+			n, err := s.buckets.Prune(ctx, now.Add(-window))
+			if err != nil {
+				s.Log.Error("failed to prune rate limit buckets", "err", err)
+				continue
+			}
+			m, err := s.lockout.Prune(ctx, now)
+			if err != nil {
+				s.Log.Error("failed to prune login failures", "err", err)
+				continue
+			}
+			s.Log.Debug("pruned rate limits", "buckets", n, "login_failures", m)
+		}
+	}
+}
+
+func (s *Server) registerChecks() {
+	if s.listenerBeat != nil {
+		s.Health.Register("event-listener", health.Readiness, s.listenerBeat.Check)
+	}
+	if s.rateLimitBeat != nil {
+		s.Health.Register("rate-limit-pruner", health.Readiness, s.rateLimitBeat.Check)
+	}
+	s.Health.Register("event-pruner", health.Readiness, s.prunerBeat.Check)
+	s.Health.Register("collab-hub", health.Readiness, s.collabBeat.Check)
+
+	if s.D == nil {
+		return
+	}
+	s.Health.Register("database", health.Readiness, func(ctx context.Context) error {
+		return s.D.PingContext(ctx)
+	})
+	for i, r := range s.D.Replicas() {
+		s.Health.Register(fmt.Sprintf("database-replica-%d", i), health.Readiness, func(ctx context.Context) error {
+			return r.PingContext(ctx)
+		})
+	}
+	s.Health.Register("migrations", health.Readiness, func(ctx context.Context) error {
+		pending, err := s.D.PendingMigrations(ctx)
+		if err != nil {
+			return err
+		}
+		if len(pending) > 0 {
+			return fmt.Errorf("pending migrations: %s", strings.Join(pending, ", "))
+		}
+		return nil
+	})
 }
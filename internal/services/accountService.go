@@ -0,0 +1,247 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/mail"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
+	"time"
+)
+
+var (
+	// ErrInvalidToken is returned for tokens that are malformed, forged, expired or already used.
+	ErrInvalidToken = errors.New("invalid or expired token")
+	// ErrEmailNotVerified is returned by Authenticate when verification is required and still pending.
+	ErrEmailNotVerified = errors.New("email address not verified")
+)
+
+type AccountService interface {
+	SendVerification(ctx context.Context, email string) error
+	VerifyEmail(ctx context.Context, token string) error
+	ForgotPassword(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, password string) error
+}
+
+type accountService struct {
+	ur     repository.UserRepository
+	tr     repository.TokenRepository
+	m      mail.Mailer
+	h      password.Hasher
+	cfg    config.AuthConfig
+	secret []byte
+}
+
+// NewAccountService creates the service handling e-mail verification and password resets.
+// Tokens are signed with secret so forged ones are rejected before reaching the database.
+func NewAccountService(ur repository.UserRepository, tr repository.TokenRepository, m mail.Mailer, h password.Hasher, cfg config.AuthConfig, secret string) AccountService {
+	return &accountService{
+		ur:     ur,
+		tr:     tr,
+		m:      m,
+		h:      h,
+		cfg:    cfg,
+		secret: []byte(secret),
+	}
+}
+
+// SendVerification mails a verification link to the account registered with email. Unknown
+// and already verified addresses are silently ignored.
+func (s accountService) SendVerification(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.SendVerification")
+	defer span.End()
+
+	u, err := s.ur.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("SendVerification: failed to get user: %v", err)
+	}
+	if u.EmailVerifiedAt != nil {
+		return nil
+	}
+
+	token, err := s.issue(ctx, int64(u.ID), models.TokenPurposeVerifyEmail, s.cfg.VerificationTTL)
+	if err != nil {
+		return fmt.Errorf("SendVerification: %v", err)
+	}
+
+	err = s.m.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Verify your e-mail address",
+		Body: fmt.Sprintf("Hi %s,\n\nplease confirm your e-mail address by opening the link below:\n\n%s\n\nThe link expires in %s.\n",
+			u.Name, s.link("/verify-email", token), s.cfg.VerificationTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("SendVerification: failed to send e-mail: %v", err)
+	}
+
+	return nil
+}
+
+func (s accountService) VerifyEmail(ctx context.Context, token string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.VerifyEmail")
+	defer span.End()
+
+	uID, err := s.consume(ctx, models.TokenPurposeVerifyEmail, token)
+	if err != nil {
+		return fmt.Errorf("VerifyEmail: %w", err)
+	}
+
+	if err := s.ur.MarkEmailVerified(ctx, uID, time.Now()); err != nil {
+		return fmt.Errorf("VerifyEmail: failed to mark e-mail as verified: %v", err)
+	}
+
+	return nil
+}
+
+// ForgotPassword mails a password reset link. It returns nil for unknown addresses so the
+// endpoint cannot be used to find out which e-mails are registered.
+func (s accountService) ForgotPassword(ctx context.Context, email string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.ForgotPassword")
+	defer span.End()
+
+	u, err := s.ur.GetUserByEmail(ctx, email)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("ForgotPassword: failed to get user: %v", err)
+	}
+
+	token, err := s.issue(ctx, int64(u.ID), models.TokenPurposeResetPassword, s.cfg.ResetTTL)
+	if err != nil {
+		return fmt.Errorf("ForgotPassword: %v", err)
+	}
+
+	err = s.m.Send(ctx, mail.Message{
+		To:      u.Email,
+		Subject: "Reset your password",
+		Body: fmt.Sprintf("Hi %s,\n\nyou can choose a new password by opening the link below:\n\n%s\n\nThe link expires in %s. If you did not ask for a password reset you can ignore this e-mail.\n",
+			u.Name, s.link("/reset-password", token), s.cfg.ResetTTL),
+	})
+	if err != nil {
+		return fmt.Errorf("ForgotPassword: failed to send e-mail: %v", err)
+	}
+
+	return nil
+}
+
+func (s accountService) ResetPassword(ctx context.Context, token, password string) error {
+	ctx, span := tracing.Start(ctx, "AccountService.ResetPassword")
+	defer span.End()
+
+	uID, err := s.consume(ctx, models.TokenPurposeResetPassword, token)
+	if err != nil {
+		return fmt.Errorf("ResetPassword: %w", err)
+	}
+
+	_, hashSpan := tracing.Start(ctx, "password.hash")
+	hash, err := s.h.Hash(password)
+	hashSpan.End()
+	if err != nil {
+		return fmt.Errorf("ResetPassword: failed to hash a password, %s", err)
+	}
+
+	if err := s.ur.UpdatePassword(ctx, uID, hash); err != nil {
+		return fmt.Errorf("ResetPassword: failed to update password: %v", err)
+	}
+	if err := s.tr.DeleteForUser(ctx, uID, models.TokenPurposeResetPassword); err != nil {
+		return fmt.Errorf("ResetPassword: failed to revoke remaining tokens: %v", err)
+	}
+
+	return nil
+}
+
+// issue replaces any pending token of the same purpose with a new one valid for ttl.
+func (s accountService) issue(ctx context.Context, uID int64, purpose string, ttl time.Duration) (string, error) {
+	token, err := signToken(s.secret, purpose)
+	if err != nil {
+		return "", err
+	}
+
+	if err := s.tr.DeleteForUser(ctx, uID, purpose); err != nil {
+		return "", fmt.Errorf("failed to revoke previous tokens: %v", err)
+	}
+
+	now := time.Now()
+	err = s.tr.Store(ctx, models.UserToken{
+		UserID:    uID,
+		Purpose:   purpose,
+		Hash:      hashToken(token),
+		ExpiresAt: now.Add(ttl),
+		CreatedAt: now,
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to store token: %v", err)
+	}
+
+	return token, nil
+}
+
+func (s accountService) consume(ctx context.Context, purpose, token string) (int64, error) {
+	if !verifyToken(s.secret, purpose, token) {
+		return 0, ErrInvalidToken
+	}
+
+	uID, err := s.tr.Consume(ctx, purpose, hashToken(token), time.Now())
+	if errors.Is(err, repository.ErrNotFound) {
+		return 0, ErrInvalidToken
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to consume token: %v", err)
+	}
+
+	return uID, nil
+}
+
+func (s accountService) link(path, token string) string {
+	return strings.TrimRight(s.cfg.AppURL, "/") + path + "?token=" + url.QueryEscape(token)
+}
+
+// signToken returns "<random>.<signature>", the signature being an HMAC over the purpose and
+// the random part so a token issued for one flow cannot be replayed in another.
+func signToken(secret []byte, purpose string) (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	r := base64.RawURLEncoding.EncodeToString(b)
+	return r + "." + base64.RawURLEncoding.EncodeToString(tokenMAC(secret, purpose, r)), nil
+}
+
+func verifyToken(secret []byte, purpose, token string) bool {
+	r, sig, ok := strings.Cut(token, ".")
+	if !ok {
+		return false
+	}
+	got, err := base64.RawURLEncoding.DecodeString(sig)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal(got, tokenMAC(secret, purpose, r))
+}
+
+func tokenMAC(secret []byte, purpose, r string) []byte {
+	mac := hmac.New(sha256.New, secret)
+	mac.Write([]byte(purpose + "." + r))
+	return mac.Sum(nil)
+}
+
+// hashToken is what gets stored, so a leaked table cannot be used to take over accounts.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
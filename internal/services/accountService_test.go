@@ -0,0 +1,232 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/mail"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"testing"
+	"time"
+)
+
+type mockTokenRepository struct {
+	tokens map[string]*models.UserToken
+	used   map[string]bool
+}
+
+func newMockTokenRepository() *mockTokenRepository {
+	return &mockTokenRepository{
+		tokens: map[string]*models.UserToken{},
+		used:   map[string]bool{},
+	}
+}
+
+func (m *mockTokenRepository) Store(ctx context.Context, t models.UserToken) error {
+	m.tokens[t.Hash] = &t
+	return nil
+}
+
+func (m *mockTokenRepository) Consume(ctx context.Context, purpose, hash string, now time.Time) (int64, error) {
+	t, ok := m.tokens[hash]
+	if !ok || m.used[hash] || t.Purpose != purpose || !t.ExpiresAt.After(now) {
+		return 0, fmt.Errorf("consume: %w", repository.ErrNotFound)
+	}
+	m.used[hash] = true
+	return t.UserID, nil
+}
+
+func (m *mockTokenRepository) DeleteForUser(ctx context.Context, uID int64, purpose string) error {
+	for h, t := range m.tokens {
+		if t.UserID == uID && t.Purpose == purpose && !m.used[h] {
+			delete(m.tokens, h)
+		}
+	}
+	return nil
+}
+
+type mockMailer struct {
+	sent []mail.Message
+}
+
+func (m *mockMailer) Send(ctx context.Context, msg mail.Message) error {
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// tokenFromMail extracts the token from the link in the last message sent.
+func tokenFromMail(t *testing.T, m *mockMailer) string {
+	t.Helper()
+	if len(m.sent) == 0 {
+		t.Fatal("no e-mail sent")
+	}
+	body := m.sent[len(m.sent)-1].Body
+	_, rest, ok := strings.Cut(body, "?token=")
+	if !ok {
+		t.Fatalf("e-mail does not contain a token link: %s", body)
+	}
+	raw, _, _ := strings.Cut(rest, "\n")
+	token, err := url.QueryUnescape(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func newTestAccountService() (AccountService, *mockTokenRepository, *mockMailer) {
+	tr := newMockTokenRepository()
+	m := &mockMailer{}
+	cfg := config.AuthConfig{VerificationTTL: time.Hour, ResetTTL: time.Hour, AppURL: "http://localhost:3000/"}
+	return NewAccountService(mockUserRepository{}, tr, m, testHasher, cfg, "example-secret-for-testing"), tr, m
+}
+
+func TestAccountService_SendVerification(t *testing.T) {
+	var tests = []struct {
+		name         string
+		email        string
+		expectsMail  bool
+		expectsError bool
+	}{
+		{"unverified user gets a link", "test@example.com", true, false},
+		{"verified user is skipped", "verified@test.com", false, false},
+		{"unknown e-mail is ignored", "no-user-found@test.com", false, false},
+		{"repository error", "error@test.com", false, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			s, tr, m := newTestAccountService()
+
+			err := s.SendVerification(context.Background(), tc.email)
+			if tc.expectsError && err == nil {
+				t.Errorf("function is expected to return an error but it did not")
+			}
+			if !tc.expectsError && err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+
+			if tc.expectsMail != (len(m.sent) == 1) {
+				t.Fatalf("expected mail sent: %v, got %d messages", tc.expectsMail, len(m.sent))
+			}
+			if !tc.expectsMail {
+				return
+			}
+			if m.sent[0].To != tc.email {
+				t.Errorf("mail sent to %s instead of %s", m.sent[0].To, tc.email)
+			}
+			if !strings.Contains(m.sent[0].Body, "http://localhost:3000/verify-email?token=") {
+				t.Errorf("mail should link to the verification page: %s", m.sent[0].Body)
+			}
+			token := tokenFromMail(t, m)
+			if _, ok := tr.tokens[token]; ok {
+				t.Errorf("token must not be stored in plain text")
+			}
+		})
+	}
+}
+
+func TestAccountService_VerifyEmail(t *testing.T) {
+	s, _, m := newTestAccountService()
+	if err := s.SendVerification(context.Background(), "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := tokenFromMail(t, m)
+	forged := strings.Split(token, ".")[0] + ".c2lnbmF0dXJl"
+
+	var tests = []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{"valid token", token, nil},
+		{"token used twice", token, ErrInvalidToken},
+		{"forged signature", forged, ErrInvalidToken},
+		{"malformed token", "not-a-token", ErrInvalidToken},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.VerifyEmail(context.Background(), tc.token)
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error <%v> but got <%v>", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestAccountService_ResetPassword(t *testing.T) {
+	s, _, m := newTestAccountService()
+
+	if err := s.ForgotPassword(context.Background(), "no-user-found@test.com"); err != nil {
+		t.Errorf("unknown e-mail should not return an error: %s", err)
+	}
+	if len(m.sent) != 0 {
+		t.Fatalf("no e-mail should be sent for unknown addresses")
+	}
+
+	if err := s.ForgotPassword(context.Background(), "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	first := tokenFromMail(t, m)
+	if err := s.ForgotPassword(context.Background(), "test@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	token := tokenFromMail(t, m)
+
+	if err := s.VerifyEmail(context.Background(), token); !errors.Is(err, ErrInvalidToken) {
+		t.Errorf("reset token must not verify an e-mail, got %v", err)
+	}
+
+	var tests = []struct {
+		name        string
+		token       string
+		expectedErr error
+	}{
+		{"superseded token", first, ErrInvalidToken},
+		{"valid token", token, nil},
+		{"token used twice", token, ErrInvalidToken},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.ResetPassword(context.Background(), tc.token, "newPassword")
+			if !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error <%v> but got <%v>", tc.expectedErr, err)
+			}
+		})
+	}
+}
+
+func TestSignToken(t *testing.T) {
+	secret := []byte("example-secret-for-testing")
+	token, err := signToken(secret, models.TokenPurposeVerifyEmail)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var tests = []struct {
+		name     string
+		secret   []byte
+		purpose  string
+		token    string
+		expected bool
+	}{
+		{"valid", secret, models.TokenPurposeVerifyEmail, token, true},
+		{"other purpose", secret, models.TokenPurposeResetPassword, token, false},
+		{"other secret", []byte("another-secret"), models.TokenPurposeVerifyEmail, token, false},
+		{"tampered", secret, models.TokenPurposeVerifyEmail, "x" + token, false},
+		{"no signature", secret, models.TokenPurposeVerifyEmail, strings.Split(token, ".")[0], false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := verifyToken(tc.secret, tc.purpose, tc.token); got != tc.expected {
+				t.Errorf("expected %v but got %v", tc.expected, got)
+			}
+		})
+	}
+}
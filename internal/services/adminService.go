@@ -0,0 +1,305 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
+	"time"
+)
+
+// ErrEmailTaken is returned by the admin operations when an account already uses the e-mail.
+var ErrEmailTaken = errors.New("email already in use")
+
+// AdminService backs the operator commands of the CLI. Unlike the HTTP services it trusts its
+// caller: no current password is asked for and exports include password hashes.
+type AdminService interface {
+	CreateUser(ctx context.Context, p models.CreateUserPayload, verified bool) (models.User, error)
+	SetPassword(ctx context.Context, email, password string) error
+	PromoteAdmin(ctx context.Context, email string) (models.User, error)
+	PurgeTrash(ctx context.Context, before time.Time) (int64, error)
+	Export(ctx context.Context) (models.DataExport, error)
+	Import(ctx context.Context, e models.DataExport, skipExisting bool) (ImportResult, error)
+}
+
+// ImportResult counts what Import wrote; Skipped holds accounts left alone because their
+// e-mail was already registered.
+type ImportResult struct {
+	Users   int
+	Tasks   int
+	Skipped int
+}
+
+type adminService struct {
+	ur repository.UserRepository
+	tr repository.TaskRepository
+	tk repository.TokenRepository
+	uw repository.UnitOfWork
+	h  password.Hasher
+}
+
+func NewAdminService(ur repository.UserRepository, tr repository.TaskRepository, tk repository.TokenRepository, uw repository.UnitOfWork, h password.Hasher) AdminService {
+	return &adminService{
+		ur: ur,
+		tr: tr,
+		tk: tk,
+		uw: uw,
+		h:  h,
+	}
+}
+
+// CreateUser registers an account with the given plain text password, optionally marking the
+// e-mail as verified so the user can log in without going through the verification mail.
+func (s adminService) CreateUser(ctx context.Context, p models.CreateUserPayload, verified bool) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "AdminService.CreateUser")
+	defer span.End()
+
+	exists, err := s.ur.CheckIfEmailExists(ctx, p.Email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("CreateUser: failed to check if email is unique: %v", err)
+	}
+	if exists {
+		return models.User{}, fmt.Errorf("CreateUser: %w", ErrEmailTaken)
+	}
+
+	p.Password, err = s.h.Hash(p.Password)
+	if err != nil {
+		return models.User{}, fmt.Errorf("CreateUser: failed to hash a password: %v", err)
+	}
+
+	var u models.User
+	err = s.uw.Do(ctx, func(ctx context.Context) error {
+		if err := s.ur.CreateUser(ctx, p); err != nil {
+			return err
+		}
+
+		u, err = s.ur.GetUserByEmail(ctx, p.Email)
+		if err != nil {
+			return fmt.Errorf("failed to get the new user: %v", err)
+		}
+		if verified {
+			now := time.Now()
+			if err := s.ur.MarkEmailVerified(ctx, int64(u.ID), now); err != nil {
+				return err
+			}
+			u.EmailVerifiedAt = &now
+		}
+		return nil
+	})
+	if err != nil {
+		return models.User{}, fmt.Errorf("CreateUser: %v", err)
+	}
+
+	return u, nil
+}
+
+// SetPassword replaces the password of the account registered with email and revokes any
+// outstanding reset links, which would otherwise still allow overriding it.
+func (s adminService) SetPassword(ctx context.Context, email, password string) error {
+	ctx, span := tracing.Start(ctx, "AdminService.SetPassword")
+	defer span.End()
+
+	u, err := s.ur.GetUserByEmail(ctx, email)
+	if err != nil {
+		return fmt.Errorf("SetPassword: failed to get user: %w", err)
+	}
+
+	hash, err := s.h.Hash(password)
+	if err != nil {
+		return fmt.Errorf("SetPassword: failed to hash a password: %v", err)
+	}
+
+	return s.uw.Do(ctx, func(ctx context.Context) error {
+		if err := s.ur.UpdatePassword(ctx, int64(u.ID), hash); err != nil {
+			return fmt.Errorf("SetPassword: %v", err)
+		}
+		if err := s.tk.DeleteForUser(ctx, int64(u.ID), models.TokenPurposeResetPassword); err != nil {
+			return fmt.Errorf("SetPassword: failed to revoke reset tokens: %v", err)
+		}
+		return nil
+	})
+}
+
+// PromoteAdmin grants the account registered with email the admin role.
+func (s adminService) PromoteAdmin(ctx context.Context, email string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "AdminService.PromoteAdmin")
+	defer span.End()
+
+	u, err := s.ur.GetUserByEmail(ctx, email)
+	if err != nil {
+		return models.User{}, fmt.Errorf("PromoteAdmin: failed to get user: %w", err)
+	}
+
+	if err := s.ur.UpdateRole(ctx, int64(u.ID), models.RoleAdmin); err != nil {
+		return models.User{}, fmt.Errorf("PromoteAdmin: %w", err)
+	}
+	u.Role = models.RoleAdmin
+
+	return u, nil
+}
+
+// PurgeTrash deletes the tasks their owners deleted before the given time for good and
+// returns how many there were.
+func (s adminService) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "AdminService.PurgeTrash")
+	defer span.End()
+
+	n, err := s.tr.PurgeTrash(ctx, before)
+	if err != nil {
+		return 0, fmt.Errorf("PurgeTrash: %v", err)
+	}
+
+	return n, nil
+}
+
+// Export collects every account together with its tasks. Tasks kept anonymously after their
+// owner deleted the account have no one to be restored to and are left out.
+func (s adminService) Export(ctx context.Context) (models.DataExport, error) {
+	ctx, span := tracing.Start(ctx, "AdminService.Export")
+	defer span.End()
+
+	users, err := s.ur.ListUsers(ctx)
+	if err != nil {
+		return models.DataExport{}, fmt.Errorf("Export: failed to list users: %v", err)
+	}
+
+	e := models.DataExport{
+		Version:    models.ExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Users:      make([]models.ExportUser, 0, len(users)),
+	}
+	for _, u := range users {
+		l, err := s.tr.Index(ctx, int64(u.ID))
+		if err != nil {
+			return models.DataExport{}, fmt.Errorf("Export: failed to list tasks of user %d: %v", u.ID, err)
+		}
+
+		eu := models.ExportUser{
+			Name:            u.Name,
+			Email:           u.Email,
+			Password:        u.Password,
+			CreatedAt:       u.CreatedAt,
+			EmailVerifiedAt: u.EmailVerifiedAt,
+			Tasks:           make([]models.ExportTask, 0, len(l.Tasks)),
+		}
+		if u.Role != models.RoleUser {
+			eu.Role = u.Role
+		}
+		for _, t := range l.Tasks {
+			eu.Tasks = append(eu.Tasks, models.ExportTask{
+				Name:        t.Name,
+				Priority:    t.Priority,
+				Description: t.Description,
+				DueDate:     t.DueDate,
+				CreatedAt:   t.CreatedAt,
+			})
+		}
+		e.Users = append(e.Users, eu)
+	}
+
+	return e, nil
+}
+
+// Import recreates the accounts and tasks of an export. Conflicting e-mails are reported before
+// anything is written unless skipExisting is set, in which case those accounts are skipped.
+// Accounts get new ids and their creation time is the time of the import. The import is
+// atomic: when any account or task cannot be written, nothing is.
+func (s adminService) Import(ctx context.Context, e models.DataExport, skipExisting bool) (ImportResult, error) {
+	ctx, span := tracing.Start(ctx, "AdminService.Import")
+	defer span.End()
+
+	if e.Version != models.ExportVersion {
+		return ImportResult{}, fmt.Errorf("Import: unsupported export version %d", e.Version)
+	}
+
+	var taken []string
+	skip := make(map[string]bool)
+	seen := make(map[string]bool)
+	for _, u := range e.Users {
+		if u.Email == "" || u.Password == "" {
+			return ImportResult{}, fmt.Errorf("Import: user %q is missing an email or password", u.Name)
+		}
+		if u.Role != "" && u.Role != models.RoleUser && u.Role != models.RoleAdmin {
+			return ImportResult{}, fmt.Errorf("Import: user %s has unknown role %q", u.Email, u.Role)
+		}
+		if seen[u.Email] {
+			return ImportResult{}, fmt.Errorf("Import: %s appears more than once", u.Email)
+		}
+		seen[u.Email] = true
+
+		exists, err := s.ur.CheckIfEmailExists(ctx, u.Email)
+		if err != nil {
+			return ImportResult{}, fmt.Errorf("Import: failed to check if email is unique: %v", err)
+		}
+		if exists {
+			taken = append(taken, u.Email)
+			skip[u.Email] = true
+		}
+	}
+	if len(taken) > 0 && !skipExisting {
+		return ImportResult{}, fmt.Errorf("Import: %w: %s", ErrEmailTaken, strings.Join(taken, ", "))
+	}
+
+	var res ImportResult
+	err := s.uw.Do(ctx, func(ctx context.Context) error {
+		res = ImportResult{Skipped: len(taken)}
+		for _, eu := range e.Users {
+			if skip[eu.Email] {
+				continue
+			}
+			if err := s.importUser(ctx, eu, &res); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ImportResult{}, fmt.Errorf("Import: %v", err)
+	}
+
+	return res, nil
+}
+
+func (s adminService) importUser(ctx context.Context, eu models.ExportUser, res *ImportResult) error {
+	p := models.CreateUserPayload{Name: eu.Name, Email: eu.Email, Password: eu.Password}
+	if err := s.ur.CreateUser(ctx, p); err != nil {
+		return err
+	}
+	u, err := s.ur.GetUserByEmail(ctx, eu.Email)
+	if err != nil {
+		return fmt.Errorf("failed to get the new user: %v", err)
+	}
+	res.Users++
+	if eu.EmailVerifiedAt != nil {
+		if err := s.ur.MarkEmailVerified(ctx, int64(u.ID), *eu.EmailVerifiedAt); err != nil {
+			return err
+		}
+	}
+	if eu.Role != "" && eu.Role != models.RoleUser {
+		if err := s.ur.UpdateRole(ctx, int64(u.ID), eu.Role); err != nil {
+			return err
+		}
+	}
+
+	uCtx := context.WithValue(ctx, contextkeys.UserID, int64(u.ID))
+	for _, t := range eu.Tasks {
+		_, err := s.tr.Store(uCtx, models.TaskPayload{
+			Name:        t.Name,
+			Priority:    t.Priority,
+			Description: t.Description,
+			DueDate:     t.DueDate,
+			CreatedAt:   t.CreatedAt,
+		})
+		if err != nil {
+			return fmt.Errorf("failed to store a task of %s: %v", eu.Email, err)
+		}
+		res.Tasks++
+	}
+
+	return nil
+}
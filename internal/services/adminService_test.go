@@ -0,0 +1,254 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/repository/memory"
+	"testing"
+	"time"
+)
+
+func newTestAdminService(r repository.Repositories) AdminService {
+	return NewAdminService(r.Ur, r.Tr, r.Tk, r.Uw, testHasher)
+}
+
+func TestAdminService_CreateUser(t *testing.T) {
+	ctx := context.Background()
+	r := memory.New()
+	s := newTestAdminService(r)
+
+	p := models.CreateUserPayload{Name: "Admin", Email: "admin@example.com", Password: "loremIpsum"}
+	u, err := s.CreateUser(ctx, p, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.ID == 0 || u.EmailVerifiedAt == nil {
+		t.Errorf("expected a verified user with an id but got %+v", u)
+	}
+	if ok, _ := testHasher.Verify("loremIpsum", u.Password); !ok {
+		t.Errorf("the password should be stored hashed")
+	}
+
+	if _, err := s.CreateUser(ctx, p, false); !errors.Is(err, ErrEmailTaken) {
+		t.Errorf("expected ErrEmailTaken but got %v", err)
+	}
+}
+
+func TestAdminService_SetPassword(t *testing.T) {
+	ctx := context.Background()
+	r := memory.New()
+	s := newTestAdminService(r)
+
+	u, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: "lorem@example.com", Password: "loremIpsum"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	tok := models.UserToken{UserID: int64(u.ID), Purpose: models.TokenPurposeResetPassword, Hash: "reset-hash", ExpiresAt: now.Add(time.Hour), CreatedAt: now}
+	if err := r.Tk.Store(ctx, tok); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := s.SetPassword(ctx, "lorem@example.com", "dolorSit"); err != nil {
+		t.Fatal(err)
+	}
+	got, _ := r.Ur.GetUserByID(ctx, int64(u.ID))
+	if ok, _ := testHasher.Verify("dolorSit", got.Password); !ok {
+		t.Errorf("the new password should be accepted")
+	}
+	if _, err := r.Tk.Consume(ctx, models.TokenPurposeResetPassword, "reset-hash", time.Now()); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("reset tokens should be revoked but got %v", err)
+	}
+
+	if err := s.SetPassword(ctx, "unknown@example.com", "dolorSit"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestAdminService_PromoteAdmin(t *testing.T) {
+	ctx := context.Background()
+	r := memory.New()
+	s := newTestAdminService(r)
+
+	u, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: "lorem@example.com", Password: "loremIpsum"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if u.Role != models.RoleUser {
+		t.Errorf("new accounts should have role %s but got %s", models.RoleUser, u.Role)
+	}
+
+	promoted, err := s.PromoteAdmin(ctx, "lorem@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := r.Ur.GetUserByID(ctx, int64(u.ID))
+	if promoted.Role != models.RoleAdmin || got.Role != models.RoleAdmin {
+		t.Errorf("expected role %s but got %s, stored %s", models.RoleAdmin, promoted.Role, got.Role)
+	}
+
+	if _, err := s.PromoteAdmin(ctx, "unknown@example.com"); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
+
+func TestAdminService_PurgeTrash(t *testing.T) {
+	ctx := context.Background()
+	r := memory.New()
+	s := newTestAdminService(r)
+
+	u, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: "lorem@example.com", Password: "loremIpsum"}, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	uCtx := context.WithValue(ctx, contextkeys.UserID, int64(u.ID))
+	task, err := r.Tr.Store(uCtx, models.TaskPayload{Name: "Dolor", Priority: models.PriorityLow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Tr.Delete(uCtx, task.ID); err != nil {
+		t.Fatal(err)
+	}
+
+	if n, err := s.PurgeTrash(ctx, time.Now().Add(-time.Hour)); err != nil || n != 0 {
+		t.Errorf("recently trashed tasks should be kept, purged %d, %v", n, err)
+	}
+	if n, err := s.PurgeTrash(ctx, time.Now().Add(time.Minute)); err != nil || n != 1 {
+		t.Errorf("expected 1 purged task but got %d, %v", n, err)
+	}
+}
+
+func TestAdminService_ExportImport(t *testing.T) {
+	ctx := context.Background()
+	src := memory.New()
+	s := newTestAdminService(src)
+
+	u, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Lorem", Email: "lorem@example.com", Password: "loremIpsum"}, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Ipsum", Email: "ipsum@example.com", Password: "loremIpsum"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := s.PromoteAdmin(ctx, "lorem@example.com"); err != nil {
+		t.Fatal(err)
+	}
+	due := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	uCtx := context.WithValue(ctx, contextkeys.UserID, int64(u.ID))
+	if _, err := src.Tr.Store(uCtx, models.TaskPayload{Name: "Dolor", Priority: models.PriorityHigh, DueDate: &due}); err != nil {
+		t.Fatal(err)
+	}
+
+	e, err := s.Export(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Version != models.ExportVersion || len(e.Users) != 2 || len(e.Users[0].Tasks) != 1 {
+		t.Fatalf("unexpected export %+v", e)
+	}
+
+	dst := memory.New()
+	res, err := newTestAdminService(dst).Import(ctx, e, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res != (ImportResult{Users: 2, Tasks: 1}) {
+		t.Errorf("unexpected result %+v", res)
+	}
+
+	got, err := dst.Ur.GetUserByEmail(ctx, "lorem@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got.Password != u.Password || got.EmailVerifiedAt == nil || got.Role != models.RoleAdmin {
+		t.Errorf("the password hash, verification and role should be kept but got %+v", got)
+	}
+	l, _ := dst.Tr.Index(ctx, int64(got.ID))
+	if len(l.Tasks) != 1 || l.Tasks[0].Name != "Dolor" || !l.Tasks[0].DueDate.Equal(due) {
+		t.Errorf("unexpected tasks %+v", l.Tasks)
+	}
+	other, _ := dst.Ur.GetUserByEmail(ctx, "ipsum@example.com")
+	if other.EmailVerifiedAt != nil || other.Role != models.RoleUser {
+		t.Errorf("an unverified user should stay an unverified user, got %+v", other)
+	}
+}
+
+func TestAdminService_Import(t *testing.T) {
+	ctx := context.Background()
+	user := func(email string) models.ExportUser {
+		return models.ExportUser{Name: "Lorem", Email: email, Password: mockPassword}
+	}
+
+	tests := []struct {
+		name         string
+		export       models.DataExport
+		skipExisting bool
+		expected     ImportResult
+		expectedErr  error
+		fails        bool
+	}{
+		{
+			name:   "new users are imported",
+			export: models.DataExport{Version: models.ExportVersion, Users: []models.ExportUser{user("new@example.com")}},
+			expected: ImportResult{
+				Users: 1,
+			},
+		},
+		{
+			name:        "existing users abort the import",
+			export:      models.DataExport{Version: models.ExportVersion, Users: []models.ExportUser{user("new@example.com"), user("taken@example.com")}},
+			expectedErr: ErrEmailTaken,
+		},
+		{
+			name:         "existing users can be skipped",
+			export:       models.DataExport{Version: models.ExportVersion, Users: []models.ExportUser{user("new@example.com"), user("taken@example.com")}},
+			skipExisting: true,
+			expected:     ImportResult{Users: 1, Skipped: 1},
+		},
+		{
+			name:   "duplicate users are rejected",
+			export: models.DataExport{Version: models.ExportVersion, Users: []models.ExportUser{user("new@example.com"), user("new@example.com")}},
+			fails:  true,
+		},
+		{
+			name:   "unknown roles are rejected",
+			export: models.DataExport{Version: models.ExportVersion, Users: []models.ExportUser{{Name: "Lorem", Email: "new@example.com", Password: mockPassword, Role: "root"}}},
+			fails:  true,
+		},
+		{
+			name:   "unknown versions are rejected",
+			export: models.DataExport{Version: models.ExportVersion + 1},
+			fails:  true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := memory.New()
+			s := newTestAdminService(r)
+			if _, err := s.CreateUser(ctx, models.CreateUserPayload{Name: "Taken", Email: "taken@example.com", Password: "loremIpsum"}, false); err != nil {
+				t.Fatal(err)
+			}
+
+			res, err := s.Import(ctx, tt.export, tt.skipExisting)
+			if tt.fails || tt.expectedErr != nil {
+				if err == nil || (tt.expectedErr != nil && !errors.Is(err, tt.expectedErr)) {
+					t.Errorf("expected error %v but got %v", tt.expectedErr, err)
+				}
+				if exists, _ := r.Ur.CheckIfEmailExists(ctx, "new@example.com"); exists {
+					t.Errorf("nothing should be written when the import is rejected")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if res != tt.expected {
+				t.Errorf("expected %+v but got %+v", tt.expected, res)
+			}
+		})
+	}
+}
@@ -1,36 +1,70 @@
 package services
 
 import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"errors"
 	"fmt"
 	"task-manager/internal/config"
 	"task-manager/internal/models"
+	"task-manager/internal/tracing"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
 )
 
+const (
+	// TokenTTL is how long issued JWTs stay valid.
+	TokenTTL = 24 * time.Hour
+	// MFAChallengeTTL is how long the second login step can take.
+	MFAChallengeTTL = 5 * time.Minute
+
+	challengePurpose = "mfa_challenge"
+)
+
+var (
+	// ErrInvalidAccessToken is returned for access tokens that are forged, malformed or expired.
+	ErrInvalidAccessToken = errors.New("invalid token")
+	// ErrInvalidChallenge is returned for MFA challenge tokens that are forged or expired.
+	ErrInvalidChallenge = errors.New("invalid or expired mfa challenge")
+)
+
 type AuthService interface {
-	CreateToken(user models.User) (string, error)
+	CreateToken(ctx context.Context, user models.User) (string, error)
+	ParseToken(ctx context.Context, token string) (models.User, error)
+	CreateChallengeToken(ctx context.Context, user models.User) (string, error)
+	ParseChallengeToken(ctx context.Context, token string) (models.User, error)
 }
 
 type authService struct {
-	jwtSecret []byte
+	jwtSecret       []byte
+	challengeSecret []byte
 }
 
+// NewAuthService creates the service. Challenge tokens are signed with a key derived from the
+// JWT secret, so they can never pass as access tokens.
 func NewAuthService(c config.JWTConfig) AuthService {
+	mac := hmac.New(sha256.New, []byte(c.Secret))
+	mac.Write([]byte(challengePurpose))
+
 	return &authService{
-		jwtSecret: []byte(c.Secret),
+		jwtSecret:       []byte(c.Secret),
+		challengeSecret: mac.Sum(nil),
 	}
 }
 
-func (a authService) CreateToken(u models.User) (string, error) {
+func (a authService) CreateToken(ctx context.Context, u models.User) (string, error) {
+	_, span := tracing.Start(ctx, "AuthService.CreateToken")
+	defer span.End()
+
 	if u.Email == "" || u.ID == 0 {
 		return "", fmt.Errorf("invalid user data provided")
 	}
 	claims := jwt.MapClaims{
 		"username": u.Email,
 		"userId":   u.ID,
-		"exp":      time.Now().Add(24 * time.Hour).Unix(),
+		"exp":      time.Now().Add(TokenTTL).Unix(),
 	}
 
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
@@ -41,3 +75,87 @@ func (a authService) CreateToken(u models.User) (string, error) {
 
 	return stringToken, nil
 }
+
+// ParseToken verifies an access token, returning the user it was issued to with only ID and
+// Email set. Callers still have to check that the user exists.
+func (a authService) ParseToken(ctx context.Context, token string) (models.User, error) {
+	_, span := tracing.Start(ctx, "AuthService.ParseToken")
+	defer span.End()
+
+	t, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		if _, ok := t.Method.(*jwt.SigningMethodHMAC); !ok {
+			return nil, fmt.Errorf("invalid sign method")
+		}
+		return a.jwtSecret, nil
+	})
+	if err != nil || !t.Valid {
+		return models.User{}, ErrInvalidAccessToken
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok {
+		return models.User{}, ErrInvalidAccessToken
+	}
+	email, ok := claims["username"].(string)
+	if !ok {
+		return models.User{}, ErrInvalidAccessToken
+	}
+	id, ok := claims["userId"].(float64)
+	if !ok {
+		return models.User{}, ErrInvalidAccessToken
+	}
+
+	return models.User{ID: int(id), Email: email}, nil
+}
+
+// CreateChallengeToken is issued after a correct password when the user has two-factor
+// authentication enabled; it is exchanged for an access token together with a code.
+func (a authService) CreateChallengeToken(ctx context.Context, u models.User) (string, error) {
+	_, span := tracing.Start(ctx, "AuthService.CreateChallengeToken")
+	defer span.End()
+
+	if u.Email == "" || u.ID == 0 {
+		return "", fmt.Errorf("invalid user data provided")
+	}
+	claims := jwt.MapClaims{
+		"username": u.Email,
+		"userId":   u.ID,
+		"purpose":  challengePurpose,
+		"exp":      time.Now().Add(MFAChallengeTTL).Unix(),
+	}
+
+	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
+	stringToken, err := token.SignedString(a.challengeSecret)
+	if err != nil {
+		return "", fmt.Errorf("CreateChallengeToken: failed to create token string: %v", err)
+	}
+
+	return stringToken, nil
+}
+
+func (a authService) ParseChallengeToken(ctx context.Context, token string) (models.User, error) {
+	_, span := tracing.Start(ctx, "AuthService.ParseChallengeToken")
+	defer span.End()
+
+	t, err := jwt.Parse(token, func(t *jwt.Token) (any, error) {
+		return a.challengeSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !t.Valid {
+		return models.User{}, ErrInvalidChallenge
+	}
+
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != challengePurpose {
+		return models.User{}, ErrInvalidChallenge
+	}
+	email, ok := claims["username"].(string)
+	if !ok {
+		return models.User{}, ErrInvalidChallenge
+	}
+	id, ok := claims["userId"].(float64)
+	if !ok {
+		return models.User{}, ErrInvalidChallenge
+	}
+
+	return models.User{ID: int(id), Email: email}, nil
+}
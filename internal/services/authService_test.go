@@ -1,6 +1,8 @@
 package services
 
 import (
+	"context"
+	"errors"
 	"task-manager/internal/config"
 	"task-manager/internal/models"
 	"testing"
@@ -40,7 +42,7 @@ func TestAuthService_CreateToken(t *testing.T) {
 		t.Run(tc.name, func(t *testing.T) {
 			s := NewAuthService(c)
 
-			token, err := s.CreateToken(tc.inputUser)
+			token, err := s.CreateToken(context.Background(), tc.inputUser)
 			if tc.expectsError {
 				if err == nil {
 					t.Errorf("function was supposed to return an error but it did not")
@@ -102,3 +104,120 @@ func TestAuthService_CreateToken(t *testing.T) {
 		})
 	}
 }
+
+func TestAuthService_ChallengeToken(t *testing.T) {
+	c := config.JWTConfig{Secret: "secret-for-testing"}
+	s := NewAuthService(c)
+	u := models.User{ID: 1, Name: "Lorem Ipsum", Email: "lorem@ipsum.com"}
+
+	challenge, err := s.CreateChallengeToken(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	access, err := s.CreateToken(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": u.Email,
+		"userId":   u.ID,
+		"purpose":  challengePurpose,
+		"exp":      time.Now().Add(-time.Minute).Unix(),
+	}).SignedString(s.(*authService).challengeSecret)
+
+	var tests = []struct {
+		name         string
+		token        string
+		expectsError bool
+	}{
+		{"valid challenge", challenge, false},
+		{"access token is not a challenge", access, true},
+		{"expired challenge", expired, true},
+		{"garbage", "not-a-token", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.ParseChallengeToken(context.Background(), tc.token)
+			if tc.expectsError {
+				if !errors.Is(err, ErrInvalidChallenge) {
+					t.Errorf("expected ErrInvalidChallenge but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.ID != u.ID || got.Email != u.Email {
+				t.Errorf("wrong user returned: %v", got)
+			}
+		})
+	}
+
+	// a challenge must not be accepted where access tokens are verified
+	_, err = jwt.Parse(challenge, func(token *jwt.Token) (any, error) {
+		return []byte(c.Secret), nil
+	})
+	if err == nil {
+		t.Errorf("challenge token should not verify with the access token secret")
+	}
+}
+
+func TestAuthService_ParseToken(t *testing.T) {
+	c := config.JWTConfig{Secret: "secret-for-testing"}
+	s := NewAuthService(c)
+	u := models.User{ID: 1, Name: "Lorem Ipsum", Email: "lorem@ipsum.com"}
+
+	access, err := s.CreateToken(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := s.CreateChallengeToken(context.Background(), u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expired, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": u.Email,
+		"userId":   u.ID,
+		"exp":      time.Now().Add(-time.Minute).Unix(),
+	}).SignedString([]byte(c.Secret))
+	forged, _ := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"username": u.Email,
+		"userId":   u.ID,
+	}).SignedString([]byte("another-secret"))
+	unsigned, _ := jwt.NewWithClaims(jwt.SigningMethodNone, jwt.MapClaims{
+		"username": u.Email,
+		"userId":   u.ID,
+	}).SignedString(jwt.UnsafeAllowNoneSignatureType)
+
+	var tests = []struct {
+		name         string
+		token        string
+		expectsError bool
+	}{
+		{"valid token", access, false},
+		{"challenge is not an access token", challenge, true},
+		{"expired token", expired, true},
+		{"signed with another secret", forged, true},
+		{"unsigned token", unsigned, true},
+		{"garbage", "not-a-token", true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := s.ParseToken(context.Background(), tc.token)
+			if tc.expectsError {
+				if !errors.Is(err, ErrInvalidAccessToken) {
+					t.Errorf("expected ErrInvalidAccessToken but got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got.ID != u.ID || got.Email != u.Email {
+				t.Errorf("wrong user returned: %v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,166 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"task-manager/internal/config"
+	"task-manager/internal/logging"
+	"task-manager/internal/metrics"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
+	"time"
+)
+
+// ErrInvalidCredentials is matched by every AuthError, whatever the reason of the failure.
+var ErrInvalidCredentials = errors.New("invalid credentials")
+
+// AuthFailure is the reason a login was rejected.
+type AuthFailure string
+
+const (
+	AuthFailureUnknownUser   AuthFailure = "unknown_user"
+	AuthFailureWrongPassword AuthFailure = "wrong_password"
+)
+
+// AuthError is returned by Authenticate for rejected credentials. The reason is meant for logs
+// and metrics only; clients get the same answer for an unknown e-mail and a wrong password.
+type AuthError struct {
+	Reason AuthFailure
+	// UserID is 0 when no account uses the e-mail.
+	UserID int64
+}
+
+func (e *AuthError) Error() string {
+	return fmt.Sprintf("%v: %s", ErrInvalidCredentials, e.Reason)
+}
+
+func (e *AuthError) Is(target error) bool {
+	return target == ErrInvalidCredentials
+}
+
+type Authenticator interface {
+	Authenticate(ctx context.Context, p models.LoginPayload) (models.User, error)
+	Spend(ctx context.Context, p models.LoginPayload)
+}
+
+type authenticator struct {
+	r   repository.UserRepository
+	h   password.Hasher
+	cfg config.AuthConfig
+	now func() time.Time
+	// dummyHash is verified for unknown users so they take as long as a wrong password
+	dummyHash string
+}
+
+// NewAuthenticator creates the service checking e-mail and password logins; with
+// cfg.RequireVerifiedEmail set, accounts with a pending verification are rejected.
+func NewAuthenticator(r repository.UserRepository, h password.Hasher, cfg config.AuthConfig) Authenticator {
+	// the dummy is made by the same hasher, so it costs the same as the hashes of real users
+	dummyHash, _ := h.Hash(rand.Text())
+
+	return &authenticator{
+		r:         r,
+		h:         h,
+		cfg:       cfg,
+		now:       time.Now,
+		dummyHash: dummyHash,
+	}
+}
+
+// Authenticate checks the credentials of p. Rejected credentials are reported as *AuthError,
+// an unverified e-mail as ErrEmailNotVerified and anything else is an internal error. A
+// successful check of the password is recorded as the last login and upgrades an outdated
+// hash; the second factor, if any, is checked by the caller.
+func (a authenticator) Authenticate(ctx context.Context, p models.LoginPayload) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "Authenticator.Authenticate")
+	defer span.End()
+
+	u, err := a.r.GetUserByEmail(ctx, p.Email)
+	if errors.Is(err, repository.ErrNotFound) {
+		_, verifySpan := tracing.Start(ctx, "password.verify")
+		_, _ = a.h.Verify(p.Password, a.dummyHash)
+		verifySpan.End()
+
+		return models.User{}, a.fail(&AuthError{Reason: AuthFailureUnknownUser})
+	}
+	if err != nil {
+		return models.User{}, fmt.Errorf("Authenticate: failed to get user: %v", err)
+	}
+
+	_, verifySpan := tracing.Start(ctx, "password.verify")
+	ok, err := a.h.Verify(p.Password, u.Password)
+	verifySpan.End()
+	if err != nil {
+		return models.User{}, fmt.Errorf("Authenticate: failed to verify password: %v", err)
+	}
+
+	now := a.now()
+	if !ok {
+		// the failure is reported either way, a 500 for known users only would give them away
+		if err := a.r.RecordLoginFailure(ctx, int64(u.ID), now); err != nil {
+			logging.FromContext(ctx).Warn("failed to record failed login", "user_id", u.ID, "err", err)
+		}
+		return models.User{}, a.fail(&AuthError{Reason: AuthFailureWrongPassword, UserID: int64(u.ID)})
+	}
+	if a.cfg.RequireVerifiedEmail && u.EmailVerifiedAt == nil {
+		return models.User{}, fmt.Errorf("Authenticate: %w", ErrEmailNotVerified)
+	}
+
+	if a.h.NeedsRehash(u.Password) {
+		// the login itself succeeded, a failed upgrade is retried on the next one
+		if err := a.rehash(ctx, &u, p.Password); err != nil {
+			logging.FromContext(ctx).Warn("failed to upgrade password hash", "user_id", u.ID, "err", err)
+		}
+	}
+
+	if err := a.r.RecordLoginSuccess(ctx, int64(u.ID), now); err != nil {
+		logging.FromContext(ctx).Warn("failed to record login", "user_id", u.ID, "err", err)
+	} else {
+		u.LastLoginAt = &now
+	}
+
+	return u, nil
+}
+
+// Spend verifies the password of p like Authenticate, but records nothing and keeps the result
+// to itself. Logins rejected before their password is checked, such as those of a locked
+// account, go through it so they take as long as any other.
+func (a authenticator) Spend(ctx context.Context, p models.LoginPayload) {
+	ctx, span := tracing.Start(ctx, "Authenticator.Spend")
+	defer span.End()
+
+	hash := a.dummyHash
+	if u, err := a.r.GetUserByEmail(ctx, p.Email); err == nil {
+		hash = u.Password
+	}
+
+	_, verifySpan := tracing.Start(ctx, "password.verify")
+	_, _ = a.h.Verify(p.Password, hash)
+	verifySpan.End()
+}
+
+func (a authenticator) fail(err *AuthError) error {
+	metrics.LoginFailures.WithLabelValues(string(err.Reason)).Inc()
+	return fmt.Errorf("Authenticate: %w", err)
+}
+
+// rehash replaces the stored hash of u with one made by the configured algorithm.
+func (a authenticator) rehash(ctx context.Context, u *models.User, plain string) error {
+	_, hashSpan := tracing.Start(ctx, "password.hash")
+	hash, err := a.h.Hash(plain)
+	hashSpan.End()
+	if err != nil {
+		return fmt.Errorf("rehash: failed to hash a password, %s", err)
+	}
+
+	if err := a.r.UpdatePassword(ctx, int64(u.ID), hash); err != nil {
+		return fmt.Errorf("rehash: failed to update password: %v", err)
+	}
+	u.Password = hash
+
+	return nil
+}
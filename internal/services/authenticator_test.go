@@ -0,0 +1,188 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"testing"
+	"time"
+)
+
+// recordingUserRepository serves a single user and records what the authenticator writes.
+type recordingUserRepository struct {
+	mockUserRepository
+	hash      string
+	updated   string
+	failures  int
+	successes int
+}
+
+func (m *recordingUserRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	if email != "test@example.com" {
+		return m.mockUserRepository.GetUserByEmail(ctx, email)
+	}
+	return models.User{ID: 1, Name: "Lorem Ipsum", Email: email, Password: m.hash}, nil
+}
+
+func (m *recordingUserRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	m.updated = hash
+	return nil
+}
+
+func (m *recordingUserRepository) RecordLoginSuccess(ctx context.Context, id int64, at time.Time) error {
+	m.successes++
+	return nil
+}
+
+func (m *recordingUserRepository) RecordLoginFailure(ctx context.Context, id int64, at time.Time) error {
+	m.failures++
+	return nil
+}
+
+// countingHasher counts verifications, to check unknown users are not answered faster.
+type countingHasher struct {
+	password.Hasher
+	verified int
+}
+
+func (h *countingHasher) Verify(password, hash string) (bool, error) {
+	h.verified++
+	return h.Hasher.Verify(password, hash)
+}
+
+func TestAuthenticator_Authenticate(t *testing.T) {
+	var tests = []struct {
+		name          string
+		payload       models.LoginPayload
+		expectedID    int
+		expectedError error
+		reason        AuthFailure
+	}{
+		{"correct login", models.LoginPayload{Email: "test@example.com", Password: "loremIpsum"}, 1, nil, ""},
+		{"unknown user", models.LoginPayload{Email: "no-user-found@test.com", Password: "loremIpsum"}, 0, ErrInvalidCredentials, AuthFailureUnknownUser},
+		{"wrong password", models.LoginPayload{Email: "test@example.com", Password: "DolorEt"}, 0, ErrInvalidCredentials, AuthFailureWrongPassword},
+		{"empty password", models.LoginPayload{Email: "test@example.com"}, 0, ErrInvalidCredentials, AuthFailureWrongPassword},
+		{"hash given as password", models.LoginPayload{Email: "test@example.com", Password: mockPassword}, 0, ErrInvalidCredentials, AuthFailureWrongPassword},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &recordingUserRepository{hash: mockPassword}
+			h := &countingHasher{Hasher: testHasher}
+			a := NewAuthenticator(r, h, config.AuthConfig{})
+
+			u, err := a.Authenticate(context.Background(), tc.payload)
+			if h.verified != 1 {
+				t.Errorf("every login should verify exactly one hash, got %d", h.verified)
+			}
+			if tc.expectedError == nil {
+				if err != nil {
+					t.Fatalf("unexpected error: %s", err)
+				}
+				if u.ID != tc.expectedID || u.LastLoginAt == nil || r.successes != 1 {
+					t.Errorf("login should be recorded for user %d, got %v", tc.expectedID, u)
+				}
+				return
+			}
+
+			if !errors.Is(err, tc.expectedError) {
+				t.Fatalf("expected %v but got %v", tc.expectedError, err)
+			}
+			var ae *AuthError
+			if !errors.As(err, &ae) || ae.Reason != tc.reason {
+				t.Errorf("expected reason %s but got %v", tc.reason, err)
+			}
+			if tc.reason == AuthFailureWrongPassword && (r.failures != 1 || ae.UserID != 1) {
+				t.Errorf("failed attempt should be recorded for the user, got %d", r.failures)
+			}
+			if r.successes != 0 {
+				t.Errorf("failed login must not be recorded as a login")
+			}
+		})
+	}
+}
+
+func TestAuthenticator_Authenticate_errors(t *testing.T) {
+	a := NewAuthenticator(mockUserRepository{}, testHasher, config.AuthConfig{RequireVerifiedEmail: true})
+
+	var tests = []struct {
+		name          string
+		email         string
+		expectedError error
+	}{
+		{"verified user logs in", "verified@test.com", nil},
+		{"unverified user is rejected", "test@example.com", ErrEmailNotVerified},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := a.Authenticate(context.Background(), models.LoginPayload{Email: tc.email, Password: "loremIpsum"})
+			if !errors.Is(err, tc.expectedError) {
+				t.Errorf("expected %v but got %v", tc.expectedError, err)
+			}
+			if errors.Is(err, ErrInvalidCredentials) {
+				t.Errorf("%v should not be reported as invalid credentials", err)
+			}
+		})
+	}
+
+	_, err := a.Authenticate(context.Background(), models.LoginPayload{Email: "error@test.com", Password: "loremIpsum"})
+	if err == nil || errors.Is(err, ErrInvalidCredentials) {
+		t.Errorf("repository failures should not be reported as invalid credentials, got %v", err)
+	}
+}
+
+func TestAuthenticator_Spend(t *testing.T) {
+	legacy, _ := password.New(config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4}).Hash("loremIpsum")
+
+	for _, email := range []string{"test@example.com", "no-user-found@test.com"} {
+		r := &recordingUserRepository{hash: legacy}
+		h := &countingHasher{Hasher: testHasher}
+		a := NewAuthenticator(r, h, config.AuthConfig{})
+
+		for _, pw := range []string{"loremIpsum", "wrong"} {
+			a.Spend(context.Background(), models.LoginPayload{Email: email, Password: pw})
+		}
+		if h.verified != 2 {
+			t.Errorf("%s: every call should verify exactly one hash, got %d", email, h.verified)
+		}
+		if r.failures != 0 || r.successes != 0 || r.updated != "" {
+			t.Errorf("%s: nothing should be recorded, got %+v", email, r)
+		}
+	}
+}
+
+func TestAuthenticator_Authenticate_rehash(t *testing.T) {
+	legacy, _ := password.New(config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4}).Hash("loremIpsum")
+	r := &recordingUserRepository{hash: legacy}
+	a := NewAuthenticator(r, testHasher, config.AuthConfig{})
+
+	if _, err := a.Authenticate(context.Background(), models.LoginPayload{Email: "test@example.com", Password: "wrong"}); err == nil {
+		t.Fatalf("login with a wrong password should fail")
+	}
+	if r.updated != "" {
+		t.Errorf("hash should not be upgraded on a failed login")
+	}
+
+	u, err := a.Authenticate(context.Background(), models.LoginPayload{Email: "test@example.com", Password: "loremIpsum"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.HasPrefix(r.updated, "$argon2id$") || u.Password != r.updated {
+		t.Errorf("bcrypt hash should be replaced by an argon2id hash, got %q", r.updated)
+	}
+	if ok, _ := testHasher.Verify("loremIpsum", r.updated); !ok {
+		t.Errorf("upgraded hash should still match the password")
+	}
+
+	r.hash, r.updated = u.Password, ""
+	if _, err := a.Authenticate(context.Background(), models.LoginPayload{Email: "test@example.com", Password: "loremIpsum"}); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if r.updated != "" {
+		t.Errorf("current hash should not be replaced again")
+	}
+}
@@ -0,0 +1,84 @@
+package services
+
+import (
+	"context"
+	"fmt"
+	"task-manager/internal/config"
+	"task-manager/internal/events"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
+	"time"
+)
+
+const (
+	// EventBatchSize bounds the events After returns at once.
+	EventBatchSize = 100
+	// pendingRetry is how soon the streams of a user look again at events held back behind a
+	// transaction still running, whose end is only announced if it changed tasks of theirs.
+	pendingRetry = 250 * time.Millisecond
+)
+
+// EventService backs the streams of task changes. Subscriptions only signal that there may be
+// new events; streams read them with After from the id of the last event they sent.
+type EventService interface {
+	Subscribe(uID int64) *events.Subscription
+	After(ctx context.Context, uID, id int64) ([]models.TaskEvent, error)
+	LastID(ctx context.Context) (int64, error)
+	Prune(ctx context.Context, now time.Time) (int64, error)
+}
+
+type eventService struct {
+	r   repository.EventRepository
+	b   *events.Broker
+	cfg config.EventsConfig
+}
+
+func NewEventService(r repository.EventRepository, b *events.Broker, cfg config.EventsConfig) EventService {
+	return &eventService{r: r, b: b, cfg: cfg}
+}
+
+func (s eventService) Subscribe(uID int64) *events.Subscription {
+	return s.b.Subscribe(uID)
+}
+
+// After returns the next EventBatchSize events of the user following the event with the given id.
+// When later events are held back, the subscriptions of the user are woken again shortly.
+func (s eventService) After(ctx context.Context, uID, id int64) ([]models.TaskEvent, error) {
+	ctx, span := tracing.Start(ctx, "EventService.After")
+	defer span.End()
+
+	e, pending, err := s.r.After(ctx, uID, id, EventBatchSize)
+	if err != nil {
+		return nil, fmt.Errorf("After: %v", err)
+	}
+	if pending {
+		s.b.NotifyLater(uID, pendingRetry)
+	}
+	return e, nil
+}
+
+// LastID returns the position of a stream that should only receive events from now on.
+func (s eventService) LastID(ctx context.Context) (int64, error) {
+	ctx, span := tracing.Start(ctx, "EventService.LastID")
+	defer span.End()
+
+	id, err := s.r.LastID(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("LastID: %v", err)
+	}
+	return id, nil
+}
+
+// Prune deletes the events older than the configured retention, after which clients can no
+// longer resume from them.
+func (s eventService) Prune(ctx context.Context, now time.Time) (int64, error) {
+	ctx, span := tracing.Start(ctx, "EventService.Prune")
+	defer span.End()
+
+	n, err := s.r.DeleteBefore(ctx, now.Add(-s.cfg.Retention))
+	if err != nil {
+		return 0, fmt.Errorf("Prune: %v", err)
+	}
+	return n, nil
+}
@@ -0,0 +1,211 @@
+package services
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/totp"
+	"task-manager/internal/tracing"
+	"time"
+)
+
+const (
+	recoveryCodeCount = 10
+	recoveryCodeBytes = 10
+)
+
+var (
+	ErrMFAAlreadyEnabled = errors.New("two-factor authentication already enabled")
+	ErrMFANotEnrolled    = errors.New("two-factor authentication not enrolled")
+	// ErrInvalidMFACode is returned for wrong, replayed or already used codes.
+	ErrInvalidMFACode = errors.New("invalid authentication code")
+)
+
+var recoveryEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+type MFAService interface {
+	EnrolTOTP(ctx context.Context, u models.User) (models.TOTPEnrolment, error)
+	ConfirmTOTP(ctx context.Context, uID int64, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, uID int64, code string) error
+	IsEnabled(ctx context.Context, uID int64) (bool, error)
+	Verify(ctx context.Context, uID int64, code string) error
+}
+
+type mfaService struct {
+	r      repository.MFARepository
+	issuer string
+}
+
+func NewMFAService(r repository.MFARepository, cfg config.AuthConfig) MFAService {
+	return &mfaService{
+		r:      r,
+		issuer: cfg.MFAIssuer,
+	}
+}
+
+// EnrolTOTP generates a new secret for u. It stays inactive until confirmed with a code, and
+// enrolling again before that replaces it.
+func (s mfaService) EnrolTOTP(ctx context.Context, u models.User) (models.TOTPEnrolment, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.EnrolTOTP")
+	defer span.End()
+
+	enabled, err := s.IsEnabled(ctx, int64(u.ID))
+	if err != nil {
+		return models.TOTPEnrolment{}, fmt.Errorf("EnrolTOTP: %v", err)
+	}
+	if enabled {
+		return models.TOTPEnrolment{}, fmt.Errorf("EnrolTOTP: %w", ErrMFAAlreadyEnabled)
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return models.TOTPEnrolment{}, fmt.Errorf("EnrolTOTP: %v", err)
+	}
+	if err := s.r.UpsertTOTP(ctx, int64(u.ID), secret, time.Now()); err != nil {
+		return models.TOTPEnrolment{}, fmt.Errorf("EnrolTOTP: failed to store secret: %v", err)
+	}
+
+	return models.TOTPEnrolment{
+		Secret: secret,
+		URI:    totp.URI(s.issuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP activates the pending secret and returns fresh recovery codes. They are only
+// stored hashed, so this is the only time they can be shown.
+func (s mfaService) ConfirmTOTP(ctx context.Context, uID int64, code string) ([]string, error) {
+	ctx, span := tracing.Start(ctx, "MFAService.ConfirmTOTP")
+	defer span.End()
+
+	t, err := s.r.GetTOTP(ctx, uID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return nil, fmt.Errorf("ConfirmTOTP: %w", ErrMFANotEnrolled)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("ConfirmTOTP: failed to get secret: %v", err)
+	}
+	if t.ConfirmedAt != nil {
+		return nil, fmt.Errorf("ConfirmTOTP: %w", ErrMFAAlreadyEnabled)
+	}
+
+	step, ok := totp.Validate(t.Secret, normalizeCode(code), time.Now())
+	if !ok {
+		return nil, fmt.Errorf("ConfirmTOTP: %w", ErrInvalidMFACode)
+	}
+
+	codes := make([]string, recoveryCodeCount)
+	hashes := make([]string, recoveryCodeCount)
+	for i := range codes {
+		codes[i], err = generateRecoveryCode()
+		if err != nil {
+			return nil, fmt.Errorf("ConfirmTOTP: %v", err)
+		}
+		hashes[i] = hashRecoveryCode(codes[i])
+	}
+
+	if err := s.r.ConfirmTOTP(ctx, uID, step, hashes, time.Now()); err != nil {
+		return nil, fmt.Errorf("ConfirmTOTP: failed to confirm secret: %v", err)
+	}
+
+	return codes, nil
+}
+
+// DisableTOTP turns two-factor authentication off; a valid code is required so a stolen
+// session alone cannot remove the second factor.
+func (s mfaService) DisableTOTP(ctx context.Context, uID int64, code string) error {
+	ctx, span := tracing.Start(ctx, "MFAService.DisableTOTP")
+	defer span.End()
+
+	if err := s.Verify(ctx, uID, code); err != nil {
+		return fmt.Errorf("DisableTOTP: %w", err)
+	}
+	if err := s.r.DeleteTOTP(ctx, uID); err != nil {
+		return fmt.Errorf("DisableTOTP: failed to delete secret: %v", err)
+	}
+
+	return nil
+}
+
+func (s mfaService) IsEnabled(ctx context.Context, uID int64) (bool, error) {
+	t, err := s.r.GetTOTP(ctx, uID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get secret: %v", err)
+	}
+
+	return t.ConfirmedAt != nil, nil
+}
+
+// Verify accepts either a current TOTP code or one of the unused recovery codes. Each code
+// can be used only once.
+func (s mfaService) Verify(ctx context.Context, uID int64, code string) error {
+	ctx, span := tracing.Start(ctx, "MFAService.Verify")
+	defer span.End()
+
+	t, err := s.r.GetTOTP(ctx, uID)
+	if errors.Is(err, repository.ErrNotFound) {
+		return ErrMFANotEnrolled
+	}
+	if err != nil {
+		return fmt.Errorf("failed to get secret: %v", err)
+	}
+	if t.ConfirmedAt == nil {
+		return ErrMFANotEnrolled
+	}
+
+	code = normalizeCode(code)
+	if len(code) == totp.Digits {
+		step, ok := totp.Validate(t.Secret, code, time.Now())
+		if !ok {
+			return ErrInvalidMFACode
+		}
+		fresh, err := s.r.UseTOTPStep(ctx, uID, step)
+		if err != nil {
+			return fmt.Errorf("failed to record code use: %v", err)
+		}
+		if !fresh {
+			return ErrInvalidMFACode
+		}
+		return nil
+	}
+
+	used, err := s.r.UseRecoveryCode(ctx, uID, hashRecoveryCode(code), time.Now())
+	if err != nil {
+		return fmt.Errorf("failed to use recovery code: %v", err)
+	}
+	if !used {
+		return ErrInvalidMFACode
+	}
+
+	return nil
+}
+
+// generateRecoveryCode returns an 80 bit code written as two groups, e.g. "k3j5q2ma-7xw4bq9d".
+func generateRecoveryCode() (string, error) {
+	b := make([]byte, recoveryCodeBytes)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate recovery code: %v", err)
+	}
+	c := strings.ToLower(recoveryEncoding.EncodeToString(b))
+	return c[:len(c)/2] + "-" + c[len(c)/2:], nil
+}
+
+// normalizeCode strips the separators users tend to type along with codes.
+func normalizeCode(code string) string {
+	return strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+}
+
+func hashRecoveryCode(code string) string {
+	sum := sha256.Sum256([]byte(normalizeCode(code)))
+	return hex.EncodeToString(sum[:])
+}
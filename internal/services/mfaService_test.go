@@ -0,0 +1,179 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"task-manager/internal/repository"
+	"task-manager/internal/totp"
+	"testing"
+	"time"
+)
+
+type mockMFARepository struct {
+	totp  map[int64]*models.TOTP
+	codes map[string]bool
+}
+
+func newMockMFARepository() *mockMFARepository {
+	return &mockMFARepository{
+		totp:  map[int64]*models.TOTP{},
+		codes: map[string]bool{},
+	}
+}
+
+func (m *mockMFARepository) UpsertTOTP(ctx context.Context, uID int64, secret string, now time.Time) error {
+	if t, ok := m.totp[uID]; ok && t.ConfirmedAt != nil {
+		return nil
+	}
+	m.totp[uID] = &models.TOTP{UserID: uID, Secret: secret}
+	return nil
+}
+
+func (m *mockMFARepository) GetTOTP(ctx context.Context, uID int64) (models.TOTP, error) {
+	t, ok := m.totp[uID]
+	if !ok {
+		return models.TOTP{}, fmt.Errorf("getTOTP: %w", repository.ErrNotFound)
+	}
+	return *t, nil
+}
+
+func (m *mockMFARepository) ConfirmTOTP(ctx context.Context, uID, step int64, codeHashes []string, now time.Time) error {
+	t, ok := m.totp[uID]
+	if !ok || t.ConfirmedAt != nil {
+		return fmt.Errorf("confirmTOTP: %w", repository.ErrNotFound)
+	}
+	t.ConfirmedAt, t.LastStep = &now, &step
+	m.codes = map[string]bool{}
+	for _, h := range codeHashes {
+		m.codes[h] = true
+	}
+	return nil
+}
+
+func (m *mockMFARepository) UseTOTPStep(ctx context.Context, uID, step int64) (bool, error) {
+	t := m.totp[uID]
+	if t.LastStep != nil && *t.LastStep >= step {
+		return false, nil
+	}
+	t.LastStep = &step
+	return true, nil
+}
+
+func (m *mockMFARepository) UseRecoveryCode(ctx context.Context, uID int64, hash string, now time.Time) (bool, error) {
+	if !m.codes[hash] {
+		return false, nil
+	}
+	m.codes[hash] = false
+	return true, nil
+}
+
+func (m *mockMFARepository) DeleteTOTP(ctx context.Context, uID int64) error {
+	delete(m.totp, uID)
+	m.codes = map[string]bool{}
+	return nil
+}
+
+func TestMFAService_Enrolment(t *testing.T) {
+	r := newMockMFARepository()
+	s := NewMFAService(r, config.AuthConfig{MFAIssuer: "task-manager"})
+	ctx := context.Background()
+	u := models.User{ID: 1, Email: "test@example.com"}
+
+	e, err := s.EnrolTOTP(ctx, u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if e.Secret == "" || e.URI != totp.URI("task-manager", u.Email, e.Secret) {
+		t.Errorf("unexpected enrolment returned: %v", e)
+	}
+	if enabled, _ := s.IsEnabled(ctx, 1); enabled {
+		t.Errorf("two-factor authentication should stay disabled until confirmed")
+	}
+
+	if _, err := s.ConfirmTOTP(ctx, 1, "abcdef"); !errors.Is(err, ErrInvalidMFACode) {
+		t.Errorf("wrong code should not confirm, got %v", err)
+	}
+	if _, err := s.ConfirmTOTP(ctx, 2, "000000"); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("expected ErrMFANotEnrolled but got %v", err)
+	}
+
+	codes, err := s.ConfirmTOTP(ctx, 1, totpCode(t, e.Secret, 0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(codes) != recoveryCodeCount {
+		t.Errorf("expected %d recovery codes but got %d", recoveryCodeCount, len(codes))
+	}
+	for _, c := range codes {
+		if r.codes[c] {
+			t.Errorf("recovery codes must not be stored in plain text")
+		}
+	}
+	if enabled, _ := s.IsEnabled(ctx, 1); !enabled {
+		t.Errorf("two-factor authentication should be enabled after confirmation")
+	}
+	if _, err := s.EnrolTOTP(ctx, u); !errors.Is(err, ErrMFAAlreadyEnabled) {
+		t.Errorf("expected ErrMFAAlreadyEnabled but got %v", err)
+	}
+}
+
+func TestMFAService_Verify(t *testing.T) {
+	r := newMockMFARepository()
+	s := NewMFAService(r, config.AuthConfig{MFAIssuer: "task-manager"})
+	ctx := context.Background()
+
+	e, err := s.EnrolTOTP(ctx, models.User{ID: 1, Email: "test@example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// confirm with the previous step so the current code is still unused
+	codes, err := s.ConfirmTOTP(ctx, 1, totpCode(t, e.Secret, -1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	current := totpCode(t, e.Secret, 0)
+
+	var tests = []struct {
+		name        string
+		code        string
+		expectedErr error
+	}{
+		{"current code", current, nil},
+		{"replayed code", current, ErrInvalidMFACode},
+		{"recovery code", codes[0], nil},
+		{"recovery code used twice", codes[0], ErrInvalidMFACode},
+		{"recovery code typed in upper case without dash", strings.ToUpper(strings.ReplaceAll(codes[1], "-", "")), nil},
+		{"unknown recovery code", "aaaaaaaa-bbbbbbbb", ErrInvalidMFACode},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if err := s.Verify(ctx, 1, tc.code); !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error <%v> but got <%v>", tc.expectedErr, err)
+			}
+		})
+	}
+
+	if err := s.Verify(ctx, 2, current); !errors.Is(err, ErrMFANotEnrolled) {
+		t.Errorf("expected ErrMFANotEnrolled but got %v", err)
+	}
+	if err := s.DisableTOTP(ctx, 1, codes[2]); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if enabled, _ := s.IsEnabled(ctx, 1); enabled {
+		t.Errorf("two-factor authentication should be disabled")
+	}
+}
+
+func totpCode(t *testing.T, secret string, offset int64) string {
+	t.Helper()
+	c, err := totp.Code(secret, totp.Step(time.Now())+offset)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return c
+}
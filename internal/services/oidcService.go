@@ -0,0 +1,220 @@
+package services
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+	"task-manager/internal/config"
+	"task-manager/internal/metrics"
+	"task-manager/internal/models"
+	"task-manager/internal/oidc"
+	"task-manager/internal/password"
+	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCFlowTTL bounds how long a user may take at the provider before coming back.
+const OIDCFlowTTL = 10 * time.Minute
+
+const flowPurpose = "oidc_flow"
+
+var (
+	// ErrInvalidOIDCFlow is returned when the callback does not belong to a login started here.
+	ErrInvalidOIDCFlow = errors.New("invalid or expired login attempt")
+	// ErrOIDCEmailNotVerified is returned when the provider does not vouch for the e-mail of an
+	// identity that is not linked yet, so it cannot be matched to an account.
+	ErrOIDCEmailNotVerified = errors.New("identity provider did not verify the e-mail address")
+	// ErrOIDCNoAccount is returned for unknown users when automatic provisioning is disabled.
+	ErrOIDCNoAccount = errors.New("no account for this identity")
+)
+
+// OIDCProvider is the part of oidc.Client the service uses.
+type OIDCProvider interface {
+	AuthCodeURL(ctx context.Context, state, nonce, verifier string) (string, error)
+	Exchange(ctx context.Context, code, verifier, nonce string) (oidc.Claims, error)
+}
+
+type OIDCService interface {
+	Begin(ctx context.Context) (authURL, flow string, err error)
+	Complete(ctx context.Context, flow, state, code string) (models.User, error)
+}
+
+type oidcService struct {
+	p             OIDCProvider
+	ur            repository.UserRepository
+	ir            repository.IdentityRepository
+	h             password.Hasher
+	autoProvision bool
+	flowSecret    []byte
+}
+
+// NewOIDCService creates the service; the state of a login in progress is kept by the client
+// in a flow token signed with a key derived from secret.
+func NewOIDCService(p OIDCProvider, ur repository.UserRepository, ir repository.IdentityRepository, h password.Hasher, cfg config.OIDCConfig, secret string) OIDCService {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(flowPurpose))
+
+	return &oidcService{
+		p:             p,
+		ur:            ur,
+		ir:            ir,
+		h:             h,
+		autoProvision: cfg.AutoProvision,
+		flowSecret:    mac.Sum(nil),
+	}
+}
+
+// Begin returns the provider URL to redirect to and the flow token the caller has to keep
+// until the callback, typically in a cookie.
+func (s oidcService) Begin(ctx context.Context) (string, string, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Begin")
+	defer span.End()
+
+	var values [3]string
+	for i := range values {
+		v, err := oidc.NewVerifier()
+		if err != nil {
+			return "", "", fmt.Errorf("Begin: %v", err)
+		}
+		values[i] = v
+	}
+	state, nonce, verifier := values[0], values[1], values[2]
+
+	authURL, err := s.p.AuthCodeURL(ctx, state, nonce, verifier)
+	if err != nil {
+		return "", "", fmt.Errorf("Begin: %v", err)
+	}
+
+	flow, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+		"purpose":  flowPurpose,
+		"state":    state,
+		"nonce":    nonce,
+		"verifier": verifier,
+		"exp":      time.Now().Add(OIDCFlowTTL).Unix(),
+	}).SignedString(s.flowSecret)
+	if err != nil {
+		return "", "", fmt.Errorf("Begin: failed to sign flow: %v", err)
+	}
+
+	return authURL, flow, nil
+}
+
+// Complete finishes the login: the identity is looked up by issuer and subject, otherwise
+// linked to the account with the same verified e-mail, otherwise provisioned.
+func (s oidcService) Complete(ctx context.Context, flow, state, code string) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "OIDCService.Complete")
+	defer span.End()
+
+	f, err := s.parseFlow(flow)
+	if err != nil || subtle.ConstantTimeCompare([]byte(f["state"]), []byte(state)) != 1 {
+		return models.User{}, fmt.Errorf("Complete: %w", ErrInvalidOIDCFlow)
+	}
+
+	claims, err := s.p.Exchange(ctx, code, f["verifier"], f["nonce"])
+	if err != nil {
+		return models.User{}, fmt.Errorf("Complete: %w", err)
+	}
+
+	uID, err := s.ir.GetUserID(ctx, claims.Issuer, claims.Subject)
+	if err == nil {
+		u, err := s.ur.GetUserByID(ctx, uID)
+		if err != nil {
+			return models.User{}, fmt.Errorf("Complete: failed to get linked user: %v", err)
+		}
+		return u, nil
+	}
+	if !errors.Is(err, repository.ErrNotFound) {
+		return models.User{}, fmt.Errorf("Complete: failed to look up identity: %v", err)
+	}
+
+	if claims.Email == "" || !claims.EmailVerified {
+		return models.User{}, fmt.Errorf("Complete: %w", ErrOIDCEmailNotVerified)
+	}
+
+	u, err := s.ur.GetUserByEmail(ctx, claims.Email)
+	switch {
+	case err == nil:
+		return s.link(ctx, u, claims)
+	case errors.Is(err, repository.ErrNotFound):
+		return s.provision(ctx, claims)
+	default:
+		return models.User{}, fmt.Errorf("Complete: failed to get user: %v", err)
+	}
+}
+
+func (s oidcService) link(ctx context.Context, u models.User, c oidc.Claims) (models.User, error) {
+	now := time.Now()
+	if err := s.ir.Link(ctx, int64(u.ID), c.Issuer, c.Subject, now); err != nil {
+		return models.User{}, fmt.Errorf("Complete: failed to link identity: %v", err)
+	}
+	// the provider vouched for the address, which is as good as our own verification mail
+	if u.EmailVerifiedAt == nil {
+		if err := s.ur.MarkEmailVerified(ctx, int64(u.ID), now); err != nil {
+			return models.User{}, fmt.Errorf("Complete: failed to mark e-mail as verified: %v", err)
+		}
+		u.EmailVerifiedAt = &now
+	}
+	return u, nil
+}
+
+func (s oidcService) provision(ctx context.Context, c oidc.Claims) (models.User, error) {
+	if !s.autoProvision {
+		return models.User{}, fmt.Errorf("Complete: %w", ErrOIDCNoAccount)
+	}
+
+	// provisioned users sign in through the provider; the random password can only be
+	// replaced through the password reset flow
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return models.User{}, fmt.Errorf("Complete: failed to generate password: %v", err)
+	}
+	hash, err := s.h.Hash(base64.RawURLEncoding.EncodeToString(b))
+	if err != nil {
+		return models.User{}, fmt.Errorf("Complete: failed to hash a password, %s", err)
+	}
+
+	name := strings.TrimSpace(c.Name)
+	if name == "" {
+		name, _, _ = strings.Cut(c.Email, "@")
+	}
+
+	u, err := s.ir.Provision(ctx, models.CreateUserPayload{Name: name, Email: c.Email, Password: hash}, c.Issuer, c.Subject, time.Now())
+	if err != nil {
+		return models.User{}, fmt.Errorf("Complete: failed to provision user: %v", err)
+	}
+	metrics.UsersRegistered.Inc()
+
+	return u, nil
+}
+
+func (s oidcService) parseFlow(flow string) (map[string]string, error) {
+	t, err := jwt.Parse(flow, func(t *jwt.Token) (any, error) {
+		return s.flowSecret, nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}), jwt.WithExpirationRequired())
+	if err != nil || !t.Valid {
+		return nil, ErrInvalidOIDCFlow
+	}
+	claims, ok := t.Claims.(jwt.MapClaims)
+	if !ok || claims["purpose"] != flowPurpose {
+		return nil, ErrInvalidOIDCFlow
+	}
+
+	f := map[string]string{}
+	for _, k := range []string{"state", "nonce", "verifier"} {
+		v, ok := claims[k].(string)
+		if !ok || v == "" {
+			return nil, ErrInvalidOIDCFlow
+		}
+		f[k] = v
+	}
+	return f, nil
+}
@@ -0,0 +1,131 @@
+package services
+
+import (
+	"context"
+	"errors"
+	"task-manager/internal/config"
+	"task-manager/internal/models"
+	"task-manager/internal/oidc"
+	"task-manager/internal/oidc/oidctest"
+	"task-manager/internal/repository"
+	"testing"
+	"time"
+)
+
+type mockIdentityRepository struct {
+	links       map[string]int64
+	provisioned []models.CreateUserPayload
+}
+
+func newMockIdentityRepository() *mockIdentityRepository {
+	return &mockIdentityRepository{links: map[string]int64{}}
+}
+
+func (m *mockIdentityRepository) GetUserID(ctx context.Context, issuer, subject string) (int64, error) {
+	uID, ok := m.links[issuer+" "+subject]
+	if !ok {
+		return 0, repository.ErrNotFound
+	}
+	return uID, nil
+}
+
+func (m *mockIdentityRepository) Link(ctx context.Context, uID int64, issuer, subject string, now time.Time) error {
+	m.links[issuer+" "+subject] = uID
+	return nil
+}
+
+func (m *mockIdentityRepository) Provision(ctx context.Context, p models.CreateUserPayload, issuer, subject string, now time.Time) (models.User, error) {
+	m.provisioned = append(m.provisioned, p)
+	uID := int64(100 + len(m.provisioned))
+	m.links[issuer+" "+subject] = uID
+	return models.User{ID: int(uID), Name: p.Name, Email: p.Email, EmailVerifiedAt: &now}, nil
+}
+
+func TestOIDCService_Complete(t *testing.T) {
+	var tests = []struct {
+		name          string
+		identity      oidctest.Identity
+		autoProvision bool
+		expectedID    int
+		expectedError error
+	}{
+		{"linked identity", oidctest.Identity{Subject: "linked", Email: "someone@else.com"}, false, 1, nil},
+		{"link by verified e-mail", oidctest.Identity{Subject: "new", Email: "test@example.com", EmailVerified: true}, false, 1, nil},
+		{"unverified e-mail", oidctest.Identity{Subject: "new", Email: "test@example.com"}, true, 0, ErrOIDCEmailNotVerified},
+		{"provision new user", oidctest.Identity{Subject: "new", Email: "new@example.com", EmailVerified: true, Name: "New User"}, true, 101, nil},
+		{"provisioning disabled", oidctest.Identity{Subject: "new", Email: "new@example.com", EmailVerified: true}, false, 0, ErrOIDCNoAccount},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			p := oidctest.NewProvider(t)
+			ir := newMockIdentityRepository()
+			ir.links[p.URL+" linked"] = 1
+			s := NewOIDCService(oidc.NewClient(p.Config("http://app/auth/oidc/callback"), nil), mockUserRepository{}, ir, testHasher, config.OIDCConfig{AutoProvision: tc.autoProvision}, "secret")
+			ctx := context.Background()
+
+			authURL, flow, err := s.Begin(ctx)
+			if err != nil {
+				t.Fatal(err)
+			}
+			code, state, err := p.Authorize(authURL, tc.identity)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			u, err := s.Complete(ctx, flow, state, code)
+			if tc.expectedError != nil {
+				if !errors.Is(err, tc.expectedError) {
+					t.Errorf("expected %v but got %v", tc.expectedError, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if u.ID != tc.expectedID {
+				t.Errorf("expected user %d but got %d", tc.expectedID, u.ID)
+			}
+			if u.EmailVerifiedAt == nil {
+				t.Errorf("users signing in through the provider should have a verified e-mail")
+			}
+			if uID, err := ir.GetUserID(ctx, p.URL, tc.identity.Subject); err != nil || uID != int64(u.ID) {
+				t.Errorf("identity should be linked to user %d, got %d (%v)", u.ID, uID, err)
+			}
+			for _, pu := range ir.provisioned {
+				if pu.Name != tc.identity.Name || pu.Password == "" {
+					t.Errorf("unexpected provisioned user: %+v", pu)
+				}
+			}
+		})
+	}
+}
+
+func TestOIDCService_Complete_invalidFlow(t *testing.T) {
+	p := oidctest.NewProvider(t)
+	s := NewOIDCService(oidc.NewClient(p.Config("http://app/auth/oidc/callback"), nil), mockUserRepository{}, newMockIdentityRepository(), testHasher, config.OIDCConfig{}, "secret")
+	other := NewOIDCService(oidc.NewClient(p.Config("http://app/auth/oidc/callback"), nil), mockUserRepository{}, newMockIdentityRepository(), testHasher, config.OIDCConfig{}, "other-secret")
+	ctx := context.Background()
+
+	authURL, flow, err := s.Begin(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	code, state, err := p.Authorize(authURL, oidctest.Identity{Subject: "linked"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := s.Complete(ctx, flow, "forged-state", code); !errors.Is(err, ErrInvalidOIDCFlow) {
+		t.Errorf("state mismatch should be rejected, got %v", err)
+	}
+	if _, err := other.Complete(ctx, flow, state, code); !errors.Is(err, ErrInvalidOIDCFlow) {
+		t.Errorf("flow signed with another key should be rejected, got %v", err)
+	}
+	if _, err := s.Complete(ctx, "not-a-flow", state, code); !errors.Is(err, ErrInvalidOIDCFlow) {
+		t.Errorf("malformed flow should be rejected, got %v", err)
+	}
+	if _, err := s.Complete(ctx, flow, state, "unknown-code"); !errors.Is(err, oidc.ErrCodeRejected) {
+		t.Errorf("unknown code should be rejected by the provider, got %v", err)
+	}
+}
@@ -2,19 +2,50 @@ package services
 
 import (
 	"task-manager/internal/config"
+	"task-manager/internal/events"
+	"task-manager/internal/mail"
+	"task-manager/internal/oidc"
+	"task-manager/internal/password"
 	"task-manager/internal/repository"
 )
 
 type Services struct {
 	Us UserService
+	Au Authenticator
 	As AuthService
 	Ts TaskService
+	Ac AccountService
+	Mf MFAService
+	Ad AdminService
+	Ev EventService
+	// Oi is nil unless OpenID Connect login is enabled.
+	Oi OIDCService
 }
 
-func New(r repository.Repositories, cfg config.JWTConfig) Services {
-	return Services{
-		Us: NewUserService(r.Ur),
-		As: NewAuthService(cfg),
-		Ts: NewTaskService(r.Tr),
+// New creates the services on top of r. Task changes wake the event streams subscribed on b.
+func New(r repository.Repositories, cfg config.Config, m mail.Mailer, b *events.Broker) Services {
+	h := password.New(cfg.Password)
+	s := Services{
+		Us: NewUserService(r.Ur, cfg.Auth, h),
+		Au: NewAuthenticator(r.Ur, h, cfg.Auth),
+		As: NewAuthService(cfg.JWT),
+		Ts: NewTaskService(r.Tr, b),
+		Ac: NewAccountService(r.Ur, r.Tk, m, h, cfg.Auth, cfg.JWT.Secret),
+		Mf: NewMFAService(r.Mf, cfg.Auth),
+		Ad: NewAdminService(r.Ur, r.Tr, r.Tk, r.Uw, h),
+		Ev: NewEventService(r.Ev, b, cfg.Events),
 	}
+
+	if cfg.OIDC.Enabled {
+		p := oidc.NewClient(oidc.Config{
+			Issuer:       cfg.OIDC.Issuer,
+			ClientID:     cfg.OIDC.ClientID,
+			ClientSecret: cfg.OIDC.ClientSecret,
+			RedirectURL:  cfg.OIDC.RedirectURL,
+			Scopes:       cfg.OIDC.Scopes,
+		}, nil)
+		s.Oi = NewOIDCService(p, r.Ur, r.Id, h, cfg.OIDC, cfg.JWT.Secret)
+	}
+
+	return s
 }
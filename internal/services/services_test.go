@@ -2,6 +2,7 @@ package services
 
 import (
 	"task-manager/internal/config"
+	"task-manager/internal/events"
 	"task-manager/internal/repository"
 	"testing"
 )
@@ -13,10 +14,11 @@ func TestService_New(t *testing.T) {
 	r := repository.Repositories{
 		Ur: mockUserRepository,
 		Tr: mockTaskRepository,
+		Tk: newMockTokenRepository(),
 	}
-	c := config.JWTConfig{Secret: "example-secret-for-testing"}
+	c := config.Config{JWT: config.JWTConfig{Secret: "example-secret-for-testing"}}
 
-	s := New(r, c)
+	s := New(r, c, &mockMailer{}, events.NewBroker())
 
 	if s.Us == nil {
 		t.Errorf("userService should not be nil")
@@ -29,4 +31,16 @@ func TestService_New(t *testing.T) {
 	if s.As == nil {
 		t.Errorf("authService should not be nil")
 	}
+
+	if s.Ac == nil {
+		t.Errorf("accountService should not be nil")
+	}
+
+	if s.Ad == nil {
+		t.Errorf("adminService should not be nil")
+	}
+
+	if s.Ev == nil {
+		t.Errorf("eventService should not be nil")
+	}
 }
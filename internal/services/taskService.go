@@ -3,62 +3,91 @@ package services
 import (
 	"context"
 	"fmt"
+	"task-manager/internal/contextkeys"
+	"task-manager/internal/events"
+	"task-manager/internal/metrics"
 	"task-manager/internal/models"
 	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
 )
 
 type TaskService interface {
-	StoreTask(ctx context.Context, p models.TaskPayload) error
+	StoreTask(ctx context.Context, p models.TaskPayload) (models.Task, error)
 	UpdateTask(ctx context.Context, p models.UpdateTask) (models.Task, error)
-	ShowTask(id int) (models.Task, error)
-	GetTasksList(uID int64) (models.TasksList, error)
-	DeleteTask(id int, uID int64) error
-	IsTaskOwner(uID int64, id int) (bool, error)
+	ShowTask(ctx context.Context, id int) (models.Task, error)
+	GetTasksList(ctx context.Context, uID int64) (models.TasksList, error)
+	DeleteTask(ctx context.Context, id int, uID int64) error
+	IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error)
 }
 
 type taskService struct {
 	r repository.TaskRepository
+	b *events.Broker
 }
 
-func NewTaskService(r repository.TaskRepository) TaskService {
-	return &taskService{r: r}
+// NewTaskService creates the service managing tasks. Changes wake the event streams of the
+// owner on b.
+func NewTaskService(r repository.TaskRepository, b *events.Broker) TaskService {
+	return &taskService{r: r, b: b}
 }
 
-func (s taskService) GetTasksList(uID int64) (models.TasksList, error) {
+func (s taskService) GetTasksList(ctx context.Context, uID int64) (models.TasksList, error) {
+	ctx, span := tracing.Start(ctx, "TaskService.GetTasksList")
+	defer span.End()
+
 	if uID < 1 {
 		return models.TasksList{}, fmt.Errorf("GetTasksList: invalid user")
 	}
 
-	l, err := s.r.Index(uID)
+	l, err := s.r.Index(ctx, uID)
 	if err != nil {
 		return models.TasksList{}, fmt.Errorf("GetTasksList: failed to get data: %v", err)
 	}
 
 	return l, nil
 }
-func (s taskService) StoreTask(ctx context.Context, p models.TaskPayload) error {
-	if err := s.r.Store(ctx, p); err != nil {
-		return fmt.Errorf("storeTask: error while storing the data: %v", err)
+func (s taskService) StoreTask(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+	ctx, span := tracing.Start(ctx, "TaskService.StoreTask")
+	defer span.End()
+
+	t, err := s.r.Store(ctx, p)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("storeTask: error while storing the data: %v", err)
+	}
+	metrics.TasksCreated.Inc()
+	if uID, ok := ctx.Value(contextkeys.UserID).(int64); ok {
+		s.b.Notify(uID)
 	}
 
-	return nil
+	return t, nil
 }
 func (s taskService) UpdateTask(ctx context.Context, p models.UpdateTask) (models.Task, error) {
+	ctx, span := tracing.Start(ctx, "TaskService.UpdateTask")
+	defer span.End()
+
 	t, err := s.r.Update(ctx, p)
 	if err != nil {
 		return models.Task{}, fmt.Errorf("UpdateTask: %v", err)
 	}
+	metrics.TasksUpdated.Inc()
+	s.b.Notify(t.CreatedBy)
 	return t, nil
 }
-func (s taskService) ShowTask(id int) (models.Task, error) {
-	t, err := s.r.Show(id)
+func (s taskService) ShowTask(ctx context.Context, id int) (models.Task, error) {
+	ctx, span := tracing.Start(ctx, "TaskService.ShowTask")
+	defer span.End()
+
+	t, err := s.r.Show(ctx, id)
 	if err != nil {
 		return models.Task{}, fmt.Errorf("ShowTask: %v", err)
 	}
 	return t, nil
 }
-func (s taskService) DeleteTask(id int, uID int64) error {
-	isOwner, err := s.IsTaskOwner(uID, id)
+func (s taskService) DeleteTask(ctx context.Context, id int, uID int64) error {
+	ctx, span := tracing.Start(ctx, "TaskService.DeleteTask")
+	defer span.End()
+
+	isOwner, err := s.IsTaskOwner(ctx, uID, id)
 	if err != nil {
 		return fmt.Errorf("deleteTask: %s", err)
 	}
@@ -66,14 +95,19 @@ func (s taskService) DeleteTask(id int, uID int64) error {
 		return fmt.Errorf("deleteTask: you are not authorized to execute this action")
 	}
 
-	if err := s.r.Delete(id); err != nil {
+	if err := s.r.Delete(ctx, id); err != nil {
 		return fmt.Errorf("DeleteTask: %s", err)
 	}
+	metrics.TasksDeleted.Inc()
+	s.b.Notify(uID)
 
 	return nil
 }
-func (s taskService) IsTaskOwner(uID int64, id int) (bool, error) {
-	isOwner, err := s.r.IsTaskOwner(uID, id)
+func (s taskService) IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error) {
+	ctx, span := tracing.Start(ctx, "TaskService.IsTaskOwner")
+	defer span.End()
+
+	isOwner, err := s.r.IsTaskOwner(ctx, uID, id)
 	if err != nil {
 		return false, fmt.Errorf("failed to check if user is a task owner: %v", err)
 	}
@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"math"
 	"task-manager/internal/contextkeys"
+	"task-manager/internal/events"
 	"task-manager/internal/models"
 	"testing"
 	"time"
@@ -14,18 +15,26 @@ import (
 
 type mockTaskRepository struct {
 	indexFn       func(uID int64) (models.TasksList, error)
-	storeFn       func(ctx context.Context, p models.TaskPayload) error
+	storeFn       func(ctx context.Context, p models.TaskPayload) (models.Task, error)
 	updateFn      func(ctx context.Context, p models.UpdateTask) (models.Task, error)
 	showFn        func(id int) (models.Task, error)
 	isTaskOwnerFn func(uID int64, id int) (bool, error)
 	deleteFn      func(id int) error
 }
 
-func (m mockTaskRepository) Store(ctx context.Context, p models.TaskPayload) error {
+func (m mockTaskRepository) CountOverdue(ctx context.Context, now time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m mockTaskRepository) PurgeTrash(ctx context.Context, before time.Time) (int64, error) {
+	return 0, nil
+}
+
+func (m mockTaskRepository) Store(ctx context.Context, p models.TaskPayload) (models.Task, error) {
 	if m.storeFn != nil {
 		return m.storeFn(ctx, p)
 	}
-	return nil
+	return models.Task{}, nil
 }
 
 func (m mockTaskRepository) Update(ctx context.Context, p models.UpdateTask) (models.Task, error) {
@@ -35,14 +44,14 @@ func (m mockTaskRepository) Update(ctx context.Context, p models.UpdateTask) (mo
 	return models.Task{}, nil
 }
 
-func (m mockTaskRepository) Show(id int) (models.Task, error) {
+func (m mockTaskRepository) Show(ctx context.Context, id int) (models.Task, error) {
 	if m.showFn != nil {
 		return m.showFn(id)
 	}
 	return models.Task{}, nil
 }
 
-func (m mockTaskRepository) Index(uID int64) (models.TasksList, error) {
+func (m mockTaskRepository) Index(ctx context.Context, uID int64) (models.TasksList, error) {
 	if m.indexFn != nil {
 		return m.indexFn(uID)
 	}
@@ -50,14 +59,14 @@ func (m mockTaskRepository) Index(uID int64) (models.TasksList, error) {
 	return models.TasksList{}, nil
 }
 
-func (m mockTaskRepository) Delete(id int) error {
+func (m mockTaskRepository) Delete(ctx context.Context, id int) error {
 	if m.deleteFn != nil {
 		return m.deleteFn(id)
 	}
 	return nil
 }
 
-func (m mockTaskRepository) IsTaskOwner(uID int64, id int) (bool, error) {
+func (m mockTaskRepository) IsTaskOwner(ctx context.Context, uID int64, id int) (bool, error) {
 	if m.isTaskOwnerFn != nil {
 		return m.isTaskOwnerFn(uID, id)
 	}
@@ -165,8 +174,8 @@ func TestTaskService_GetTasksList(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
-			tl, err := s.GetTasksList(tc.uID)
+			s := NewTaskService(tc.mock, events.NewBroker())
+			tl, err := s.GetTasksList(context.Background(), tc.uID)
 			if tc.expectsError && err == nil {
 				t.Errorf("function is expected to return an error but it did not")
 			}
@@ -197,8 +206,8 @@ func TestTaskService_StoreTask(t *testing.T) {
 	}{
 		{
 			"valid payload, low priority, task created",
-			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) error {
-				return nil
+			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+				return models.Task{ID: 1, Name: p.Name, Priority: p.Priority}, nil
 			}},
 			models.TaskPayload{
 				Name:        "LoremIpsum",
@@ -212,8 +221,8 @@ func TestTaskService_StoreTask(t *testing.T) {
 		},
 		{
 			"valid payload, medium priority, task created",
-			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) error {
-				return nil
+			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+				return models.Task{ID: 1, Name: p.Name, Priority: p.Priority}, nil
 			}},
 			models.TaskPayload{
 				Name:        "LoremIpsum",
@@ -227,8 +236,8 @@ func TestTaskService_StoreTask(t *testing.T) {
 		},
 		{
 			"valid payload, high priority, task created",
-			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) error {
-				return nil
+			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+				return models.Task{ID: 1, Name: p.Name, Priority: p.Priority}, nil
 			}},
 			models.TaskPayload{
 				Name:        "LoremIpsum",
@@ -242,8 +251,8 @@ func TestTaskService_StoreTask(t *testing.T) {
 		},
 		{
 			"invalid payload, error returned",
-			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) error {
-				return fmt.Errorf("error executing the query")
+			mockTaskRepository{storeFn: func(ctx context.Context, p models.TaskPayload) (models.Task, error) {
+				return models.Task{}, fmt.Errorf("error executing the query")
 			}},
 			models.TaskPayload{
 				Name:        "LoremIpsum",
@@ -259,8 +268,8 @@ func TestTaskService_StoreTask(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
-			err := s.StoreTask(context.Background(), tc.payload)
+			s := NewTaskService(tc.mock, events.NewBroker())
+			task, err := s.StoreTask(context.Background(), tc.payload)
 			if tc.expectsError && err == nil {
 				t.Errorf("function was supposed to return an error but it did not")
 			}
@@ -268,6 +277,9 @@ func TestTaskService_StoreTask(t *testing.T) {
 			if !tc.expectsError && err != nil {
 				t.Errorf("unexpected error: %s", err)
 			}
+			if !tc.expectsError && (task.ID == 0 || task.Name != tc.payload.Name) {
+				t.Errorf("expected the stored task but got %+v", task)
+			}
 
 			if tc.expectsError && err != nil {
 				if tc.errorWanted != err.Error() {
@@ -465,7 +477,7 @@ func TestTaskService_UpdateTask(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
+			s := NewTaskService(tc.mock, events.NewBroker())
 			ctx := context.WithValue(context.Background(), contextkeys.UserID, int64(2))
 
 			task, err := s.UpdateTask(ctx, tc.payload)
@@ -564,9 +576,9 @@ func TestTaskService_ShowTask(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
+			s := NewTaskService(tc.mock, events.NewBroker())
 
-			task, err := s.ShowTask(tc.taskID)
+			task, err := s.ShowTask(context.Background(), tc.taskID)
 			if tc.expectsError && err == nil {
 				t.Errorf("function is supposed to return an error but it did not")
 			}
@@ -648,9 +660,9 @@ func TestTaskService_IsTaskOwner(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
+			s := NewTaskService(tc.mock, events.NewBroker())
 
-			isOwner, err := s.IsTaskOwner(tc.uID, tc.tID)
+			isOwner, err := s.IsTaskOwner(context.Background(), tc.uID, tc.tID)
 
 			if tc.expectsError && err == nil {
 				t.Errorf("function is supposed to return an error but it did not")
@@ -738,9 +750,9 @@ func TestTaskService_DeleteTask(t *testing.T) {
 
 	for _, tc := range tests {
 		t.Run(tc.name, func(t *testing.T) {
-			s := NewTaskService(tc.mock)
+			s := NewTaskService(tc.mock, events.NewBroker())
 
-			err := s.DeleteTask(tc.tID, tc.uID)
+			err := s.DeleteTask(context.Background(), tc.tID, tc.uID)
 
 			if !tc.expectsError && err != nil {
 				t.Errorf("unexpected error: %s", err)
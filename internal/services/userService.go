@@ -2,37 +2,64 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"task-manager/internal/helpers"
+	"task-manager/internal/config"
+	"task-manager/internal/metrics"
 	"task-manager/internal/models"
+	"task-manager/internal/password"
 	"task-manager/internal/repository"
+	"task-manager/internal/tracing"
 )
 
 type UserService interface {
 	RegisterUser(ctx context.Context, p models.CreateUserPayload) error
-	LoginUser(p models.LoginPayload) (models.User, error)
-	CheckIfEmailExists(e string) (bool, error)
+	CheckIfEmailExists(ctx context.Context, e string) (bool, error)
+	GetProfile(ctx context.Context, uID int64) (models.User, error)
+	GetUsers(ctx context.Context, ids []int64) ([]models.User, error)
+	UpdateProfile(ctx context.Context, uID int64, p models.UpdateProfilePayload) (u models.User, emailChanged bool, err error)
+	ChangePassword(ctx context.Context, uID int64, current, password string) error
+	DeleteAccount(ctx context.Context, uID int64) error
 }
 
+var (
+	// ErrEmailInUse is returned when a registration or profile update would reuse another
+	// account's e-mail.
+	ErrEmailInUse = errors.New("email already in use")
+	// ErrIncorrectPassword is returned when the current password given for a change is wrong.
+	ErrIncorrectPassword = errors.New("incorrect password")
+)
+
 type userService struct {
-	r repository.UserRepository
+	r   repository.UserRepository
+	cfg config.AuthConfig
+	h   password.Hasher
 }
 
-func NewUserService(r repository.UserRepository) UserService {
+// NewUserService creates the service; cfg.DeletedUserTasks decides what happens to the tasks
+// of deleted accounts.
+func NewUserService(r repository.UserRepository, cfg config.AuthConfig, h password.Hasher) UserService {
 	return &userService{
-		r: r,
+		r:   r,
+		cfg: cfg,
+		h:   h,
 	}
 }
 
 func (s userService) RegisterUser(ctx context.Context, p models.CreateUserPayload) error {
-	emailExists, err := s.r.CheckIfEmailExists(p.Email)
+	ctx, span := tracing.Start(ctx, "UserService.RegisterUser")
+	defer span.End()
+
+	emailExists, err := s.r.CheckIfEmailExists(ctx, p.Email)
 	if err != nil {
 		return fmt.Errorf("RegisterUser: failed to check if email is unique: %v", err)
 	}
 	if emailExists {
-		return fmt.Errorf("RegisterUser: email already in use")
+		return fmt.Errorf("RegisterUser: %w", ErrEmailInUse)
 	}
-	p.Password, err = helpers.HashPassword(p.Password)
+	_, hashSpan := tracing.Start(ctx, "password.hash")
+	p.Password, err = s.h.Hash(p.Password)
+	hashSpan.End()
 	if err != nil {
 		return fmt.Errorf("RegisterUser: failed to hash a password, %s", err)
 	}
@@ -40,19 +67,122 @@ func (s userService) RegisterUser(ctx context.Context, p models.CreateUserPayloa
 	if err := s.r.CreateUser(ctx, p); err != nil {
 		return fmt.Errorf("RegisterUser: failed to run create user: %v", err)
 	}
+	metrics.UsersRegistered.Inc()
 
 	return nil
 }
 
-func (s userService) LoginUser(p models.LoginPayload) (models.User, error) {
-	u, err := s.r.GetUserData(p)
+func (s userService) CheckIfEmailExists(ctx context.Context, e string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "UserService.CheckIfEmailExists")
+	defer span.End()
+
+	return s.r.CheckIfEmailExists(ctx, e)
+}
+
+func (s userService) GetProfile(ctx context.Context, uID int64) (models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetProfile")
+	defer span.End()
+
+	u, err := s.r.GetUserByID(ctx, uID)
 	if err != nil {
-		return models.User{}, fmt.Errorf("LoginUser: failed to get user data: %v", err)
+		return models.User{}, fmt.Errorf("GetProfile: failed to get user: %w", err)
 	}
 
 	return u, nil
 }
 
-func (s userService) CheckIfEmailExists(e string) (bool, error) {
-	return s.r.CheckIfEmailExists(e)
+// GetUsers looks up several users with one query; unknown ids are skipped.
+func (s userService) GetUsers(ctx context.Context, ids []int64) ([]models.User, error) {
+	ctx, span := tracing.Start(ctx, "UserService.GetUsers")
+	defer span.End()
+
+	l, err := s.r.GetUsersByIDs(ctx, ids)
+	if err != nil {
+		return nil, fmt.Errorf("GetUsers: failed to get users: %v", err)
+	}
+
+	return l, nil
+}
+
+// UpdateProfile applies the non-empty fields of p. A new e-mail address has to be verified
+// again, emailChanged tells the caller to send the verification link.
+func (s userService) UpdateProfile(ctx context.Context, uID int64, p models.UpdateProfilePayload) (models.User, bool, error) {
+	ctx, span := tracing.Start(ctx, "UserService.UpdateProfile")
+	defer span.End()
+
+	u, err := s.r.GetUserByID(ctx, uID)
+	if err != nil {
+		return models.User{}, false, fmt.Errorf("UpdateProfile: failed to get user: %w", err)
+	}
+
+	name, email := u.Name, u.Email
+	if p.Name != "" {
+		name = p.Name
+	}
+	emailChanged := p.Email != "" && p.Email != u.Email
+	if emailChanged {
+		exists, err := s.r.CheckIfEmailExists(ctx, p.Email)
+		if err != nil {
+			return models.User{}, false, fmt.Errorf("UpdateProfile: failed to check if email is unique: %v", err)
+		}
+		if exists {
+			return models.User{}, false, fmt.Errorf("UpdateProfile: %w", ErrEmailInUse)
+		}
+		email = p.Email
+	}
+
+	u, err = s.r.UpdateProfile(ctx, uID, name, email)
+	if err != nil {
+		return models.User{}, false, fmt.Errorf("UpdateProfile: failed to update user: %w", err)
+	}
+
+	return u, emailChanged, nil
+}
+
+func (s userService) ChangePassword(ctx context.Context, uID int64, current, password string) error {
+	ctx, span := tracing.Start(ctx, "UserService.ChangePassword")
+	defer span.End()
+
+	u, err := s.r.GetUserByID(ctx, uID)
+	if err != nil {
+		return fmt.Errorf("ChangePassword: failed to get user: %w", err)
+	}
+
+	_, verifySpan := tracing.Start(ctx, "password.verify")
+	ok, err := s.h.Verify(current, u.Password)
+	verifySpan.End()
+	if err != nil {
+		return fmt.Errorf("ChangePassword: failed to verify password: %v", err)
+	}
+	if !ok {
+		return fmt.Errorf("ChangePassword: %w", ErrIncorrectPassword)
+	}
+
+	_, hashSpan := tracing.Start(ctx, "password.hash")
+	hash, err := s.h.Hash(password)
+	hashSpan.End()
+	if err != nil {
+		return fmt.Errorf("ChangePassword: failed to hash a password, %s", err)
+	}
+
+	if err := s.r.UpdatePassword(ctx, uID, hash); err != nil {
+		return fmt.Errorf("ChangePassword: failed to update password: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteAccount removes the user; their tasks are deleted or anonymised according to
+// cfg.DeletedUserTasks in the same transaction.
+func (s userService) DeleteAccount(ctx context.Context, uID int64) error {
+	ctx, span := tracing.Start(ctx, "UserService.DeleteAccount")
+	defer span.End()
+
+	anonymize := s.cfg.DeletedUserTasks == config.DeletedUserTasksAnonymize
+	if err := s.r.DeleteUser(ctx, uID, anonymize); err != nil {
+		return fmt.Errorf("DeleteAccount: failed to delete user: %w", err)
+	}
+	metrics.UsersDeleted.Inc()
+
+	return nil
 }
@@ -2,15 +2,26 @@ package services
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"strings"
-	"task-manager/internal/helpers"
+	"task-manager/internal/config"
 	"task-manager/internal/models"
+	"task-manager/internal/password"
+	"task-manager/internal/repository"
 	"testing"
+	"time"
 
 	"github.com/google/go-cmp/cmp"
 )
 
+var (
+	verifiedAt = time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	// testHasher uses cheap Argon2id parameters to keep the tests fast
+	testHasher      = password.New(config.PasswordConfig{Argon2Memory: 64, Argon2Iterations: 1})
+	mockPassword, _ = testHasher.Hash("loremIpsum")
+)
+
 type mockUserRepository struct {
 }
 
@@ -21,7 +32,7 @@ func (m mockUserRepository) CreateUser(ctx context.Context, r models.CreateUserP
 	return nil
 }
 
-func (m mockUserRepository) CheckIfEmailExists(email string) (bool, error) {
+func (m mockUserRepository) CheckIfEmailExists(ctx context.Context, email string) (bool, error) {
 	switch email {
 	case "example@test.com":
 		return true, nil
@@ -32,32 +43,83 @@ func (m mockUserRepository) CheckIfEmailExists(email string) (bool, error) {
 	}
 }
 
-func (m mockUserRepository) GetUserData(p models.LoginPayload) (models.User, error) {
-	switch p.Email {
-	case "no-user-found@test.com":
-		return models.User{}, fmt.Errorf("GetUserData: no entries found")
+func (m mockUserRepository) GetUserByEmail(ctx context.Context, email string) (models.User, error) {
+	switch email {
 	case "test@example.com":
-		return models.User{
-			ID:        1,
-			Name:      "Lorem Ipsum",
-			Email:     "test@example.com",
-			Password:  "loremIpsum",
-			CreatedAt: nil,
-		}, nil
-	case "wrong-password@test.com":
-		h, _ := helpers.HashPassword("loremIpsum")
-
-		if !helpers.ValidatePassword(p.Password, h) {
-			return models.User{}, fmt.Errorf("incorrect credentials")
-		}
+		return models.User{ID: 1, Name: "Lorem Ipsum", Email: "test@example.com", Password: mockPassword}, nil
+	case "verified@test.com":
+		return models.User{ID: 2, Name: "Dolor Sit", Email: "verified@test.com", Password: mockPassword, EmailVerifiedAt: &verifiedAt}, nil
+	case "error@test.com":
+		return models.User{}, fmt.Errorf("error while executing the query")
 	default:
-		return models.User{}, fmt.Errorf("unexpected error")
+		return models.User{}, fmt.Errorf("getUser: %w", repository.ErrNotFound)
+	}
+}
+
+func (m mockUserRepository) GetUserByID(ctx context.Context, id int64) (models.User, error) {
+	if id == 1 {
+		return models.User{ID: 1, Name: "Lorem Ipsum", Email: "test@example.com", Password: mockPassword, EmailVerifiedAt: &verifiedAt}, nil
+	}
+	return models.User{}, fmt.Errorf("getUser: %w", repository.ErrNotFound)
+}
+
+func (m mockUserRepository) GetUsersByIDs(ctx context.Context, ids []int64) ([]models.User, error) {
+	var list []models.User
+	for _, id := range ids {
+		if u, err := m.GetUserByID(ctx, id); err == nil {
+			list = append(list, u)
+		}
+	}
+	return list, nil
+}
+
+func (m mockUserRepository) ListUsers(ctx context.Context) ([]models.User, error) {
+	return nil, nil
+}
+
+func (m mockUserRepository) MarkEmailVerified(ctx context.Context, id int64, at time.Time) error {
+	if id != 1 {
+		return fmt.Errorf("MarkEmailVerified: failed to execute query")
+	}
+	return nil
+}
+
+func (m mockUserRepository) UpdatePassword(ctx context.Context, id int64, hash string) error {
+	if id != 1 {
+		return fmt.Errorf("UpdatePassword: failed to execute query")
+	}
+	return nil
+}
+
+func (m mockUserRepository) UpdateRole(ctx context.Context, id int64, role string) error {
+	return nil
+}
+
+func (m mockUserRepository) RecordLoginSuccess(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+
+func (m mockUserRepository) RecordLoginFailure(ctx context.Context, id int64, at time.Time) error {
+	return nil
+}
+
+func (m mockUserRepository) UpdateProfile(ctx context.Context, id int64, name, email string) (models.User, error) {
+	u := models.User{ID: int(id), Name: name, Email: email}
+	if email == "test@example.com" {
+		u.EmailVerifiedAt = &verifiedAt
+	}
+	return u, nil
+}
+
+func (m mockUserRepository) DeleteUser(ctx context.Context, id int64, anonymizeTasks bool) error {
+	if id != 1 {
+		return fmt.Errorf("DeleteUser: %w", repository.ErrNotFound)
 	}
-	return models.User{}, nil
+	return nil
 }
 
 func TestUserService_RegisterUser(t *testing.T) {
-	s := NewUserService(mockUserRepository{})
+	s := NewUserService(mockUserRepository{}, config.AuthConfig{}, password.New(config.PasswordConfig{Algorithm: config.PasswordAlgorithmBcrypt, BcryptCost: 4}))
 	var tests = []struct {
 		name         string
 		payload      models.CreateUserPayload
@@ -92,7 +154,7 @@ func TestUserService_RegisterUser(t *testing.T) {
 				Password: strings.Repeat("a", 73),
 			},
 			true,
-			"RegisterUser: failed to hash a password, bcrypt: password length exceeds 72 bytes",
+			"RegisterUser: failed to hash a password, password: longer than 72 bytes",
 		},
 		{
 			"error while checking email",
@@ -135,115 +197,144 @@ func TestUserService_RegisterUser(t *testing.T) {
 	}
 }
 
-func TestUserService_LoginUser(t *testing.T) {
-	s := NewUserService(mockUserRepository{})
+func TestUserService_CheckIfEmailExists(t *testing.T) {
+	s := NewUserService(mockUserRepository{}, config.AuthConfig{}, testHasher)
+
 	var tests = []struct {
-		name                    string
-		payload                 models.LoginPayload
-		expectedPayloadReturned models.User
-		expectError             bool
-		errorWanted             string
+		name           string
+		testedEmail    string
+		expectedResult bool
 	}{
 		{
-			"correct login",
-			models.LoginPayload{
-				Email:    "test@example.com",
-				Password: "loremIpsum",
-			},
-			models.User{
-				ID:        1,
-				Name:      "Lorem Ipsum",
-				Email:     "test@example.com",
-				Password:  "loremIpsum",
-				CreatedAt: nil,
-			},
-			false,
-			"",
-		},
-		{
-			"no user found",
-			models.LoginPayload{
-				Email:    "no-user-found@test.com",
-				Password: "DolorEt",
-			},
-			models.User{
-				ID:        0,
-				Name:      "",
-				Email:     "",
-				Password:  "",
-				CreatedAt: nil,
-			},
+			"email exists, returns true",
+			"example@test.com",
 			true,
-			"LoginUser: failed to get user data: GetUserData: no entries found",
 		},
 		{
-			"incorrect password",
-			models.LoginPayload{
-				Email:    "wrong-password@test.com",
-				Password: "DolorEt",
-			},
-			models.User{
-				ID:        0,
-				Name:      "",
-				Email:     "",
-				Password:  "",
-				CreatedAt: nil,
-			},
-			true,
-			"LoginUser: failed to get user data: incorrect credentials",
+			"email does not exist, returns false",
+			"test@example.com",
+			false,
 		},
 	}
 
 	for _, i := range tests {
 		t.Run(i.name, func(t *testing.T) {
-			u, err := s.LoginUser(i.payload)
-			if i.expectError && err == nil {
-				t.Errorf("function is expected to return an error but it did not")
-			}
-			if !i.expectError && err != nil {
-				t.Errorf("unexpected error: %s", err)
-			}
+			exists, _ := s.CheckIfEmailExists(context.Background(), i.testedEmail)
 
-			if !i.expectError {
-				if diff := cmp.Diff(i.expectedPayloadReturned, u); diff != "" {
-					t.Errorf("invalid data returned, (-want, +got)\n %s", diff)
-				}
-			} else {
-				if !strings.Contains(err.Error(), i.errorWanted) {
-					t.Errorf("expected error <%s> but got <%s>", i.errorWanted, err)
-				}
+			if i.expectedResult != exists {
+				t.Errorf("CheckIfEmailExists(%q) = %t, wanted %t ", i.testedEmail, i.expectedResult, exists)
 			}
 		})
 	}
 }
 
-func TestUserService_CheckIfEmailExists(t *testing.T) {
-	s := NewUserService(mockUserRepository{})
+func TestUserService_UpdateProfile(t *testing.T) {
+	s := NewUserService(mockUserRepository{}, config.AuthConfig{}, testHasher)
 
 	var tests = []struct {
-		name           string
-		testedEmail    string
-		expectedResult bool
+		name                 string
+		uID                  int64
+		payload              models.UpdateProfilePayload
+		expectedUser         models.User
+		expectedEmailChanged bool
+		expectedErr          error
+		expectsError         bool
 	}{
 		{
-			"email exists, returns true",
-			"example@test.com",
+			"name change keeps the e-mail verified",
+			1,
+			models.UpdateProfilePayload{Name: "Dolor Sit"},
+			models.User{ID: 1, Name: "Dolor Sit", Email: "test@example.com", EmailVerifiedAt: &verifiedAt},
+			false,
+			nil,
+			false,
+		},
+		{
+			"new e-mail has to be verified",
+			1,
+			models.UpdateProfilePayload{Email: "new@example.com"},
+			models.User{ID: 1, Name: "Lorem Ipsum", Email: "new@example.com"},
 			true,
+			nil,
+			false,
 		},
 		{
-			"email does not exist, returns false",
-			"test@example.com",
+			"e-mail taken by another account",
+			1,
+			models.UpdateProfilePayload{Email: "example@test.com"},
+			models.User{},
 			false,
+			ErrEmailInUse,
+			true,
+		},
+		{
+			"unknown user",
+			2,
+			models.UpdateProfilePayload{Name: "Dolor Sit"},
+			models.User{},
+			false,
+			repository.ErrNotFound,
+			true,
 		},
 	}
 
-	for _, i := range tests {
-		t.Run(i.name, func(t *testing.T) {
-			exists, _ := s.CheckIfEmailExists(i.testedEmail)
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			u, changed, err := s.UpdateProfile(context.Background(), tc.uID, tc.payload)
+			if tc.expectsError {
+				if !errors.Is(err, tc.expectedErr) {
+					t.Errorf("expected error <%v> but got <%v>", tc.expectedErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if changed != tc.expectedEmailChanged {
+				t.Errorf("expected emailChanged %v but got %v", tc.expectedEmailChanged, changed)
+			}
+			if diff := cmp.Diff(tc.expectedUser, u); diff != "" {
+				t.Errorf("invalid data returned, (-want, +got)\n %s", diff)
+			}
+		})
+	}
+}
 
-			if i.expectedResult != exists {
-				t.Errorf("CheckIfEmailExists(%q) = %t, wanted %t ", i.testedEmail, i.expectedResult, exists)
+func TestUserService_ChangePassword(t *testing.T) {
+	s := NewUserService(mockUserRepository{}, config.AuthConfig{}, testHasher)
+
+	var tests = []struct {
+		name         string
+		uID          int64
+		current      string
+		expectedErr  error
+		expectsError bool
+	}{
+		{"correct current password", 1, "loremIpsum", nil, false},
+		{"wrong current password", 1, "dolorSit", ErrIncorrectPassword, true},
+		{"unknown user", 2, "loremIpsum", repository.ErrNotFound, true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := s.ChangePassword(context.Background(), tc.uID, tc.current, "newPassword")
+			if tc.expectsError && !errors.Is(err, tc.expectedErr) {
+				t.Errorf("expected error <%v> but got <%v>", tc.expectedErr, err)
+			}
+			if !tc.expectsError && err != nil {
+				t.Errorf("unexpected error: %s", err)
 			}
 		})
 	}
 }
+
+func TestUserService_DeleteAccount(t *testing.T) {
+	s := NewUserService(mockUserRepository{}, config.AuthConfig{DeletedUserTasks: config.DeletedUserTasksAnonymize}, testHasher)
+
+	if err := s.DeleteAccount(context.Background(), 1); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := s.DeleteAccount(context.Background(), 2); !errors.Is(err, repository.ErrNotFound) {
+		t.Errorf("expected ErrNotFound but got %v", err)
+	}
+}
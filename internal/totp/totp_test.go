@@ -0,0 +1,97 @@
+package totp
+
+import (
+	"encoding/base32"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// rfcSecret is the SHA1 key of the RFC 6238 test vectors.
+var rfcSecret = base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString([]byte("12345678901234567890"))
+
+func TestCode(t *testing.T) {
+	// expected values are the last 6 digits of the 8 digit codes in RFC 6238 appendix B
+	var tests = []struct {
+		name     string
+		unix     int64
+		expected string
+	}{
+		{"59", 59, "287082"},
+		{"1111111109", 1111111109, "081804"},
+		{"1111111111", 1111111111, "050471"},
+		{"1234567890", 1234567890, "005924"},
+		{"2000000000", 2000000000, "279037"},
+		{"20000000000", 20000000000, "353130"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, err := Code(rfcSecret, Step(time.Unix(tc.unix, 0)))
+			if err != nil {
+				t.Fatalf("unexpected error: %s", err)
+			}
+			if got != tc.expected {
+				t.Errorf("expected code %s but got %s", tc.expected, got)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+
+	var tests = []struct {
+		name         string
+		code         string
+		expectedStep int64
+		expectsValid bool
+	}{
+		{"current step", "050471", Step(now), true},
+		{"previous step within skew", "081804", Step(now) - 1, true},
+		{"wrong code", "123456", 0, false},
+		{"wrong length", "50471", 0, false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			step, ok := Validate(rfcSecret, tc.code, now)
+			if ok != tc.expectsValid {
+				t.Fatalf("expected valid %v but got %v", tc.expectsValid, ok)
+			}
+			if ok && step != tc.expectedStep {
+				t.Errorf("expected step %d but got %d", tc.expectedStep, step)
+			}
+		})
+	}
+}
+
+func TestGenerateSecret(t *testing.T) {
+	s, err := GenerateSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Code(s, 1); err != nil {
+		t.Errorf("generated secret should be usable: %s", err)
+	}
+	if other, _ := GenerateSecret(); other == s {
+		t.Errorf("secrets should be random")
+	}
+}
+
+func TestURI(t *testing.T) {
+	uri := URI("task-manager", "lorem@ipsum.com", "JBSWY3DPEHPK3PXP")
+
+	if !strings.HasPrefix(uri, "otpauth://totp/task-manager:lorem@ipsum.com?") {
+		t.Errorf("unexpected label in %s", uri)
+	}
+	u, err := url.Parse(uri)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q := u.Query()
+	if q.Get("secret") != "JBSWY3DPEHPK3PXP" || q.Get("issuer") != "task-manager" || q.Get("digits") != "6" || q.Get("period") != "30" {
+		t.Errorf("unexpected parameters in %s", uri)
+	}
+}
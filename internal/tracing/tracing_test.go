@@ -0,0 +1,105 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	sr := tracetest.NewSpanRecorder()
+	tp := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr))
+	prev := otel.GetTracerProvider()
+	otel.SetTracerProvider(tp)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prev)
+	})
+	return sr
+}
+
+func TestMiddleware(t *testing.T) {
+	var tests = []struct {
+		name          string
+		path          string
+		traceparent   string
+		expectedName  string
+		expectedTrace string
+		expectsError  bool
+	}{
+		{
+			"route pattern used as span name",
+			"/tasks/12",
+			"",
+			"GET /tasks/{task_id}",
+			"",
+			false,
+		},
+		{
+			"incoming traceparent is continued",
+			"/tasks/12",
+			"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"GET /tasks/{task_id}",
+			"4bf92f3577b34da6a3ce929d0e0e4736",
+			false,
+		},
+		{
+			"server error marks the span",
+			"/fail",
+			"",
+			"GET /fail",
+			"",
+			true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			sr := setupRecorder(t)
+
+			r := chi.NewRouter()
+			r.Use(Middleware)
+			r.Get("/tasks/{task_id}", func(w http.ResponseWriter, r *http.Request) {
+				_, span := Start(r.Context(), "TaskService.ShowTask")
+				span.End()
+			})
+			r.Get("/fail", func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusInternalServerError)
+			})
+
+			req := httptest.NewRequest(http.MethodGet, tc.path, nil)
+			if tc.traceparent != "" {
+				req.Header.Set("traceparent", tc.traceparent)
+			}
+			r.ServeHTTP(httptest.NewRecorder(), req)
+
+			spans := sr.Ended()
+			if len(spans) == 0 {
+				t.Fatalf("no spans recorded")
+			}
+			server := spans[len(spans)-1]
+			if server.Name() != tc.expectedName {
+				t.Errorf("expected span name %s but got %s", tc.expectedName, server.Name())
+			}
+			if tc.expectedTrace != "" && server.SpanContext().TraceID().String() != tc.expectedTrace {
+				t.Errorf("expected trace id %s but got %s", tc.expectedTrace, server.SpanContext().TraceID())
+			}
+			for _, s := range spans[:len(spans)-1] {
+				if s.Parent().SpanID() != server.SpanContext().SpanID() {
+					t.Errorf("span %s should be a child of the server span", s.Name())
+				}
+			}
+			if tc.expectsError && server.Status().Code != codes.Error {
+				t.Errorf("server span should have error status")
+			}
+		})
+	}
+}